@@ -0,0 +1,802 @@
+//    reddlinks, a simple link shortener written in Go.
+//    Copyright (C) 2025 redd
+//
+//    This program is free software: you can redistribute it and/or modify
+//    it under the terms of the GNU General Public License as published by
+//    the Free Software Foundation, either version 3 of the License, or
+//    (at your option) any later version.
+//
+//    This program is distributed in the hope that it will be useful,
+//    but WITHOUT ANY WARRANTY; without even the implied warranty of
+//    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//    GNU General Public License for more details.
+//
+//    You should have received a copy of the GNU General Public License
+//    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redds-be/reddlinks/internal/accounts"
+	"github.com/redds-be/reddlinks/internal/apikeys"
+	"github.com/redds-be/reddlinks/internal/archive"
+	"github.com/redds-be/reddlinks/internal/database"
+	"github.com/redds-be/reddlinks/internal/env"
+	"github.com/redds-be/reddlinks/internal/links"
+	"github.com/redds-be/reddlinks/internal/logging"
+	"github.com/redds-be/reddlinks/internal/utils"
+)
+
+// errUnknownCommand is returned when the subcommand given on the command line doesn't exist.
+var errUnknownCommand = errors.New("unknown command")
+
+// errMissingArchive is returned when no archive is given to the import subcommand.
+var errMissingArchive = errors.New("an archive to import is required")
+
+// errMissingShort is returned when no short is given to a subcommand working on existing links.
+var errMissingShort = errors.New("at least one short is required")
+
+// errMissingKey is returned when no API key ID is given to the revoke-key subcommand.
+var errMissingKey = errors.New("at least one API key ID is required")
+
+// errCreate is returned when the create subcommand couldn't create the link.
+var errCreate = errors.New("could not create the link")
+
+// errInvalidConfig is returned by the check-config subcommand when the configuration is invalid.
+var errInvalidConfig = errors.New("the configuration is invalid")
+
+// command defines a subcommand of the command line.
+//
+// Usage is the synopsis of the subcommand, Description is a one line summary of what it does,
+// Run is called with the arguments following the name of the subcommand.
+type command struct {
+	Usage       string
+	Description string
+	Run         func(args []string) error
+}
+
+// commands returns every subcommand of the command line, by name.
+func commands() map[string]command {
+	return map[string]command{
+		"serve": {
+			Usage:       "serve",
+			Description: "start the server, the default command",
+			Run:         runServe,
+		},
+		"create": {
+			Usage:       "create -url URL [-short short] [-length n] [-expire-after duration] [-expire-date date] [-password password]",
+			Description: "create a link and print its management token",
+			Run:         runCreate,
+		},
+		"delete": {
+			Usage:       "delete short...",
+			Description: "delete links",
+			Run:         runDelete,
+		},
+		"info": {
+			Usage:       "info short",
+			Description: "print the information of a link, expired or not",
+			Run:         runInfo,
+		},
+		"list": {
+			Usage:       "list [-short filter] [-url filter] [-limit n] [-offset n]",
+			Description: "list links",
+			Run:         runList,
+		},
+		"gc": {
+			Usage:       "gc",
+			Description: "delete the expired links once",
+			Run:         runGC,
+		},
+		"check-config": {
+			Usage:       "check-config",
+			Description: "validate the configuration and report every problem",
+			Run:         runCheckConfig,
+		},
+		"migrate": {
+			Usage:       "migrate",
+			Description: "apply the pending database migrations",
+			Run:         runMigrate,
+		},
+		"export": {
+			Usage:       "export [-o archive.jsonl]",
+			Description: "write every link to an archive",
+			Run:         runExport,
+		},
+		"import": {
+			Usage:       "import [-on-conflict skip|overwrite|fail] archive.jsonl",
+			Description: "read the links of an archive",
+			Run:         runImport,
+		},
+		"create-key": {
+			Usage:       "create-key -label label -scopes create,manage,admin,stats [-expire-after days]",
+			Description: "create an API key and print it",
+			Run:         runCreateKey,
+		},
+		"list-keys": {
+			Usage:       "list-keys",
+			Description: "list the API keys",
+			Run:         runListKeys,
+		},
+		"revoke-key": {
+			Usage:       "revoke-key id...",
+			Description: "revoke API keys",
+			Run:         runRevokeKey,
+		},
+		"create-user": {
+			Usage:       "create-user -username name [-password password]",
+			Description: "create a user account",
+			Run:         runCreateUser,
+		},
+	}
+}
+
+// runCommand runs a subcommand given on the command line.
+//
+// Parameters:
+//   - name: The name of the subcommand, see [commands], "help" prints the usage
+//   - args: The arguments following the name of the subcommand
+//
+// Returns:
+//   - error: Any error encountered by the subcommand
+func runCommand(name string, args []string) error {
+	available := commands()
+
+	if name == "help" || name == "-h" || name == "-help" || name == "--help" {
+		printUsage(os.Stdout, available)
+
+		return nil
+	}
+
+	cmd, exists := available[name]
+	if !exists {
+		printUsage(os.Stderr, available)
+
+		return fmt.Errorf("%w: %q", errUnknownCommand, name)
+	}
+
+	return cmd.Run(args)
+}
+
+// printUsage writes the list of the subcommands along with their usage.
+func printUsage(writer io.Writer, available map[string]command) {
+	names := make([]string, 0, len(available))
+	for name := range available {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	fmt.Fprintln(writer, "Usage: reddlinks [command] [arguments]")
+	fmt.Fprintln(writer, "\nCommands:")
+
+	for _, name := range names {
+		fmt.Fprintf(writer, "  %s\n      %s\n", available[name].Usage, available[name].Description)
+	}
+
+	fmt.Fprintln(writer, "\nEvery command reads its configuration from the environment and the .env file.")
+}
+
+// openStore opens the store configured by the environment variables, see [database.OpenStore].
+func openStore(envVars env.Env) (database.LinkStore, error) {
+	return database.OpenStore(
+		envVars.DBType,
+		envVars.DBURL,
+		envVars.DBUser,
+		envVars.DBPass,
+		envVars.DBHost,
+		envVars.DBPort,
+		envVars.DBName,
+		envVars.DefaultMaxLength,
+	)
+}
+
+// loadEnv loads the configuration using [env.GetEnv] and configures the logger accordingly, see [logging.Setup].
+func loadEnv() env.Env {
+	envVars := env.GetEnv(".env")
+
+	// The configuration was validated, the log settings are supported
+	_ = logging.Setup(envVars.LogFormat, envVars.LogLevel)
+
+	return envVars
+}
+
+// newConfiguration gathers the env vars and the store into a configuration used by the subcommands.
+//
+// The locales are the embedded ones, the messages of the subcommands are in english.
+func newConfiguration(envVars env.Env, store database.LinkStore) (*utils.Configuration, error) {
+	locales, supportedLocales, err := utils.GetLocales("", embeddedStatic)
+	if err != nil {
+		return nil, err
+	}
+
+	return &utils.Configuration{
+		Store:                  store,
+		AddrAndPort:            envVars.AddrAndPort,
+		InstanceName:           envVars.InstanceName,
+		InstanceURL:            envVars.InstanceURL,
+		DefaultShortLength:     envVars.DefaultLength,
+		DefaultMaxShortLength:  envVars.DefaultMaxLength,
+		DefaultMaxCustomLength: envVars.DefaultMaxCustomLength,
+		DefaultExpiryTime:      envVars.DefaultExpiryTime,
+		ContactEmail:           envVars.ContactEmail,
+		Static:                 embeddedStatic,
+		Version:                version,
+		SupportedLocales:       supportedLocales,
+		Locales:                locales,
+	}, nil
+}
+
+// runCreate creates a link, see [links.CreateLink].
+//
+// Usage: reddlinks create -url URL [-short short] [-length n] [-expire-after duration] [-expire-date date] [-password password],
+// the shortened link is printed along with its expiration date and its management token.
+// The duration is written like the expireAfter parameter of the API, in days, hours, minutes and seconds (ex: 1d12h30m).
+func runCreate(args []string) error {
+	var params utils.Parameters
+
+	flags := flag.NewFlagSet("create", flag.ContinueOnError)
+	flags.StringVar(&params.URL, "url", "", "the URL to shorten")
+	flags.StringVar(&params.Path, "short", "", "a custom short, a random one is generated by default")
+	flags.IntVar(&params.Length, "length", 0, "the length of the random short, the default length by default")
+	flags.StringVar(
+		&params.ExpireAfter,
+		"expire-after",
+		"",
+		"the lifetime of the link, from the greater unit to the lesser one (ex: 2d, 90m, 1d12h30m), the default expiry time by default",
+	)
+	flags.StringVar(&params.ExpireDate, "expire-date", "", "the date at which the link expires (YYYY-MM-DDTHH:MM)")
+	flags.StringVar(&params.Password, "password", "", "a password protecting the link")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	envVars := loadEnv()
+
+	// Open the store
+	store, err := openStore(envVars)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	conf, err := newConfiguration(envVars, store)
+	if err != nil {
+		return err
+	}
+
+	// Create an adapter for links
+	linksAdapter := links.NewAdapter(*conf)
+
+	// Create the link entry
+	link, addInfo, err := linksAdapter.CreateLink(params, conf.Locales["en"])
+	if err != nil {
+		return fmt.Errorf("%w: %s", errCreate, links.AsError(err).Code.Message(conf.Locales["en"]))
+	}
+
+	if addInfo != "" {
+		slog.Info(addInfo)
+	}
+
+	// Format the expiration date that will be displayed to the user
+	expireAt := link.ExpireAt.Format(time.RFC822)
+	if params.ExpireDate == "" && params.ExpireAfter == "" && conf.DefaultExpiryTime == 0 {
+		expireAt = "Never"
+	}
+
+	fmt.Printf("Link:      %s%s\n", conf.InstanceURL, link.Short)
+	fmt.Printf("URL:       %s\n", link.URL)
+	fmt.Printf("Expire at: %s\n", expireAt)
+	fmt.Printf("Token:     %s\n", link.Token)
+
+	return nil
+}
+
+// runDelete deletes links before their expiration, see [database.LinkStore.DeleteLinks].
+//
+// Usage: reddlinks delete short..., the unknown shorts are ignored.
+func runDelete(args []string) error {
+	flags := flag.NewFlagSet("delete", flag.ContinueOnError)
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() == 0 {
+		return errMissingShort
+	}
+
+	// Open the store
+	store, err := openStore(loadEnv())
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	deleted, err := store.DeleteLinks(flags.Args())
+	if err != nil {
+		return err
+	}
+
+	slog.Info("Links deleted", slog.Int64("count", deleted))
+
+	return nil
+}
+
+// runInfo prints the information of a link, its accesses included if any were recorded.
+//
+// Usage: reddlinks info short, expired links that weren't collected yet are shown as well.
+func runInfo(args []string) error {
+	flags := flag.NewFlagSet("info", flag.ContinueOnError)
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 {
+		return errMissingShort
+	}
+
+	short := flags.Arg(0)
+
+	// Open the store
+	store, err := openStore(loadEnv())
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	url, createdAt, expireAt, err := store.GetURLInfo(short)
+	if err != nil {
+		return err
+	}
+
+	hash, err := store.GetHashByShort(short)
+	if err != nil {
+		return err
+	}
+
+	hits, err := store.GetHitStats(short)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Short:      %s\n", short)
+	fmt.Printf("URL:        %s\n", url)
+	fmt.Printf("Created at: %s\n", createdAt.Format(time.RFC822))
+	fmt.Printf("Expire at:  %s\n", expireAt.Format(time.RFC822))
+	fmt.Printf("Protected:  %t\n", hash != "")
+	fmt.Printf("Hits:       %d\n", hits.Total)
+
+	return nil
+}
+
+// runList prints the links matching the given filters, see [database.LinkStore.ListLinks].
+//
+// Usage: reddlinks list [-short filter] [-url filter] [-limit n] [-offset n].
+func runList(args []string) error {
+	// Set the default number of listed links and the padding of the columns as const to not have magic numbers
+	const defaultLimit = 50
+	const padding = 2
+
+	var filter database.LinkFilter
+
+	flags := flag.NewFlagSet("list", flag.ContinueOnError)
+	flags.StringVar(&filter.Short, "short", "", "only list the links whose short contains this text")
+	flags.StringVar(&filter.URL, "url", "", "only list the links whose URL contains this text")
+	flags.IntVar(&filter.Limit, "limit", defaultLimit, "the maximum number of listed links")
+	flags.IntVar(&filter.Offset, "offset", 0, "the number of links to skip")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	// Open the store
+	store, err := openStore(loadEnv())
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	found, total, err := store.ListLinks(filter)
+	if err != nil {
+		return err
+	}
+
+	// Align the columns
+	table := tabwriter.NewWriter(os.Stdout, 0, 0, padding, ' ', 0)
+
+	fmt.Fprintln(table, "SHORT\tURL\tCREATED AT\tEXPIRE AT\tPROTECTED")
+
+	for _, link := range found {
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%t\n",
+			link.Short,
+			link.URL,
+			link.CreatedAt.Format(time.RFC822),
+			link.ExpireAt.Format(time.RFC822),
+			link.Password != "",
+		)
+	}
+
+	if err := table.Flush(); err != nil {
+		return err
+	}
+
+	slog.Info("Links listed", slog.Int("count", len(found)), slog.Int("total", total))
+
+	return nil
+}
+
+// runGC deletes the expired links once, see [utils.Configuration.CollectGarbage].
+//
+// Usage: reddlinks gc.
+func runGC(args []string) error {
+	flags := flag.NewFlagSet("gc", flag.ContinueOnError)
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	// Open the store
+	store, err := openStore(loadEnv())
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	if err := (utils.Configuration{Store: store}).CollectGarbage(); err != nil {
+		return err
+	}
+
+	slog.Info("Expired links collected")
+
+	return nil
+}
+
+// runCheckConfig validates the configuration and prints every problem found, see [env.LoadEnv].
+//
+// Usage: reddlinks check-config, it fails if the configuration is invalid.
+func runCheckConfig(args []string) error {
+	flags := flag.NewFlagSet("check-config", flag.ContinueOnError)
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if _, err := env.LoadEnv(".env"); err != nil {
+		// Print each problem on its own line
+		problems := strings.Split(err.Error(), "\n")
+		for _, problem := range problems {
+			fmt.Fprintf(os.Stderr, "- %s\n", problem)
+		}
+
+		return fmt.Errorf("%w: %d problem(s) found", errInvalidConfig, len(problems))
+	}
+
+	fmt.Println("Configuration is valid.")
+
+	return nil
+}
+
+// runMigrate applies the pending database migrations, it is done by [database.OpenStore] when opening the store.
+//
+// Usage: reddlinks migrate.
+func runMigrate(args []string) error {
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	// Open the store, applying the migrations
+	store, err := openStore(loadEnv())
+	if err != nil {
+		return err
+	}
+
+	if err := store.Close(); err != nil {
+		return err
+	}
+
+	slog.Info("The database schema is up to date")
+
+	return nil
+}
+
+// runExport writes every link of the configured store to an archive, see [archive.Export].
+//
+// Usage: reddlinks export [-o archive.jsonl], the archive is written to the standard output by default.
+func runExport(args []string) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	output := flags.String("o", "", "file to write the archive to, the standard output by default")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	// Open the store
+	store, err := openStore(loadEnv())
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	// Write to the standard output unless a file is given
+	var writer io.Writer = os.Stdout
+
+	if *output != "" {
+		file, err := os.OpenFile(*output, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		writer = file
+	}
+
+	exported, err := archive.Export(store, writer)
+	if err != nil {
+		return err
+	}
+
+	slog.Info("Links exported", slog.Int("count", exported))
+
+	return nil
+}
+
+// runImport reads the links of an archive into the configured store, see [archive.Import].
+//
+// Usage: reddlinks import [-on-conflict skip|overwrite|fail] archive.jsonl, nothing is imported on conflict by default.
+func runImport(args []string) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	onConflict := flags.String("on-conflict", string(archive.ConflictFail),
+		"what to do with the links whose short is already used: skip, overwrite or fail")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 {
+		return errMissingArchive
+	}
+
+	mode, err := archive.ParseConflictMode(*onConflict)
+	if err != nil {
+		return err
+	}
+
+	// Open the archive
+	file, err := os.Open(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	// Open the store
+	store, err := openStore(loadEnv())
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	stats, err := archive.Import(store, file, mode)
+	if err != nil {
+		return err
+	}
+
+	slog.Info("Links imported",
+		slog.Int("imported", stats.Imported),
+		slog.Int("overwritten", stats.Overwritten),
+		slog.Int("skipped", stats.Skipped),
+	)
+
+	return nil
+}
+
+// runCreateKey creates an API key, see [apikeys.Generate].
+//
+// Usage: reddlinks create-key -label label -scopes create,manage,admin,stats [-expire-after days],
+// the key is printed once, only its hash is stored.
+func runCreateKey(args []string) error {
+	flags := flag.NewFlagSet("create-key", flag.ContinueOnError)
+	label := flags.String("label", "", "describes who or what the key is given to")
+	scopeList := flags.String("scopes", "", "the comma-separated scopes of the key: create, manage, admin or stats")
+	expireAfter := flags.Int("expire-after", 0, "the number of days after which the key expires, it never does by default")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	scopes, err := apikeys.ParseScopes(*scopeList)
+	if err != nil {
+		return err
+	}
+
+	var expireAt time.Time
+	if *expireAfter > 0 {
+		expireAt = time.Now().AddDate(0, 0, *expireAfter)
+	}
+
+	plain, key, err := apikeys.Generate(*label, scopes, expireAt)
+	if err != nil {
+		return err
+	}
+
+	// Open the store
+	store, err := openStore(loadEnv())
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	if err := store.CreateAPIKey(key); err != nil {
+		return err
+	}
+
+	// Format the expiration date that will be displayed to the user
+	expiry := "Never"
+	if !key.ExpireAt.IsZero() {
+		expiry = key.ExpireAt.Format(time.RFC822)
+	}
+
+	fmt.Printf("ID:        %s\n", key.ID)
+	fmt.Printf("Scopes:    %s\n", key.Scopes)
+	fmt.Printf("Expire at: %s\n", expiry)
+	fmt.Printf("Key:       %s\n", plain)
+
+	return nil
+}
+
+// runListKeys prints every API key, see [database.APIKeyStore.ListAPIKeys].
+//
+// Usage: reddlinks list-keys, only the beginning of the keys is known.
+func runListKeys(args []string) error {
+	// Set the padding of the columns as const to not have magic numbers
+	const padding = 2
+
+	flags := flag.NewFlagSet("list-keys", flag.ContinueOnError)
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	// Open the store
+	store, err := openStore(loadEnv())
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	keys, err := store.ListAPIKeys()
+	if err != nil {
+		return err
+	}
+
+	// Align the columns
+	table := tabwriter.NewWriter(os.Stdout, 0, 0, padding, ' ', 0)
+
+	fmt.Fprintln(table, "ID\tLABEL\tKEY\tSCOPES\tCREATED AT\tEXPIRE AT")
+
+	for _, key := range keys {
+		expiry := "Never"
+		if !key.ExpireAt.IsZero() {
+			expiry = key.ExpireAt.Format(time.RFC822)
+		}
+
+		fmt.Fprintf(table, "%s\t%s\t%s...\t%s\t%s\t%s\n",
+			key.ID,
+			key.Label,
+			key.Prefix,
+			key.Scopes,
+			key.CreatedAt.Format(time.RFC822),
+			expiry,
+		)
+	}
+
+	if err := table.Flush(); err != nil {
+		return err
+	}
+
+	slog.Info("API keys listed", slog.Int("count", len(keys)))
+
+	return nil
+}
+
+// runRevokeKey revokes API keys, see [database.APIKeyStore.DeleteAPIKey].
+//
+// Usage: reddlinks revoke-key id..., every ID must be known.
+func runRevokeKey(args []string) error {
+	flags := flag.NewFlagSet("revoke-key", flag.ContinueOnError)
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() == 0 {
+		return errMissingKey
+	}
+
+	// Check every ID before revoking anything
+	ids := make([]uuid.UUID, 0, flags.NArg())
+
+	for _, arg := range flags.Args() {
+		id, err := uuid.Parse(arg)
+		if err != nil {
+			return fmt.Errorf("invalid API key ID %q: %w", arg, err)
+		}
+
+		ids = append(ids, id)
+	}
+
+	// Open the store
+	store, err := openStore(loadEnv())
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	for _, id := range ids {
+		if err := store.DeleteAPIKey(id); err != nil {
+			return err
+		}
+	}
+
+	slog.Info("API keys revoked", slog.Int("count", len(ids)))
+
+	return nil
+}
+
+// runCreateUser creates a user account, see [accounts.Register].
+//
+// Usage: reddlinks create-user -username name [-password password], the password is read from the first line
+// of the standard input if it isn't given, so that it doesn't end up in the shell history.
+func runCreateUser(args []string) error {
+	flags := flag.NewFlagSet("create-user", flag.ContinueOnError)
+	username := flags.String("username", "", "the name the user logs in with")
+	password := flags.String("password", "", "the password of the user, read from the standard input by default")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	// Read the password from the standard input
+	if *password == "" {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+
+		*password = strings.TrimRight(line, "\r\n")
+	}
+
+	// Open the store
+	store, err := openStore(loadEnv())
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	user, err := accounts.Register(store, *username, *password)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("ID:       %s\n", user.ID)
+	fmt.Printf("Username: %s\n", user.Username)
+
+	return nil
+}
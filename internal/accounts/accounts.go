@@ -0,0 +1,337 @@
+//    reddlinks, a simple link shortener written in Go.
+//    Copyright (C) 2025 redd
+//
+//    This program is free software: you can redistribute it and/or modify
+//    it under the terms of the GNU General Public License as published by
+//    the Free Software Foundation, either version 3 of the License, or
+//    (at your option) any later version.
+//
+//    This program is distributed in the hope that it will be useful,
+//    but WITHOUT ANY WARRANTY; without even the implied warranty of
+//    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//    GNU General Public License for more details.
+//
+//    You should have received a copy of the GNU General Public License
+//    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+// Package accounts handles the user accounts, which own the links created while they are logged in.
+//
+// The passwords are hashed using argon2id, like the passwords of the links. Logging in opens a session
+// identified by a random token kept in a cookie, only the SHA-256 hash of the token is stored, see [database.UserStore],
+// so the sessions survive restarts and are shared by the instances using the same database.
+package accounts
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/alexedwards/argon2id"
+	"github.com/google/uuid"
+	"github.com/redds-be/reddlinks/internal/database"
+)
+
+// SessionCookie is the name of the cookie holding the session of a user.
+const SessionCookie = "reddlinks_session"
+
+// Password length bounds, the upper one limits the time spent hashing.
+const (
+	MinPasswordLength = 8
+	MaxPasswordLength = 256
+)
+
+// Settings of the sessions.
+const (
+	sessionLifetime = 30 * 24 * time.Hour
+	tokenLength     = 32
+)
+
+// usernamePattern defines the allowed usernames, they are lowercased beforehand.
+var usernamePattern = regexp.MustCompile(`^[a-z0-9_.-]{3,32}$`)
+
+// Define all the errors for the accounts package.
+//
+// ErrInvalidUsername defines an error for a username that isn't made of 3 to 32 letters, digits, dots, dashes or underscores,
+// ErrInvalidPassword defines an error for a password shorter than [MinPasswordLength] or longer than [MaxPasswordLength],
+// ErrWrongCredentials defines an error for a username that doesn't exist or a password that doesn't match,
+// ErrNoSession defines an error for a request without a valid session.
+var (
+	ErrInvalidUsername  = errors.New("the username must be made of 3 to 32 letters, digits, dots, dashes or underscores")
+	ErrInvalidPassword  = errors.New("the password must be between 8 and 256 characters long")
+	ErrWrongCredentials = errors.New("wrong username or password")
+	ErrNoSession        = errors.New("no valid session")
+)
+
+// NewUser validates a username and a password and returns the user to store.
+//
+// The username is lowercased so that it can't be registered twice using different cases.
+//
+// Parameters:
+//   - username: The name the user logs in with
+//   - password: The plain password of the user
+//
+// Returns:
+//   - database.User: The user to store, holding the argon2id hash of the password
+//   - error: [ErrInvalidUsername] or [ErrInvalidPassword] if they aren't valid, or any error encountered while hashing
+func NewUser(username, password string) (database.User, error) {
+	username = strings.ToLower(strings.TrimSpace(username))
+	if !usernamePattern.MatchString(username) {
+		return database.User{}, ErrInvalidUsername
+	}
+
+	if len(password) < MinPasswordLength || len(password) > MaxPasswordLength {
+		return database.User{}, ErrInvalidPassword
+	}
+
+	hash, err := argon2id.CreateHash(password, argon2id.DefaultParams)
+	if err != nil {
+		return database.User{}, fmt.Errorf("could not hash the password: %w", err)
+	}
+
+	return database.User{
+		ID:        uuid.New(),
+		Username:  username,
+		Password:  hash,
+		CreatedAt: time.Now().UTC(),
+	}, nil
+}
+
+// Register creates and stores a new user, see [NewUser].
+//
+// Parameters:
+//   - store: Where the users are stored
+//   - username: The name the user logs in with
+//   - password: The plain password of the user
+//
+// Returns:
+//   - database.User: The stored user
+//   - error: [database.ErrUsernameInUse] if the username is already used, the errors of [NewUser],
+//     or any error encountered while storing the user
+func Register(store database.UserStore, username, password string) (database.User, error) {
+	user, err := NewUser(username, password)
+	if err != nil {
+		return database.User{}, err
+	}
+
+	// Check the username beforehand, the SQL databases report their own error through their unique index
+	if _, err := store.GetUserByName(user.Username); err == nil {
+		return database.User{}, database.ErrUsernameInUse
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return database.User{}, err
+	}
+
+	if err := store.CreateUser(user); err != nil {
+		return database.User{}, err
+	}
+
+	return user, nil
+}
+
+// Login checks the credentials of a user.
+//
+// Parameters:
+//   - store: Where the users are stored
+//   - username: The name the user logs in with, regardless of its case
+//   - password: The plain password of the user
+//
+// Returns:
+//   - database.User: The user
+//   - error: [ErrWrongCredentials] if the user doesn't exist or if the password doesn't match,
+//     or any error encountered during the lookup or the comparison
+func Login(store database.UserStore, username, password string) (database.User, error) {
+	user, err := store.GetUserByName(strings.ToLower(strings.TrimSpace(username)))
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.User{}, ErrWrongCredentials
+	} else if err != nil {
+		return database.User{}, err
+	}
+
+	// The users created by the single sign-on don't have a password
+	if user.Password == "" {
+		return database.User{}, ErrWrongCredentials
+	}
+
+	match, err := argon2id.ComparePasswordAndHash(password, user.Password)
+	if err != nil {
+		return database.User{}, fmt.Errorf("could not compare the password: %w", err)
+	}
+
+	if !match {
+		return database.User{}, ErrWrongCredentials
+	}
+
+	return user, nil
+}
+
+// SignIn returns the user logged in by an external identity provider, creating it on its first login.
+//
+// The users are found by their subject. New users are named after their preferred username if it is valid
+// and free, or after the hash of their subject otherwise. Their roles are updated on every login.
+//
+// Parameters:
+//   - store: Where the users are stored
+//   - subject: The identifier of the user at the provider
+//   - username: The preferred username of the user, it may be empty
+//   - roles: The roles given to the user by the provider
+//
+// Returns:
+//   - database.User: The user
+//   - error: Any error encountered during the lookups or while storing the user
+func SignIn(store database.UserStore, subject, username string, roles []string) (database.User, error) {
+	joined := strings.Join(roles, ",")
+
+	user, err := store.GetUserBySubject(subject)
+	if err == nil {
+		// Only update the roles if they changed, MySQL doesn't count the rows left as is
+		if user.Roles != joined {
+			if err := store.UpdateUserRoles(user.ID, joined); err != nil {
+				return database.User{}, err
+			}
+
+			user.Roles = joined
+		}
+
+		return user, nil
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return database.User{}, err
+	}
+
+	// Fall back to a name derived from the subject if the preferred one can't be used
+	username = strings.ToLower(strings.TrimSpace(username))
+	if !usernamePattern.MatchString(username) {
+		username = ""
+	} else if _, err := store.GetUserByName(username); err == nil {
+		username = ""
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return database.User{}, err
+	}
+
+	if username == "" {
+		username = "sso-" + Hash(subject)[:12]
+	}
+
+	user = database.User{
+		ID:        uuid.New(),
+		Username:  username,
+		Subject:   subject,
+		Roles:     joined,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	if err := store.CreateUser(user); err != nil {
+		return database.User{}, err
+	}
+
+	return user, nil
+}
+
+// HasRole tells if a user was given a role by the identity provider, see [SignIn].
+func HasRole(user database.User, role string) bool {
+	return role != "" && slices.Contains(strings.Split(user.Roles, ","), role)
+}
+
+// NewSession opens a session for a user.
+//
+// Parameters:
+//   - userID: The ID of the logged in user
+//
+// Returns:
+//   - string: The token to give to the client in the [SessionCookie] cookie
+//   - database.Session: The session to store, which expires after 30 days
+//   - error: Any error encountered while generating the token
+func NewSession(userID uuid.UUID) (string, database.Session, error) {
+	random := make([]byte, tokenLength)
+	if _, err := rand.Read(random); err != nil {
+		return "", database.Session{}, fmt.Errorf("could not generate the session token: %w", err)
+	}
+
+	token := base64.RawURLEncoding.EncodeToString(random)
+
+	return token, database.Session{
+		Hash:     Hash(token),
+		UserID:   userID,
+		ExpireAt: time.Now().UTC().Add(sessionLifetime),
+	}, nil
+}
+
+// Hash returns the SHA-256 hash of a session token, encoded in hexadecimal.
+func Hash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// Authenticate returns the user whose session is given by the [SessionCookie] cookie of a request.
+//
+// Parameters:
+//   - store: Where the users and their sessions are stored
+//   - req: The request giving the session
+//
+// Returns:
+//   - database.User: The logged in user
+//   - error: [ErrNoSession] if there is no cookie, or if its session doesn't exist or has expired,
+//     or any error encountered during the lookups
+func Authenticate(store database.UserStore, req *http.Request) (database.User, error) {
+	cookie, err := req.Cookie(SessionCookie)
+	if err != nil || cookie.Value == "" {
+		return database.User{}, ErrNoSession
+	}
+
+	session, err := store.GetSession(Hash(cookie.Value))
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.User{}, ErrNoSession
+	} else if err != nil {
+		return database.User{}, err
+	}
+
+	user, err := store.GetUserByID(session.UserID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.User{}, ErrNoSession
+	} else if err != nil {
+		return database.User{}, err
+	}
+
+	return user, nil
+}
+
+// contextKey is the type of the key of the user within the context of a request.
+type contextKey struct{}
+
+// WithUser returns a copy of a context holding the user logged in by the request.
+func WithUser(ctx context.Context, user database.User) context.Context {
+	return context.WithValue(ctx, contextKey{}, user)
+}
+
+// FromContext returns the user stored in a context, the boolean is false if there is none.
+func FromContext(ctx context.Context) (database.User, bool) {
+	user, found := ctx.Value(contextKey{}).(database.User)
+
+	return user, found
+}
+
+// Owner returns the ID of the user logged in by a request, see [WithUser], [uuid.Nil] for anonymous requests.
+func Owner(req *http.Request) uuid.UUID {
+	user, found := FromContext(req.Context())
+	if !found {
+		return uuid.Nil
+	}
+
+	return user.ID
+}
+
+// Owns tells if a link was created by the user logged in by a request.
+func Owns(req *http.Request, link database.Link) bool {
+	owner := Owner(req)
+
+	return owner != uuid.Nil && link.Owner == owner
+}
@@ -0,0 +1,134 @@
+//    reddlinks, a simple link shortener written in Go.
+//    Copyright (C) 2025 redd
+//
+//    This program is free software: you can redistribute it and/or modify
+//    it under the terms of the GNU General Public License as published by
+//    the Free Software Foundation, either version 3 of the License, or
+//    (at your option) any later version.
+//
+//    This program is distributed in the hope that it will be useful,
+//    but WITHOUT ANY WARRANTY; without even the implied warranty of
+//    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//    GNU General Public License for more details.
+//
+//    You should have received a copy of the GNU General Public License
+//    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+// Package admin authenticates the operator of the instance for the admin dashboard.
+//
+// The admin password is only kept as an argon2id hash. Once logged in, the operator receives
+// a session cookie signed with a key generated at startup, restarting the instance logs everybody out.
+package admin
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alexedwards/argon2id"
+)
+
+// SessionCookie is the name of the cookie holding the admin session.
+const SessionCookie = "reddlinks_admin"
+
+// Default settings of the sessions.
+const (
+	sessionLifetime = 12 * time.Hour
+	keyLength       = 32
+)
+
+// ErrNoPassword defines an error for an admin dashboard without a password nor a hash.
+var ErrNoPassword = errors.New("an admin password or an admin password hash is required")
+
+// Auth checks the admin password and signs the admin sessions.
+type Auth struct {
+	hash string
+	key  []byte
+}
+
+// NewAuth creates the authenticator of the admin dashboard.
+//
+// If a hash is given, it is used as is, otherwise, the plain password is hashed using argon2id.
+//
+// Parameters:
+//   - password: The plain admin password, ignored if a hash is given
+//   - hash: The argon2id hash of the admin password
+//
+// Returns:
+//   - *Auth: The authenticator
+//   - error: [ErrNoPassword] if both are empty, or any error encountered while hashing or decoding the hash
+func NewAuth(password, hash string) (*Auth, error) {
+	var err error
+
+	switch {
+	case hash != "":
+		// Make sure the hash is usable before starting
+		if _, _, _, err = argon2id.DecodeHash(hash); err != nil {
+			return nil, fmt.Errorf("invalid admin password hash: %w", err)
+		}
+	case password != "":
+		hash, err = argon2id.CreateHash(password, argon2id.DefaultParams)
+		if err != nil {
+			return nil, fmt.Errorf("could not hash the admin password: %w", err)
+		}
+	default:
+		return nil, ErrNoPassword
+	}
+
+	// Generate the key used to sign the sessions
+	key := make([]byte, keyLength)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("could not generate the session key: %w", err)
+	}
+
+	return &Auth{hash: hash, key: key}, nil
+}
+
+// CheckPassword tells if the given password is the admin password.
+func (auth *Auth) CheckPassword(password string) (bool, error) {
+	return argon2id.ComparePasswordAndHash(password, auth.hash)
+}
+
+// NewSession returns the value of a new session cookie along with its expiration date.
+//
+// The value is made of the expiration date as a unix timestamp and its signature.
+func (auth *Auth) NewSession() (string, time.Time) {
+	expireAt := time.Now().Add(sessionLifetime)
+	expiry := strconv.FormatInt(expireAt.Unix(), 10)
+
+	return expiry + "." + auth.sign(expiry), expireAt
+}
+
+// ValidSession tells if the value of a session cookie has been signed by this authenticator and hasn't expired.
+func (auth *Auth) ValidSession(value string) bool {
+	expiry, signature, found := strings.Cut(value, ".")
+	if !found {
+		return false
+	}
+
+	// Check the signature before trusting the expiration date
+	if !hmac.Equal([]byte(signature), []byte(auth.sign(expiry))) {
+		return false
+	}
+
+	expireAt, err := strconv.ParseInt(expiry, 10, 64)
+	if err != nil {
+		return false
+	}
+
+	return time.Now().Unix() < expireAt
+}
+
+// sign returns the base64 encoded HMAC-SHA256 of a value.
+func (auth *Auth) sign(value string) string {
+	mac := hmac.New(sha256.New, auth.key)
+	mac.Write([]byte(value))
+
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,210 @@
+//    reddlinks, a simple link shortener written in Go.
+//    Copyright (C) 2025 redd
+//
+//    This program is free software: you can redistribute it and/or modify
+//    it under the terms of the GNU General Public License as published by
+//    the Free Software Foundation, either version 3 of the License, or
+//    (at your option) any later version.
+//
+//    This program is distributed in the hope that it will be useful,
+//    but WITHOUT ANY WARRANTY; without even the implied warranty of
+//    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//    GNU General Public License for more details.
+//
+//    You should have received a copy of the GNU General Public License
+//    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+// Package analytics records accesses to links without slowing down redirections.
+//
+// Hits are anonymized when they are recorded: only the hour of the access, the host of the
+// referring page and a coarse class of the client are kept, no IP address or location is stored.
+// They are then written to the store in batches by a background goroutine.
+package analytics
+
+import (
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redds-be/reddlinks/internal/database"
+)
+
+// Client classes stored with each hit.
+const (
+	AgentBrowser = "browser"
+	AgentMobile  = "mobile"
+	AgentBot     = "bot"
+	AgentCLI     = "cli"
+	AgentOther   = "other"
+)
+
+// Default settings of the recorder.
+const (
+	bufferSize    = 4096
+	maxBatchSize  = 256
+	flushInterval = 10 * time.Second
+)
+
+// Substrings used to classify user agents, the order of the checks matters.
+var (
+	botMarkers    = []string{"bot", "crawler", "spider", "slurp", "preview", "facebookexternalhit"}
+	cliMarkers    = []string{"curl", "wget", "httpie", "python", "go-http-client", "libwww", "okhttp", "powershell"}
+	mobileMarkers = []string{"mobile", "android", "iphone", "ipad"}
+)
+
+// Recorder writes hits to the store asynchronously.
+//
+// Hits are queued in a buffered channel and flushed in batches either when a batch is full
+// or periodically. When the queue is full, new hits are dropped rather than delaying redirections.
+type Recorder struct {
+	store     database.HitStore
+	hits      chan database.Hit
+	done      chan struct{}
+	stopped   chan struct{}
+	closeOnce sync.Once
+}
+
+// NewRecorder creates a recorder and starts its background writer.
+//
+// Parameters:
+//   - store: Where the hits are written
+//
+// Returns:
+//   - *Recorder: The started recorder, [Recorder.Close] must be called to flush the pending hits
+func NewRecorder(store database.HitStore) *Recorder {
+	rec := &Recorder{
+		store:   store,
+		hits:    make(chan database.Hit, bufferSize),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+
+	go rec.run()
+
+	return rec
+}
+
+// Record queues an anonymized hit for the given short, built from the request.
+//
+// It never blocks, if the queue is full, the hit is dropped.
+func (rec *Recorder) Record(req *http.Request, short string) {
+	hit := database.Hit{
+		Short:    short,
+		HitAt:    time.Now().UTC().Truncate(time.Hour),
+		Referrer: ReferrerHost(req.Header.Get("Referer")),
+		Agent:    ClassifyAgent(req.Header.Get("User-Agent")),
+	}
+
+	select {
+	case rec.hits <- hit:
+	default:
+	}
+}
+
+// Close stops the background writer after flushing the pending hits.
+func (rec *Recorder) Close() {
+	rec.closeOnce.Do(func() {
+		close(rec.done)
+	})
+
+	<-rec.stopped
+}
+
+// run collects the queued hits and flushes them in batches until the recorder is closed.
+func (rec *Recorder) run() {
+	defer close(rec.stopped)
+
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]database.Hit, 0, maxBatchSize)
+
+	for {
+		select {
+		case hit := <-rec.hits:
+			batch = append(batch, hit)
+			if len(batch) >= maxBatchSize {
+				batch = rec.flush(batch)
+			}
+		case <-ticker.C:
+			batch = rec.flush(batch)
+		case <-rec.done:
+			// Drain what's left in the queue before the last flush
+			for {
+				select {
+				case hit := <-rec.hits:
+					batch = append(batch, hit)
+				default:
+					rec.flush(batch)
+
+					return
+				}
+			}
+		}
+	}
+}
+
+// flush writes a batch of hits to the store and returns the emptied batch.
+func (rec *Recorder) flush(batch []database.Hit) []database.Hit {
+	if len(batch) == 0 {
+		return batch
+	}
+
+	if err := rec.store.AddHits(batch); err != nil {
+		slog.Error("Could not record hits", slog.Int("count", len(batch)), slog.Any("error", err))
+	}
+
+	return batch[:0]
+}
+
+// ReferrerHost returns the lowercase host of a referrer URL, without a leading "www.".
+//
+// An empty string is returned for direct accesses and invalid referrers.
+func ReferrerHost(referrer string) string {
+	if referrer == "" {
+		return ""
+	}
+
+	parsedURL, err := url.Parse(referrer)
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimPrefix(strings.ToLower(parsedURL.Hostname()), "www.")
+}
+
+// ClassifyAgent returns the class of a client based on its user agent.
+//
+// The class is one of [AgentBot], [AgentCLI], [AgentMobile], [AgentBrowser] or [AgentOther].
+func ClassifyAgent(userAgent string) string {
+	agent := strings.ToLower(userAgent)
+
+	switch {
+	case agent == "":
+		return AgentOther
+	case containsAny(agent, botMarkers):
+		return AgentBot
+	case containsAny(agent, cliMarkers):
+		return AgentCLI
+	case !strings.HasPrefix(agent, "mozilla/"):
+		return AgentOther
+	case containsAny(agent, mobileMarkers):
+		return AgentMobile
+	default:
+		return AgentBrowser
+	}
+}
+
+// containsAny tells if a string contains any of the given substrings.
+func containsAny(str string, substrings []string) bool {
+	for _, substring := range substrings {
+		if strings.Contains(str, substring) {
+			return true
+		}
+	}
+
+	return false
+}
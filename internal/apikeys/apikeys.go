@@ -0,0 +1,258 @@
+//    reddlinks, a simple link shortener written in Go.
+//    Copyright (C) 2025 redd
+//
+//    This program is free software: you can redistribute it and/or modify
+//    it under the terms of the GNU General Public License as published by
+//    the Free Software Foundation, either version 3 of the License, or
+//    (at your option) any later version.
+//
+//    This program is distributed in the hope that it will be useful,
+//    but WITHOUT ANY WARRANTY; without even the implied warranty of
+//    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//    GNU General Public License for more details.
+//
+//    You should have received a copy of the GNU General Public License
+//    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+// Package apikeys authenticates the clients of the API using keys given as bearer tokens.
+//
+// Each key is a random string starting with [KeyPrefix], only its SHA-256 hash is stored, see [database.APIKeyStore].
+// The keys are high-entropy random strings, a fast hash is enough to keep them secret, unlike passwords.
+// A key holds scopes telling what it allows, and may expire.
+package apikeys
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/redds-be/reddlinks/internal/database"
+)
+
+// Scope defines what an API key allows.
+type Scope string
+
+// Scopes of the API keys.
+//
+// ScopeCreate allows creating links, bypassing the rate limits and the creation restriction,
+// ScopeManage allows updating and deleting any link without its management token,
+// ScopeAdmin allows using the admin dashboard,
+// ScopeStats allows getting the information of protected links without their password, and the metrics.
+const (
+	ScopeCreate Scope = "create"
+	ScopeManage Scope = "manage"
+	ScopeAdmin  Scope = "admin"
+	ScopeStats  Scope = "stats"
+)
+
+// Scopes lists every scope, in the order they are displayed.
+var Scopes = []Scope{ScopeCreate, ScopeManage, ScopeAdmin, ScopeStats}
+
+// KeyPrefix is the beginning of every API key, it tells them apart from the other bearer tokens such as the metrics token.
+const KeyPrefix = "rlk_"
+
+// MaxLabelLength is the maximum length of the label of a key.
+const MaxLabelLength = 255
+
+// Settings of the keys.
+const (
+	keyLength           = 32
+	displayPrefixLength = len(KeyPrefix) + 8
+)
+
+// Define all the errors for the apikeys package.
+//
+// ErrNoKey defines an error for a request without an API key,
+// ErrInvalidKey defines an error for an API key that doesn't exist, or was revoked,
+// ErrExpiredKey defines an error for an API key that has expired,
+// ErrInvalidLabel defines an error for an API key created without a label or with a label longer than [MaxLabelLength],
+// ErrNoScope defines an error for an API key created without any scope,
+// ErrUnknownScope defines an error for a scope that doesn't exist.
+var (
+	ErrNoKey        = errors.New("no API key given")
+	ErrInvalidKey   = errors.New("invalid API key")
+	ErrExpiredKey   = errors.New("the API key has expired")
+	ErrInvalidLabel = errors.New("a label of at most 255 characters is required")
+	ErrNoScope      = errors.New("at least one scope is required")
+	ErrUnknownScope = errors.New("unknown scope")
+)
+
+// ParseScopes splits a comma-separated list of scopes, ignoring the spaces, the empty items and the duplicates.
+//
+// Parameters:
+//   - list: The comma-separated list of scopes
+//
+// Returns:
+//   - []Scope: The scopes
+//   - error: [ErrUnknownScope] if a scope doesn't exist, [ErrNoScope] if the list is empty
+func ParseScopes(list string) ([]Scope, error) {
+	var scopes []Scope
+
+	for _, item := range strings.Split(list, ",") {
+		scope := Scope(strings.ToLower(strings.TrimSpace(item)))
+		if scope == "" || slices.Contains(scopes, scope) {
+			continue
+		}
+
+		if !slices.Contains(Scopes, scope) {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownScope, scope)
+		}
+
+		scopes = append(scopes, scope)
+	}
+
+	if len(scopes) == 0 {
+		return nil, ErrNoScope
+	}
+
+	return scopes, nil
+}
+
+// Generate creates a new API key.
+//
+// Parameters:
+//   - label: Describes who or what the key is given to
+//   - scopes: What the key allows, see [ParseScopes]
+//   - expireAt: The date at which the key will expire, zero if it never does
+//
+// Returns:
+//   - string: The plain key, to be given once to the client since it can't be recovered
+//   - database.APIKey: The key to store
+//   - error: [ErrInvalidLabel] if the label is empty or too long, [ErrNoScope] if no scope is given,
+//     or any error encountered while generating the key
+func Generate(label string, scopes []Scope, expireAt time.Time) (string, database.APIKey, error) {
+	label = strings.TrimSpace(label)
+	if label == "" || utf8.RuneCountInString(label) > MaxLabelLength {
+		return "", database.APIKey{}, ErrInvalidLabel
+	}
+
+	if len(scopes) == 0 {
+		return "", database.APIKey{}, ErrNoScope
+	}
+
+	// Generate the random part of the key
+	random := make([]byte, keyLength)
+	if _, err := rand.Read(random); err != nil {
+		return "", database.APIKey{}, fmt.Errorf("could not generate the API key: %w", err)
+	}
+
+	plain := KeyPrefix + base64.RawURLEncoding.EncodeToString(random)
+
+	names := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		names = append(names, string(scope))
+	}
+
+	key := database.APIKey{
+		ID:        uuid.New(),
+		Label:     label,
+		Prefix:    plain[:displayPrefixLength],
+		Hash:      Hash(plain),
+		Scopes:    strings.Join(names, ","),
+		CreatedAt: time.Now().UTC(),
+	}
+
+	if !expireAt.IsZero() {
+		key.ExpireAt = expireAt.UTC()
+	}
+
+	return plain, key, nil
+}
+
+// Hash returns the SHA-256 hash of a plain key, encoded in hexadecimal.
+func Hash(plain string) string {
+	sum := sha256.Sum256([]byte(plain))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// Has tells if an API key holds a scope.
+func Has(key database.APIKey, scope Scope) bool {
+	for _, name := range strings.Split(key.Scopes, ",") {
+		if Scope(name) == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Expired tells if an API key has expired at a given date.
+func Expired(key database.APIKey, now time.Time) bool {
+	return !key.ExpireAt.IsZero() && !now.Before(key.ExpireAt)
+}
+
+// FromHeader returns the API key given as a bearer token in the Authorization header of a request.
+//
+// The boolean is false if there is no bearer token starting with [KeyPrefix].
+func FromHeader(req *http.Request) (string, bool) {
+	token, found := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+	if !found || !strings.HasPrefix(token, KeyPrefix) {
+		return "", false
+	}
+
+	return token, true
+}
+
+// Authenticate returns the stored API key matching the one given by a request, see [FromHeader].
+//
+// Parameters:
+//   - store: Where the API keys are stored
+//   - req: The request giving the key
+//
+// Returns:
+//   - database.APIKey: The stored key
+//   - error: [ErrNoKey] if no key is given, [ErrInvalidKey] if it doesn't exist, [ErrExpiredKey] if it has expired,
+//     or any error encountered during the lookup
+func Authenticate(store database.APIKeyStore, req *http.Request) (database.APIKey, error) {
+	plain, found := FromHeader(req)
+	if !found {
+		return database.APIKey{}, ErrNoKey
+	}
+
+	key, err := store.GetAPIKeyByHash(Hash(plain))
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.APIKey{}, ErrInvalidKey
+	} else if err != nil {
+		return database.APIKey{}, err
+	}
+
+	if Expired(key, time.Now()) {
+		return database.APIKey{}, ErrExpiredKey
+	}
+
+	return key, nil
+}
+
+// contextKey is the type of the key of the API key within the context of a request.
+type contextKey struct{}
+
+// WithKey returns a copy of a context holding the API key the request was authenticated with.
+func WithKey(ctx context.Context, key database.APIKey) context.Context {
+	return context.WithValue(ctx, contextKey{}, key)
+}
+
+// FromContext returns the API key stored in a context, the boolean is false if there is none.
+func FromContext(ctx context.Context) (database.APIKey, bool) {
+	key, found := ctx.Value(contextKey{}).(database.APIKey)
+
+	return key, found
+}
+
+// Allows tells if a request was authenticated with an API key holding a scope, see [WithKey].
+func Allows(req *http.Request, scope Scope) bool {
+	key, found := FromContext(req.Context())
+
+	return found && Has(key, scope)
+}
@@ -0,0 +1,383 @@
+//    reddlinks, a simple link shortener written in Go.
+//    Copyright (C) 2025 redd
+//
+//    This program is free software: you can redistribute it and/or modify
+//    it under the terms of the GNU General Public License as published by
+//    the Free Software Foundation, either version 3 of the License, or
+//    (at your option) any later version.
+//
+//    This program is distributed in the hope that it will be useful,
+//    but WITHOUT ANY WARRANTY; without even the implied warranty of
+//    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//    GNU General Public License for more details.
+//
+//    You should have received a copy of the GNU General Public License
+//    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+// Package archive exports and imports links as a portable archive.
+//
+// An archive is a JSON Lines file, its first line is a [Header] giving the version of the format,
+// each following line is a [Record] holding a link. Password and management token hashes are kept
+// as is, so that protected links can still be accessed and managed once imported into another instance,
+// whatever the type of its database.
+package archive
+
+import (
+	"bufio"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redds-be/reddlinks/internal/database"
+)
+
+// Format is the name of the format, written in the header of every archive.
+const Format = "reddlinks"
+
+// Version is the version of the format written by [Export], archives of a newer version can't be imported.
+//
+// The version 2 adds the owner of the links, the links of version 1 archives are imported as anonymous ones.
+const Version = 2
+
+// pageSize is the number of links read from or written to the store at once.
+const pageSize = 1000
+
+// maxLineSize is the maximum size of a line of an archive.
+const maxLineSize = 1 << 20
+
+// ConflictMode defines what to do when an imported link has the short of an existing link.
+type ConflictMode string
+
+// Conflict modes supported by [Import].
+const (
+	// ConflictSkip keeps the existing link and ignores the imported one
+	ConflictSkip ConflictMode = "skip"
+	// ConflictOverwrite replaces the existing link, and its hits, by the imported one
+	ConflictOverwrite ConflictMode = "overwrite"
+	// ConflictFail refuses to import anything if one of the shorts is already used
+	ConflictFail ConflictMode = "fail"
+)
+
+var (
+	// ErrUnknownConflictMode is returned when a conflict mode is not one of the supported modes.
+	ErrUnknownConflictMode = errors.New("unknown conflict mode")
+
+	// ErrInvalidArchive is returned when an archive is not a valid reddlinks archive.
+	ErrInvalidArchive = errors.New("invalid archive")
+
+	// ErrUnsupportedVersion is returned when an archive was written by a newer version of the format.
+	ErrUnsupportedVersion = errors.New("unsupported archive version")
+
+	// ErrConflict is returned when an imported link has the short of an existing link with [ConflictFail].
+	ErrConflict = errors.New("the short is already in use")
+)
+
+// Header defines the first line of an archive.
+//
+// Format is always [Format], Version is the version of the format,
+// ExportedAt is the date at which the archive was written.
+type Header struct {
+	Format     string    `json:"format"`
+	Version    int       `json:"version"`
+	ExportedAt time.Time `json:"exported_at"`
+}
+
+// Record defines a link as written in an archive.
+//
+// Password and Token are the hashes of the password and of the management token, empty if the link has none.
+// Owner is the ID of the user who created the link, nil if it was created anonymously. The users themselves
+// aren't part of the archive, the links are only found in the account of their owner if it has the same ID
+// in the instance they are imported into.
+type Record struct {
+	ID        uuid.UUID  `json:"id"`
+	Short     string     `json:"short"`
+	URL       string     `json:"url"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpireAt  time.Time  `json:"expire_at"`
+	Password  string     `json:"password,omitempty"`
+	Token     string     `json:"token,omitempty"`
+	Owner     *uuid.UUID `json:"owner,omitempty"`
+}
+
+// ImportStats defines the outcome of an import.
+//
+// Imported is the number of links that didn't exist before,
+// Overwritten is the number of existing links that were replaced,
+// Skipped is the number of existing links that were kept.
+type ImportStats struct {
+	Imported    int
+	Overwritten int
+	Skipped     int
+}
+
+// ParseConflictMode returns the conflict mode corresponding to its name.
+//
+// Parameters:
+//   - mode: The name of the mode ("skip", "overwrite" or "fail")
+//
+// Returns:
+//   - ConflictMode: The conflict mode
+//   - error: [ErrUnknownConflictMode] if the mode isn't supported
+func ParseConflictMode(mode string) (ConflictMode, error) {
+	switch ConflictMode(mode) {
+	case ConflictSkip, ConflictOverwrite, ConflictFail:
+		return ConflictMode(mode), nil
+	default:
+		return "", fmt.Errorf("%w: %q", ErrUnknownConflictMode, mode)
+	}
+}
+
+// Export writes every link of a store to an archive, expired links that weren't collected yet included.
+//
+// Parameters:
+//   - store: The store to read the links from
+//   - writer: Where the archive is written
+//
+// Returns:
+//   - int: The number of exported links
+//   - error: Any error encountered while reading the links or writing the archive
+func Export(store database.LinkStore, writer io.Writer) (int, error) {
+	buffered := bufio.NewWriter(writer)
+	encoder := json.NewEncoder(buffered)
+
+	// Write the header
+	header := Header{Format: Format, Version: Version, ExportedAt: time.Now().UTC()}
+	if err := encoder.Encode(header); err != nil {
+		return 0, fmt.Errorf("failed to write header: %w", err)
+	}
+
+	// Write the links page by page, each page starting after the last short of the previous one,
+	// so that the links created or deleted during the export don't shift the pages
+	exported := 0
+	filter := database.LinkFilter{Limit: pageSize, SortByShort: true}
+
+	for {
+		links, _, err := store.ListLinks(filter)
+		if err != nil {
+			return exported, err
+		}
+
+		for _, link := range links {
+			record := Record{
+				ID:        link.ID,
+				Short:     link.Short,
+				URL:       link.URL,
+				CreatedAt: link.CreatedAt.UTC(),
+				ExpireAt:  link.ExpireAt.UTC(),
+				Password:  link.Password,
+				Token:     link.Token,
+			}
+
+			if link.Owner != uuid.Nil {
+				record.Owner = &link.Owner
+			}
+
+			if err := encoder.Encode(record); err != nil {
+				return exported, fmt.Errorf("failed to write link %q: %w", link.Short, err)
+			}
+
+			exported++
+		}
+
+		if len(links) < pageSize {
+			break
+		}
+
+		filter.ShortAfter = links[len(links)-1].Short
+	}
+
+	if err := buffered.Flush(); err != nil {
+		return exported, fmt.Errorf("failed to write archive: %w", err)
+	}
+
+	return exported, nil
+}
+
+// Import reads the links of an archive into a store.
+//
+// The archive is read twice: it is first entirely validated, along with the conflicts if the mode is [ConflictFail],
+// so that nothing is imported from an invalid archive. The links are then inserted in batches using
+// [database.LinkStore.CreateLinks], existing links being skipped or, with [ConflictOverwrite],
+// replaced within the transaction inserting their batch using [database.LinkStore.ReplaceLinks].
+//
+// Parameters:
+//   - store: The store to write the links to
+//   - archive: The archive to read, it must be seekable to be read twice
+//   - mode: What to do with the links whose short is already used
+//
+// Returns:
+//   - ImportStats: The number of imported, overwritten and skipped links
+//   - error: Any error encountered while reading the archive or writing the links
+func Import(store database.LinkStore, archive io.ReadSeeker, mode ConflictMode) (ImportStats, error) {
+	if _, err := ParseConflictMode(string(mode)); err != nil {
+		return ImportStats{}, err
+	}
+
+	// Validate the archive before importing anything
+	shorts := make(map[string]struct{})
+
+	err := readRecords(archive, func(record Record) error {
+		if _, duplicate := shorts[record.Short]; duplicate {
+			return fmt.Errorf("%w: short %q is given twice", ErrInvalidArchive, record.Short)
+		}
+
+		shorts[record.Short] = struct{}{}
+
+		if mode != ConflictFail {
+			return nil
+		}
+
+		exists, err := shortExists(store, record.Short)
+		if err != nil {
+			return err
+		} else if exists {
+			return fmt.Errorf("%w: %q", ErrConflict, record.Short)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return ImportStats{}, err
+	}
+
+	// Go back to the beginning to import the links
+	if _, err := archive.Seek(0, io.SeekStart); err != nil {
+		return ImportStats{}, fmt.Errorf("failed to read archive: %w", err)
+	}
+
+	var stats ImportStats
+
+	// The existing links are only replaced along with the insertion of their batch,
+	// so that they are kept if the batch can't be inserted
+	insert := store.CreateLinks
+	if mode == ConflictOverwrite {
+		insert = store.ReplaceLinks
+	}
+
+	pending := make([]database.Link, 0, pageSize)
+
+	err = readRecords(archive, func(record Record) error {
+		exists, err := shortExists(store, record.Short)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case exists && mode == ConflictSkip:
+			stats.Skipped++
+
+			return nil
+		case exists:
+			stats.Overwritten++
+		default:
+			stats.Imported++
+		}
+
+		link := database.Link{
+			ID:        record.ID,
+			CreatedAt: record.CreatedAt,
+			ExpireAt:  record.ExpireAt,
+			URL:       record.URL,
+			Short:     record.Short,
+			Password:  record.Password,
+			Token:     record.Token,
+		}
+
+		if record.Owner != nil {
+			link.Owner = *record.Owner
+		}
+
+		pending = append(pending, link)
+
+		// Insert the pending links once there are enough of them
+		if len(pending) == pageSize {
+			if err := insert(pending); err != nil {
+				return err
+			}
+
+			pending = pending[:0]
+		}
+
+		return nil
+	})
+	if err != nil {
+		return stats, err
+	}
+
+	// Insert what's left
+	if len(pending) != 0 {
+		if err := insert(pending); err != nil {
+			return stats, err
+		}
+	}
+
+	return stats, nil
+}
+
+// readRecords reads an archive from its current position and calls a function for each of its valid records.
+func readRecords(archive io.Reader, handle func(record Record) error) error {
+	scanner := bufio.NewScanner(archive)
+	scanner.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), maxLineSize)
+
+	// Read and check the header
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return fmt.Errorf("failed to read archive: %w", err)
+		}
+
+		return fmt.Errorf("%w: the archive is empty", ErrInvalidArchive)
+	}
+
+	var header Header
+	if err := json.Unmarshal(scanner.Bytes(), &header); err != nil || header.Format != Format {
+		return fmt.Errorf("%w: the header is missing", ErrInvalidArchive)
+	}
+
+	if header.Version < 1 || header.Version > Version {
+		return fmt.Errorf("%w: %d", ErrUnsupportedVersion, header.Version)
+	}
+
+	// Read and check the records
+	for line := 2; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return fmt.Errorf("%w: line %d: %w", ErrInvalidArchive, line, err)
+		}
+
+		if record.ID == uuid.Nil || record.Short == "" || record.URL == "" {
+			return fmt.Errorf("%w: line %d: id, short and url are required", ErrInvalidArchive, line)
+		}
+
+		if err := handle(record); err != nil {
+			return err
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read archive: %w", err)
+	}
+
+	return nil
+}
+
+// shortExists tells if a short is used by a link of a store, expired or not.
+func shortExists(store database.LinkStore, short string) (bool, error) {
+	_, err := store.GetURLByShort(short)
+
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.Is(err, sql.ErrNoRows):
+		return false, nil
+	default:
+		return false, err
+	}
+}
@@ -0,0 +1,260 @@
+//    reddlinks, a simple link shortener written in Go.
+//    Copyright (C) 2025 redd
+//
+//    This program is free software: you can redistribute it and/or modify
+//    it under the terms of the GNU General Public License as published by
+//    the Free Software Foundation, either version 3 of the License, or
+//    (at your option) any later version.
+//
+//    This program is distributed in the hope that it will be useful,
+//    but WITHOUT ANY WARRANTY; without even the implied warranty of
+//    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//    GNU General Public License for more details.
+//
+//    You should have received a copy of the GNU General Public License
+//    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+// Package certs provides the TLS certificates used to serve reddlinks over HTTPS.
+//
+// The certificate is either loaded from a certificate file and a key file, or obtained and renewed
+// automatically from an ACME certificate authority such as Let's Encrypt. The ACME account key and
+// the certificates are cached in the database, see [Cache], or in a directory.
+package certs
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/redds-be/reddlinks/internal/database"
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// acmeClientTimeout is the timeout of the requests sent to the ACME directory.
+const acmeClientTimeout = 30 * time.Second
+
+// Define all the errors for the certs package.
+//
+// ErrNoCertificate defines an error for settings giving neither certificate files nor ACME domains,
+// ErrInvalidCA defines an error for CA files without any PEM encoded certificate.
+var (
+	ErrNoCertificate = errors.New("no certificate files or ACME domains given")
+	ErrInvalidCA     = errors.New("no certificate found in the CA file")
+)
+
+// Settings defines where the certificates come from.
+//
+// CertFile and KeyFile are the PEM encoded certificate chain and private key, used instead of ACME if set,
+// Domains are the host names a certificate is requested for using ACME, any other host name is refused,
+// Email is the contact address given to the certificate authority (optional),
+// DirectoryURL is the ACME directory of the certificate authority (optional, Let's Encrypt by default),
+// DirectoryCA is a PEM file of the CA trusted to reach the directory (optional, for test authorities like Pebble),
+// CacheDir is the directory caching the certificates (optional, they are cached in the database by default).
+type Settings struct {
+	CertFile     string
+	KeyFile      string
+	Domains      []string
+	Email        string
+	DirectoryURL string
+	DirectoryCA  string
+	CacheDir     string
+}
+
+// TLS holds the TLS configuration of the server.
+type TLS struct {
+	// Config is the configuration given to the HTTPS server
+	Config *tls.Config
+
+	// manager obtains and renews the certificates, nil if they are loaded from files
+	manager *autocert.Manager
+}
+
+// New returns the TLS configuration matching the settings.
+//
+// If certificate files are given, they are loaded once. Otherwise, an ACME manager obtains a certificate
+// for the allowed domains on the first TLS handshake and renews it before it expires, answering
+// the tls-alpn-01 challenges itself. The http-01 challenges are answered by [TLS.ChallengeHandler].
+//
+// Parameters:
+//   - settings: Where the certificates come from
+//   - store: Where the ACME data is cached if no cache directory is given
+//
+// Returns:
+//   - *TLS: The TLS configuration
+//   - error: Any error encountered while loading the files
+func New(settings Settings, store database.CertStore) (*TLS, error) {
+	// Load the certificate from the files if given
+	if settings.CertFile != "" {
+		certificate, err := tls.LoadX509KeyPair(settings.CertFile, settings.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load the certificate: %w", err)
+		}
+
+		return &TLS{Config: &tls.Config{
+			MinVersion:   tls.VersionTLS12,
+			Certificates: []tls.Certificate{certificate},
+		}}, nil
+	}
+
+	if len(settings.Domains) == 0 {
+		return nil, ErrNoCertificate
+	}
+
+	// Obtain the certificates using ACME otherwise
+	manager := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: autocert.HostWhitelist(settings.Domains...),
+		Email:      settings.Email,
+		Cache:      Cache{Store: store},
+	}
+
+	if settings.CacheDir != "" {
+		manager.Cache = autocert.DirCache(settings.CacheDir)
+	}
+
+	// Use another certificate authority if asked to
+	if settings.DirectoryURL != "" {
+		client, err := newACMEClient(settings.DirectoryURL, settings.DirectoryCA)
+		if err != nil {
+			return nil, err
+		}
+
+		manager.Client = client
+	}
+
+	config := manager.TLSConfig()
+	config.MinVersion = tls.VersionTLS12
+
+	return &TLS{Config: config, manager: manager}, nil
+}
+
+// ParseDomains splits a comma-separated list of domains, ignoring the spaces and the empty items.
+func ParseDomains(list string) []string {
+	var domains []string
+
+	for _, domain := range strings.Split(list, ",") {
+		if domain = strings.TrimSpace(domain); domain != "" {
+			domains = append(domains, domain)
+		}
+	}
+
+	return domains
+}
+
+// newACMEClient returns an ACME client using a given directory, trusting the CA of a PEM file if given.
+func newACMEClient(directoryURL, caFile string) (*acme.Client, error) {
+	client := &acme.Client{DirectoryURL: directoryURL}
+
+	if caFile == "" {
+		return client, nil
+	}
+
+	data, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the ACME directory CA: %w", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, ErrInvalidCA
+	}
+
+	client.HTTPClient = &http.Client{
+		Timeout: acmeClientTimeout,
+		Transport: &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: &tls.Config{MinVersion: tls.VersionTLS12, RootCAs: pool},
+		},
+	}
+
+	return client, nil
+}
+
+// ChallengeHandler returns a handler answering the ACME http-01 challenges sent over plain HTTP,
+// the other requests are handled by the fallback handler.
+// The fallback handler is returned as is if the certificates are loaded from files.
+func (config *TLS) ChallengeHandler(fallback http.Handler) http.Handler {
+	if config == nil || config.manager == nil {
+		return fallback
+	}
+
+	return config.manager.HTTPHandler(fallback)
+}
+
+// RedirectToHTTPS returns a handler redirecting every request to the same URL over HTTPS.
+//
+// The host of the request is kept, with the given port unless it is the default HTTPS port.
+// The redirection is permanent so that browsers remember it.
+//
+// Parameters:
+//   - port: The port the HTTPS server listens on
+//
+// Returns:
+//   - http.Handler: The redirecting handler
+func RedirectToHTTPS(port string) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
+		// Remove the port of the plain HTTP server from the host
+		host := req.Host
+		if hostname, _, err := net.SplitHostPort(host); err == nil {
+			host = hostname
+		}
+
+		if port != "" && port != "443" {
+			host = net.JoinHostPort(host, port)
+		}
+
+		http.Redirect(writer, req, "https://"+host+req.URL.RequestURI(), http.StatusPermanentRedirect)
+	})
+}
+
+// Cache is an [autocert.Cache] keeping the ACME data in the database, see [database.CertStore].
+//
+// It allows several instances sharing a database to share the certificates, and the certificates to
+// survive the loss of the container of an instance.
+type Cache struct {
+	Store database.CertStore
+}
+
+// Make sure the cache satisfies the interface.
+var _ autocert.Cache = Cache{}
+
+// Get returns the data of an entry, [autocert.ErrCacheMiss] is returned if it doesn't exist.
+func (cache Cache) Get(ctx context.Context, name string) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	data, err := cache.Store.GetCert(name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, autocert.ErrCacheMiss
+	}
+
+	return data, err
+}
+
+// Put inserts or replaces an entry.
+func (cache Cache) Put(ctx context.Context, name string, data []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return cache.Store.PutCert(name, data)
+}
+
+// Delete deletes an entry.
+func (cache Cache) Delete(ctx context.Context, name string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return cache.Store.DeleteCert(name)
+}
@@ -0,0 +1,256 @@
+//    reddlinks, a simple link shortener written in Go.
+//    Copyright (C) 2025 redd
+//
+//    This program is free software: you can redistribute it and/or modify
+//    it under the terms of the GNU General Public License as published by
+//    the Free Software Foundation, either version 3 of the License, or
+//    (at your option) any later version.
+//
+//    This program is distributed in the hope that it will be useful,
+//    but WITHOUT ANY WARRANTY; without even the implied warranty of
+//    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//    GNU General Public License for more details.
+//
+//    You should have received a copy of the GNU General Public License
+//    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// LinkFilter defines the criteria used to list links.
+//
+// Short and URL are substrings that must be found in the short and in the URL,
+// CreatedAfter, CreatedBefore, ExpireAfter and ExpireBefore bound the creation and expiration dates,
+// zero values are ignored,
+// Owner only keeps the links of a user, ignored if it is [uuid.Nil],
+// Limit and Offset are used for pagination, a Limit of 0 means no limit,
+// SortByShort sorts the links by short instead of the most recent first, and ShortAfter only keeps the links
+// whose short sorts after it, so that every link can be read page by page even if links are created
+// or deleted meanwhile.
+type LinkFilter struct {
+	Short         string
+	URL           string
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	ExpireAfter   time.Time
+	ExpireBefore  time.Time
+	Owner         uuid.UUID
+	Limit         int
+	Offset        int
+	SortByShort   bool
+	ShortAfter    string
+}
+
+// whereClause builds the WHERE clause matching the filter along with its arguments.
+//
+// Every criterion uses a numbered placeholder so the clause can be used by every database type.
+func (filter LinkFilter) whereClause() (string, []any) {
+	var (
+		conditions []string
+		args       []any
+	)
+
+	addCondition := func(condition string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.Short != "" {
+		addCondition(`short LIKE $%d ESCAPE '!'`, "%"+escapeLike(filter.Short)+"%")
+	}
+
+	if filter.URL != "" {
+		addCondition(`url LIKE $%d ESCAPE '!'`, "%"+escapeLike(filter.URL)+"%")
+	}
+
+	if !filter.CreatedAfter.IsZero() {
+		addCondition("created_at >= $%d", filter.CreatedAfter)
+	}
+
+	if !filter.CreatedBefore.IsZero() {
+		addCondition("created_at < $%d", filter.CreatedBefore)
+	}
+
+	if !filter.ExpireAfter.IsZero() {
+		addCondition("expire_at >= $%d", filter.ExpireAfter)
+	}
+
+	if !filter.ExpireBefore.IsZero() {
+		addCondition("expire_at < $%d", filter.ExpireBefore)
+	}
+
+	if filter.Owner != uuid.Nil {
+		addCondition("owner = $%d", filter.Owner)
+	}
+
+	if filter.ShortAfter != "" {
+		addCondition("short > $%d", filter.ShortAfter)
+	}
+
+	if len(conditions) == 0 {
+		return "", nil
+	}
+
+	return "WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// escapeLike escapes the wildcards of a LIKE pattern so they are matched literally.
+//
+// The escape character is "!" as a backslash would need to be escaped itself with MySQL.
+func escapeLike(pattern string) string {
+	return strings.NewReplacer(`!`, `!!`, `%`, `!%`, `_`, `!_`).Replace(pattern)
+}
+
+// ListLinks lists the links matching a filter, the most recent first unless sorted by short.
+//
+// Parameters:
+//   - filter: The criteria the links must match and the pagination
+//
+// Returns:
+//   - []Link: The links of the requested page
+//   - int: The total number of links matching the filter, regardless of the pagination
+//   - error: Any error encountered during the queries
+func (store *SQLStore) ListLinks(filter LinkFilter) ([]Link, int, error) {
+	where, args := filter.whereClause()
+
+	// Count every matching link for the pagination
+	var total int
+	if err := store.db.QueryRow(store.rebind("SELECT COUNT(*) FROM links "+where+";"), args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count links: %w", err)
+	}
+
+	order := "created_at DESC, short"
+	if filter.SortByShort {
+		order = "short"
+	}
+
+	query := `SELECT id, created_at, expire_at, url, short, password, COALESCE(token, ''), owner 
+		FROM links ` + where + ` 
+		ORDER BY ` + order
+
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit, filter.Offset)
+		query += fmt.Sprintf(" LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+	}
+
+	rows, err := store.db.Query(store.rebind(query+";"), args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list links: %w", err)
+	}
+	defer rows.Close()
+
+	links := make([]Link, 0, filter.Limit)
+
+	for rows.Next() {
+		link, err := scanLink(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to read link: %w", err)
+		}
+
+		links = append(links, link)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to list links: %w", err)
+	}
+
+	return links, total, nil
+}
+
+// DeleteLinks deletes several links and their hits by their shorts within a single transaction.
+//
+// Parameters:
+//   - shorts: The shortened URLs of the links to delete
+//
+// Returns:
+//   - int64: The number of deleted links, unknown shorts are ignored
+//   - error: Any error encountered during the deletion, nothing is deleted in that case
+func (store *SQLStore) DeleteLinks(shorts []string) (int64, error) {
+	const sqlDeleteHits = `
+		DELETE FROM link_hits 
+		WHERE link_id IN (SELECT id FROM links WHERE short = $1);`
+
+	const sqlDeleteLink = `DELETE FROM links WHERE short = $1;`
+
+	return bulkExec(store.db, shorts, "delete links", func(trans *sql.Tx, short string) (sql.Result, error) {
+		if _, err := trans.Exec(store.rebind(sqlDeleteHits), short); err != nil {
+			return nil, err
+		}
+
+		return trans.Exec(store.rebind(sqlDeleteLink), short)
+	})
+}
+
+// ExpireLinks sets the expiration date of several links to a given date within a single transaction.
+//
+// Parameters:
+//   - shorts: The shortened URLs of the links to expire
+//   - expireAt: The new expiration date, usually now
+//
+// Returns:
+//   - int64: The number of expired links, unknown shorts are ignored
+//   - error: Any error encountered during the update, nothing is updated in that case
+func (store *SQLStore) ExpireLinks(shorts []string, expireAt time.Time) (int64, error) {
+	const sqlExpireLink = `UPDATE links SET expire_at = $1 WHERE short = $2;`
+
+	return bulkExec(store.db, shorts, "expire links", func(trans *sql.Tx, short string) (sql.Result, error) {
+		return trans.Exec(store.rebind(sqlExpireLink), expireAt, short)
+	})
+}
+
+// bulkExec runs a statement for each short within a single transaction.
+//
+// Parameters:
+//   - dbase: A pointer to the SQL database connection
+//   - shorts: The shortened URLs to run the statement for
+//   - action: A short description of the action, used in error messages
+//   - exec: The function running the statement for a single short
+//
+// Returns:
+//   - int64: The total number of affected rows
+//   - error: Any error encountered, the transaction is rolled back in that case
+func bulkExec(
+	dbase *sql.DB,
+	shorts []string,
+	action string,
+	exec func(trans *sql.Tx, short string) (sql.Result, error),
+) (int64, error) {
+	trans, err := dbase.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	var total int64
+
+	for _, short := range shorts {
+		result, err := exec(trans, short)
+		if err != nil {
+			_ = trans.Rollback()
+
+			return 0, fmt.Errorf("failed to %s: %w", action, err)
+		}
+
+		affected, err := result.RowsAffected()
+		if err != nil {
+			_ = trans.Rollback()
+
+			return 0, fmt.Errorf("failed to %s: %w", action, err)
+		}
+
+		total += affected
+	}
+
+	if err := trans.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to %s: %w", action, err)
+	}
+
+	return total, nil
+}
@@ -0,0 +1,175 @@
+//    reddlinks, a simple link shortener written in Go.
+//    Copyright (C) 2025 redd
+//
+//    This program is free software: you can redistribute it and/or modify
+//    it under the terms of the GNU General Public License as published by
+//    the Free Software Foundation, either version 3 of the License, or
+//    (at your option) any later version.
+//
+//    This program is distributed in the hope that it will be useful,
+//    but WITHOUT ANY WARRANTY; without even the implied warranty of
+//    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//    GNU General Public License for more details.
+//
+//    You should have received a copy of the GNU General Public License
+//    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// errDuplicateKey defines an error for an API key created with the hash of another key,
+// the SQL databases report their own error through their unique index.
+var errDuplicateKey = errors.New("the API key already exists")
+
+// sortAPIKeys sorts API keys by creation date, the ones created at the same time by ID.
+func sortAPIKeys(keys []APIKey) {
+	sort.Slice(keys, func(i, j int) bool {
+		if !keys[i].CreatedAt.Equal(keys[j].CreatedAt) {
+			return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+		}
+
+		return keys[i].ID.String() < keys[j].ID.String()
+	})
+}
+
+// nullTime converts a date to a nullable database value, the zero date being stored as NULL.
+func nullTime(date time.Time) sql.NullTime {
+	return sql.NullTime{Time: date.UTC(), Valid: !date.IsZero()}
+}
+
+// CreateAPIKey inserts a new API key, a key that never expires is stored without expiration date.
+//
+// Parameters:
+//   - key: The API key to insert, its ID and its hash must be unique
+//
+// Returns:
+//   - error: Any error encountered during the insertion
+func (store *SQLStore) CreateAPIKey(key APIKey) error {
+	const sqlCreateAPIKey = `
+		INSERT INTO api_keys (id, label, prefix, hash, scopes, created_at, expire_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7);`
+
+	_, err := store.db.Exec(
+		store.rebind(sqlCreateAPIKey),
+		key.ID,
+		key.Label,
+		key.Prefix,
+		key.Hash,
+		key.Scopes,
+		key.CreatedAt.UTC(),
+		nullTime(key.ExpireAt),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create API key: %w", err)
+	}
+
+	return nil
+}
+
+// rowScanner is implemented by [sql.Row] and [sql.Rows].
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanAPIKey reads an API key selected by the queries of the store.
+func scanAPIKey(row rowScanner) (APIKey, error) {
+	var key APIKey
+	var expireAt sql.NullTime
+
+	err := row.Scan(&key.ID, &key.Label, &key.Prefix, &key.Hash, &key.Scopes, &key.CreatedAt, &expireAt)
+	if err != nil {
+		return APIKey{}, err
+	}
+
+	if expireAt.Valid {
+		key.ExpireAt = expireAt.Time
+	}
+
+	return key, nil
+}
+
+// GetAPIKeyByHash retrieves an API key by the hash of its clear key.
+//
+// Parameters:
+//   - hash: The SHA-256 hash of the clear key, encoded in hexadecimal
+//
+// Returns:
+//   - APIKey: The API key
+//   - error: Any error encountered during lookup, [sql.ErrNoRows] if the key doesn't exist
+func (store *SQLStore) GetAPIKeyByHash(hash string) (APIKey, error) {
+	const sqlGetAPIKeyByHash = `
+		SELECT id, label, prefix, hash, scopes, created_at, expire_at
+		FROM api_keys
+		WHERE hash = $1;`
+
+	key, err := scanAPIKey(store.db.QueryRow(store.rebind(sqlGetAPIKeyByHash), hash))
+	if err != nil {
+		return APIKey{}, fmt.Errorf("failed to get API key: %w", err)
+	}
+
+	return key, nil
+}
+
+// ListAPIKeys retrieves every API key, sorted by creation date.
+//
+// Returns:
+//   - []APIKey: The API keys
+//   - error: Any error encountered during the listing
+func (store *SQLStore) ListAPIKeys() ([]APIKey, error) {
+	const sqlListAPIKeys = `
+		SELECT id, label, prefix, hash, scopes, created_at, expire_at
+		FROM api_keys
+		ORDER BY created_at, id;`
+
+	rows, err := store.db.Query(store.rebind(sqlListAPIKeys))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list API keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []APIKey
+
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read API key: %w", err)
+		}
+
+		keys = append(keys, key)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list API keys: %w", err)
+	}
+
+	return keys, nil
+}
+
+// DeleteAPIKey deletes an API key by its ID, revoking it.
+//
+// Parameters:
+//   - id: The ID of the key to delete
+//
+// Returns:
+//   - error: Any error encountered during the deletion, [sql.ErrNoRows] if the key doesn't exist
+func (store *SQLStore) DeleteAPIKey(id uuid.UUID) error {
+	const sqlDeleteAPIKey = `
+		DELETE FROM api_keys
+		WHERE id = $1;`
+
+	result, err := store.db.Exec(store.rebind(sqlDeleteAPIKey), id)
+	if err != nil {
+		return fmt.Errorf("failed to delete API key: %w", err)
+	}
+
+	return checkAffected(result, "delete API key")
+}
@@ -0,0 +1,1074 @@
+//    reddlinks, a simple link shortener written in Go.
+//    Copyright (C) 2025 redd
+//
+//    This program is free software: you can redistribute it and/or modify
+//    it under the terms of the GNU General Public License as published by
+//    the Free Software Foundation, either version 3 of the License, or
+//    (at your option) any later version.
+//
+//    This program is distributed in the hope that it will be useful,
+//    but WITHOUT ANY WARRANTY; without even the implied warranty of
+//    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//    GNU General Public License for more details.
+//
+//    You should have received a copy of the GNU General Public License
+//    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package database
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"go.etcd.io/bbolt"
+)
+
+// Buckets used by the bbolt store.
+//
+// The links bucket maps each short to its JSON encoded [Link],
+// the expiry bucket is an index whose keys are the expiration date followed by the short, sorted by date,
+// the hits bucket maps each short to its JSON encoded [HitStats],
+// the certs bucket maps the name of each cached certificate entry to its data,
+// the rates bucket maps the key of each rate limit token bucket to its JSON encoded [Bucket],
+// the keys bucket maps the hash of each API key to its JSON encoded [APIKey],
+// the users bucket maps the ID of each user to its JSON encoded [User],
+// the usernames bucket is an index mapping the username of each user to its ID,
+// the subjects bucket is an index mapping the OpenID Connect subject of each user having one to its ID,
+// the sessions bucket maps the hash of each session token to its JSON encoded [Session].
+var (
+	boltLinksBucket     = []byte("links")
+	boltExpiryBucket    = []byte("expiry")
+	boltHitsBucket      = []byte("hits")
+	boltCertsBucket     = []byte("certs")
+	boltRatesBucket     = []byte("rates")
+	boltKeysBucket      = []byte("keys")
+	boltUsersBucket     = []byte("users")
+	boltUsernamesBucket = []byte("usernames")
+	boltSubjectsBucket  = []byte("subjects")
+	boltSessionsBucket  = []byte("sessions")
+)
+
+// Settings of the bbolt store.
+const (
+	boltFileMode    = 0o600
+	boltOpenTimeout = 5 * time.Second
+	expiryKeyLength = 12
+)
+
+// BoltStore is a [LinkStore] backed by an embedded bbolt key-value database.
+//
+// It only needs a file, which makes it suited to small single binary deployments.
+// Expired links are found through an index sorted by expiration date, so the garbage collection
+// doesn't need to go through every link.
+type BoltStore struct {
+	db *bbolt.DB
+}
+
+// NewBoltStore opens or creates a bbolt database and its buckets.
+//
+// Parameters:
+//   - path: The path of the database file
+//
+// Returns:
+//   - *BoltStore: The opened store
+//   - error: Any error encountered while opening the file or creating the buckets
+func NewBoltStore(path string) (*BoltStore, error) {
+	dbase, err := bbolt.Open(path, boltFileMode, &bbolt.Options{Timeout: boltOpenTimeout})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open bbolt database: %w", err)
+	}
+
+	err = dbase.Update(func(trans *bbolt.Tx) error {
+		for _, bucket := range [][]byte{
+			boltLinksBucket,
+			boltExpiryBucket,
+			boltHitsBucket,
+			boltCertsBucket,
+			boltRatesBucket,
+			boltKeysBucket,
+			boltUsersBucket,
+			boltUsernamesBucket,
+			boltSubjectsBucket,
+			boltSessionsBucket,
+		} {
+			if _, err := trans.CreateBucketIfNotExists(bucket); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		_ = dbase.Close()
+
+		return nil, fmt.Errorf("failed to create bbolt buckets: %w", err)
+	}
+
+	return &BoltStore{db: dbase}, nil
+}
+
+// expiryKey returns the key of a link within the expiry index.
+//
+// The key starts with the expiration date encoded so that the byte order matches the chronological order:
+// the seconds since the epoch with their sign bit flipped followed by the nanoseconds, both big-endian.
+func expiryKey(expireAt time.Time, short string) []byte {
+	key := make([]byte, expiryKeyLength, expiryKeyLength+len(short))
+	binary.BigEndian.PutUint64(key, uint64(expireAt.Unix())^(1<<63))   //nolint:gosec
+	binary.BigEndian.PutUint32(key[8:], uint32(expireAt.Nanosecond())) //nolint:gosec
+
+	return append(key, short...)
+}
+
+// parseExpiryKey returns the expiration date and the short stored within a key of the expiry index.
+func parseExpiryKey(key []byte) (time.Time, string) {
+	seconds := int64(binary.BigEndian.Uint64(key) ^ (1 << 63))            //nolint:gosec
+	nanoseconds := int64(binary.BigEndian.Uint32(key[8:expiryKeyLength])) //nolint:gosec
+
+	return time.Unix(seconds, nanoseconds).UTC(), string(key[expiryKeyLength:])
+}
+
+// getLink reads a link within a transaction, the returned boolean tells if it exists.
+func getLink(trans *bbolt.Tx, short string) (Link, bool, error) {
+	data := trans.Bucket(boltLinksBucket).Get([]byte(short))
+	if data == nil {
+		return Link{}, false, nil
+	}
+
+	var link Link
+	if err := json.Unmarshal(data, &link); err != nil {
+		return Link{}, false, fmt.Errorf("failed to decode link: %w", err)
+	}
+
+	return link, true, nil
+}
+
+// putLink writes a link and its expiry index entry within a transaction.
+//
+// The index entry of the previous version of the link must have been removed beforehand.
+func putLink(trans *bbolt.Tx, link Link) error {
+	data, err := json.Marshal(link)
+	if err != nil {
+		return fmt.Errorf("failed to encode link: %w", err)
+	}
+
+	if err := trans.Bucket(boltLinksBucket).Put([]byte(link.Short), data); err != nil {
+		return err
+	}
+
+	return trans.Bucket(boltExpiryBucket).Put(expiryKey(link.ExpireAt, link.Short), nil)
+}
+
+// deleteLink removes a link, its expiry index entry and its hits within a transaction.
+func deleteLink(trans *bbolt.Tx, link Link) error {
+	if err := trans.Bucket(boltLinksBucket).Delete([]byte(link.Short)); err != nil {
+		return err
+	}
+
+	if err := trans.Bucket(boltExpiryBucket).Delete(expiryKey(link.ExpireAt, link.Short)); err != nil {
+		return err
+	}
+
+	return trans.Bucket(boltHitsBucket).Delete([]byte(link.Short))
+}
+
+// CreateLink inserts a new link, [ErrShortInUse] is returned if the short is already used.
+func (store *BoltStore) CreateLink(link Link) error {
+	return store.db.Update(func(trans *bbolt.Tx) error {
+		_, exists, err := getLink(trans, link.Short)
+		if err != nil {
+			return err
+		}
+
+		if exists {
+			return fmt.Errorf("failed to create link: %w", ErrShortInUse)
+		}
+
+		if err := putLink(trans, link); err != nil {
+			return fmt.Errorf("failed to create link: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// CreateLinks inserts several new links within a single transaction,
+// [ErrShortInUse] is returned without inserting anything if one of the shorts is already used.
+func (store *BoltStore) CreateLinks(links []Link) error {
+	return store.db.Update(func(trans *bbolt.Tx) error {
+		for _, link := range links {
+			_, exists, err := getLink(trans, link.Short)
+			if err != nil {
+				return err
+			}
+
+			if exists {
+				return fmt.Errorf("failed to create links: %w", ErrShortInUse)
+			}
+
+			if err := putLink(trans, link); err != nil {
+				return fmt.Errorf("failed to create links: %w", err)
+			}
+		}
+
+		return nil
+	})
+}
+
+// ReplaceLinks inserts several links within a single transaction, replacing the existing links having the same shorts
+// along with their hits, nothing is changed if one of them can't be inserted.
+func (store *BoltStore) ReplaceLinks(links []Link) error {
+	return store.db.Update(func(trans *bbolt.Tx) error {
+		for _, link := range links {
+			existing, exists, err := getLink(trans, link.Short)
+			if err != nil {
+				return err
+			}
+
+			if exists {
+				if err := deleteLink(trans, existing); err != nil {
+					return fmt.Errorf("failed to replace links: %w", err)
+				}
+			}
+
+			if err := putLink(trans, link); err != nil {
+				return fmt.Errorf("failed to replace links: %w", err)
+			}
+		}
+
+		return nil
+	})
+}
+
+// viewLink returns a link by its short, an error wrapping [sql.ErrNoRows] is returned if it doesn't exist.
+func (store *BoltStore) viewLink(short, action string) (Link, error) {
+	var link Link
+
+	err := store.db.View(func(trans *bbolt.Tx) error {
+		var (
+			exists bool
+			err    error
+		)
+
+		link, exists, err = getLink(trans, short)
+		if err != nil {
+			return fmt.Errorf("failed to %s: %w", action, err)
+		}
+
+		if !exists {
+			return notFound(action)
+		}
+
+		return nil
+	})
+
+	return link, err
+}
+
+// GetLinkByShort returns a complete link, links that have expired are not found.
+func (store *BoltStore) GetLinkByShort(short string) (Link, error) {
+	link, err := store.viewLink(short, "get link")
+	if err != nil {
+		return Link{}, err
+	}
+
+	if !link.ExpireAt.After(time.Now()) {
+		return Link{}, notFound("get link")
+	}
+
+	return link, nil
+}
+
+// GetURLInfo returns the original URL, the creation date and the expiration date of a link.
+func (store *BoltStore) GetURLInfo(short string) (string, time.Time, time.Time, error) {
+	link, err := store.viewLink(short, "get URL info")
+	if err != nil {
+		return "", time.Time{}, time.Time{}, err
+	}
+
+	return link.URL, link.CreatedAt, link.ExpireAt, nil
+}
+
+// GetURLByShort returns the original URL of a link.
+func (store *BoltStore) GetURLByShort(short string) (string, error) {
+	link, err := store.viewLink(short, "get URL by short code")
+
+	return link.URL, err
+}
+
+// GetHashByShort returns the password hash of a link, empty if it isn't protected.
+func (store *BoltStore) GetHashByShort(short string) (string, error) {
+	link, err := store.viewLink(short, "get password hash")
+
+	return link.Password, err
+}
+
+// GetTokenHashByShort returns the management token hash of a link, empty if it has none.
+func (store *BoltStore) GetTokenHashByShort(short string) (string, error) {
+	link, err := store.viewLink(short, "get token hash")
+
+	return link.Token, err
+}
+
+// UpdateLink replaces the URL, the expiration date and the password hash of a link.
+func (store *BoltStore) UpdateLink(short, url string, expireAt time.Time, password string) error {
+	return store.db.Update(func(trans *bbolt.Tx) error {
+		link, exists, err := getLink(trans, short)
+		if err != nil {
+			return fmt.Errorf("failed to update link: %w", err)
+		}
+
+		if !exists {
+			return notFound("update link")
+		}
+
+		// Move the link within the expiry index
+		if err := trans.Bucket(boltExpiryBucket).Delete(expiryKey(link.ExpireAt, link.Short)); err != nil {
+			return fmt.Errorf("failed to update link: %w", err)
+		}
+
+		link.URL = url
+		link.ExpireAt = expireAt
+		link.Password = password
+
+		if err := putLink(trans, link); err != nil {
+			return fmt.Errorf("failed to update link: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// DeleteLink deletes a link and its hits.
+func (store *BoltStore) DeleteLink(short string) error {
+	return store.db.Update(func(trans *bbolt.Tx) error {
+		link, exists, err := getLink(trans, short)
+		if err != nil {
+			return fmt.Errorf("failed to delete link: %w", err)
+		}
+
+		if !exists {
+			return notFound("delete link")
+		}
+
+		if err := deleteLink(trans, link); err != nil {
+			return fmt.Errorf("failed to delete link: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// RemoveExpiredLinks deletes every expired link and their hits, it returns the number of deleted links.
+//
+// Only the expired entries of the expiry index are read, the index being sorted by expiration date.
+func (store *BoltStore) RemoveExpiredLinks() (int64, error) {
+	now := time.Now()
+
+	var removed int64
+
+	err := store.db.Update(func(trans *bbolt.Tx) error {
+		// Collect the expired links before deleting them, a cursor can't be used while its bucket is modified
+		var expired []Link
+
+		cursor := trans.Bucket(boltExpiryBucket).Cursor()
+		for key, _ := cursor.First(); key != nil; key, _ = cursor.Next() {
+			expireAt, short := parseExpiryKey(key)
+			if expireAt.After(now) {
+				break
+			}
+
+			expired = append(expired, Link{Short: short, ExpireAt: expireAt})
+		}
+
+		for _, link := range expired {
+			if err := deleteLink(trans, link); err != nil {
+				return err
+			}
+		}
+
+		removed = int64(len(expired))
+
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to remove expired links: %w", err)
+	}
+
+	return removed, nil
+}
+
+// ListLinks returns the links matching a filter, the most recent first unless sorted by short,
+// and the number of matching links regardless of the pagination.
+func (store *BoltStore) ListLinks(filter LinkFilter) ([]Link, int, error) {
+	var links []Link
+
+	err := store.db.View(func(trans *bbolt.Tx) error {
+		return trans.Bucket(boltLinksBucket).ForEach(func(_, data []byte) error {
+			var link Link
+			if err := json.Unmarshal(data, &link); err != nil {
+				return err
+			}
+
+			if filter.matches(link) {
+				links = append(links, link)
+			}
+
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list links: %w", err)
+	}
+
+	links, total := filter.page(links)
+
+	return links, total, nil
+}
+
+// DeleteLinks deletes several links and their hits within a single transaction, unknown shorts are ignored.
+func (store *BoltStore) DeleteLinks(shorts []string) (int64, error) {
+	var deleted int64
+
+	err := store.db.Update(func(trans *bbolt.Tx) error {
+		for _, short := range shorts {
+			link, exists, err := getLink(trans, short)
+			if err != nil {
+				return err
+			}
+
+			if !exists {
+				continue
+			}
+
+			if err := deleteLink(trans, link); err != nil {
+				return err
+			}
+
+			deleted++
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete links: %w", err)
+	}
+
+	return deleted, nil
+}
+
+// ExpireLinks sets the expiration date of several links within a single transaction, unknown shorts are ignored.
+func (store *BoltStore) ExpireLinks(shorts []string, expireAt time.Time) (int64, error) {
+	var expired int64
+
+	err := store.db.Update(func(trans *bbolt.Tx) error {
+		for _, short := range shorts {
+			link, exists, err := getLink(trans, short)
+			if err != nil {
+				return err
+			}
+
+			if !exists {
+				continue
+			}
+
+			if err := trans.Bucket(boltExpiryBucket).Delete(expiryKey(link.ExpireAt, link.Short)); err != nil {
+				return err
+			}
+
+			link.ExpireAt = expireAt
+
+			if err := putLink(trans, link); err != nil {
+				return err
+			}
+
+			expired++
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to expire links: %w", err)
+	}
+
+	return expired, nil
+}
+
+// getHitStats reads the aggregated accesses of a link within a transaction, empty ones if there are none.
+func getHitStats(trans *bbolt.Tx, short string) (HitStats, error) {
+	stats := HitStats{
+		Referrers: make(map[string]int),
+		Agents:    make(map[string]int),
+	}
+
+	data := trans.Bucket(boltHitsBucket).Get([]byte(short))
+	if data == nil {
+		return stats, nil
+	}
+
+	if err := json.Unmarshal(data, &stats); err != nil {
+		return HitStats{}, fmt.Errorf("failed to decode hits: %w", err)
+	}
+
+	return stats, nil
+}
+
+// AddHits counts a batch of hits within a single transaction, the ones of unknown links are ignored.
+func (store *BoltStore) AddHits(hits []Hit) error {
+	err := store.db.Update(func(trans *bbolt.Tx) error {
+		// Aggregate the batch before writing each link's accesses once
+		batch := make(map[string]HitStats)
+
+		for _, hit := range hits {
+			stats, exists := batch[hit.Short]
+			if !exists {
+				if trans.Bucket(boltLinksBucket).Get([]byte(hit.Short)) == nil {
+					continue
+				}
+
+				var err error
+				if stats, err = getHitStats(trans, hit.Short); err != nil {
+					return err
+				}
+			}
+
+			stats.Total++
+			stats.Referrers[hit.Referrer]++
+			stats.Agents[hit.Agent]++
+			batch[hit.Short] = stats
+		}
+
+		for short, stats := range batch {
+			data, err := json.Marshal(stats)
+			if err != nil {
+				return err
+			}
+
+			if err := trans.Bucket(boltHitsBucket).Put([]byte(short), data); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to add hits: %w", err)
+	}
+
+	return nil
+}
+
+// GetHitStats returns the aggregated accesses of a link, only the most common referrers are returned.
+func (store *BoltStore) GetHitStats(short string) (HitStats, error) {
+	var stats HitStats
+
+	err := store.db.View(func(trans *bbolt.Tx) error {
+		var err error
+		stats, err = getHitStats(trans, short)
+
+		return err
+	})
+	if err != nil {
+		return HitStats{}, fmt.Errorf("failed to get hit stats: %w", err)
+	}
+
+	stats.Referrers = topReferrers(stats.Referrers)
+
+	return stats, nil
+}
+
+// GetCert returns the data of a cached certificate entry, [sql.ErrNoRows] is returned if it doesn't exist.
+func (store *BoltStore) GetCert(name string) ([]byte, error) {
+	var data []byte
+
+	err := store.db.View(func(trans *bbolt.Tx) error {
+		stored := trans.Bucket(boltCertsBucket).Get([]byte(name))
+		if stored == nil {
+			return notFound("get cert")
+		}
+
+		// The stored slice is only valid during the transaction
+		data = append([]byte(nil), stored...)
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// PutCert inserts or replaces a cached certificate entry.
+func (store *BoltStore) PutCert(name string, data []byte) error {
+	err := store.db.Update(func(trans *bbolt.Tx) error {
+		return trans.Bucket(boltCertsBucket).Put([]byte(name), data)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to put cert: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteCert deletes a cached certificate entry, nothing happens if it doesn't exist.
+func (store *BoltStore) DeleteCert(name string) error {
+	err := store.db.Update(func(trans *bbolt.Tx) error {
+		return trans.Bucket(boltCertsBucket).Delete([]byte(name))
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete cert: %w", err)
+	}
+
+	return nil
+}
+
+// getBucket returns a token bucket within a transaction, the boolean is false if it doesn't exist.
+func getBucket(trans *bbolt.Tx, key string) (Bucket, bool, error) {
+	data := trans.Bucket(boltRatesBucket).Get([]byte(key))
+	if data == nil {
+		return Bucket{}, false, nil
+	}
+
+	var bucket Bucket
+	if err := json.Unmarshal(data, &bucket); err != nil {
+		return Bucket{}, false, fmt.Errorf("failed to decode bucket: %w", err)
+	}
+
+	return bucket, true, nil
+}
+
+// GetBucket returns a token bucket, [sql.ErrNoRows] is returned if it doesn't exist.
+func (store *BoltStore) GetBucket(key string) (Bucket, error) {
+	var bucket Bucket
+
+	err := store.db.View(func(trans *bbolt.Tx) error {
+		stored, exists, err := getBucket(trans, key)
+		if err != nil {
+			return err
+		}
+
+		if !exists {
+			return notFound("get bucket")
+		}
+
+		bucket = stored
+
+		return nil
+	})
+	if err != nil {
+		return Bucket{}, err
+	}
+
+	return bucket, nil
+}
+
+// SaveBucket inserts or updates a token bucket, [ErrBucketConflict] is returned if its version changed.
+func (store *BoltStore) SaveBucket(bucket Bucket) error {
+	err := store.db.Update(func(trans *bbolt.Tx) error {
+		stored, _, err := getBucket(trans, bucket.Key)
+		if err != nil {
+			return err
+		}
+
+		if stored.Version != bucket.Version {
+			return ErrBucketConflict
+		}
+
+		bucket.Version++
+
+		data, err := json.Marshal(bucket)
+		if err != nil {
+			return err
+		}
+
+		return trans.Bucket(boltRatesBucket).Put([]byte(bucket.Key), data)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save bucket: %w", err)
+	}
+
+	return nil
+}
+
+// RemoveIdleBuckets deletes the token buckets that weren't updated since a date.
+func (store *BoltStore) RemoveIdleBuckets(before time.Time) (int64, error) {
+	var removed int64
+
+	err := store.db.Update(func(trans *bbolt.Tx) error {
+		var idle [][]byte
+
+		err := trans.Bucket(boltRatesBucket).ForEach(func(key, data []byte) error {
+			var bucket Bucket
+			if err := json.Unmarshal(data, &bucket); err != nil {
+				return fmt.Errorf("failed to decode bucket: %w", err)
+			}
+
+			if bucket.UpdatedAt.Before(before) {
+				idle = append(idle, append([]byte(nil), key...))
+			}
+
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		// Keys can't be deleted while iterating
+		for _, key := range idle {
+			if err := trans.Bucket(boltRatesBucket).Delete(key); err != nil {
+				return err
+			}
+		}
+
+		removed = int64(len(idle))
+
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to remove idle buckets: %w", err)
+	}
+
+	return removed, nil
+}
+
+// CreateAPIKey inserts a new API key, an error is returned if another key has the same hash.
+func (store *BoltStore) CreateAPIKey(key APIKey) error {
+	err := store.db.Update(func(trans *bbolt.Tx) error {
+		if trans.Bucket(boltKeysBucket).Get([]byte(key.Hash)) != nil {
+			return errDuplicateKey
+		}
+
+		data, err := json.Marshal(key)
+		if err != nil {
+			return err
+		}
+
+		return trans.Bucket(boltKeysBucket).Put([]byte(key.Hash), data)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create API key: %w", err)
+	}
+
+	return nil
+}
+
+// GetAPIKeyByHash returns the API key having a hash, [sql.ErrNoRows] is returned if it doesn't exist.
+func (store *BoltStore) GetAPIKeyByHash(hash string) (APIKey, error) {
+	var key APIKey
+
+	err := store.db.View(func(trans *bbolt.Tx) error {
+		data := trans.Bucket(boltKeysBucket).Get([]byte(hash))
+		if data == nil {
+			return notFound("get API key")
+		}
+
+		if err := json.Unmarshal(data, &key); err != nil {
+			return fmt.Errorf("failed to decode API key: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return APIKey{}, err
+	}
+
+	return key, nil
+}
+
+// ListAPIKeys returns every API key, sorted by creation date.
+func (store *BoltStore) ListAPIKeys() ([]APIKey, error) {
+	var keys []APIKey
+
+	err := store.db.View(func(trans *bbolt.Tx) error {
+		return trans.Bucket(boltKeysBucket).ForEach(func(_, data []byte) error {
+			var key APIKey
+			if err := json.Unmarshal(data, &key); err != nil {
+				return fmt.Errorf("failed to decode API key: %w", err)
+			}
+
+			keys = append(keys, key)
+
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list API keys: %w", err)
+	}
+
+	sortAPIKeys(keys)
+
+	return keys, nil
+}
+
+// DeleteAPIKey deletes an API key by its ID, [sql.ErrNoRows] is returned if it doesn't exist.
+//
+// The keys are stored by hash, the deletion goes through every key, there are only a few of them.
+func (store *BoltStore) DeleteAPIKey(id uuid.UUID) error {
+	return store.db.Update(func(trans *bbolt.Tx) error {
+		cursor := trans.Bucket(boltKeysBucket).Cursor()
+
+		for hash, data := cursor.First(); hash != nil; hash, data = cursor.Next() {
+			var key APIKey
+			if err := json.Unmarshal(data, &key); err != nil {
+				return fmt.Errorf("failed to decode API key: %w", err)
+			}
+
+			if key.ID == id {
+				return cursor.Delete()
+			}
+		}
+
+		return notFound("delete API key")
+	})
+}
+
+// CreateUser inserts a new user and indexes its username and its subject, [ErrUsernameInUse] is returned
+// if the username is already used.
+func (store *BoltStore) CreateUser(user User) error {
+	err := store.db.Update(func(trans *bbolt.Tx) error {
+		usernames := trans.Bucket(boltUsernamesBucket)
+		if usernames.Get([]byte(user.Username)) != nil {
+			return ErrUsernameInUse
+		}
+
+		subjects := trans.Bucket(boltSubjectsBucket)
+		if user.Subject != "" && subjects.Get([]byte(user.Subject)) != nil {
+			return ErrSubjectInUse
+		}
+
+		data, err := json.Marshal(user)
+		if err != nil {
+			return err
+		}
+
+		if err := trans.Bucket(boltUsersBucket).Put([]byte(user.ID.String()), data); err != nil {
+			return err
+		}
+
+		if user.Subject != "" {
+			if err := subjects.Put([]byte(user.Subject), []byte(user.ID.String())); err != nil {
+				return err
+			}
+		}
+
+		return usernames.Put([]byte(user.Username), []byte(user.ID.String()))
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create user: %w", err)
+	}
+
+	return nil
+}
+
+// getUser reads a user by its ID within a transaction, [sql.ErrNoRows] is returned if it doesn't exist.
+func getUser(trans *bbolt.Tx, id []byte) (User, error) {
+	data := trans.Bucket(boltUsersBucket).Get(id)
+	if data == nil {
+		return User{}, notFound("get user")
+	}
+
+	var user User
+	if err := json.Unmarshal(data, &user); err != nil {
+		return User{}, fmt.Errorf("failed to decode user: %w", err)
+	}
+
+	return user, nil
+}
+
+// GetUserByID returns a user, [sql.ErrNoRows] is returned if it doesn't exist.
+func (store *BoltStore) GetUserByID(id uuid.UUID) (User, error) {
+	var user User
+
+	err := store.db.View(func(trans *bbolt.Tx) error {
+		var err error
+		user, err = getUser(trans, []byte(id.String()))
+
+		return err
+	})
+
+	return user, err
+}
+
+// GetUserByName returns the user having a username using the usernames index,
+// [sql.ErrNoRows] is returned if it doesn't exist.
+func (store *BoltStore) GetUserByName(username string) (User, error) {
+	var user User
+
+	err := store.db.View(func(trans *bbolt.Tx) error {
+		id := trans.Bucket(boltUsernamesBucket).Get([]byte(username))
+		if id == nil {
+			return notFound("get user")
+		}
+
+		var err error
+		user, err = getUser(trans, id)
+
+		return err
+	})
+
+	return user, err
+}
+
+// GetUserBySubject returns the user having an OpenID Connect subject using the subjects index,
+// [sql.ErrNoRows] is returned if it doesn't exist.
+func (store *BoltStore) GetUserBySubject(subject string) (User, error) {
+	var user User
+
+	err := store.db.View(func(trans *bbolt.Tx) error {
+		id := trans.Bucket(boltSubjectsBucket).Get([]byte(subject))
+		if id == nil {
+			return notFound("get user")
+		}
+
+		var err error
+		user, err = getUser(trans, id)
+
+		return err
+	})
+
+	return user, err
+}
+
+// UpdateUserRoles replaces the roles of a user, [sql.ErrNoRows] is returned if it doesn't exist.
+func (store *BoltStore) UpdateUserRoles(id uuid.UUID, roles string) error {
+	err := store.db.Update(func(trans *bbolt.Tx) error {
+		user, err := getUser(trans, []byte(id.String()))
+		if err != nil {
+			return err
+		}
+
+		user.Roles = roles
+
+		data, err := json.Marshal(user)
+		if err != nil {
+			return err
+		}
+
+		return trans.Bucket(boltUsersBucket).Put([]byte(id.String()), data)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update user roles: %w", err)
+	}
+
+	return nil
+}
+
+// CreateSession inserts a new session.
+func (store *BoltStore) CreateSession(session Session) error {
+	err := store.db.Update(func(trans *bbolt.Tx) error {
+		data, err := json.Marshal(session)
+		if err != nil {
+			return err
+		}
+
+		return trans.Bucket(boltSessionsBucket).Put([]byte(session.Hash), data)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create session: %w", err)
+	}
+
+	return nil
+}
+
+// GetSession returns the session having a hash, [sql.ErrNoRows] is returned if it doesn't exist or has expired.
+func (store *BoltStore) GetSession(hash string) (Session, error) {
+	var session Session
+
+	err := store.db.View(func(trans *bbolt.Tx) error {
+		data := trans.Bucket(boltSessionsBucket).Get([]byte(hash))
+		if data == nil {
+			return notFound("get session")
+		}
+
+		if err := json.Unmarshal(data, &session); err != nil {
+			return fmt.Errorf("failed to decode session: %w", err)
+		}
+
+		if !session.ExpireAt.After(time.Now()) {
+			return notFound("get session")
+		}
+
+		return nil
+	})
+	if err != nil {
+		return Session{}, err
+	}
+
+	return session, nil
+}
+
+// DeleteSession deletes a session, [sql.ErrNoRows] is returned if it doesn't exist.
+func (store *BoltStore) DeleteSession(hash string) error {
+	return store.db.Update(func(trans *bbolt.Tx) error {
+		sessions := trans.Bucket(boltSessionsBucket)
+		if sessions.Get([]byte(hash)) == nil {
+			return notFound("delete session")
+		}
+
+		return sessions.Delete([]byte(hash))
+	})
+}
+
+// RemoveExpiredSessions deletes every expired session and returns how many were deleted.
+//
+// The sessions aren't indexed by expiration date, the removal goes through every session.
+func (store *BoltStore) RemoveExpiredSessions() (int64, error) {
+	var removed int64
+
+	err := store.db.Update(func(trans *bbolt.Tx) error {
+		var expired [][]byte
+
+		now := time.Now()
+
+		err := trans.Bucket(boltSessionsBucket).ForEach(func(hash, data []byte) error {
+			var session Session
+			if err := json.Unmarshal(data, &session); err != nil {
+				return fmt.Errorf("failed to decode session: %w", err)
+			}
+
+			if !session.ExpireAt.After(now) {
+				expired = append(expired, append([]byte(nil), hash...))
+			}
+
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		// Keys can't be deleted while iterating
+		for _, hash := range expired {
+			if err := trans.Bucket(boltSessionsBucket).Delete(hash); err != nil {
+				return err
+			}
+		}
+
+		removed = int64(len(expired))
+
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to remove expired sessions: %w", err)
+	}
+
+	return removed, nil
+}
+
+// Ping checks that the database file is still open, the context is only checked beforehand
+// since reading the file doesn't block.
+func (store *BoltStore) Ping(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return store.db.View(func(*bbolt.Tx) error { return nil })
+}
+
+// Close closes the database file.
+func (store *BoltStore) Close() error {
+	return store.db.Close()
+}
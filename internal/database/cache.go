@@ -0,0 +1,222 @@
+//    reddlinks, a simple link shortener written in Go.
+//    Copyright (C) 2025 redd
+//
+//    This program is free software: you can redistribute it and/or modify
+//    it under the terms of the GNU General Public License as published by
+//    the Free Software Foundation, either version 3 of the License, or
+//    (at your option) any later version.
+//
+//    This program is distributed in the hope that it will be useful,
+//    but WITHOUT ANY WARRANTY; without even the implied warranty of
+//    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//    GNU General Public License for more details.
+//
+//    You should have received a copy of the GNU General Public License
+//    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package database
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// CacheStats defines the counters of a [CachedStore].
+//
+// Hits is the number of lookups answered by the cache,
+// Misses is the number of lookups that had to go to the store,
+// Size is the number of cached links,
+// Capacity is the maximum number of cached links.
+type CacheStats struct {
+	Hits     uint64
+	Misses   uint64
+	Size     int
+	Capacity int
+}
+
+// CachedStore is a [LinkStore] keeping the most recently accessed links in memory in front of another store.
+//
+// Links are cached on their first lookup through [CachedStore.GetLinkByShort], the other lookups always go
+// to the store. The least recently used link is evicted when the cache is full. Entries are dropped once
+// their link expires, and when their link is updated, deleted or garbage collected through the cache.
+// Changes made to the underlying store by other instances are not seen until the entry is dropped.
+type CachedStore struct {
+	LinkStore
+
+	mutex    sync.Mutex
+	capacity int
+	entries  map[string]*list.Element
+	order    *list.List
+	version  uint64
+	hits     uint64
+	misses   uint64
+}
+
+// Make sure the cache satisfies the interface.
+var _ LinkStore = (*CachedStore)(nil)
+
+// NewCachedStore returns a [CachedStore] in front of a given store.
+//
+// Parameters:
+//   - store: The store holding the links
+//   - capacity: The maximum number of cached links, it must be positive
+//
+// Returns:
+//   - *CachedStore: The cache in front of the store
+func NewCachedStore(store LinkStore, capacity int) *CachedStore {
+	return &CachedStore{
+		LinkStore: store,
+		capacity:  capacity,
+		entries:   make(map[string]*list.Element, capacity),
+		order:     list.New(),
+	}
+}
+
+// Stats returns the current counters of the cache.
+func (cache *CachedStore) Stats() CacheStats {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	return CacheStats{
+		Hits:     cache.hits,
+		Misses:   cache.misses,
+		Size:     cache.order.Len(),
+		Capacity: cache.capacity,
+	}
+}
+
+// GetLinkByShort returns a complete link, reading it from the store on a miss.
+//
+// Expired links are dropped from the cache and are not found, lookup errors are returned as is and are not cached.
+func (cache *CachedStore) GetLinkByShort(short string) (Link, error) {
+	cache.mutex.Lock()
+
+	if element, exists := cache.entries[short]; exists {
+		link, _ := element.Value.(Link)
+
+		if time.Now().Before(link.ExpireAt) {
+			cache.order.MoveToFront(element)
+			cache.hits++
+			cache.mutex.Unlock()
+
+			return link, nil
+		}
+
+		cache.removeElement(element)
+	}
+
+	cache.misses++
+	version := cache.version
+	cache.mutex.Unlock()
+
+	// Read the link from the store
+	link, err := cache.LinkStore.GetLinkByShort(short)
+	if err != nil {
+		return Link{}, err
+	}
+
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	// Don't cache what was read if links were changed in the meantime, it may already be outdated
+	if version != cache.version {
+		return link, nil
+	}
+
+	if element, exists := cache.entries[short]; exists {
+		cache.removeElement(element)
+	}
+
+	cache.entries[short] = cache.order.PushFront(link)
+
+	// Evict the least recently used link if the cache is full
+	if cache.order.Len() > cache.capacity {
+		cache.removeElement(cache.order.Back())
+	}
+
+	return link, nil
+}
+
+// removeElement removes an entry from the cache, the mutex must be held.
+func (cache *CachedStore) removeElement(element *list.Element) {
+	link, _ := cache.order.Remove(element).(Link)
+	delete(cache.entries, link.Short)
+}
+
+// invalidate drops the entries of the given links, or every expired entry if no short is given.
+func (cache *CachedStore) invalidate(shorts ...string) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	cache.version++
+
+	if len(shorts) != 0 {
+		for _, short := range shorts {
+			if element, exists := cache.entries[short]; exists {
+				cache.removeElement(element)
+			}
+		}
+
+		return
+	}
+
+	now := time.Now()
+
+	for element := cache.order.Front(); element != nil; {
+		next := element.Next()
+
+		if link, _ := element.Value.(Link); !now.Before(link.ExpireAt) {
+			cache.removeElement(element)
+		}
+
+		element = next
+	}
+}
+
+// UpdateLink updates a link in the store and drops its entry.
+func (cache *CachedStore) UpdateLink(short, url string, expireAt time.Time, password string) error {
+	defer cache.invalidate(short)
+
+	return cache.LinkStore.UpdateLink(short, url, expireAt, password)
+}
+
+// ReplaceLinks replaces several links in the store and drops their entries.
+func (cache *CachedStore) ReplaceLinks(links []Link) error {
+	shorts := make([]string, 0, len(links))
+	for _, link := range links {
+		shorts = append(shorts, link.Short)
+	}
+
+	defer cache.invalidate(shorts...)
+
+	return cache.LinkStore.ReplaceLinks(links)
+}
+
+// DeleteLink deletes a link from the store and drops its entry.
+func (cache *CachedStore) DeleteLink(short string) error {
+	defer cache.invalidate(short)
+
+	return cache.LinkStore.DeleteLink(short)
+}
+
+// RemoveExpiredLinks deletes the expired links from the store and drops the expired entries.
+func (cache *CachedStore) RemoveExpiredLinks() (int64, error) {
+	defer cache.invalidate()
+
+	return cache.LinkStore.RemoveExpiredLinks()
+}
+
+// DeleteLinks deletes several links from the store and drops their entries.
+func (cache *CachedStore) DeleteLinks(shorts []string) (int64, error) {
+	defer cache.invalidate(shorts...)
+
+	return cache.LinkStore.DeleteLinks(shorts)
+}
+
+// ExpireLinks sets the expiration date of several links in the store and drops their entries.
+func (cache *CachedStore) ExpireLinks(shorts []string, expireAt time.Time) (int64, error) {
+	defer cache.invalidate(shorts...)
+
+	return cache.LinkStore.ExpireLinks(shorts, expireAt)
+}
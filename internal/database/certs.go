@@ -0,0 +1,105 @@
+//    reddlinks, a simple link shortener written in Go.
+//    Copyright (C) 2025 redd
+//
+//    This program is free software: you can redistribute it and/or modify
+//    it under the terms of the GNU General Public License as published by
+//    the Free Software Foundation, either version 3 of the License, or
+//    (at your option) any later version.
+//
+//    This program is distributed in the hope that it will be useful,
+//    but WITHOUT ANY WARRANTY; without even the implied warranty of
+//    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//    GNU General Public License for more details.
+//
+//    You should have received a copy of the GNU General Public License
+//    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package database
+
+import (
+	"fmt"
+	"time"
+)
+
+// GetCert retrieves a cached certificate entry by its name.
+//
+// Parameters:
+//   - name: The name of the entry
+//
+// Returns:
+//   - []byte: The data of the entry
+//   - error: Any error encountered during lookup, [sql.ErrNoRows] if the entry doesn't exist
+func (store *SQLStore) GetCert(name string) ([]byte, error) {
+	const sqlGetCert = `
+		SELECT data
+		FROM cert_cache
+		WHERE name = $1;`
+
+	var data []byte
+	if err := store.db.QueryRow(store.rebind(sqlGetCert), name).Scan(&data); err != nil {
+		return nil, fmt.Errorf("failed to get cert: %w", err)
+	}
+
+	return data, nil
+}
+
+// PutCert inserts or replaces a cached certificate entry within a single transaction.
+//
+// Parameters:
+//   - name: The name of the entry
+//   - data: The data of the entry
+//
+// Returns:
+//   - error: Any error encountered during the insertion
+func (store *SQLStore) PutCert(name string, data []byte) error {
+	const sqlDeleteCert = `
+		DELETE FROM cert_cache
+		WHERE name = $1;`
+
+	const sqlInsertCert = `
+		INSERT INTO cert_cache (name, data, updated_at)
+		VALUES ($1, $2, $3);`
+
+	trans, err := store.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	// Replace the previous version of the entry, the upsert syntax isn't the same for every database type
+	if _, err := trans.Exec(store.rebind(sqlDeleteCert), name); err != nil {
+		_ = trans.Rollback()
+
+		return fmt.Errorf("failed to replace cert: %w", err)
+	}
+
+	if _, err := trans.Exec(store.rebind(sqlInsertCert), name, data, time.Now().UTC()); err != nil {
+		_ = trans.Rollback()
+
+		return fmt.Errorf("failed to put cert: %w", err)
+	}
+
+	if err := trans.Commit(); err != nil {
+		return fmt.Errorf("failed to commit cert: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteCert deletes a cached certificate entry by its name, nothing happens if it doesn't exist.
+//
+// Parameters:
+//   - name: The name of the entry
+//
+// Returns:
+//   - error: Any error encountered during the deletion
+func (store *SQLStore) DeleteCert(name string) error {
+	const sqlDeleteCert = `
+		DELETE FROM cert_cache
+		WHERE name = $1;`
+
+	if _, err := store.db.Exec(store.rebind(sqlDeleteCert), name); err != nil {
+		return fmt.Errorf("failed to delete cert: %w", err)
+	}
+
+	return nil
+}
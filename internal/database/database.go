@@ -16,7 +16,7 @@
 
 // Package database provides utilities for database connection management and query operations.
 //
-// This package supports both PostgreSQL and SQLite database systems, handling
+// This package supports PostgreSQL, SQLite and MySQL/MariaDB database systems, handling
 // connection establishment and maintenance for URL shortening services.
 package database
 
@@ -24,21 +24,24 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"net"
+	"time"
 
-	"github.com/lib/pq"    // Driver for PostgreSQL
-	_ "modernc.org/sqlite" // Driver for SQLite3
+	"github.com/go-sql-driver/mysql" // Driver for MySQL and MariaDB
+	"github.com/lib/pq"              // Driver for PostgreSQL
+	_ "modernc.org/sqlite"           // Driver for SQLite3
 )
 
 // DBConnect establishes a connection to the specified database.
 //
-// This function initiates a connection to either a PostgreSQL or SQLite database
-// using the provided parameters.
+// This function initiates a connection to either a PostgreSQL, SQLite or MySQL database
+// using the provided parameters, MySQL connections are handled by [mysqlConnect].
 //
 // The function accepts either a complete connection URL or individual connection
 // parameters. If both are provided, the URL takes precedence.
 //
 // Parameters:
-//   - dbType: Database type ("postgres" or "sqlite")
+//   - dbType: Database type ("postgres", "sqlite" or "mysql")
 //   - dbURL: Complete database connection URL (optional if individual parameters provided)
 //   - dbUser: Database username (used if dbURL is empty)
 //   - dbPass: Database password (used if dbURL is empty)
@@ -50,6 +53,10 @@ import (
 //   - *sql.DB: A database connection object
 //   - error: Any error encountered during connection establishment
 func DBConnect(dbType, dbURL, dbUser, dbPass, dbHost, dbPort, dbName string) (*sql.DB, error) { //nolint:cyclop
+	if dbType == "mysql" {
+		return mysqlConnect(dbURL, dbUser, dbPass, dbHost, dbPort, dbName)
+	}
+
 	var (
 		dbase *sql.DB
 		err   error
@@ -132,3 +139,59 @@ func DBConnect(dbType, dbURL, dbUser, dbPass, dbHost, dbPort, dbName string) (*s
 
 	return dbase, nil
 }
+
+// mysqlConnect establishes a connection to a MySQL or MariaDB database.
+//
+// The connection URL is a DSN as understood by the driver, e.g. "user:password@tcp(host:3306)/name".
+// Whatever the given settings, dates are parsed and stored in UTC, multiple statements
+// are allowed, as some migrations contain several of them, and the rows matched by an update
+// are reported as affected even if unchanged, like the other databases do.
+//
+// Parameters:
+//   - dbURL: Complete database DSN (optional if individual parameters provided)
+//   - dbUser: Database username (used if dbURL is empty)
+//   - dbPass: Database password (used if dbURL is empty)
+//   - dbHost: Database host address (used if dbURL is empty)
+//   - dbPort: Database port number (used if dbURL is empty)
+//   - dbName: Database name (used if dbURL is empty)
+//
+// Returns:
+//   - *sql.DB: A database connection object
+//   - error: Any error encountered during connection establishment
+func mysqlConnect(dbURL, dbUser, dbPass, dbHost, dbPort, dbName string) (*sql.DB, error) {
+	config := mysql.NewConfig()
+
+	if dbURL != "" {
+		var err error
+		if config, err = mysql.ParseDSN(dbURL); err != nil {
+			return nil, fmt.Errorf("failed to parse MySQL connection string: %w", err)
+		}
+	} else {
+		config.User = dbUser
+		config.Passwd = dbPass
+		config.Net = "tcp"
+		config.Addr = net.JoinHostPort(dbHost, dbPort)
+		config.DBName = dbName
+	}
+
+	config.ParseTime = true
+	config.Loc = time.UTC
+	config.MultiStatements = true
+	config.ClientFoundRows = true
+
+	connector, err := mysql.NewConnector(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open MySQL connection: %w", err)
+	}
+
+	dbase := sql.OpenDB(connector)
+
+	// Test the connection
+	if err := dbase.Ping(); err != nil {
+		_ = dbase.Close()
+
+		return nil, fmt.Errorf("database connection test failed: %w", err)
+	}
+
+	return dbase, nil
+}
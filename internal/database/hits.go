@@ -0,0 +1,183 @@
+//    reddlinks, a simple link shortener written in Go.
+//    Copyright (C) 2025 redd
+//
+//    This program is free software: you can redistribute it and/or modify
+//    it under the terms of the GNU General Public License as published by
+//    the Free Software Foundation, either version 3 of the License, or
+//    (at your option) any later version.
+//
+//    This program is distributed in the hope that it will be useful,
+//    but WITHOUT ANY WARRANTY; without even the implied warranty of
+//    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//    GNU General Public License for more details.
+//
+//    You should have received a copy of the GNU General Public License
+//    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// maxReferrers is the maximum number of referrers returned by GetHitStats.
+const maxReferrers = 10
+
+// Hit defines a single access to a link.
+//
+// Short is the short of the accessed link,
+// HitAt is the hourly bucket in which the access happened,
+// Referrer is the host of the referring page (empty for direct accesses),
+// Agent is the class of the client (browser, mobile, bot, cli or other).
+type Hit struct {
+	Short    string
+	HitAt    time.Time
+	Referrer string
+	Agent    string
+}
+
+// HitStats defines the aggregated accesses of a link.
+//
+// Total is the total number of accesses,
+// Referrers is the number of accesses per referrer host, limited to the most common ones,
+// Agents is the number of accesses per client class.
+type HitStats struct {
+	Total     int
+	Referrers map[string]int
+	Agents    map[string]int
+}
+
+// AddHits inserts a batch of hits within a single transaction.
+//
+// The link identifier is resolved from the short at insertion time,
+// hits of links that don't exist anymore are ignored.
+//
+// Parameters:
+//   - hits: The hits to insert
+//
+// Returns:
+//   - error: Any error encountered during the insertion
+func (store *SQLStore) AddHits(hits []Hit) error {
+	const sqlAddHit = `
+		INSERT INTO link_hits (link_id, hit_at, referrer, agent)
+		SELECT id, $1, $2, $3
+		FROM links
+		WHERE short = $4;`
+
+	trans, err := store.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	stmt, err := trans.Prepare(store.rebind(sqlAddHit))
+	if err != nil {
+		_ = trans.Rollback()
+
+		return fmt.Errorf("failed to prepare hit insertion: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, hit := range hits {
+		if _, err := stmt.Exec(hit.HitAt, hit.Referrer, hit.Agent, hit.Short); err != nil {
+			_ = trans.Rollback()
+
+			return fmt.Errorf("failed to add hit: %w", err)
+		}
+	}
+
+	if err := trans.Commit(); err != nil {
+		return fmt.Errorf("failed to commit hits: %w", err)
+	}
+
+	return nil
+}
+
+// GetHitStats retrieves the aggregated accesses of a link by its short.
+//
+// Parameters:
+//   - short: The shortened URL to look up
+//
+// Returns:
+//   - HitStats: The aggregated accesses
+//   - error: Any error encountered during lookup
+func (store *SQLStore) GetHitStats(short string) (HitStats, error) {
+	const sqlCountHits = `
+		SELECT COUNT(*)
+		FROM link_hits
+		JOIN links ON links.id = link_hits.link_id
+		WHERE links.short = $1;`
+
+	stats := HitStats{
+		Referrers: make(map[string]int),
+		Agents:    make(map[string]int),
+	}
+
+	if err := store.db.QueryRow(store.rebind(sqlCountHits), short).Scan(&stats.Total); err != nil {
+		return HitStats{}, fmt.Errorf("failed to count hits: %w", err)
+	}
+
+	const sqlGroupReferrers = `
+		SELECT link_hits.referrer, COUNT(*) AS hits
+		FROM link_hits
+		JOIN links ON links.id = link_hits.link_id
+		WHERE links.short = $1
+		GROUP BY link_hits.referrer
+		ORDER BY hits DESC
+		LIMIT $2;`
+
+	if err := scanGroups(store.db, stats.Referrers, store.rebind(sqlGroupReferrers), short, maxReferrers); err != nil {
+		return HitStats{}, fmt.Errorf("failed to group hits by referrer: %w", err)
+	}
+
+	const sqlGroupAgents = `
+		SELECT link_hits.agent, COUNT(*)
+		FROM link_hits
+		JOIN links ON links.id = link_hits.link_id
+		WHERE links.short = $1
+		GROUP BY link_hits.agent;`
+
+	if err := scanGroups(store.db, stats.Agents, store.rebind(sqlGroupAgents), short); err != nil {
+		return HitStats{}, fmt.Errorf("failed to group hits by agent: %w", err)
+	}
+
+	return stats, nil
+}
+
+// scanGroups runs a query returning (key, count) rows and stores them in the given map.
+func scanGroups(dbase *sql.DB, groups map[string]int, query string, args ...any) error {
+	rows, err := dbase.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			key   string
+			count int
+		)
+
+		if err := rows.Scan(&key, &count); err != nil {
+			return err
+		}
+
+		groups[key] = count
+	}
+
+	return rows.Err()
+}
+
+// removeOrphanHits deletes the hits of links that don't exist anymore.
+func removeOrphanHits(dbase *sql.DB) error {
+	const sqlRemoveOrphanHits = `
+		DELETE FROM link_hits
+		WHERE link_id NOT IN (SELECT id FROM links);`
+
+	if _, err := dbase.Exec(sqlRemoveOrphanHits); err != nil {
+		return fmt.Errorf("failed to remove orphan hits: %w", err)
+	}
+
+	return nil
+}
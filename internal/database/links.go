@@ -19,211 +19,339 @@ package database
 import (
 	"database/sql"
 	"fmt"
-	"log"
+	"log/slog"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/redds-be/reddlinks/internal/migrations"
 )
 
-// indexLinks creates indexes on the links table to improve query performance.
+// Link defines a complete link record.
+//
+// ID is the unique identifier of the link,
+// CreatedAt is the date at which the link was created,
+// ExpireAt is the date at which the link will expire,
+// URL is the original URL,
+// Short is the short string used in the shortened URL,
+// Password is the password hash (empty string if none),
+// Token is the management token hash (empty string if none),
+// Owner is the ID of the user who created the link ([uuid.Nil] if it was created anonymously).
+type Link struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	ExpireAt  time.Time
+	URL       string
+	Short     string
+	Password  string
+	Token     string
+	Owner     uuid.UUID
+}
+
+// CreateLinksTable brings the database schema up to date, creating the links table if it doesn't exist.
+//
+// The schema is managed by the versioned migrations of the [migrations] package, see [migrations.Up].
+// The maximum length for the short string follows the provided configuration, with PostgreSQL and MySQL,
+// the short column is resized when this configuration changes.
 //
 // Parameters:
 //   - dbase: A pointer to the SQL database connection
+//   - dbType: The type of database being used ("postgres", "sqlite" or "mysql")
+//   - maxShort: The maximum allowed length for short strings
 //
 // Returns:
-//   - error: Any error encountered during index creation
-func indexLinks(dbase *sql.DB) error {
-	// Index on the short column for quick lookups
-	if _, err := dbase.Exec("CREATE INDEX IF NOT EXISTS idx_links_short ON links(short);"); err != nil {
-		return fmt.Errorf("failed to create short index: %w", err)
+//   - error: Any error encountered during the migrations, including [migrations.ErrSchemaTooNew]
+func CreateLinksTable(dbase *sql.DB, dbType string, maxShort int) error {
+	// Apply the pending migrations
+	applied, err := migrations.Up(dbase, dbType, migrations.Params{MaxShortLength: maxShort})
+	if err != nil {
+		return fmt.Errorf("failed to migrate the database: %w", err)
 	}
 
-	// Index on expiration time for efficient cleanup queries
-	if _, err := dbase.Exec("CREATE INDEX IF NOT EXISTS idx_links_expire ON links(expire_at);"); err != nil {
-		return fmt.Errorf("failed to create expiration index: %w", err)
+	if applied != 0 {
+		slog.Info("Applied database migrations", slog.Int("count", applied))
+	}
+
+	// SQLite doesn't enforce the length of varchar columns
+	if dbType == "postgres" || dbType == "mysql" {
+		if err := resizeShortColumn(dbase, dbType, maxShort); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
-// CreateLinksTable creates the links table in the database if it doesn't exist.
-//
-// The function creates a links table with columns for unique identifiers, timestamps,
-// URL data, and optional password protection. It dynamically sets the maximum
-// length for the short string based on the provided configuration.
+// resizeShortColumn changes the length of the short column if it differs from the configured one.
 //
 // Parameters:
-//   - database: A pointer to the SQL database connection
-//   - dbType: The type of database being used ("postgres" or "sqlite")
+//   - dbase: A pointer to the SQL database connection
+//   - dbType: The type of database being used ("postgres" or "mysql")
 //   - maxShort: The maximum allowed length for short strings
 //
 // Returns:
-//   - error: Any error encountered during table creation or update
-func CreateLinksTable(dbase *sql.DB, dbType string, maxShort int) error {
-	// Creating the table with a parameterized short column length
-	sqlCreateTable := fmt.Sprintf(
-		"CREATE TABLE IF NOT EXISTS links ("+
-			"id UUID PRIMARY KEY, "+
-			"created_at TIMESTAMP NOT NULL, "+
-			"expire_at TIMESTAMP NOT NULL, "+
-			"url TEXT NOT NULL, "+
-			"short varchar(%d) UNIQUE NOT NULL, "+
-			"password TEXT);",
-		maxShort,
+//   - error: Any error encountered during the check or the update
+func resizeShortColumn(dbase *sql.DB, dbType string, maxShort int) error {
+	const sqlGetShortLength = `
+		SELECT character_maximum_length 
+		FROM information_schema.columns 
+		WHERE table_schema = %s AND table_name = 'links' AND column_name = 'short';`
+
+	schema := "current_schema()"
+	if dbType == "mysql" {
+		schema = "DATABASE()"
+	}
+
+	var length int
+	if err := dbase.QueryRow(fmt.Sprintf(sqlGetShortLength, schema)).Scan(&length); err != nil {
+		return fmt.Errorf("failed to get short column length: %w", err)
+	}
+
+	if length == maxShort {
+		return nil
+	}
+
+	sqlUpdateMaxShort := fmt.Sprintf("ALTER TABLE links ALTER COLUMN short TYPE varchar(%d);", maxShort)
+	if dbType == "mysql" {
+		sqlUpdateMaxShort = fmt.Sprintf(
+			"ALTER TABLE links MODIFY short VARCHAR(%d) CHARACTER SET ascii COLLATE ascii_bin NOT NULL;",
+			maxShort,
+		)
+	}
+	if _, err := dbase.Exec(sqlUpdateMaxShort); err != nil {
+		return fmt.Errorf("failed to alter short column type: %w", err)
+	}
+
+	return nil
+}
+
+// CreateLink inserts a new shortened URL entry into the database.
+//
+// This function stores a complete link record with all necessary metadata including
+// creation and expiration timestamps, the original URL, short string,
+// an optional password for protected links, the management token hash and the owner of the link.
+//
+// Parameters:
+//   - link: The link to insert, its ID must be unique
+//
+// Returns:
+//   - error: Any error encountered during the insert operation, including an already used short
+func (store *SQLStore) CreateLink(link Link) error {
+	const sqlCreateLink = `
+		INSERT INTO links (id, created_at, expire_at, url, short, password, token, owner) 
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8);`
+
+	_, err := store.db.Exec(
+		store.rebind(sqlCreateLink),
+		link.ID,
+		link.CreatedAt,
+		link.ExpireAt,
+		link.URL,
+		link.Short,
+		link.Password,
+		link.Token,
+		nullUUID(link.Owner),
 	)
+	if err != nil {
+		return fmt.Errorf("failed to create link: %w", err)
+	}
+
+	return nil
+}
 
-	if _, err := dbase.Exec(sqlCreateTable); err != nil {
-		return fmt.Errorf("failed to create links table: %w", err)
+// CreateLinks inserts several new links within a single transaction.
+//
+// The transaction is rolled back if one of the links can't be inserted, for example because its short is already used,
+// in which case none of the links are inserted.
+//
+// Parameters:
+//   - links: The links to insert
+//
+// Returns:
+//   - error: Any error encountered during the insertion
+func (store *SQLStore) CreateLinks(links []Link) error {
+	trans, err := store.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
 
-	// Update table structure for backward compatibility
-	if err := updateLinksTable(dbase, dbType, maxShort); err != nil {
-		return fmt.Errorf("failed to update links table: %w", err)
+	if err := store.insertLinks(trans, links); err != nil {
+		_ = trans.Rollback()
+
+		return fmt.Errorf("failed to create links: %w", err)
 	}
 
-	// Create indexes
-	if err := indexLinks(dbase); err != nil {
-		return fmt.Errorf("failed to index links table: %w", err)
+	if err := trans.Commit(); err != nil {
+		return fmt.Errorf("failed to commit links: %w", err)
 	}
 
 	return nil
 }
 
-// updateLinksTable updates the structure of an existing links table to ensure
-// compatibility with the current schema definition.
+// ReplaceLinks inserts several links within a single transaction, replacing the existing links having the same shorts.
 //
-// This function handles database-specific operations for modifying table structure
-// without losing data. For PostgreSQL, it alters column types directly. For SQLite,
-// which doesn't support ALTER COLUMN, it uses a temporary table to reconstruct the data.
+// The hits of the replaced links are deleted along with them. The transaction is rolled back if one of the links
+// can't be inserted, for example because its ID is already used, in which case the existing links are kept.
 //
 // Parameters:
-//   - database: A pointer to the SQL database connection
-//   - dbType: The type of database being used ("postgres" or "sqlite")
-//   - maxShort: The maximum allowed length for short strings
+//   - links: The links to insert
 //
 // Returns:
-//   - error: Any error encountered during the update process
-func updateLinksTable(database *sql.DB, dbType string, maxShort int) error { //nolint:cyclop,funlen
-	switch dbType {
-	case "postgres":
-		// PostgreSQL supports direct column type modifications
-		sqlUpdateMaxShort := fmt.Sprintf(
-			"ALTER TABLE links ALTER COLUMN short TYPE varchar(%d);",
-			maxShort,
-		)
-		if _, err := database.Exec(sqlUpdateMaxShort); err != nil {
-			return fmt.Errorf("failed to alter short column type: %w", err)
-		}
+//   - error: Any error encountered during the replacement
+func (store *SQLStore) ReplaceLinks(links []Link) error {
+	const sqlDeleteHits = `
+		DELETE FROM link_hits 
+		WHERE link_id IN (SELECT id FROM links WHERE short = $1);`
 
-	case "sqlite":
-		// SQLite requires table recreation for schema changes
-		// Begin transaction for atomicity
-		trans, err := database.Begin()
-		if err != nil {
-			return fmt.Errorf("failed to begin transaction: %w", err)
-		}
+	const sqlDeleteLink = `DELETE FROM links WHERE short = $1;`
 
-		defer func() {
-			// Ensure transaction is properly handled on any return path
-			if err != nil {
-				err := trans.Rollback()
-				if err != nil {
-					log.Println("failed to rollback transaction:", err)
-				}
-			}
-		}()
-
-		// Create temporary table with the new schema
-		sqlCreateTempTable := fmt.Sprintf(
-			"CREATE TABLE tmp_links ("+
-				"id UUID PRIMARY KEY, "+
-				"created_at TIMESTAMP NOT NULL, "+
-				"expire_at TIMESTAMP NOT NULL, "+
-				"url TEXT NOT NULL, "+
-				"short varchar(%d) UNIQUE NOT NULL, "+
-				"password varchar(97));",
-			maxShort,
-		)
+	trans, err := store.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	for _, link := range links {
+		if _, err := trans.Exec(store.rebind(sqlDeleteHits), link.Short); err != nil {
+			_ = trans.Rollback()
 
-		if _, err = trans.Exec(sqlCreateTempTable); err != nil {
-			return fmt.Errorf("failed to create temporary table: %w", err)
+			return fmt.Errorf("failed to delete link hits: %w", err)
 		}
 
-		// Copy data from old table to new table
-		const sqlCopyOldToNew = `
-			INSERT INTO tmp_links 
-				(id, created_at, expire_at, url, short, password) 
-			SELECT 
-				id, created_at, expire_at, url, short, password 
-			FROM links;`
+		if _, err := trans.Exec(store.rebind(sqlDeleteLink), link.Short); err != nil {
+			_ = trans.Rollback()
 
-		if _, err = trans.Exec(sqlCopyOldToNew); err != nil {
-			return fmt.Errorf("failed to copy data to temporary table: %w", err)
+			return fmt.Errorf("failed to delete link: %w", err)
 		}
+	}
 
-		// Drop old table and rename new table
-		if _, err = trans.Exec("DROP TABLE links;"); err != nil {
-			return fmt.Errorf("failed to drop old table: %w", err)
-		}
+	if err := store.insertLinks(trans, links); err != nil {
+		_ = trans.Rollback()
 
-		if _, err = trans.Exec("ALTER TABLE tmp_links RENAME TO links;"); err != nil {
-			return fmt.Errorf("failed to rename temporary table: %w", err)
-		}
+		return fmt.Errorf("failed to replace links: %w", err)
+	}
+
+	if err := trans.Commit(); err != nil {
+		return fmt.Errorf("failed to commit links: %w", err)
+	}
+
+	return nil
+}
+
+// linkColumns is the number of columns given when inserting a link.
+const linkColumns = 8
+
+// insertChunkSize is the maximum number of links inserted by a single statement,
+// binding the arguments of larger statements gets slow with some drivers.
+const insertChunkSize = 100
+
+// insertLinks inserts several links using a statement per chunk of [insertChunkSize] links,
+// so that none of the links of a chunk is inserted if one of them can't be.
+func (store *SQLStore) insertLinks(trans *sql.Tx, links []Link) error {
+	for start := 0; start < len(links); start += insertChunkSize {
+		chunk := links[start:min(start+insertChunkSize, len(links))]
+
+		var query strings.Builder
+
+		query.WriteString("INSERT INTO links (id, created_at, expire_at, url, short, password, token, owner) VALUES ")
+
+		args := make([]any, 0, len(chunk)*linkColumns)
+
+		for index, link := range chunk {
+			if index > 0 {
+				query.WriteString(", ")
+			}
+
+			query.WriteString("(")
+
+			for column := 1; column <= linkColumns; column++ {
+				if column > 1 {
+					query.WriteString(", ")
+				}
+
+				fmt.Fprintf(&query, "$%d", index*linkColumns+column)
+			}
 
-		// Commit the transaction
-		if err = trans.Commit(); err != nil {
-			return fmt.Errorf("failed to commit transaction: %w", err)
+			query.WriteString(")")
+
+			args = append(args,
+				link.ID,
+				link.CreatedAt,
+				link.ExpireAt,
+				link.URL,
+				link.Short,
+				link.Password,
+				link.Token,
+				nullUUID(link.Owner),
+			)
 		}
 
-		// Create indexes
-		if err = indexLinks(database); err != nil {
-			return fmt.Errorf("failed to index links table: %w", err)
+		query.WriteString(";")
+
+		if _, err := trans.Exec(store.rebind(query.String()), args...); err != nil {
+			return err
 		}
 	}
 
 	return nil
 }
 
-// CreateLink inserts a new shortened URL entry into the database.
+// GetLinkByShort retrieves a complete link record by its short within a single query.
 //
-// This function stores a complete link record with all necessary metadata including
-// creation and expiration timestamps, the original URL, short string, and
-// an optional password for protected links.
+// Links that have expired are treated as not found, even if they haven't been removed by
+// [SQLStore.RemoveExpiredLinks] yet.
 //
 // Parameters:
-//   - database: A pointer to the SQL database connection
-//   - identifier: A UUID that uniquely identifies this link
-//   - createdAt: Timestamp indicating when the link was created
-//   - expireAt: Timestamp indicating when the link will expire
-//   - url: The original URL that is being shortened
-//   - short: The short string to be used in the shortened URL
-//   - password: Optional password hash for protected links (empty string if none)
+//   - short: The shortened URL to look up
 //
 // Returns:
-//   - error: Any error encountered during the insert operation
-func CreateLink(
-	database *sql.DB,
-	identifier uuid.UUID,
-	createdAt time.Time,
-	expireAt time.Time,
-	url, short, password string,
-) error {
-	const sqlCreateLink = `
-		INSERT INTO links (id, created_at, expire_at, url, short, password) 
-		VALUES ($1, $2, $3, $4, $5, $6);`
+//   - Link: The link record
+//   - error: Any error encountered during lookup, [sql.ErrNoRows] if the link doesn't exist or has expired
+func (store *SQLStore) GetLinkByShort(short string) (Link, error) {
+	const sqlGetLinkByShort = `
+		SELECT id, created_at, expire_at, url, short, password, COALESCE(token, ''), owner 
+		FROM links 
+		WHERE short = $1 AND expire_at > $2;`
 
-	_, err := database.Exec(sqlCreateLink, identifier, createdAt, expireAt, url, short, password)
+	link, err := scanLink(store.db.QueryRow(store.rebind(sqlGetLinkByShort), short, time.Now().UTC()))
 	if err != nil {
-		return fmt.Errorf("failed to create link: %w", err)
+		return Link{}, fmt.Errorf("failed to get link: %w", err)
 	}
 
-	return nil
+	return link, nil
+}
+
+// nullUUID converts an ID to a nullable database value, [uuid.Nil] being stored as NULL.
+func nullUUID(id uuid.UUID) uuid.NullUUID {
+	return uuid.NullUUID{UUID: id, Valid: id != uuid.Nil}
+}
+
+// scanLink reads a link selected by the queries of the store, a link without owner is given [uuid.Nil].
+func scanLink(row rowScanner) (Link, error) {
+	var link Link
+	var owner uuid.NullUUID
+
+	err := row.Scan(
+		&link.ID,
+		&link.CreatedAt,
+		&link.ExpireAt,
+		&link.URL,
+		&link.Short,
+		&link.Password,
+		&link.Token,
+		&owner,
+	)
+	if err != nil {
+		return Link{}, err
+	}
+
+	link.Owner = owner.UUID
+
+	return link, nil
 }
 
 // GetURLInfo retrieves the complete information for a link by its short.
 //
 // Parameters:
-//   - dbase: A pointer to the SQL database connection
 //   - short: The shortened URL to look up
 //
 // Returns:
@@ -231,7 +359,7 @@ func CreateLink(
 //   - time.Time: When the link was created
 //   - time.Time: When the link will expire
 //   - error: Any error encountered during lookup, including "not found" errors
-func GetURLInfo(dbase *sql.DB, short string) (string, time.Time, time.Time, error) {
+func (store *SQLStore) GetURLInfo(short string) (string, time.Time, time.Time, error) {
 	const sqlGetURLByShort = `
 		SELECT url, created_at, expire_at 
 		FROM links 
@@ -243,7 +371,7 @@ func GetURLInfo(dbase *sql.DB, short string) (string, time.Time, time.Time, erro
 		expireAt  time.Time
 	)
 
-	err := dbase.QueryRow(sqlGetURLByShort, short).Scan(&url, &createdAt, &expireAt)
+	err := store.db.QueryRow(store.rebind(sqlGetURLByShort), short).Scan(&url, &createdAt, &expireAt)
 	if err != nil {
 		return "", time.Time{}, time.Time{}, fmt.Errorf("failed to get URL info: %w", err)
 	}
@@ -254,20 +382,19 @@ func GetURLInfo(dbase *sql.DB, short string) (string, time.Time, time.Time, erro
 // GetURLByShort retrieves just the original URL for a given short.
 //
 // Parameters:
-//   - dbase: A pointer to the SQL database connection
 //   - short: The shortened URL to look up
 //
 // Returns:
 //   - string: The original URL
 //   - error: Any error encountered during lookup, including "not found" errors
-func GetURLByShort(dbase *sql.DB, short string) (string, error) {
+func (store *SQLStore) GetURLByShort(short string) (string, error) {
 	const sqlGetURLByShort = `
 		SELECT url 
 		FROM links 
 		WHERE short = $1;`
 
 	var url string
-	err := dbase.QueryRow(sqlGetURLByShort, short).Scan(&url)
+	err := store.db.QueryRow(store.rebind(sqlGetURLByShort), short).Scan(&url)
 	if err != nil {
 		return "", fmt.Errorf("failed to get URL by short code: %w", err)
 	}
@@ -281,17 +408,16 @@ func GetURLByShort(dbase *sql.DB, short string) (string, error) {
 // verify provided passwords against the stored hash.
 //
 // Parameters:
-//   - dbase: A pointer to the SQL database connection
 //   - short: The shortened URL to look up
 //
 // Returns:
 //   - string: The stored password hash (empty string if no password)
 //   - error: Any error encountered during lookup, including "not found" errors
-func GetHashByShort(dbase *sql.DB, short string) (string, error) {
+func (store *SQLStore) GetHashByShort(short string) (string, error) {
 	const sqlGetPasswordByShort = `SELECT password FROM links WHERE short = $1;`
 
 	var password string
-	err := dbase.QueryRow(sqlGetPasswordByShort, short).Scan(&password)
+	err := store.db.QueryRow(store.rebind(sqlGetPasswordByShort), short).Scan(&password)
 	if err != nil {
 		return "", fmt.Errorf("failed to get password hash: %w", err)
 	}
@@ -299,22 +425,140 @@ func GetHashByShort(dbase *sql.DB, short string) (string, error) {
 	return password, nil
 }
 
-// RemoveExpiredLinks deletes all links that have passed their expiration date.
+// GetTokenHashByShort retrieves the management token hash for a given short.
+//
+// Links created before management tokens existed don't have one, in which case
+// an empty string is returned.
 //
 // Parameters:
-//   - dbase: A pointer to the SQL database connection
+//   - short: The shortened URL to look up
+//
+// Returns:
+//   - string: The stored token hash (empty string if no token)
+//   - error: Any error encountered during lookup, including "not found" errors
+func (store *SQLStore) GetTokenHashByShort(short string) (string, error) {
+	const sqlGetTokenByShort = `SELECT COALESCE(token, '') FROM links WHERE short = $1;`
+
+	var token string
+	err := store.db.QueryRow(store.rebind(sqlGetTokenByShort), short).Scan(&token)
+	if err != nil {
+		return "", fmt.Errorf("failed to get token hash: %w", err)
+	}
+
+	return token, nil
+}
+
+// UpdateLink replaces the URL, expiration date and password hash of a link.
+//
+// Parameters:
+//   - short: The shortened URL of the link to update
+//   - url: The new original URL
+//   - expireAt: The new expiration date
+//   - password: The new password hash (empty string if none)
 //
 // Returns:
+//   - error: Any error encountered during the update, [sql.ErrNoRows] if the link doesn't exist
+func (store *SQLStore) UpdateLink(short, url string, expireAt time.Time, password string) error {
+	const sqlUpdateLink = `
+		UPDATE links 
+		SET url = $1, expire_at = $2, password = $3 
+		WHERE short = $4;`
+
+	result, err := store.db.Exec(store.rebind(sqlUpdateLink), url, expireAt, password, short)
+	if err != nil {
+		return fmt.Errorf("failed to update link: %w", err)
+	}
+
+	return checkAffected(result, "update link")
+}
+
+// DeleteLink deletes a link and its hits by its short, within a single transaction.
+//
+// Parameters:
+//   - short: The shortened URL of the link to delete
+//
+// Returns:
+//   - error: Any error encountered during the deletion, [sql.ErrNoRows] if the link doesn't exist
+func (store *SQLStore) DeleteLink(short string) error {
+	const sqlDeleteHits = `
+		DELETE FROM link_hits 
+		WHERE link_id IN (SELECT id FROM links WHERE short = $1);`
+
+	const sqlDeleteLink = `DELETE FROM links WHERE short = $1;`
+
+	trans, err := store.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	if _, err := trans.Exec(store.rebind(sqlDeleteHits), short); err != nil {
+		_ = trans.Rollback()
+
+		return fmt.Errorf("failed to delete link hits: %w", err)
+	}
+
+	result, err := trans.Exec(store.rebind(sqlDeleteLink), short)
+	if err != nil {
+		_ = trans.Rollback()
+
+		return fmt.Errorf("failed to delete link: %w", err)
+	}
+
+	if err := checkAffected(result, "delete link"); err != nil {
+		_ = trans.Rollback()
+
+		return err
+	}
+
+	if err := trans.Commit(); err != nil {
+		return fmt.Errorf("failed to commit link deletion: %w", err)
+	}
+
+	return nil
+}
+
+// checkAffected makes sure a statement affected at least one row.
+//
+// Parameters:
+//   - result: The result of the executed statement
+//   - action: A short description of the action, used in error messages
+//
+// Returns:
+//   - error: Any error encountered while reading the result, [sql.ErrNoRows] if no row was affected
+func checkAffected(result sql.Result, action string) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to %s: %w", action, err)
+	}
+
+	if affected == 0 {
+		return fmt.Errorf("failed to %s: %w", action, sql.ErrNoRows)
+	}
+
+	return nil
+}
+
+// RemoveExpiredLinks deletes all links that have passed their expiration date along with their hits.
+//
+// Returns:
+//   - int64: The number of deleted links
 //   - error: Any error encountered during the deletion operation
-func RemoveExpiredLinks(dbase *sql.DB) error {
+func (store *SQLStore) RemoveExpiredLinks() (int64, error) {
+	// The current date is given as a parameter rather than using CURRENT_TIMESTAMP,
+	// which doesn't follow the time zone of the stored dates with every database type
 	const sqlRemoveLink = `
 		DELETE FROM links 
-		WHERE expire_at <= CURRENT_TIMESTAMP;`
+		WHERE expire_at <= $1;`
 
-	_, err := dbase.Exec(sqlRemoveLink)
+	result, err := store.db.Exec(store.rebind(sqlRemoveLink), time.Now().UTC())
 	if err != nil {
-		return fmt.Errorf("failed to remove expired links: %w", err)
+		return 0, fmt.Errorf("failed to remove expired links: %w", err)
 	}
 
-	return nil
+	removed, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to remove expired links: %w", err)
+	}
+
+	return removed, removeOrphanHits(store.db)
 }
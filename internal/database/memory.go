@@ -0,0 +1,678 @@
+//    reddlinks, a simple link shortener written in Go.
+//    Copyright (C) 2025 redd
+//
+//    This program is free software: you can redistribute it and/or modify
+//    it under the terms of the GNU General Public License as published by
+//    the Free Software Foundation, either version 3 of the License, or
+//    (at your option) any later version.
+//
+//    This program is distributed in the hope that it will be useful,
+//    but WITHOUT ANY WARRANTY; without even the implied warranty of
+//    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//    GNU General Public License for more details.
+//
+//    You should have received a copy of the GNU General Public License
+//    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package database
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MemoryStore is a [LinkStore] keeping the links in memory.
+//
+// Everything is lost when the instance stops, it is meant for tests and ephemeral instances.
+type MemoryStore struct {
+	mutex    sync.RWMutex
+	links    map[string]Link
+	hits     map[string]*HitStats
+	certs    map[string][]byte
+	rates    map[string]Bucket
+	keys     map[string]APIKey
+	users    map[uuid.UUID]User
+	sessions map[string]Session
+}
+
+// NewMemoryStore returns an empty [MemoryStore].
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		links:    make(map[string]Link),
+		hits:     make(map[string]*HitStats),
+		certs:    make(map[string][]byte),
+		rates:    make(map[string]Bucket),
+		keys:     make(map[string]APIKey),
+		users:    make(map[uuid.UUID]User),
+		sessions: make(map[string]Session),
+	}
+}
+
+// CreateLink inserts a new link, [ErrShortInUse] is returned if the short is already used.
+func (store *MemoryStore) CreateLink(link Link) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if _, exists := store.links[link.Short]; exists {
+		return fmt.Errorf("failed to create link: %w", ErrShortInUse)
+	}
+
+	store.links[link.Short] = link
+
+	return nil
+}
+
+// CreateLinks inserts several new links at once, [ErrShortInUse] is returned without inserting anything
+// if one of the shorts is already used, including by another link of the batch.
+func (store *MemoryStore) CreateLinks(links []Link) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	shorts := make(map[string]struct{}, len(links))
+
+	for _, link := range links {
+		_, exists := store.links[link.Short]
+		_, duplicate := shorts[link.Short]
+
+		if exists || duplicate {
+			return fmt.Errorf("failed to create links: %w", ErrShortInUse)
+		}
+
+		shorts[link.Short] = struct{}{}
+	}
+
+	for _, link := range links {
+		store.links[link.Short] = link
+	}
+
+	return nil
+}
+
+// ReplaceLinks inserts several links at once, replacing the existing links having the same shorts along with their hits,
+// [ErrShortInUse] is returned without changing anything if a short is given twice.
+func (store *MemoryStore) ReplaceLinks(links []Link) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	shorts := make(map[string]struct{}, len(links))
+
+	for _, link := range links {
+		if _, duplicate := shorts[link.Short]; duplicate {
+			return fmt.Errorf("failed to replace links: %w", ErrShortInUse)
+		}
+
+		shorts[link.Short] = struct{}{}
+	}
+
+	for _, link := range links {
+		delete(store.hits, link.Short)
+		store.links[link.Short] = link
+	}
+
+	return nil
+}
+
+// getLink returns a link by its short, an error wrapping [sql.ErrNoRows] is returned if it doesn't exist.
+func (store *MemoryStore) getLink(short, action string) (Link, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	link, exists := store.links[short]
+	if !exists {
+		return Link{}, notFound(action)
+	}
+
+	return link, nil
+}
+
+// GetLinkByShort returns a complete link, links that have expired are not found.
+func (store *MemoryStore) GetLinkByShort(short string) (Link, error) {
+	link, err := store.getLink(short, "get link")
+	if err != nil {
+		return Link{}, err
+	}
+
+	if !link.ExpireAt.After(time.Now()) {
+		return Link{}, notFound("get link")
+	}
+
+	return link, nil
+}
+
+// GetURLInfo returns the original URL, the creation date and the expiration date of a link.
+func (store *MemoryStore) GetURLInfo(short string) (string, time.Time, time.Time, error) {
+	link, err := store.getLink(short, "get URL info")
+	if err != nil {
+		return "", time.Time{}, time.Time{}, err
+	}
+
+	return link.URL, link.CreatedAt, link.ExpireAt, nil
+}
+
+// GetURLByShort returns the original URL of a link.
+func (store *MemoryStore) GetURLByShort(short string) (string, error) {
+	link, err := store.getLink(short, "get URL by short code")
+
+	return link.URL, err
+}
+
+// GetHashByShort returns the password hash of a link, empty if it isn't protected.
+func (store *MemoryStore) GetHashByShort(short string) (string, error) {
+	link, err := store.getLink(short, "get password hash")
+
+	return link.Password, err
+}
+
+// GetTokenHashByShort returns the management token hash of a link, empty if it has none.
+func (store *MemoryStore) GetTokenHashByShort(short string) (string, error) {
+	link, err := store.getLink(short, "get token hash")
+
+	return link.Token, err
+}
+
+// UpdateLink replaces the URL, the expiration date and the password hash of a link.
+func (store *MemoryStore) UpdateLink(short, url string, expireAt time.Time, password string) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	link, exists := store.links[short]
+	if !exists {
+		return notFound("update link")
+	}
+
+	link.URL = url
+	link.ExpireAt = expireAt
+	link.Password = password
+	store.links[short] = link
+
+	return nil
+}
+
+// DeleteLink deletes a link and its hits.
+func (store *MemoryStore) DeleteLink(short string) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if _, exists := store.links[short]; !exists {
+		return notFound("delete link")
+	}
+
+	delete(store.links, short)
+	delete(store.hits, short)
+
+	return nil
+}
+
+// RemoveExpiredLinks deletes every expired link and their hits, it returns the number of deleted links.
+func (store *MemoryStore) RemoveExpiredLinks() (int64, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	now := time.Now()
+
+	var removed int64
+
+	for short, link := range store.links {
+		if !link.ExpireAt.After(now) {
+			delete(store.links, short)
+			delete(store.hits, short)
+
+			removed++
+		}
+	}
+
+	return removed, nil
+}
+
+// matches tells if a link matches a filter, the pagination is ignored.
+func (filter LinkFilter) matches(link Link) bool {
+	switch {
+	case filter.Short != "" && !strings.Contains(link.Short, filter.Short),
+		filter.URL != "" && !strings.Contains(link.URL, filter.URL),
+		!filter.CreatedAfter.IsZero() && link.CreatedAt.Before(filter.CreatedAfter),
+		!filter.CreatedBefore.IsZero() && !link.CreatedAt.Before(filter.CreatedBefore),
+		!filter.ExpireAfter.IsZero() && link.ExpireAt.Before(filter.ExpireAfter),
+		!filter.ExpireBefore.IsZero() && !link.ExpireAt.Before(filter.ExpireBefore),
+		filter.Owner != uuid.Nil && link.Owner != filter.Owner,
+		filter.ShortAfter != "" && link.Short <= filter.ShortAfter:
+		return false
+	default:
+		return true
+	}
+}
+
+// ListLinks returns the links matching a filter, the most recent first unless sorted by short,
+// and the number of matching links regardless of the pagination.
+func (store *MemoryStore) ListLinks(filter LinkFilter) ([]Link, int, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	links := make([]Link, 0, len(store.links))
+
+	for _, link := range store.links {
+		if filter.matches(link) {
+			links = append(links, link)
+		}
+	}
+
+	links, total := filter.page(links)
+
+	return links, total, nil
+}
+
+// page sorts matching links in the same order as the SQL implementation and returns the requested page
+// along with the number of matching links.
+func (filter LinkFilter) page(links []Link) ([]Link, int) {
+	sort.Slice(links, func(i, j int) bool {
+		if !filter.SortByShort && !links[i].CreatedAt.Equal(links[j].CreatedAt) {
+			return links[i].CreatedAt.After(links[j].CreatedAt)
+		}
+
+		return links[i].Short < links[j].Short
+	})
+
+	total := len(links)
+
+	if filter.Limit > 0 {
+		start := min(filter.Offset, total)
+		end := min(start+filter.Limit, total)
+		links = links[start:end]
+	}
+
+	return links, total
+}
+
+// DeleteLinks deletes several links and their hits, unknown shorts are ignored.
+func (store *MemoryStore) DeleteLinks(shorts []string) (int64, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	var deleted int64
+
+	for _, short := range shorts {
+		if _, exists := store.links[short]; exists {
+			delete(store.links, short)
+			delete(store.hits, short)
+			deleted++
+		}
+	}
+
+	return deleted, nil
+}
+
+// ExpireLinks sets the expiration date of several links, unknown shorts are ignored.
+func (store *MemoryStore) ExpireLinks(shorts []string, expireAt time.Time) (int64, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	var expired int64
+
+	for _, short := range shorts {
+		if link, exists := store.links[short]; exists {
+			link.ExpireAt = expireAt
+			store.links[short] = link
+			expired++
+		}
+	}
+
+	return expired, nil
+}
+
+// AddHits counts a batch of hits, the ones of unknown links are ignored.
+func (store *MemoryStore) AddHits(hits []Hit) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	for _, hit := range hits {
+		if _, exists := store.links[hit.Short]; !exists {
+			continue
+		}
+
+		stats, exists := store.hits[hit.Short]
+		if !exists {
+			stats = &HitStats{Referrers: make(map[string]int), Agents: make(map[string]int)}
+			store.hits[hit.Short] = stats
+		}
+
+		stats.Total++
+		stats.Referrers[hit.Referrer]++
+		stats.Agents[hit.Agent]++
+	}
+
+	return nil
+}
+
+// GetHitStats returns the aggregated accesses of a link, only the most common referrers are returned.
+func (store *MemoryStore) GetHitStats(short string) (HitStats, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	result := HitStats{
+		Referrers: make(map[string]int),
+		Agents:    make(map[string]int),
+	}
+
+	stats, exists := store.hits[short]
+	if !exists {
+		return result, nil
+	}
+
+	result.Total = stats.Total
+
+	for agent, count := range stats.Agents {
+		result.Agents[agent] = count
+	}
+
+	result.Referrers = topReferrers(stats.Referrers)
+
+	return result, nil
+}
+
+// topReferrers returns a copy of the given referrers limited to the most common ones.
+func topReferrers(counts map[string]int) map[string]int {
+	referrers := make([]string, 0, len(counts))
+	for referrer := range counts {
+		referrers = append(referrers, referrer)
+	}
+
+	sort.Slice(referrers, func(i, j int) bool {
+		if counts[referrers[i]] != counts[referrers[j]] {
+			return counts[referrers[i]] > counts[referrers[j]]
+		}
+
+		return referrers[i] < referrers[j]
+	})
+
+	top := make(map[string]int, min(len(referrers), maxReferrers))
+	for _, referrer := range referrers[:min(len(referrers), maxReferrers)] {
+		top[referrer] = counts[referrer]
+	}
+
+	return top
+}
+
+// GetCert returns the data of a cached certificate entry, [sql.ErrNoRows] is returned if it doesn't exist.
+func (store *MemoryStore) GetCert(name string) ([]byte, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	data, exists := store.certs[name]
+	if !exists {
+		return nil, notFound("get cert")
+	}
+
+	return append([]byte(nil), data...), nil
+}
+
+// PutCert inserts or replaces a cached certificate entry.
+func (store *MemoryStore) PutCert(name string, data []byte) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	store.certs[name] = append([]byte(nil), data...)
+
+	return nil
+}
+
+// DeleteCert deletes a cached certificate entry, nothing happens if it doesn't exist.
+func (store *MemoryStore) DeleteCert(name string) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	delete(store.certs, name)
+
+	return nil
+}
+
+// GetBucket returns a token bucket, [sql.ErrNoRows] is returned if it doesn't exist.
+func (store *MemoryStore) GetBucket(key string) (Bucket, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	bucket, exists := store.rates[key]
+	if !exists {
+		return Bucket{}, notFound("get bucket")
+	}
+
+	return bucket, nil
+}
+
+// SaveBucket inserts or updates a token bucket, [ErrBucketConflict] is returned if its version changed.
+func (store *MemoryStore) SaveBucket(bucket Bucket) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if store.rates[bucket.Key].Version != bucket.Version {
+		return fmt.Errorf("failed to save bucket: %w", ErrBucketConflict)
+	}
+
+	bucket.Version++
+	store.rates[bucket.Key] = bucket
+
+	return nil
+}
+
+// RemoveIdleBuckets deletes the token buckets that weren't updated since a date.
+func (store *MemoryStore) RemoveIdleBuckets(before time.Time) (int64, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	var removed int64
+
+	for key, bucket := range store.rates {
+		if bucket.UpdatedAt.Before(before) {
+			delete(store.rates, key)
+			removed++
+		}
+	}
+
+	return removed, nil
+}
+
+// CreateAPIKey inserts a new API key, an error is returned if another key has the same hash.
+func (store *MemoryStore) CreateAPIKey(key APIKey) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if _, exists := store.keys[key.Hash]; exists {
+		return fmt.Errorf("failed to create API key: %w", errDuplicateKey)
+	}
+
+	store.keys[key.Hash] = key
+
+	return nil
+}
+
+// GetAPIKeyByHash returns the API key having a hash, [sql.ErrNoRows] is returned if it doesn't exist.
+func (store *MemoryStore) GetAPIKeyByHash(hash string) (APIKey, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	key, exists := store.keys[hash]
+	if !exists {
+		return APIKey{}, notFound("get API key")
+	}
+
+	return key, nil
+}
+
+// ListAPIKeys returns every API key, sorted by creation date.
+func (store *MemoryStore) ListAPIKeys() ([]APIKey, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	keys := make([]APIKey, 0, len(store.keys))
+	for _, key := range store.keys {
+		keys = append(keys, key)
+	}
+
+	sortAPIKeys(keys)
+
+	return keys, nil
+}
+
+// DeleteAPIKey deletes an API key by its ID, [sql.ErrNoRows] is returned if it doesn't exist.
+func (store *MemoryStore) DeleteAPIKey(id uuid.UUID) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	for hash, key := range store.keys {
+		if key.ID == id {
+			delete(store.keys, hash)
+
+			return nil
+		}
+	}
+
+	return notFound("delete API key")
+}
+
+// CreateUser inserts a new user, [ErrUsernameInUse] is returned if the username is already used.
+func (store *MemoryStore) CreateUser(user User) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	for _, existing := range store.users {
+		if existing.Username == user.Username {
+			return fmt.Errorf("failed to create user: %w", ErrUsernameInUse)
+		}
+
+		if user.Subject != "" && existing.Subject == user.Subject {
+			return fmt.Errorf("failed to create user: %w", ErrSubjectInUse)
+		}
+	}
+
+	store.users[user.ID] = user
+
+	return nil
+}
+
+// GetUserByID returns a user, [sql.ErrNoRows] is returned if it doesn't exist.
+func (store *MemoryStore) GetUserByID(id uuid.UUID) (User, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	user, exists := store.users[id]
+	if !exists {
+		return User{}, notFound("get user")
+	}
+
+	return user, nil
+}
+
+// GetUserByName returns the user having a username, [sql.ErrNoRows] is returned if it doesn't exist.
+func (store *MemoryStore) GetUserByName(username string) (User, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	for _, user := range store.users {
+		if user.Username == username {
+			return user, nil
+		}
+	}
+
+	return User{}, notFound("get user")
+}
+
+// GetUserBySubject returns the user having an OpenID Connect subject, [sql.ErrNoRows] is returned if it doesn't exist.
+func (store *MemoryStore) GetUserBySubject(subject string) (User, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	for _, user := range store.users {
+		if subject != "" && user.Subject == subject {
+			return user, nil
+		}
+	}
+
+	return User{}, notFound("get user")
+}
+
+// UpdateUserRoles replaces the roles of a user, [sql.ErrNoRows] is returned if it doesn't exist.
+func (store *MemoryStore) UpdateUserRoles(id uuid.UUID, roles string) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	user, exists := store.users[id]
+	if !exists {
+		return notFound("update user roles")
+	}
+
+	user.Roles = roles
+	store.users[id] = user
+
+	return nil
+}
+
+// CreateSession inserts a new session.
+func (store *MemoryStore) CreateSession(session Session) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	store.sessions[session.Hash] = session
+
+	return nil
+}
+
+// GetSession returns the session having a hash, [sql.ErrNoRows] is returned if it doesn't exist or has expired.
+func (store *MemoryStore) GetSession(hash string) (Session, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	session, exists := store.sessions[hash]
+	if !exists || !session.ExpireAt.After(time.Now()) {
+		return Session{}, notFound("get session")
+	}
+
+	return session, nil
+}
+
+// DeleteSession deletes a session, [sql.ErrNoRows] is returned if it doesn't exist.
+func (store *MemoryStore) DeleteSession(hash string) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if _, exists := store.sessions[hash]; !exists {
+		return notFound("delete session")
+	}
+
+	delete(store.sessions, hash)
+
+	return nil
+}
+
+// RemoveExpiredSessions deletes every expired session and returns how many were deleted.
+func (store *MemoryStore) RemoveExpiredSessions() (int64, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	now := time.Now()
+
+	var removed int64
+
+	for hash, session := range store.sessions {
+		if !session.ExpireAt.After(now) {
+			delete(store.sessions, hash)
+
+			removed++
+		}
+	}
+
+	return removed, nil
+}
+
+// Ping does nothing, the links are always reachable.
+func (store *MemoryStore) Ping(context.Context) error {
+	return nil
+}
+
+// Close does nothing, there is nothing to release.
+func (store *MemoryStore) Close() error {
+	return nil
+}
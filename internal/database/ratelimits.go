@@ -0,0 +1,129 @@
+//    reddlinks, a simple link shortener written in Go.
+//    Copyright (C) 2025 redd
+//
+//    This program is free software: you can redistribute it and/or modify
+//    it under the terms of the GNU General Public License as published by
+//    the Free Software Foundation, either version 3 of the License, or
+//    (at your option) any later version.
+//
+//    This program is distributed in the hope that it will be useful,
+//    but WITHOUT ANY WARRANTY; without even the implied warranty of
+//    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//    GNU General Public License for more details.
+//
+//    You should have received a copy of the GNU General Public License
+//    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// GetBucket retrieves a token bucket by its key.
+//
+// Parameters:
+//   - key: The key of the bucket
+//
+// Returns:
+//   - Bucket: The stored bucket
+//   - error: Any error encountered during lookup, [sql.ErrNoRows] if the bucket doesn't exist
+func (store *SQLStore) GetBucket(key string) (Bucket, error) {
+	const sqlGetBucket = `
+		SELECT tokens, updated_at, version
+		FROM rate_limits
+		WHERE bucket_key = $1;`
+
+	bucket := Bucket{Key: key}
+
+	err := store.db.QueryRow(store.rebind(sqlGetBucket), key).Scan(&bucket.Tokens, &bucket.UpdatedAt, &bucket.Version)
+	if err != nil {
+		return Bucket{}, fmt.Errorf("failed to get bucket: %w", err)
+	}
+
+	return bucket, nil
+}
+
+// SaveBucket inserts a new token bucket or updates a stored one if its version didn't change.
+//
+// The update only matches the row having the version of the given bucket, and increments it,
+// so that two instances updating the same bucket at the same time can't both succeed.
+//
+// Parameters:
+//   - bucket: The bucket to save, with the version it had when it was read
+//
+// Returns:
+//   - error: Any error encountered during the saving, [ErrBucketConflict] if the bucket was changed in between
+func (store *SQLStore) SaveBucket(bucket Bucket) error {
+	const sqlInsertBucket = `
+		INSERT INTO rate_limits (bucket_key, tokens, updated_at, version)
+		VALUES ($1, $2, $3, 1);`
+
+	const sqlUpdateBucket = `
+		UPDATE rate_limits
+		SET tokens = $1, updated_at = $2, version = version + 1
+		WHERE bucket_key = $3 AND version = $4;`
+
+	if bucket.Version == 0 {
+		_, err := store.db.Exec(store.rebind(sqlInsertBucket), bucket.Key, bucket.Tokens, bucket.UpdatedAt.UTC())
+		if err == nil {
+			return nil
+		}
+
+		// The error of a duplicate key isn't the same for every database type,
+		// the bucket was inserted by someone else if it exists now
+		if _, getErr := store.GetBucket(bucket.Key); getErr == nil {
+			return fmt.Errorf("failed to insert bucket: %w", ErrBucketConflict)
+		}
+
+		return fmt.Errorf("failed to insert bucket: %w", err)
+	}
+
+	result, err := store.db.Exec(
+		store.rebind(sqlUpdateBucket),
+		bucket.Tokens,
+		bucket.UpdatedAt.UTC(),
+		bucket.Key,
+		bucket.Version,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update bucket: %w", err)
+	}
+
+	if err := checkAffected(result, "update bucket"); errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("failed to update bucket: %w", ErrBucketConflict)
+	} else if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// RemoveIdleBuckets deletes the token buckets that weren't updated since a given date.
+//
+// Parameters:
+//   - before: The date of the oldest update to keep
+//
+// Returns:
+//   - int64: The number of deleted buckets
+//   - error: Any error encountered during the deletion
+func (store *SQLStore) RemoveIdleBuckets(before time.Time) (int64, error) {
+	const sqlRemoveIdleBuckets = `
+		DELETE FROM rate_limits
+		WHERE updated_at < $1;`
+
+	result, err := store.db.Exec(store.rebind(sqlRemoveIdleBuckets), before.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("failed to remove idle buckets: %w", err)
+	}
+
+	removed, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count removed buckets: %w", err)
+	}
+
+	return removed, nil
+}
@@ -0,0 +1,313 @@
+//    reddlinks, a simple link shortener written in Go.
+//    Copyright (C) 2025 redd
+//
+//    This program is free software: you can redistribute it and/or modify
+//    it under the terms of the GNU General Public License as published by
+//    the Free Software Foundation, either version 3 of the License, or
+//    (at your option) any later version.
+//
+//    This program is distributed in the hope that it will be useful,
+//    but WITHOUT ANY WARRANTY; without even the implied warranty of
+//    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//    GNU General Public License for more details.
+//
+//    You should have received a copy of the GNU General Public License
+//    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redds-be/reddlinks/internal/migrations"
+)
+
+// Define all the errors of the stores.
+//
+// ErrShortInUse defines an error for a link created with a short that is already used by another link,
+// ErrBucketConflict defines an error for a token bucket that was changed by someone else since it was read,
+// ErrUsernameInUse defines an error for a user created with a username that is already used by another user,
+// ErrSubjectInUse defines an error for a user created with a subject that is already used by another user.
+var (
+	ErrShortInUse     = errors.New("the short is already in use")
+	ErrBucketConflict = errors.New("the bucket was changed concurrently")
+	ErrUsernameInUse  = errors.New("the username is already in use")
+	ErrSubjectInUse   = errors.New("the subject is already in use")
+)
+
+// LinkStore defines where the links are stored.
+//
+// Lookups of links that don't exist return an error wrapping [sql.ErrNoRows], whatever the implementation.
+// [SQLStore] stores the links in a PostgreSQL, SQLite or MySQL database, [BoltStore] in an embedded bbolt database
+// and [MemoryStore] keeps them in memory.
+type LinkStore interface {
+	HitStore
+	CertStore
+	RateLimitStore
+	APIKeyStore
+	UserStore
+
+	// CreateLink inserts a new link, the short must not be used by another link
+	CreateLink(link Link) error
+	// CreateLinks inserts several new links at once, none of them are inserted if one of them can't be
+	CreateLinks(links []Link) error
+	// ReplaceLinks inserts several links at once, replacing the existing links having the same shorts along with their hits,
+	// nothing is changed if one of them can't be inserted
+	ReplaceLinks(links []Link) error
+	// GetLinkByShort returns a complete link in a single lookup, links that have expired are not found
+	GetLinkByShort(short string) (Link, error)
+	// GetURLByShort returns the original URL of a link
+	GetURLByShort(short string) (string, error)
+	// GetURLInfo returns the original URL, the creation date and the expiration date of a link
+	GetURLInfo(short string) (string, time.Time, time.Time, error)
+	// GetHashByShort returns the password hash of a link, empty if it isn't protected
+	GetHashByShort(short string) (string, error)
+	// GetTokenHashByShort returns the management token hash of a link, empty if it has none
+	GetTokenHashByShort(short string) (string, error)
+	// UpdateLink replaces the URL, the expiration date and the password hash of a link
+	UpdateLink(short, url string, expireAt time.Time, password string) error
+	// DeleteLink deletes a link and its hits
+	DeleteLink(short string) error
+	// RemoveExpiredLinks deletes every expired link and their hits, returning the number of deleted links
+	RemoveExpiredLinks() (int64, error)
+	// ListLinks returns the links matching a filter and the number of matching links regardless of the pagination
+	ListLinks(filter LinkFilter) ([]Link, int, error)
+	// DeleteLinks deletes several links and returns how many were deleted
+	DeleteLinks(shorts []string) (int64, error)
+	// ExpireLinks sets the expiration date of several links and returns how many were updated
+	ExpireLinks(shorts []string, expireAt time.Time) (int64, error)
+	// Ping checks that the store can be reached, giving up once the context is done
+	Ping(ctx context.Context) error
+	// Close releases the resources used by the store
+	Close() error
+}
+
+// HitStore defines where the accesses to links are stored.
+type HitStore interface {
+	// AddHits inserts a batch of hits, the ones of unknown links are ignored
+	AddHits(hits []Hit) error
+	// GetHitStats returns the aggregated accesses of a link
+	GetHitStats(short string) (HitStats, error)
+}
+
+// CertStore defines where the TLS certificates and keys obtained using ACME are cached.
+//
+// Entries are opaque data identified by a name, lookups of entries that don't exist return an error wrapping [sql.ErrNoRows].
+type CertStore interface {
+	// GetCert returns the data of an entry
+	GetCert(name string) ([]byte, error)
+	// PutCert inserts or replaces an entry
+	PutCert(name string, data []byte) error
+	// DeleteCert deletes an entry, deleting an entry that doesn't exist is not an error
+	DeleteCert(name string) error
+}
+
+// Bucket is the token bucket of a rate limit, shared between the instances using the same store.
+//
+// Key identifies the limited client and action, Tokens is the number of tokens left when the bucket was last updated,
+// UpdatedAt is the date of the last update and Version is incremented by each update, zero for a bucket that isn't stored yet.
+type Bucket struct {
+	Key       string
+	Tokens    float64
+	UpdatedAt time.Time
+	Version   int64
+}
+
+// RateLimitStore defines where the token buckets of the rate limits are kept when several instances share them.
+//
+// Buckets are updated using optimistic concurrency: a bucket is only saved if it wasn't changed since it was read,
+// lookups of buckets that don't exist return an error wrapping [sql.ErrNoRows].
+type RateLimitStore interface {
+	// GetBucket returns a token bucket
+	GetBucket(key string) (Bucket, error)
+	// SaveBucket inserts a bucket whose version is zero or updates the stored bucket having the same version,
+	// an error wrapping [ErrBucketConflict] is returned if another update happened in between
+	SaveBucket(bucket Bucket) error
+	// RemoveIdleBuckets deletes the buckets that weren't updated since a date and returns how many were deleted
+	RemoveIdleBuckets(before time.Time) (int64, error)
+}
+
+// APIKey is a key given to the clients of the API, such as internal tools, using the Authorization header.
+//
+// ID is the unique identifier of the key,
+// Label describes who or what the key was given to,
+// Prefix is the beginning of the clear key, kept to recognize it,
+// Hash is the SHA-256 hash of the clear key, encoded in hexadecimal,
+// Scopes is the comma-separated list of what the key allows,
+// CreatedAt is the date at which the key was created,
+// ExpireAt is the date at which the key will expire, zero if it never does.
+type APIKey struct {
+	ID        uuid.UUID
+	Label     string
+	Prefix    string
+	Hash      string
+	Scopes    string
+	CreatedAt time.Time
+	ExpireAt  time.Time
+}
+
+// APIKeyStore defines where the API keys are stored.
+//
+// Only the hashes of the keys are stored, lookups of keys that don't exist return an error wrapping [sql.ErrNoRows].
+type APIKeyStore interface {
+	// CreateAPIKey inserts a new API key, its ID and its hash must be unique
+	CreateAPIKey(key APIKey) error
+	// GetAPIKeyByHash returns the API key having a hash, expired keys are returned as well
+	GetAPIKeyByHash(hash string) (APIKey, error)
+	// ListAPIKeys returns every API key, sorted by creation date
+	ListAPIKeys() ([]APIKey, error)
+	// DeleteAPIKey deletes an API key, revoking it
+	DeleteAPIKey(id uuid.UUID) error
+}
+
+// User is an account owning the links created while it is logged in.
+//
+// ID is the unique identifier of the user,
+// Username is the unique name the user logs in with,
+// Password is the argon2id hash of the password of the user, empty for the users logging in with OpenID Connect,
+// CreatedAt is the date at which the account was created,
+// Subject is the identifier of the user at the OpenID Connect provider, empty for the local users,
+// Roles is the comma-separated list of the roles given by the OpenID Connect provider at the last login.
+type User struct {
+	ID        uuid.UUID
+	Username  string
+	Password  string
+	CreatedAt time.Time
+	Subject   string
+	Roles     string
+}
+
+// Session is opened when a user logs in, it is identified by a random token kept in a cookie.
+//
+// Hash is the SHA-256 hash of the token, encoded in hexadecimal,
+// UserID is the ID of the logged in user,
+// ExpireAt is the date at which the session will expire.
+type Session struct {
+	Hash     string
+	UserID   uuid.UUID
+	ExpireAt time.Time
+}
+
+// UserStore defines where the user accounts and their sessions are stored.
+//
+// Only the hashes of the passwords and of the session tokens are stored,
+// lookups of users or sessions that don't exist return an error wrapping [sql.ErrNoRows].
+type UserStore interface {
+	// CreateUser inserts a new user, its ID, its username and its subject, if any, must be unique
+	CreateUser(user User) error
+	// GetUserByID returns a user
+	GetUserByID(id uuid.UUID) (User, error)
+	// GetUserByName returns the user having a username
+	GetUserByName(username string) (User, error)
+	// GetUserBySubject returns the user having an OpenID Connect subject
+	GetUserBySubject(subject string) (User, error)
+	// UpdateUserRoles replaces the roles of a user
+	UpdateUserRoles(id uuid.UUID, roles string) error
+	// CreateSession inserts a new session, its hash must be unique
+	CreateSession(session Session) error
+	// GetSession returns the session having a hash, sessions that have expired are not found
+	GetSession(hash string) (Session, error)
+	// DeleteSession deletes a session, logging its user out
+	DeleteSession(hash string) error
+	// RemoveExpiredSessions deletes every expired session and returns how many were deleted
+	RemoveExpiredSessions() (int64, error)
+}
+
+// Make sure the implementations satisfy the interface.
+var (
+	_ LinkStore = (*SQLStore)(nil)
+	_ LinkStore = (*BoltStore)(nil)
+	_ LinkStore = (*MemoryStore)(nil)
+)
+
+// SQLStore is a [LinkStore] backed by a PostgreSQL, SQLite or MySQL database.
+type SQLStore struct {
+	db      *sql.DB
+	dialect string
+}
+
+// NewSQLStore returns a [LinkStore] using a given database connection.
+//
+// The schema must be up to date, see [CreateLinksTable].
+//
+// Parameters:
+//   - dbase: A pointer to the SQL database connection
+//   - dbType: The type of database being used ("postgres", "sqlite" or "mysql")
+//
+// Returns:
+//   - *SQLStore: The store using the connection
+func NewSQLStore(dbase *sql.DB, dbType string) *SQLStore {
+	return &SQLStore{db: dbase, dialect: dbType}
+}
+
+// rebind converts the placeholders of a query to the ones of the database type, see [migrations.Rebind].
+func (store *SQLStore) rebind(query string) string {
+	return migrations.Rebind(store.dialect, query)
+}
+
+// DB returns the database connection used by the store.
+func (store *SQLStore) DB() *sql.DB {
+	return store.db
+}
+
+// Ping checks that the database can be reached, see [sql.DB.PingContext].
+func (store *SQLStore) Ping(ctx context.Context) error {
+	return store.db.PingContext(ctx)
+}
+
+// Close closes the database connection.
+func (store *SQLStore) Close() error {
+	return store.db.Close()
+}
+
+// OpenStore opens the store corresponding to a database type.
+//
+// The "memory" type returns an empty [MemoryStore], the "bolt" type opens the [BoltStore] file given by dbURL,
+// the other types connect to the database using [DBConnect] and bring its schema up to date using [CreateLinksTable].
+//
+// Parameters:
+//   - dbType: Database type ("postgres", "sqlite", "mysql", "bolt" or "memory")
+//   - dbURL: Complete database connection URL (optional if individual parameters provided)
+//   - dbUser: Database username (used if dbURL is empty)
+//   - dbPass: Database password (used if dbURL is empty)
+//   - dbHost: Database host address (used if dbURL is empty)
+//   - dbPort: Database port number (used if dbURL is empty)
+//   - dbName: Database name (used if dbURL is empty)
+//   - maxShort: The maximum allowed length for short strings
+//
+// Returns:
+//   - LinkStore: The opened store
+//   - error: Any error encountered during the connection or the migrations
+func OpenStore(dbType, dbURL, dbUser, dbPass, dbHost, dbPort, dbName string, maxShort int) (LinkStore, error) {
+	switch dbType {
+	case "memory":
+		return NewMemoryStore(), nil
+	case "bolt":
+		return NewBoltStore(dbURL)
+	}
+
+	// Connect to the database
+	dbase, err := DBConnect(dbType, dbURL, dbUser, dbPass, dbHost, dbPort, dbName)
+	if err != nil {
+		return nil, err
+	}
+
+	// Apply the database migrations, creating the links table if it doesn't exist
+	if err := CreateLinksTable(dbase, dbType, maxShort); err != nil {
+		_ = dbase.Close()
+
+		return nil, err
+	}
+
+	return NewSQLStore(dbase, dbType), nil
+}
+
+// notFound returns an error wrapping [sql.ErrNoRows] for a given action.
+func notFound(action string) error {
+	return fmt.Errorf("failed to %s: %w", action, sql.ErrNoRows)
+}
@@ -0,0 +1,233 @@
+//    reddlinks, a simple link shortener written in Go.
+//    Copyright (C) 2025 redd
+//
+//    This program is free software: you can redistribute it and/or modify
+//    it under the terms of the GNU General Public License as published by
+//    the Free Software Foundation, either version 3 of the License, or
+//    (at your option) any later version.
+//
+//    This program is distributed in the hope that it will be useful,
+//    but WITHOUT ANY WARRANTY; without even the implied warranty of
+//    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//    GNU General Public License for more details.
+//
+//    You should have received a copy of the GNU General Public License
+//    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CreateUser inserts a new user.
+//
+// Parameters:
+//   - user: The user to insert, its ID, its username and its subject, if any, must be unique
+//
+// Returns:
+//   - error: Any error encountered during the insertion, including an already used username or subject
+func (store *SQLStore) CreateUser(user User) error {
+	const sqlCreateUser = `
+		INSERT INTO users (id, username, password, created_at, subject, roles)
+		VALUES ($1, $2, $3, $4, $5, $6);`
+
+	// The local users are stored without subject, so that they don't collide on the unique index
+	subject := sql.NullString{String: user.Subject, Valid: user.Subject != ""}
+
+	_, err := store.db.Exec(
+		store.rebind(sqlCreateUser),
+		user.ID,
+		user.Username,
+		user.Password,
+		user.CreatedAt.UTC(),
+		subject,
+		user.Roles,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create user: %w", err)
+	}
+
+	return nil
+}
+
+// getUser retrieves a user using a query selecting it by a single value.
+func (store *SQLStore) getUser(query string, arg any) (User, error) {
+	var user User
+
+	err := store.db.QueryRow(store.rebind(query), arg).
+		Scan(&user.ID, &user.Username, &user.Password, &user.CreatedAt, &user.Subject, &user.Roles)
+	if err != nil {
+		return User{}, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	return user, nil
+}
+
+// GetUserByID retrieves a user by its ID.
+//
+// Parameters:
+//   - id: The ID of the user
+//
+// Returns:
+//   - User: The user
+//   - error: Any error encountered during lookup, [sql.ErrNoRows] if the user doesn't exist
+func (store *SQLStore) GetUserByID(id uuid.UUID) (User, error) {
+	const sqlGetUserByID = `
+		SELECT id, username, password, created_at, COALESCE(subject, ''), roles
+		FROM users
+		WHERE id = $1;`
+
+	return store.getUser(sqlGetUserByID, id)
+}
+
+// GetUserByName retrieves a user by its username.
+//
+// Parameters:
+//   - username: The username of the user
+//
+// Returns:
+//   - User: The user
+//   - error: Any error encountered during lookup, [sql.ErrNoRows] if the user doesn't exist
+func (store *SQLStore) GetUserByName(username string) (User, error) {
+	const sqlGetUserByName = `
+		SELECT id, username, password, created_at, COALESCE(subject, ''), roles
+		FROM users
+		WHERE username = $1;`
+
+	return store.getUser(sqlGetUserByName, username)
+}
+
+// GetUserBySubject retrieves a user by its OpenID Connect subject.
+//
+// Parameters:
+//   - subject: The identifier of the user at the OpenID Connect provider
+//
+// Returns:
+//   - User: The user
+//   - error: Any error encountered during lookup, [sql.ErrNoRows] if the user doesn't exist
+func (store *SQLStore) GetUserBySubject(subject string) (User, error) {
+	const sqlGetUserBySubject = `
+		SELECT id, username, password, created_at, COALESCE(subject, ''), roles
+		FROM users
+		WHERE subject = $1;`
+
+	return store.getUser(sqlGetUserBySubject, subject)
+}
+
+// UpdateUserRoles replaces the roles of a user.
+//
+// Parameters:
+//   - id: The ID of the user
+//   - roles: The comma-separated list of roles
+//
+// Returns:
+//   - error: Any error encountered during the update, [sql.ErrNoRows] if the user doesn't exist
+func (store *SQLStore) UpdateUserRoles(id uuid.UUID, roles string) error {
+	const sqlUpdateUserRoles = `
+		UPDATE users
+		SET roles = $1
+		WHERE id = $2;`
+
+	result, err := store.db.Exec(store.rebind(sqlUpdateUserRoles), roles, id)
+	if err != nil {
+		return fmt.Errorf("failed to update user roles: %w", err)
+	}
+
+	return checkAffected(result, "update user roles")
+}
+
+// CreateSession inserts a new session.
+//
+// Parameters:
+//   - session: The session to insert, its hash must be unique
+//
+// Returns:
+//   - error: Any error encountered during the insertion
+func (store *SQLStore) CreateSession(session Session) error {
+	const sqlCreateSession = `
+		INSERT INTO sessions (hash, user_id, expire_at)
+		VALUES ($1, $2, $3);`
+
+	_, err := store.db.Exec(store.rebind(sqlCreateSession), session.Hash, session.UserID, session.ExpireAt.UTC())
+	if err != nil {
+		return fmt.Errorf("failed to create session: %w", err)
+	}
+
+	return nil
+}
+
+// GetSession retrieves a session by the hash of its token.
+//
+// Sessions that have expired are treated as not found, even if they haven't been removed by
+// [SQLStore.RemoveExpiredSessions] yet.
+//
+// Parameters:
+//   - hash: The SHA-256 hash of the token, encoded in hexadecimal
+//
+// Returns:
+//   - Session: The session
+//   - error: Any error encountered during lookup, [sql.ErrNoRows] if the session doesn't exist or has expired
+func (store *SQLStore) GetSession(hash string) (Session, error) {
+	const sqlGetSession = `
+		SELECT hash, user_id, expire_at
+		FROM sessions
+		WHERE hash = $1 AND expire_at > $2;`
+
+	var session Session
+
+	err := store.db.QueryRow(store.rebind(sqlGetSession), hash, time.Now().UTC()).
+		Scan(&session.Hash, &session.UserID, &session.ExpireAt)
+	if err != nil {
+		return Session{}, fmt.Errorf("failed to get session: %w", err)
+	}
+
+	return session, nil
+}
+
+// DeleteSession deletes a session by the hash of its token, logging its user out.
+//
+// Parameters:
+//   - hash: The SHA-256 hash of the token, encoded in hexadecimal
+//
+// Returns:
+//   - error: Any error encountered during the deletion, [sql.ErrNoRows] if the session doesn't exist
+func (store *SQLStore) DeleteSession(hash string) error {
+	const sqlDeleteSession = `
+		DELETE FROM sessions
+		WHERE hash = $1;`
+
+	result, err := store.db.Exec(store.rebind(sqlDeleteSession), hash)
+	if err != nil {
+		return fmt.Errorf("failed to delete session: %w", err)
+	}
+
+	return checkAffected(result, "delete session")
+}
+
+// RemoveExpiredSessions deletes all sessions that have passed their expiration date.
+//
+// Returns:
+//   - int64: The number of deleted sessions
+//   - error: Any error encountered during the deletion
+func (store *SQLStore) RemoveExpiredSessions() (int64, error) {
+	const sqlRemoveSessions = `
+		DELETE FROM sessions
+		WHERE expire_at <= $1;`
+
+	result, err := store.db.Exec(store.rebind(sqlRemoveSessions), time.Now().UTC())
+	if err != nil {
+		return 0, fmt.Errorf("failed to remove expired sessions: %w", err)
+	}
+
+	removed, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to remove expired sessions: %w", err)
+	}
+
+	return removed, nil
+}
@@ -28,7 +28,9 @@ import "errors"
 // ErrNullOrNegative defines an error for variables where a value is either null or negative,
 // ErrNegative defines an error for variables where a value is negative,
 // ErrSuperior defines an error for variables where a value can't be superior to another one,
-// ErrInferior defines an error for variables where a value can't be inferior to another one.
+// ErrInferior defines an error for variables where a value can't be inferior to another one,
+// ErrExclusive defines an error for variables that can't be set along with another one,
+// ErrRequires defines an error for variables that can't be set without another one.
 var (
 	ErrEmpty                = errors.New("can't be empty")
 	ErrRead                 = errors.New("couldn't be read")
@@ -39,4 +41,6 @@ var (
 	ErrNegative             = errors.New("can't be negative")
 	ErrSuperior             = errors.New("can't be superior to")
 	ErrInferior             = errors.New("can't be inferior to")
+	ErrExclusive            = errors.New("can't be set along with")
+	ErrRequires             = errors.New("can't be set without")
 )
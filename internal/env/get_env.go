@@ -5,13 +5,19 @@ package env
 import (
 	"errors"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
+	"net/url"
 	"os"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 
+	"github.com/alexedwards/argon2id"
 	"github.com/joho/godotenv"
+	"github.com/redds-be/reddlinks/internal/logging"
+	"github.com/redds-be/reddlinks/internal/proxy"
 	"github.com/redds-be/reddlinks/internal/utils"
 )
 
@@ -20,7 +26,7 @@ type Env struct {
 	AddrAndPort            string // Address and port the server listens on (format: "host:port")
 	InstanceName           string // Name of this instance
 	InstanceURL            string // Base URL where this instance is accessible
-	DBType                 string // Database type ("postgres" or "sqlite")
+	DBType                 string // Database type ("postgres", "sqlite", "mysql", "bolt" or "memory")
 	DBUser                 string // Database username
 	DBPass                 string // Database password
 	DBHost                 string // Database host address
@@ -29,10 +35,55 @@ type Env struct {
 	DBURL                  string // Full database connection string (optional, if not provided will be built from other DB fields)
 	ContactEmail           string // Admin contact email address
 	TimeBetweenCleanups    int    // Time between garbage collection runs (in minutes)
+	CacheSize              int    // Maximum number of links kept in the in-process cache (0 to disable the cache)
 	DefaultLength          int    // Default length for generated short URLs
 	DefaultMaxLength       int    // Maximum allowed length for any short URL
 	DefaultMaxCustomLength int    // Maximum allowed length for custom short URLs
 	DefaultExpiryTime      int    // Default time until links expire (in minutes, 0 for no expiry)
+	Analytics              bool   // Whether accesses to links are recorded
+	AdminPassword          string // Password of the admin dashboard (optional, the dashboard is disabled without a password)
+	AdminPasswordHash      string // Argon2id hash of the password of the admin dashboard, used instead of AdminPassword
+	RestrictCreation       bool   // Whether the creation of links is restricted to the API keys having the create scope and the logged in users
+	Accounts               bool   // Whether the user accounts are enabled
+	Registration           bool   // Whether anybody can register an account when the accounts are enabled
+	OIDCIssuer             string // URL of the OpenID Connect provider users log in with (optional, enables the single sign-on)
+	OIDCClientID           string // Client ID of the instance at the OpenID Connect provider
+	OIDCClientSecret       string // Client secret of the instance at the OpenID Connect provider (optional for public clients)
+	OIDCScopes             string // Space-separated scopes requested from the OpenID Connect provider
+	OIDCUsernameClaim      string // Claim giving the preferred username of the users
+	OIDCRolesClaim         string // Claim giving the roles of the users, nested claims are separated by dots
+	OIDCAdminRole          string // Role giving access to the admin dashboard (optional)
+	OIDCCreatorRole        string // Role required by the logged in users to create links when the creation is restricted (optional)
+	LogFormat              string // Format of the log lines ("text" or "json")
+	LogLevel               string // Minimum level of the log lines ("debug", "info", "warn" or "error")
+	Metrics                bool   // Whether the metrics are exposed on /metrics
+	MetricsToken           string // Bearer token required to read the metrics (optional, the metrics are public without a token)
+	ShutdownTimeout        int    // Time given to the in-flight requests to be handled on shutdown (in seconds)
+	TLSCertFile            string // PEM certificate chain served over HTTPS (optional, set along with TLSKeyFile)
+	TLSKeyFile             string // PEM private key of the certificate
+	ACMEDomains            string // Comma-separated domains a certificate is obtained for using ACME (optional, enables HTTPS)
+	ACMEEmail              string // Contact address given to the ACME certificate authority (optional)
+	ACMEDirectory          string // ACME directory URL (optional, Let's Encrypt by default)
+	ACMEDirectoryCA        string // PEM CA trusted to reach the ACME directory (optional, for test authorities)
+	ACMECacheDir           string // Directory caching the ACME certificates (optional, cached in the database by default)
+	RedirectAddr           string // Address of the plain HTTP server redirecting to HTTPS (optional)
+	HSTSMaxAge             int    // Time browsers must only use HTTPS for the instance (in seconds, 0 to disable)
+	ReadTimeout            int    // Time given to read a whole request (in seconds)
+	ReadHeaderTimeout      int    // Time given to read the headers of a request (in seconds)
+	WriteTimeout           int    // Time given to handle a request and write its response (in seconds)
+	IdleTimeout            int    // Time an idle keep-alive connection is kept open (in seconds)
+	BatchTimeout           int    // Time given to read a batch and create its links, overriding the read and write timeouts (in seconds)
+	MaxHeaderBytes         int    // Maximum size of the headers of a request (in bytes)
+	MaxBodyBytes           int    // Maximum size of a JSON payload (in bytes)
+	MaxBatchBytes          int    // Maximum size of a batch of links (in bytes)
+	RateLimitCreate        int    // Links a client can create per minute (0 to disable the limit)
+	RateLimitCreateBurst   int    // Links a client can create at once
+	RateLimitBatch         int    // Batches a client can upload per minute (0 to disable the limit)
+	RateLimitBatchBurst    int    // Batches a client can upload at once
+	RateLimitPassword      int    // Passwords a client can try per minute (0 to disable the limit)
+	RateLimitPasswordBurst int    // Passwords a client can try at once
+	RateLimitStore         string // Where the rate limits are kept ("memory" or "database" to share them between instances)
+	TrustedProxies         string // Comma-separated networks of the reverse proxies whose forwarding headers are trusted (optional)
 }
 
 // EnvCheck performs comprehensive validation of the environment configuration.
@@ -42,24 +93,31 @@ type Env struct {
 // This method delegates specific validation tasks to specialized helper methods
 // that focus on validating related groups of configuration settings.
 //
-// Returns an error if any validation check fails with a detailed message about
-// which validation failed and why. Returns nil if all validations pass.
+// Returns an error joining every failed validation check with a detailed message about
+// which validation failed and why, see [errors.Join]. Returns nil if all validations pass.
 func (env Env) EnvCheck() error {
-	// Validate instance settings
-	if err := env.validateInstanceConfig(); err != nil {
-		return err
-	}
-
-	// Validate database settings
-	if err := env.validateDatabaseConfig(); err != nil {
-		return err
-	}
-
-	if err := env.validateLengthConstraints(); err != nil {
-		return err
-	}
-
-	return nil
+	return errors.Join(
+		// Validate instance settings
+		env.validateInstanceConfig(),
+		// Validate server settings
+		env.validateServerConfig(),
+		// Validate HTTPS settings
+		env.validateTLSConfig(),
+		// Validate rate limit settings
+		env.validateRateLimitConfig(),
+		// Validate reverse proxy settings
+		env.validateProxyConfig(),
+		// Validate database settings
+		env.validateDatabaseConfig(),
+		// Validate length settings
+		env.validateLengthConstraints(),
+		// Validate admin settings
+		env.validateAdminConfig(),
+		// Validate OpenID Connect settings
+		env.validateOIDCConfig(),
+		// Validate log settings
+		env.validateLogConfig(),
+	)
 }
 
 // validateInstanceConfig checks the validity of instance name and URL parameters.
@@ -67,16 +125,163 @@ func (env Env) EnvCheck() error {
 // - The instance name is not empty
 // - The instance URL is properly formatted as a valid URL
 //
-// Returns an error if any validation fails, nil otherwise.
+// Returns an error joining every failed validation, nil otherwise.
 func (env Env) validateInstanceConfig() error {
+	var errs []error
+
 	// Check if the instance name isn't null
 	if env.InstanceName == "" {
-		return fmt.Errorf("the instance name %w", ErrEmpty)
+		errs = append(errs, fmt.Errorf("the instance name %w", ErrEmpty))
 	}
 
 	// Check if the instance URL is valid
 	if err := utils.IsURL(env.InstanceURL); err != nil {
-		return err
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
+}
+
+// validateServerConfig checks the validity of the HTTP server parameters.
+// It ensures that:
+// - The timeouts are positive
+// - The header timeout isn't superior to the read timeout
+// - The batch timeout isn't inferior to the read and write timeouts
+// - The size limits are positive
+//
+// Returns an error joining every failed validation, nil otherwise.
+func (env Env) validateServerConfig() error {
+	var errs []error
+
+	// Check the timeouts, in a fixed order so that the errors are too
+	for _, timeout := range []struct {
+		name  string
+		value int
+	}{
+		{"shutdown", env.ShutdownTimeout},
+		{"read", env.ReadTimeout},
+		{"read header", env.ReadHeaderTimeout},
+		{"write", env.WriteTimeout},
+		{"idle", env.IdleTimeout},
+		{"batch", env.BatchTimeout},
+	} {
+		if timeout.value <= 0 {
+			errs = append(errs, fmt.Errorf("the %s timeout %w", timeout.name, ErrNullOrNegative))
+		}
+	}
+
+	if env.ReadHeaderTimeout > env.ReadTimeout {
+		errs = append(errs, fmt.Errorf("the read header timeout %w the read timeout", ErrSuperior))
+	}
+
+	if env.BatchTimeout < max(env.ReadTimeout, env.WriteTimeout) {
+		errs = append(errs, fmt.Errorf("the batch timeout %w the read and write timeouts", ErrInferior))
+	}
+
+	// Check the size limits
+	for _, limit := range []struct {
+		name  string
+		value int
+	}{
+		{"max header bytes", env.MaxHeaderBytes},
+		{"max body bytes", env.MaxBodyBytes},
+		{"max batch bytes", env.MaxBatchBytes},
+	} {
+		if limit.value <= 0 {
+			errs = append(errs, fmt.Errorf("the %s %w", limit.name, ErrNullOrNegative))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// validateTLSConfig checks the validity of the HTTPS parameters.
+// It ensures that:
+// - The TLS certificate file and key file are set together
+// - The certificate files and the ACME domains aren't both set
+// - The ACME settings and the HTTP redirection are only set if HTTPS is enabled
+// - The ACME directory, if set, is an HTTPS URL
+// - The HSTS max age isn't negative
+//
+// Returns an error joining every failed validation, nil otherwise.
+func (env Env) validateTLSConfig() error {
+	var errs []error
+
+	// Check that the certificate comes from a single source
+	if (env.TLSCertFile == "") != (env.TLSKeyFile == "") {
+		errs = append(errs, fmt.Errorf("the TLS certificate file %w the TLS key file", ErrRequires))
+	}
+
+	if env.TLSCertFile != "" && env.ACMEDomains != "" {
+		errs = append(errs, fmt.Errorf("the TLS certificate file %w the ACME domains", ErrExclusive))
+	}
+
+	// Check that the settings depending on HTTPS aren't set without it
+	acmeSettings := env.ACMEEmail + env.ACMEDirectory + env.ACMEDirectoryCA + env.ACMECacheDir
+	if env.ACMEDomains == "" && acmeSettings != "" {
+		errs = append(errs, fmt.Errorf("the ACME settings %w the ACME domains", ErrRequires))
+	}
+
+	if env.RedirectAddr != "" && env.TLSCertFile == "" && env.ACMEDomains == "" {
+		errs = append(errs, fmt.Errorf("the HTTP redirect address %w a TLS certificate or the ACME domains", ErrRequires))
+	}
+
+	// Check the ACME directory, the ACME protocol requires HTTPS
+	if env.ACMEDirectory != "" {
+		if directory, err := url.Parse(env.ACMEDirectory); err != nil || directory.Scheme != "https" || directory.Host == "" {
+			errs = append(errs, fmt.Errorf("the ACME directory %w", ErrInvalid))
+		}
+	}
+
+	// Check the HSTS max age
+	if env.HSTSMaxAge < 0 {
+		errs = append(errs, fmt.Errorf("the HSTS max age %w", ErrNegative))
+	}
+
+	return errors.Join(errs...)
+}
+
+// validateRateLimitConfig checks the validity of the rate limit parameters.
+// It ensures that:
+// - The rates aren't negative
+// - The bursts of the enabled limits are positive
+// - The rate limit store is one of the supported stores (memory or database)
+//
+// Returns an error joining every failed validation, nil otherwise.
+func (env Env) validateRateLimitConfig() error {
+	var errs []error
+
+	// Check the limits, in a fixed order so that the errors are too
+	for _, limit := range []struct {
+		name  string
+		rate  int
+		burst int
+	}{
+		{"creation", env.RateLimitCreate, env.RateLimitCreateBurst},
+		{"batch", env.RateLimitBatch, env.RateLimitBatchBurst},
+		{"password", env.RateLimitPassword, env.RateLimitPasswordBurst},
+	} {
+		if limit.rate < 0 {
+			errs = append(errs, fmt.Errorf("the %s rate limit %w", limit.name, ErrNegative))
+		} else if limit.rate > 0 && limit.burst <= 0 {
+			errs = append(errs, fmt.Errorf("the %s rate limit burst %w", limit.name, ErrNullOrNegative))
+		}
+	}
+
+	// Check if the rate limit store is valid
+	if env.RateLimitStore != "memory" && env.RateLimitStore != "database" {
+		errs = append(errs, fmt.Errorf("the rate limit store %w", ErrInvalidOrUnsupported))
+	}
+
+	return errors.Join(errs...)
+}
+
+// validateProxyConfig checks that the trusted proxies are valid networks in the CIDR notation or single addresses.
+//
+// Returns an error if a network can't be parsed, nil otherwise.
+func (env Env) validateProxyConfig() error {
+	if _, err := proxy.ParseTrusted(env.TrustedProxies); err != nil {
+		return fmt.Errorf("the trusted proxies %w: %w", ErrInvalid, err)
 	}
 
 	return nil
@@ -84,23 +289,37 @@ func (env Env) validateInstanceConfig() error {
 
 // validateDatabaseConfig checks the validity of database connection parameters and other database related configs.
 // It ensures that:
-// - The database type is one of the supported types (postgres or sqlite)
+// - The database type is one of the supported types (postgres, sqlite, mysql, bolt or memory)
 // - The database type is not empty
+// - The database file is set when using bolt
 // - The time between cleanups is positive
+// - The cache size is not negative
 //
-// Returns an error if any validation fails, nil otherwise.
+// Returns an error joining every failed validation, nil otherwise.
 func (env Env) validateDatabaseConfig() error {
+	var errs []error
+
 	// Check if the database type is valid
-	if env.DBType == "" || !regexp.MustCompile(`^postgres$|^sqlite$`).MatchString(env.DBType) {
-		return fmt.Errorf("the database type %w", ErrInvalidOrUnsupported)
+	if env.DBType == "" || !regexp.MustCompile(`^postgres$|^sqlite$|^mysql$|^bolt$|^memory$`).MatchString(env.DBType) {
+		errs = append(errs, fmt.Errorf("the database type %w", ErrInvalidOrUnsupported))
+	}
+
+	// Check if the bolt database file is set
+	if env.DBType == "bolt" && env.DBURL == "" {
+		errs = append(errs, fmt.Errorf("the database file %w", ErrEmpty))
 	}
 
 	// Check the time between cleanups
 	if env.TimeBetweenCleanups <= 0 {
-		return fmt.Errorf("the time between database cleanups %w", ErrNullOrNegative)
+		errs = append(errs, fmt.Errorf("the time between database cleanups %w", ErrNullOrNegative))
 	}
 
-	return nil
+	// Check the cache size
+	if env.CacheSize < 0 {
+		errs = append(errs, fmt.Errorf("the cache size %w", ErrNegative))
+	}
+
+	return errors.Join(errs...)
 }
 
 // validateLengthConstraints checks the consistency and validity of URL length parameters.
@@ -110,55 +329,152 @@ func (env Env) validateDatabaseConfig() error {
 // - DefaultMaxLength is positive
 // - DefaultLength does not exceed DefaultMaxLength
 // - DefaultMaxCustomLength does not exceed DefaultMaxLength
-// - DefaultMaxLength is not less than DefaultLength
-// - DefaultMaxLength is not less than DefaultMaxCustomLength
 // - DefaultMaxLength does not exceed the maximum string length supported by databases
+// - DefaultMaxLength does not exceed the maximum length of an indexed string with MySQL
 // - DefaultExpiryTime is positive
 //
-// Returns an error if any validation fails, nil otherwise.
+// Returns an error joining every failed validation, nil otherwise.
 func (env Env) validateLengthConstraints() error {
 	// Set max string size of string in db to avoid having a magic number
 	const maxStringLength = 8000
 
+	// MySQL limits the size of index keys to 3072 bytes, shorts are stored as ASCII strings
+	const maxMySQLStringLength = 3072
+
+	var errs []error
+
 	// Check the default short length
 	if env.DefaultLength <= 0 {
-		return fmt.Errorf("the default short length %w", ErrNullOrNegative)
+		errs = append(errs, fmt.Errorf("the default short length %w", ErrNullOrNegative))
 	} else if env.DefaultLength > env.DefaultMaxLength {
-		return fmt.Errorf("the default short length %w the default max short length", ErrSuperior)
+		errs = append(errs, fmt.Errorf("the default short length %w the default max short length", ErrSuperior))
 	}
 
 	// Check the default max custom short length
 	if env.DefaultMaxCustomLength <= 0 {
-		return fmt.Errorf("the default max custom short length %w", ErrNullOrNegative)
+		errs = append(errs, fmt.Errorf("the default max custom short length %w", ErrNullOrNegative))
 	} else if env.DefaultMaxCustomLength > env.DefaultMaxLength {
-		return fmt.Errorf("the default max custom short %w the default max short length", ErrSuperior)
+		errs = append(errs, fmt.Errorf("the default max custom short %w the default max short length", ErrSuperior))
 	}
 
-	// Check the default max short length
+	// Check the default max short length, its consistency with the other lengths is checked above
 	switch {
 	case env.DefaultMaxLength <= 0:
-		return fmt.Errorf("the default short length %w", ErrNullOrNegative)
-	case env.DefaultMaxLength < env.DefaultLength:
-		return fmt.Errorf("the max default short length %w the default short length", ErrInferior)
-	case env.DefaultMaxLength < env.DefaultMaxCustomLength:
-		return fmt.Errorf(
-			"the max default short length %w the default max custom short length",
-			ErrInferior,
-		)
+		errs = append(errs, fmt.Errorf("the max default short length %w", ErrNullOrNegative))
 	case env.DefaultMaxLength > maxStringLength:
-		return fmt.Errorf( //nolint:goerr113
+		errs = append(errs, fmt.Errorf( //nolint:goerr113
 			"strangely, some database engines don't support strings over %d chars long"+
 				" for fixed-sized strings",
 			maxStringLength,
-		)
+		))
+	case env.DBType == "mysql" && env.DefaultMaxLength > maxMySQLStringLength:
+		errs = append(errs, fmt.Errorf("the max default short length %w %d with MySQL", ErrSuperior, maxMySQLStringLength))
 	}
 
 	// Check the default expiry time
 	if env.DefaultExpiryTime < 0 {
-		return fmt.Errorf("the default expiry time %w", ErrNegative)
+		errs = append(errs, fmt.Errorf("the default expiry time %w", ErrNegative))
 	}
 
-	return nil
+	return errors.Join(errs...)
+}
+
+// validateAdminConfig checks the validity of the admin dashboard parameters.
+// It ensures that:
+// - The admin password and the admin password hash aren't both set
+// - The admin password hash, if set, is a valid argon2id hash
+// - The creation of links isn't restricted on an in-memory database without the admin dashboard, the registration
+// or the single sign-on, neither the API keys nor the accounts could be created otherwise
+//
+// Returns an error joining every failed validation, nil otherwise.
+func (env Env) validateAdminConfig() error {
+	var errs []error
+
+	// Only one way to give the password is allowed
+	if env.AdminPassword != "" && env.AdminPasswordHash != "" {
+		errs = append(errs, fmt.Errorf("the admin password %w the admin password hash", ErrExclusive))
+	}
+
+	// Check if the hash can be used
+	if env.AdminPasswordHash != "" {
+		if _, _, _, err := argon2id.DecodeHash(env.AdminPasswordHash); err != nil {
+			errs = append(errs, fmt.Errorf("the admin password hash %w: %w", ErrInvalid, err))
+		}
+	}
+
+	// The CLI can't reach the keys and the users of an in-memory database
+	registration := env.Accounts && (env.Registration || env.OIDCIssuer != "")
+	if env.RestrictCreation && env.DBType == "memory" && env.AdminPassword == "" && env.AdminPasswordHash == "" && !registration {
+		errs = append(errs, fmt.Errorf(
+			"the creation restriction %w the admin dashboard or the registration of accounts on an in-memory database", ErrRequires))
+	}
+
+	return errors.Join(errs...)
+}
+
+// validateOIDCConfig checks the validity of the OpenID Connect parameters.
+// It ensures that:
+// - The issuer and the client ID are set together
+// - The issuer is a valid URL
+// - The accounts are enabled along with the single sign-on, the users it logs in are accounts
+// - The openid scope is requested
+// - The roles are only set along with the issuer
+//
+// Returns an error joining every failed validation, nil otherwise.
+func (env Env) validateOIDCConfig() error {
+	var errs []error
+
+	if env.OIDCIssuer == "" {
+		if env.OIDCClientID != "" {
+			errs = append(errs, fmt.Errorf("the OpenID Connect client ID %w the OpenID Connect issuer", ErrRequires))
+		}
+
+		if env.OIDCAdminRole != "" || env.OIDCCreatorRole != "" {
+			errs = append(errs, fmt.Errorf("the OpenID Connect roles %w the OpenID Connect issuer", ErrRequires))
+		}
+
+		return errors.Join(errs...)
+	}
+
+	if env.OIDCClientID == "" {
+		errs = append(errs, fmt.Errorf("the OpenID Connect issuer %w the OpenID Connect client ID", ErrRequires))
+	}
+
+	if issuer, err := url.Parse(env.OIDCIssuer); err != nil || (issuer.Scheme != "https" && issuer.Scheme != "http") || issuer.Host == "" {
+		errs = append(errs, fmt.Errorf("the OpenID Connect issuer %w", ErrInvalid))
+	}
+
+	if !env.Accounts {
+		errs = append(errs, fmt.Errorf("the OpenID Connect login %w the accounts", ErrRequires))
+	}
+
+	if !slices.Contains(strings.Fields(env.OIDCScopes), "openid") {
+		errs = append(errs, fmt.Errorf("the OpenID Connect scopes %w: the openid scope is missing", ErrInvalid))
+	}
+
+	return errors.Join(errs...)
+}
+
+// validateLogConfig checks the validity of the log parameters.
+// It ensures that:
+// - The log format is supported, see [logging.NewLogger]
+// - The log level is supported, see [logging.ParseLevel]
+//
+// Returns an error joining every failed validation, nil otherwise.
+func (env Env) validateLogConfig() error {
+	var errs []error
+
+	// Check the log format
+	if _, err := logging.NewLogger(io.Discard, env.LogFormat, ""); err != nil {
+		errs = append(errs, fmt.Errorf("the log format %w: %w", ErrInvalidOrUnsupported, err))
+	}
+
+	// Check the log level
+	if _, err := logging.ParseLevel(env.LogLevel); err != nil {
+		errs = append(errs, fmt.Errorf("the log level %w: %w", ErrInvalidOrUnsupported, err))
+	}
+
+	return errors.Join(errs...)
 }
 
 // GetEnv loads and validates the application's environment configuration.
@@ -172,7 +488,7 @@ func (env Env) validateLengthConstraints() error {
 // system environment.
 //
 // If required environment variables are missing or validation fails, the
-// function will terminate the program with a fatal error.
+// function will terminate the program with a fatal error listing every problem, see [LoadEnv].
 //
 // Parameters:
 //   - envFile: Path to an optional .env file containing environment variables.
@@ -180,33 +496,74 @@ func (env Env) validateLengthConstraints() error {
 // Returns:
 //   - A fully populated and validated Env struct with all configuration values.
 func GetEnv(envFile string) Env {
+	env, err := LoadEnv(envFile)
+	if err != nil {
+		slog.Error("Invalid configuration", slog.Any("error", err))
+		os.Exit(1)
+	}
+
+	return env
+}
+
+// LoadEnv loads and validates the application's environment configuration like [GetEnv]
+// without terminating the program.
+//
+// Every variable is read even if a previous one is missing or invalid, and the configuration is
+// validated using [Env.EnvCheck] so that every problem is reported at once.
+//
+// Parameters:
+//   - envFile: Path to an optional .env file containing environment variables.
+//
+// Returns:
+//   - The populated Env struct, it must not be used if there's an error.
+//   - An error joining every missing, unreadable or invalid variable, see [errors.Join].
+func LoadEnv(envFile string) (Env, error) {
 	// Set some default numbers as const to not have magic numbers
 	const defaultCleanupTime = 1
 	const defaultShortLength = 3
 	const defaultMaxLength = 12
 	const defaultCustomShortLength = 12
 	const defaultExpiryTime = 2880
+	const defaultShutdownTimeout = 30
+	const defaultHSTSMaxAge = 31536000
+	const defaultReadTimeout = 10
+	const defaultReadHeaderTimeout = 2
+	const defaultWriteTimeout = 30
+	const defaultIdleTimeout = 30
+	const defaultBatchTimeout = 120
+	const defaultMaxHeaderBytes = 1 << 20
+	const defaultRateLimitCreate = 30
+	const defaultRateLimitCreateBurst = 10
+	const defaultRateLimitBatch = 2
+	const defaultRateLimitBatchBurst = 2
+	const defaultRateLimitPassword = 10
+	const defaultRateLimitPasswordBurst = 5
+
+	if err := loadEnvFile(envFile); err != nil {
+		return Env{}, err
+	}
 
-	loadEnvFile(envFile)
+	// Collect the errors encountered while reading the variables
+	reader := &envReader{}
 
 	env := Env{
 		// Server settings with defaults
 		AddrAndPort:  getEnvWithDefault("REDDLINKS_LISTEN_ADDR", "0.0.0.0:8080"),
 		InstanceName: getEnvWithDefault("REDDLINKS_INSTANCE_NAME", "reddlinks"),
-		InstanceURL:  getRequiredEnv("REDDLINKS_INSTANCE_URL"),
+		InstanceURL:  reader.getRequiredEnv("REDDLINKS_INSTANCE_URL"),
 
 		// Database settings
-		DBType: getRequiredEnv("REDDLINKS_DB_TYPE"),
+		DBType: reader.getRequiredEnv("REDDLINKS_DB_TYPE"),
 		DBURL:  os.Getenv("REDDLINKS_DB_STRING"),
 	}
 
-	// Only require these if no direct DB string is provided
-	if env.DBURL == "" {
-		env.DBUser = getRequiredEnv("REDDLINKS_DB_USERNAME")
-		env.DBPass = getRequiredEnv("REDDLINKS_DB_PASSWORD")
-		env.DBHost = getRequiredEnv("REDDLINKS_DB_HOST")
-		env.DBPort = getRequiredEnv("REDDLINKS_DB_PORT")
-		env.DBName = getRequiredEnv("REDDLINKS_DB_NAME")
+	// Only require these if no direct DB string is provided, the memory and bolt stores don't need any
+	if env.DBURL == "" && env.DBType != "memory" && env.DBType != "bolt" {
+		env.DBUser = reader.getRequiredEnv("REDDLINKS_DB_USERNAME")
+		env.DBPass = reader.getRequiredEnv("REDDLINKS_DB_PASSWORD")
+		env.DBHost = reader.getRequiredEnv("REDDLINKS_DB_HOST")
+		env.DBPort = reader.getRequiredEnv("REDDLINKS_DB_PORT")
+		env.DBName = reader.getRequiredEnv("REDDLINKS_DB_NAME")
 	}
 
 	// Add trailing slash to instance URL if missing
@@ -215,37 +572,101 @@ func GetEnv(envFile string) Env {
 	}
 
 	// Load numeric values
-	env.TimeBetweenCleanups = getEnvAsIntWithDefault("REDDLINKS_TIME_BETWEEN_DB_CLEANUPS", defaultCleanupTime)
-	env.DefaultLength = getEnvAsIntWithDefault("REDDLINKS_DEF_SHORT_LENGTH", defaultShortLength)
-	env.DefaultMaxLength = getEnvAsIntWithDefault("REDDLINKS_MAX_SHORT_LENGTH", defaultMaxLength)
-	env.DefaultMaxCustomLength = getEnvAsIntWithDefault("REDDLINKS_MAX_CUSTOM_SHORT_LENGTH", defaultCustomShortLength)
-	env.DefaultExpiryTime = getEnvAsIntWithDefault("REDDLINKS_DEF_EXPIRY_TIME", defaultExpiryTime)
+	env.TimeBetweenCleanups = reader.getEnvAsIntWithDefault("REDDLINKS_TIME_BETWEEN_DB_CLEANUPS", defaultCleanupTime)
+	env.DefaultLength = reader.getEnvAsIntWithDefault("REDDLINKS_DEF_SHORT_LENGTH", defaultShortLength)
+	env.DefaultMaxLength = reader.getEnvAsIntWithDefault("REDDLINKS_MAX_SHORT_LENGTH", defaultMaxLength)
+	env.DefaultMaxCustomLength = reader.getEnvAsIntWithDefault("REDDLINKS_MAX_CUSTOM_SHORT_LENGTH", defaultCustomShortLength)
+	env.DefaultExpiryTime = reader.getEnvAsIntWithDefault("REDDLINKS_DEF_EXPIRY_TIME", defaultExpiryTime)
+	env.CacheSize = reader.getEnvAsIntWithDefault("REDDLINKS_CACHE_SIZE", 0)
+
+	// Server timeouts and limits
+	env.ShutdownTimeout = reader.getEnvAsIntWithDefault("REDDLINKS_SHUTDOWN_TIMEOUT", defaultShutdownTimeout)
+	env.ReadTimeout = reader.getEnvAsIntWithDefault("REDDLINKS_READ_TIMEOUT", defaultReadTimeout)
+	env.ReadHeaderTimeout = reader.getEnvAsIntWithDefault("REDDLINKS_READ_HEADER_TIMEOUT", defaultReadHeaderTimeout)
+	env.WriteTimeout = reader.getEnvAsIntWithDefault("REDDLINKS_WRITE_TIMEOUT", defaultWriteTimeout)
+	env.IdleTimeout = reader.getEnvAsIntWithDefault("REDDLINKS_IDLE_TIMEOUT", defaultIdleTimeout)
+	env.BatchTimeout = reader.getEnvAsIntWithDefault("REDDLINKS_BATCH_TIMEOUT", defaultBatchTimeout)
+	env.MaxHeaderBytes = reader.getEnvAsIntWithDefault("REDDLINKS_MAX_HEADER_BYTES", defaultMaxHeaderBytes)
+	env.MaxBodyBytes = reader.getEnvAsIntWithDefault("REDDLINKS_MAX_BODY_BYTES", utils.DefaultMaxBodyBytes)
+	env.MaxBatchBytes = reader.getEnvAsIntWithDefault("REDDLINKS_MAX_BATCH_BYTES", utils.DefaultMaxBatchBytes)
 
 	// Optional values
 	env.ContactEmail = os.Getenv("REDDLINKS_CONTACT_EMAIL")
-
-	// Validate the configuration
-	if err := env.EnvCheck(); err != nil {
-		log.Fatal(err)
+	env.Analytics = reader.getEnvAsBoolWithDefault("REDDLINKS_ANALYTICS", false)
+	env.AdminPassword = os.Getenv("REDDLINKS_ADMIN_PASSWORD")
+	env.AdminPasswordHash = os.Getenv("REDDLINKS_ADMIN_PASSWORD_HASH")
+	env.RestrictCreation = reader.getEnvAsBoolWithDefault("REDDLINKS_RESTRICT_CREATION", false)
+
+	// Account settings
+	env.Accounts = reader.getEnvAsBoolWithDefault("REDDLINKS_ACCOUNTS", false)
+	env.Registration = reader.getEnvAsBoolWithDefault("REDDLINKS_REGISTRATION", true)
+
+	// OpenID Connect settings
+	env.OIDCIssuer = os.Getenv("REDDLINKS_OIDC_ISSUER")
+	env.OIDCClientID = os.Getenv("REDDLINKS_OIDC_CLIENT_ID")
+	env.OIDCClientSecret = os.Getenv("REDDLINKS_OIDC_CLIENT_SECRET")
+	env.OIDCScopes = getEnvWithDefault("REDDLINKS_OIDC_SCOPES", "openid profile email")
+	env.OIDCUsernameClaim = getEnvWithDefault("REDDLINKS_OIDC_USERNAME_CLAIM", "preferred_username")
+	env.OIDCRolesClaim = getEnvWithDefault("REDDLINKS_OIDC_ROLES_CLAIM", "roles")
+	env.OIDCAdminRole = os.Getenv("REDDLINKS_OIDC_ADMIN_ROLE")
+	env.OIDCCreatorRole = os.Getenv("REDDLINKS_OIDC_CREATOR_ROLE")
+
+	// Metrics settings
+	env.Metrics = reader.getEnvAsBoolWithDefault("REDDLINKS_METRICS", false)
+	env.MetricsToken = os.Getenv("REDDLINKS_METRICS_TOKEN")
+
+	// TLS settings
+	env.TLSCertFile = os.Getenv("REDDLINKS_TLS_CERT_FILE")
+	env.TLSKeyFile = os.Getenv("REDDLINKS_TLS_KEY_FILE")
+	env.ACMEDomains = os.Getenv("REDDLINKS_ACME_DOMAINS")
+	env.ACMEEmail = os.Getenv("REDDLINKS_ACME_EMAIL")
+	env.ACMEDirectory = os.Getenv("REDDLINKS_ACME_DIRECTORY")
+	env.ACMEDirectoryCA = os.Getenv("REDDLINKS_ACME_DIRECTORY_CA")
+	env.ACMECacheDir = os.Getenv("REDDLINKS_ACME_CACHE_DIR")
+	env.RedirectAddr = os.Getenv("REDDLINKS_HTTP_REDIRECT_ADDR")
+	env.HSTSMaxAge = reader.getEnvAsIntWithDefault("REDDLINKS_HSTS_MAX_AGE", defaultHSTSMaxAge)
+
+	// Rate limit settings
+	env.RateLimitCreate = reader.getEnvAsIntWithDefault("REDDLINKS_RATE_LIMIT_CREATE", defaultRateLimitCreate)
+	env.RateLimitCreateBurst = reader.getEnvAsIntWithDefault("REDDLINKS_RATE_LIMIT_CREATE_BURST", defaultRateLimitCreateBurst)
+	env.RateLimitBatch = reader.getEnvAsIntWithDefault("REDDLINKS_RATE_LIMIT_BATCH", defaultRateLimitBatch)
+	env.RateLimitBatchBurst = reader.getEnvAsIntWithDefault("REDDLINKS_RATE_LIMIT_BATCH_BURST", defaultRateLimitBatchBurst)
+	env.RateLimitPassword = reader.getEnvAsIntWithDefault("REDDLINKS_RATE_LIMIT_PASSWORD", defaultRateLimitPassword)
+	env.RateLimitPasswordBurst = reader.getEnvAsIntWithDefault("REDDLINKS_RATE_LIMIT_PASSWORD_BURST", defaultRateLimitPasswordBurst)
+	env.RateLimitStore = getEnvWithDefault("REDDLINKS_RATE_LIMIT_STORE", "memory")
+
+	// Reverse proxy settings
+	env.TrustedProxies = os.Getenv("REDDLINKS_TRUSTED_PROXIES")
+
+	// Log settings with defaults
+	env.LogFormat = getEnvWithDefault("REDDLINKS_LOG_FORMAT", logging.FormatText)
+	env.LogLevel = getEnvWithDefault("REDDLINKS_LOG_LEVEL", "info")
+
+	// Validate the configuration, along with the errors encountered while reading it
+	if err := errors.Join(append(reader.errs, env.EnvCheck())...); err != nil {
+		return env, err
 	}
 
-	return env
+	return env, nil
 }
 
 // loadEnvFile attempts to load environment variables from a specified .env file.
 // If the file exists at the given path, it will be loaded using godotenv.Load().
 // If the file does not exist, this function does nothing and returns silently.
-// If the file exists but cannot be loaded, the function will terminate the program
-// with a fatal error.
 //
 // Parameters:
 //   - envFile: Path to the .env file to load.
-func loadEnvFile(envFile string) {
+//
+// Returns:
+//   - An error if the file exists but cannot be loaded.
+func loadEnvFile(envFile string) error {
 	if _, err := os.Stat(envFile); !errors.Is(err, os.ErrNotExist) {
 		if err := godotenv.Load(envFile); err != nil {
-			log.Fatal(err)
+			return fmt.Errorf("the env file %w: %w", ErrRead, err)
 		}
 	}
+
+	return nil
 }
 
 // getEnvWithDefault retrieves the value of an environment variable with a fallback
@@ -267,22 +688,23 @@ func getEnvWithDefault(key, defaultValue string) string {
 	return value
 }
 
+// envReader reads environment variables, keeping every error encountered instead of stopping at the first one.
+type envReader struct {
+	errs []error
+}
+
 // getRequiredEnv retrieves the value of a required environment variable.
-// If the environment variable is not set or is empty, the function will
-// terminate the program with a fatal error.
+// If the environment variable is not set or is empty, an error is recorded.
 //
 // Parameters:
 //   - key: The name of the required environment variable to retrieve.
 //
 // Returns:
 //   - The value of the environment variable.
-//
-// Fatal error if:
-//   - The environment variable is not set or is empty.
-func getRequiredEnv(key string) string {
+func (reader *envReader) getRequiredEnv(key string) string {
 	value := os.Getenv(key)
 	if value == "" {
-		log.Fatalf("reddlinks could not find a value for %s env variable", key)
+		reader.errs = append(reader.errs, fmt.Errorf("the %s env variable %w", key, ErrEmpty))
 	}
 
 	return value
@@ -291,8 +713,7 @@ func getRequiredEnv(key string) string {
 // getEnvAsIntWithDefault retrieves an environment variable as an integer with
 // a fallback default value. If the environment variable is not set or is empty,
 // the function returns the provided default value. If the variable is set but
-// cannot be converted to an integer, the function will terminate the program
-// with a fatal error.
+// cannot be converted to an integer, an error is recorded.
 //
 // Parameters:
 //   - key: The name of the environment variable to retrieve.
@@ -300,10 +721,7 @@ func getRequiredEnv(key string) string {
 //
 // Returns:
 //   - The integer value of the environment variable, or the default value if not set.
-//
-// Fatal error if:
-//   - The environment variable is set but cannot be converted to an integer.
-func getEnvAsIntWithDefault(key string, defaultValue int) int {
+func (reader *envReader) getEnvAsIntWithDefault(key string, defaultValue int) int {
 	valueStr := os.Getenv(key)
 	if valueStr == "" {
 		return defaultValue
@@ -311,7 +729,36 @@ func getEnvAsIntWithDefault(key string, defaultValue int) int {
 
 	value, err := strconv.Atoi(valueStr)
 	if err != nil {
-		log.Fatalf("the value for %s couldn't be converted to an integer: %v", key, err)
+		reader.errs = append(reader.errs, fmt.Errorf("the value for %s %w as an integer: %w", key, ErrRead, err))
+
+		return defaultValue
+	}
+
+	return value
+}
+
+// getEnvAsBoolWithDefault retrieves an environment variable as a boolean with
+// a fallback default value. If the environment variable is not set or is empty,
+// the function returns the provided default value. If the variable is set but
+// cannot be converted to a boolean, an error is recorded.
+//
+// Parameters:
+//   - key: The name of the environment variable to retrieve.
+//   - defaultValue: The boolean value to return if the environment variable is not set or empty.
+//
+// Returns:
+//   - The boolean value of the environment variable, or the default value if not set.
+func (reader *envReader) getEnvAsBoolWithDefault(key string, defaultValue bool) bool {
+	valueStr := os.Getenv(key)
+	if valueStr == "" {
+		return defaultValue
+	}
+
+	value, err := strconv.ParseBool(valueStr)
+	if err != nil {
+		reader.errs = append(reader.errs, fmt.Errorf("the value for %s %w as a boolean: %w", key, ErrRead, err))
+
+		return defaultValue
 	}
 
 	return value
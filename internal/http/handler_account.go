@@ -0,0 +1,382 @@
+//    reddlinks, a simple link shortener written in Go.
+//    Copyright (C) 2025 redd
+//
+//    This program is free software: you can redistribute it and/or modify
+//    it under the terms of the GNU General Public License as published by
+//    the Free Software Foundation, either version 3 of the License, or
+//    (at your option) any later version.
+//
+//    This program is distributed in the hope that it will be useful,
+//    but WITHOUT ANY WARRANTY; without even the implied warranty of
+//    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//    GNU General Public License for more details.
+//
+//    You should have received a copy of the GNU General Public License
+//    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package http
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redds-be/reddlinks/internal/accounts"
+	"github.com/redds-be/reddlinks/internal/database"
+	"github.com/redds-be/reddlinks/internal/links"
+	"github.com/redds-be/reddlinks/internal/ratelimit"
+	"github.com/redds-be/reddlinks/internal/utils"
+)
+
+// accountPageSize is the number of links displayed per page of the account page.
+const accountPageSize = 50
+
+// AccountPage defines what can be displayed on the account page of a logged in user.
+//
+// Username is the name of the user,
+// Links are the links of the current page, all created by the user,
+// Total is the number of links created by the user,
+// Page is the number of the current page,
+// PrevURL and NextURL link to the previous and next pages, they are empty if there are no such pages,
+// Edit is the link being edited, nil if none is.
+type AccountPage struct {
+	Username string
+	Links    []AdminLink
+	Total    int
+	Page     int
+	PrevURL  string
+	NextURL  string
+	Edit     *AdminLink
+}
+
+// accountURL returns the URL of a given page of the account page.
+func accountURL(page int) string {
+	if page > 1 {
+		return "/account?page=" + strconv.Itoa(page)
+	}
+
+	return "/account"
+}
+
+// frontAccountLogin displays the login form of the account page, along with the registration form if it's open,
+// with an optional error message.
+func (conf Configuration) frontAccountLogin(
+	writer http.ResponseWriter,
+	code int,
+	errMsg string,
+	locale utils.PageLocaleTl,
+) {
+	// Set what is going to be displayed on the login form
+	pageParams := &PageParameters{
+		InstanceTitle: conf.InstanceName,
+		InstanceURL:   conf.InstanceURL,
+		Version:       conf.Version,
+		Error:         errMsg,
+		Accounts:      conf.Accounts,
+		Registration:  conf.Registration,
+		SingleSignOn:  conf.OIDC != nil,
+	}
+
+	// Display the login form
+	RenderTemplate(writer, "account", pageParams, code, locale)
+}
+
+// FrontHandlerAccount displays the links created by the logged in user, or the login form if the client isn't logged in.
+//
+// The links are listed using [database.LinkStore.ListLinks] filtered on their owner with the page given in the query,
+// the link given by the "edit" query value is displayed in a form to update it if the user owns it.
+// The account page is not found if the accounts are disabled.
+func (conf Configuration) FrontHandlerAccount(writer http.ResponseWriter, req *http.Request) { //nolint:funlen
+	// Get the locale
+	locale := utils.GetLocale(req, conf.Locales, conf.SupportedLocales)
+
+	// The account page is disabled without the accounts
+	if !conf.Accounts {
+		conf.FrontErrorPage(writer, req, http.StatusNotFound, locale.ErrNotFound, "/")
+
+		return
+	}
+
+	// Ask for the credentials if the client isn't logged in
+	user, loggedIn := accounts.FromContext(req.Context())
+	if !loggedIn {
+		conf.frontAccountLogin(writer, http.StatusOK, "", locale)
+
+		return
+	}
+
+	// Get the page from the query
+	page, err := strconv.Atoi(req.URL.Query().Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	// List the links of the user
+	links, total, err := conf.Store.ListLinks(database.LinkFilter{
+		Owner:  user.ID,
+		Limit:  accountPageSize,
+		Offset: (page - 1) * accountPageSize,
+	})
+	if err != nil {
+		conf.FrontErrorPage(writer, req, http.StatusInternalServerError, locale.ErrListLinks, "/")
+
+		return
+	}
+
+	accountPage := &AccountPage{
+		Username: user.Username,
+		Links:    make([]AdminLink, 0, len(links)),
+		Total:    total,
+		Page:     page,
+	}
+
+	for _, link := range links {
+		accountPage.Links = append(accountPage.Links, AdminLink{
+			Short:     link.Short,
+			URL:       link.URL,
+			CreatedAt: link.CreatedAt.Format(time.RFC822),
+			ExpireAt:  link.ExpireAt.Format(time.RFC822),
+			Protected: link.Password != "",
+		})
+	}
+
+	// Display the link to edit, the links of the other users are not found
+	if short := req.URL.Query().Get("edit"); short != "" {
+		link, err := conf.Store.GetLinkByShort(short)
+		if err != nil || link.Owner != user.ID {
+			conf.FrontErrorPage(writer, req, http.StatusNotFound, locale.ErrNotFound, "/account")
+
+			return
+		}
+
+		accountPage.Edit = &AdminLink{
+			Short:     link.Short,
+			URL:       link.URL,
+			ExpireAt:  link.ExpireAt.Format(time.RFC822),
+			Protected: link.Password != "",
+		}
+	}
+
+	// Link to the surrounding pages if they exist
+	if page > 1 {
+		accountPage.PrevURL = accountURL(page - 1)
+	}
+
+	if page*accountPageSize < total {
+		accountPage.NextURL = accountURL(page + 1)
+	}
+
+	// Set what is going to be displayed on the account page
+	pageParams := &PageParameters{
+		InstanceTitle: conf.InstanceName,
+		InstanceURL:   conf.InstanceURL,
+		Version:       conf.Version,
+		Account:       accountPage,
+		Accounts:      conf.Accounts,
+		Username:      user.Username,
+	}
+
+	// Display the account page
+	RenderTemplate(writer, "account", pageParams, http.StatusOK, locale)
+}
+
+// FrontHandlerAccountAction applies an action posted from the account page.
+//
+// The "login" and "register" actions check the credentials, see [Configuration.accountLogin] and [Configuration.accountRegister],
+// every other action requires a logged in user: "logout" closes the session, "update" changes the URL, the expiration date
+// or the password of a link using [links.Configuration.UpdateOwnedLink], and "delete" deletes a link using
+// [links.Configuration.DeleteOwnedLink], the links of the other users are not found.
+// The client is then redirected to the account page.
+func (conf Configuration) FrontHandlerAccountAction(writer http.ResponseWriter, req *http.Request) { //nolint:funlen
+	// Get the locale
+	locale := utils.GetLocale(req, conf.Locales, conf.SupportedLocales)
+
+	// The account page is disabled without the accounts
+	if !conf.Accounts {
+		conf.FrontErrorPage(writer, req, http.StatusNotFound, locale.ErrNotFound, "/")
+
+		return
+	}
+
+	action := req.FormValue("action")
+
+	// Logging in and registering are the only actions that don't require a session
+	switch action {
+	case "login":
+		conf.accountLogin(writer, req, locale)
+
+		return
+	case "register":
+		conf.accountRegister(writer, req, locale)
+
+		return
+	}
+
+	user, loggedIn := accounts.FromContext(req.Context())
+	if !loggedIn {
+		conf.frontAccountLogin(writer, http.StatusUnauthorized, locale.ErrAccountLoginRequired, locale)
+
+		return
+	}
+
+	// Create an adapter for links
+	linksAdapter := conf.newLinksAdapter()
+
+	switch action {
+	case "logout":
+		// Close the session, the cookie is cleared even if the session is already gone
+		if cookie, err := req.Cookie(accounts.SessionCookie); err == nil {
+			if err := conf.Store.DeleteSession(accounts.Hash(cookie.Value)); err != nil && !errors.Is(err, sql.ErrNoRows) {
+				conf.FrontErrorPage(writer, req, http.StatusInternalServerError, locale.ErrCheckSession, "/account")
+
+				return
+			}
+		}
+
+		http.SetCookie(writer, &http.Cookie{
+			Name:     accounts.SessionCookie,
+			Value:    "",
+			Path:     "/",
+			MaxAge:   -1,
+			HttpOnly: true,
+			Secure:   strings.HasPrefix(conf.InstanceURL, "https://"),
+			SameSite: http.SameSiteLaxMode,
+		})
+	case "update":
+		params := utils.Parameters{
+			URL:        req.FormValue("url"),
+			ExpireDate: req.FormValue("expire_datetime"),
+			Password:   req.FormValue("password"),
+		}
+
+		if _, err := linksAdapter.UpdateOwnedLink(req.FormValue("short"), user.ID, params); err != nil {
+			linkErr := links.AsError(err)
+			conf.FrontErrorPage(writer, req, linkErr.Status, linkErr.Code.Message(locale), "/account")
+
+			return
+		}
+	case "delete":
+		if err := linksAdapter.DeleteOwnedLink(req.FormValue("short"), user.ID); err != nil {
+			linkErr := links.AsError(err)
+			conf.FrontErrorPage(writer, req, linkErr.Status, linkErr.Code.Message(locale), "/account")
+
+			return
+		}
+	default:
+		conf.FrontErrorPage(writer, req, http.StatusBadRequest, locale.ErrUnknownAction, "/account")
+
+		return
+	}
+
+	// Go back to the account page
+	http.Redirect(writer, req, "/account", http.StatusSeeOther)
+}
+
+// accountLogin checks the posted credentials using [accounts.Login] and opens a session if they match.
+//
+// The attempts are limited like the password attempts of the links.
+func (conf Configuration) accountLogin(writer http.ResponseWriter, req *http.Request, locale utils.PageLocaleTl) {
+	// Limit how often a client can try a password
+	if !conf.allow(writer, req, ratelimit.RoutePassword) {
+		conf.FrontErrorPage(writer, req, http.StatusTooManyRequests, locale.ErrRateLimited, "/account")
+
+		return
+	}
+
+	// Check the credentials
+	user, err := accounts.Login(conf.Store, req.FormValue("username"), req.FormValue("password"))
+	if errors.Is(err, accounts.ErrWrongCredentials) {
+		conf.Metrics.PasswordFailure()
+		conf.frontAccountLogin(writer, http.StatusUnauthorized, locale.ErrWrongCredentials, locale)
+
+		return
+	} else if err != nil {
+		conf.FrontErrorPage(writer, req, http.StatusInternalServerError, locale.ErrCompHash, "/account")
+
+		return
+	}
+
+	conf.openSession(writer, req, user, "/account", locale)
+}
+
+// accountRegister creates an account using [accounts.Register] if the registration is open, then logs the user in.
+//
+// The registrations are limited like the password attempts of the links, hashing the password being as slow.
+func (conf Configuration) accountRegister(writer http.ResponseWriter, req *http.Request, locale utils.PageLocaleTl) {
+	if !conf.Registration {
+		conf.frontAccountLogin(writer, http.StatusForbidden, locale.ErrRegistrationClosed, locale)
+
+		return
+	}
+
+	// Limit how often a client can register
+	if !conf.allow(writer, req, ratelimit.RoutePassword) {
+		conf.FrontErrorPage(writer, req, http.StatusTooManyRequests, locale.ErrRateLimited, "/account")
+
+		return
+	}
+
+	// Create the account
+	user, err := accounts.Register(conf.Store, req.FormValue("username"), req.FormValue("password"))
+
+	switch {
+	case err == nil:
+	case errors.Is(err, accounts.ErrInvalidUsername):
+		conf.frontAccountLogin(writer, http.StatusBadRequest, locale.ErrInvalidUsername, locale)
+
+		return
+	case errors.Is(err, accounts.ErrInvalidPassword):
+		conf.frontAccountLogin(writer, http.StatusBadRequest, locale.ErrInvalidPassword, locale)
+
+		return
+	case errors.Is(err, database.ErrUsernameInUse):
+		conf.frontAccountLogin(writer, http.StatusConflict, locale.ErrUsernameInUse, locale)
+
+		return
+	default:
+		conf.FrontErrorPage(writer, req, http.StatusInternalServerError, locale.ErrCreateUser, "/account")
+
+		return
+	}
+
+	conf.openSession(writer, req, user, "/account", locale)
+}
+
+// openSession stores a new session for a user, gives its token to the client in the [accounts.SessionCookie] cookie,
+// then redirects the client to a given path, the account page or the admin dashboard.
+//
+// The cookie is sent to every route, so that the links created by the user belong to them,
+// but not along with the cross-site requests changing data.
+func (conf Configuration) openSession(
+	writer http.ResponseWriter,
+	req *http.Request,
+	user database.User,
+	next string,
+	locale utils.PageLocaleTl,
+) {
+	token, session, err := accounts.NewSession(user.ID)
+	if err == nil {
+		err = conf.Store.CreateSession(session)
+	}
+
+	if err != nil {
+		conf.FrontErrorPage(writer, req, http.StatusInternalServerError, locale.ErrCreateSession, "/account")
+
+		return
+	}
+
+	http.SetCookie(writer, &http.Cookie{
+		Name:     accounts.SessionCookie,
+		Value:    token,
+		Path:     "/",
+		Expires:  session.ExpireAt,
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(conf.InstanceURL, "https://"),
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	http.Redirect(writer, req, next, http.StatusSeeOther)
+}
@@ -0,0 +1,587 @@
+//    reddlinks, a simple link shortener written in Go.
+//    Copyright (C) 2025 redd
+//
+//    This program is free software: you can redistribute it and/or modify
+//    it under the terms of the GNU General Public License as published by
+//    the Free Software Foundation, either version 3 of the License, or
+//    (at your option) any later version.
+//
+//    This program is distributed in the hope that it will be useful,
+//    but WITHOUT ANY WARRANTY; without even the implied warranty of
+//    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//    GNU General Public License for more details.
+//
+//    You should have received a copy of the GNU General Public License
+//    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package http
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redds-be/reddlinks/internal/accounts"
+	"github.com/redds-be/reddlinks/internal/admin"
+	"github.com/redds-be/reddlinks/internal/apikeys"
+	"github.com/redds-be/reddlinks/internal/database"
+	"github.com/redds-be/reddlinks/internal/ratelimit"
+	"github.com/redds-be/reddlinks/internal/utils"
+)
+
+// adminPageSize is the number of links displayed per page of the admin dashboard.
+const adminPageSize = 50
+
+// adminDateFormat is the format of the dates used to filter links, as sent by date inputs.
+const adminDateFormat = "2006-01-02"
+
+// AdminPage defines what can be displayed on the admin dashboard.
+//
+// Links are the links of the current page,
+// Total is the number of links matching the filter,
+// Filter holds the values of the filter form,
+// Query is the encoded filter, used to keep it after an action,
+// Page is the number of the current page,
+// PrevURL and NextURL link to the previous and next pages, they are empty if there are no such pages,
+// Cache holds the counters of the cache, nil if the cache is disabled,
+// Keys are the API keys, Scopes are the scopes a new key can be given,
+// NewKey is the plain API key that was just created, it is only displayed once.
+type AdminPage struct {
+	Links   []AdminLink
+	Total   int
+	Filter  AdminFilter
+	Query   string
+	Page    int
+	PrevURL string
+	NextURL string
+	Cache   *database.CacheStats
+	Keys    []AdminKey
+	Scopes  []apikeys.Scope
+	NewKey  string
+}
+
+// AdminLink defines a link as displayed on the admin dashboard.
+//
+// Short is the short of the link,
+// URL is the original URL,
+// CreatedAt and ExpireAt are the formatted creation and expiration dates,
+// Protected tells if the link is password protected.
+type AdminLink struct {
+	Short     string
+	URL       string
+	CreatedAt string
+	ExpireAt  string
+	Protected bool
+}
+
+// AdminKey defines an API key as displayed on the admin dashboard.
+//
+// ID identifies the key to revoke,
+// Label describes who or what the key was given to,
+// Prefix is the beginning of the key,
+// Scopes is the comma-separated list of what the key allows,
+// CreatedAt and ExpireAt are the formatted creation and expiration dates, ExpireAt is empty if the key never expires,
+// Expired tells if the key has expired.
+type AdminKey struct {
+	ID        string
+	Label     string
+	Prefix    string
+	Scopes    string
+	CreatedAt string
+	ExpireAt  string
+	Expired   bool
+}
+
+// AdminFilter defines the values of the filter form of the admin dashboard.
+//
+// Short and URL are substrings of the short and of the URL,
+// CreatedAfter, CreatedBefore, ExpiresAfter and ExpiresBefore are dates formatted as YYYY-MM-DD.
+type AdminFilter struct {
+	Short         string
+	URL           string
+	CreatedAfter  string
+	CreatedBefore string
+	ExpiresAfter  string
+	ExpiresBefore string
+}
+
+// values returns the filter as URL query values, empty values are omitted.
+func (filter AdminFilter) values() url.Values {
+	values := url.Values{}
+
+	for key, value := range map[string]string{
+		"short":          filter.Short,
+		"url":            filter.URL,
+		"created_after":  filter.CreatedAfter,
+		"created_before": filter.CreatedBefore,
+		"expires_after":  filter.ExpiresAfter,
+		"expires_before": filter.ExpiresBefore,
+	} {
+		if value != "" {
+			values.Set(key, value)
+		}
+	}
+
+	return values
+}
+
+// linkFilter converts the filter into a [database.LinkFilter] for a given page.
+//
+// Dates are interpreted in UTC, the "before" dates are inclusive.
+//
+// Parameters:
+//   - page: The requested page, starting at 1
+//
+// Returns:
+//   - database.LinkFilter: The filter to give to [database.LinkStore.ListLinks]
+//   - error: Any error encountered while parsing the dates
+func (filter AdminFilter) linkFilter(page int) (database.LinkFilter, error) {
+	linkFilter := database.LinkFilter{
+		Short:  filter.Short,
+		URL:    filter.URL,
+		Limit:  adminPageSize,
+		Offset: (page - 1) * adminPageSize,
+	}
+
+	// Parse the dates, the ones that aren't set are left as zero values
+	for _, date := range []struct {
+		value     string
+		dst       *time.Time
+		inclusive bool
+	}{
+		{filter.CreatedAfter, &linkFilter.CreatedAfter, false},
+		{filter.CreatedBefore, &linkFilter.CreatedBefore, true},
+		{filter.ExpiresAfter, &linkFilter.ExpireAfter, false},
+		{filter.ExpiresBefore, &linkFilter.ExpireBefore, true},
+	} {
+		if date.value == "" {
+			continue
+		}
+
+		parsed, err := time.Parse(adminDateFormat, date.value)
+		if err != nil {
+			return database.LinkFilter{}, err
+		}
+
+		// Include the whole day
+		if date.inclusive {
+			parsed = parsed.AddDate(0, 0, 1)
+		}
+
+		*date.dst = parsed
+	}
+
+	return linkFilter, nil
+}
+
+// adminURL returns the URL of a given page of the admin dashboard using a given filter.
+func adminURL(values url.Values, page int) string {
+	pageValues := url.Values{}
+	for key, value := range values {
+		pageValues[key] = value
+	}
+
+	if page > 1 {
+		pageValues.Set("page", strconv.Itoa(page))
+	}
+
+	if len(pageValues) == 0 {
+		return "/admin"
+	}
+
+	return "/admin?" + pageValues.Encode()
+}
+
+// adminEnabled tells if the admin dashboard is enabled, which requires an admin password or an admin role.
+func (conf Configuration) adminEnabled() bool {
+	return conf.Admin != nil || conf.AdminRole != ""
+}
+
+// isAdminUser tells if the client is logged in as a user given the admin role by the identity provider.
+func (conf Configuration) isAdminUser(req *http.Request) bool {
+	user, loggedIn := accounts.FromContext(req.Context())
+
+	return loggedIn && accounts.HasRole(user, conf.AdminRole)
+}
+
+// isAdmin tells if the client has a valid admin session, was authenticated with an API key having the admin scope,
+// or is logged in as a user having the admin role.
+func (conf Configuration) isAdmin(req *http.Request) bool {
+	if apikeys.Allows(req, apikeys.ScopeAdmin) || conf.isAdminUser(req) {
+		return true
+	}
+
+	if conf.Admin == nil {
+		return false
+	}
+
+	cookie, err := req.Cookie(admin.SessionCookie)
+	if err != nil {
+		return false
+	}
+
+	return conf.Admin.ValidSession(cookie.Value)
+}
+
+// frontAdminLogin displays the login form of the admin dashboard with an optional error message.
+func (conf Configuration) frontAdminLogin(
+	writer http.ResponseWriter,
+	code int,
+	errMsg string,
+	locale utils.PageLocaleTl,
+) {
+	// Set what is going to be displayed on the login form
+	pageParams := &PageParameters{
+		InstanceTitle: conf.InstanceName,
+		InstanceURL:   conf.InstanceURL,
+		Version:       conf.Version,
+		Error:         errMsg,
+		AdminPassword: conf.Admin != nil,
+		SingleSignOn:  conf.OIDC != nil && conf.AdminRole != "",
+	}
+
+	// Display the login form
+	RenderTemplate(writer, "admin", pageParams, code, locale)
+}
+
+// FrontHandlerAdmin displays the admin dashboard, or its login form if the client isn't logged in.
+//
+// The links are listed using [database.LinkStore.ListLinks] with the filter and the page given in the query,
+// the admin dashboard is not found if neither an admin password nor an admin role is configured.
+func (conf Configuration) FrontHandlerAdmin(writer http.ResponseWriter, req *http.Request) {
+	// Get the locale
+	locale := utils.GetLocale(req, conf.Locales, conf.SupportedLocales)
+
+	// The admin dashboard is disabled without an admin password or an admin role
+	if !conf.adminEnabled() {
+		conf.FrontErrorPage(writer, req, http.StatusNotFound, locale.ErrNotFound, "/")
+
+		return
+	}
+
+	// Ask for the admin password if the client isn't logged in
+	if !conf.isAdmin(req) {
+		conf.frontAdminLogin(writer, http.StatusOK, "", locale)
+
+		return
+	}
+
+	conf.frontAdminDashboard(writer, req, "", locale)
+}
+
+// frontAdminDashboard displays the admin dashboard to a logged in client, along with an API key that was just created.
+//
+// The links are filtered and paginated using the query, the API keys are listed using [database.APIKeyStore.ListAPIKeys].
+func (conf Configuration) frontAdminDashboard( //nolint:funlen
+	writer http.ResponseWriter,
+	req *http.Request,
+	newKey string,
+	locale utils.PageLocaleTl,
+) {
+	// Get the filter and the page from the query
+	query := req.URL.Query()
+	filter := AdminFilter{
+		Short:         query.Get("short"),
+		URL:           query.Get("url"),
+		CreatedAfter:  query.Get("created_after"),
+		CreatedBefore: query.Get("created_before"),
+		ExpiresAfter:  query.Get("expires_after"),
+		ExpiresBefore: query.Get("expires_before"),
+	}
+
+	page, err := strconv.Atoi(query.Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	linkFilter, err := filter.linkFilter(page)
+	if err != nil {
+		conf.FrontErrorPage(writer, req, http.StatusBadRequest, locale.ErrInvalidFilter, "/admin")
+
+		return
+	}
+
+	// List the links of the page
+	links, total, err := conf.Store.ListLinks(linkFilter)
+	if err != nil {
+		conf.FrontErrorPage(writer, req, http.StatusInternalServerError, locale.ErrListLinks, "/")
+
+		return
+	}
+
+	// List the API keys
+	keys, err := conf.Store.ListAPIKeys()
+	if err != nil {
+		conf.FrontErrorPage(writer, req, http.StatusInternalServerError, locale.ErrListAPIKeys, "/")
+
+		return
+	}
+
+	adminPage := &AdminPage{
+		Links:  make([]AdminLink, 0, len(links)),
+		Total:  total,
+		Filter: filter,
+		Query:  filter.values().Encode(),
+		Page:   page,
+		Keys:   make([]AdminKey, 0, len(keys)),
+		Scopes: apikeys.Scopes,
+		NewKey: newKey,
+	}
+
+	for _, link := range links {
+		adminPage.Links = append(adminPage.Links, AdminLink{
+			Short:     link.Short,
+			URL:       link.URL,
+			CreatedAt: link.CreatedAt.Format(time.RFC822),
+			ExpireAt:  link.ExpireAt.Format(time.RFC822),
+			Protected: link.Password != "",
+		})
+	}
+
+	now := time.Now()
+	for _, key := range keys {
+		adminKey := AdminKey{
+			ID:        key.ID.String(),
+			Label:     key.Label,
+			Prefix:    key.Prefix,
+			Scopes:    key.Scopes,
+			CreatedAt: key.CreatedAt.Format(time.RFC822),
+			Expired:   apikeys.Expired(key, now),
+		}
+
+		if !key.ExpireAt.IsZero() {
+			adminKey.ExpireAt = key.ExpireAt.Format(time.RFC822)
+		}
+
+		adminPage.Keys = append(adminPage.Keys, adminKey)
+	}
+
+	// Show the counters of the cache if it's enabled
+	if cache, isCached := conf.Store.(*database.CachedStore); isCached {
+		stats := cache.Stats()
+		adminPage.Cache = &stats
+	}
+
+	// Link to the surrounding pages if they exist
+	if page > 1 {
+		adminPage.PrevURL = adminURL(filter.values(), page-1)
+	}
+
+	if page*adminPageSize < total {
+		adminPage.NextURL = adminURL(filter.values(), page+1)
+	}
+
+	// Set what is going to be displayed on the admin dashboard
+	pageParams := &PageParameters{
+		InstanceTitle: conf.InstanceName,
+		InstanceURL:   conf.InstanceURL,
+		Version:       conf.Version,
+		Admin:         adminPage,
+	}
+
+	// Display the admin dashboard
+	RenderTemplate(writer, "admin", pageParams, http.StatusOK, locale)
+}
+
+// FrontHandlerAdminAction applies an action posted from the admin dashboard.
+//
+// The "login" action checks the admin password using [admin.Auth.CheckPassword] and opens a session,
+// every other action requires a valid session, see [Configuration.isAdmin]: "logout" closes it, "delete" deletes the selected links
+// using [database.LinkStore.DeleteLinks], "expire" makes the selected links expire now using [database.LinkStore.ExpireLinks],
+// "create_key" creates an API key, see [Configuration.adminCreateKey], and "revoke_key" deletes an API key.
+// The client is then redirected to the admin dashboard, keeping the filter it was using,
+// except after the creation of an API key, which is displayed once instead.
+func (conf Configuration) FrontHandlerAdminAction(writer http.ResponseWriter, req *http.Request) { //nolint:funlen,cyclop
+	// Get the locale
+	locale := utils.GetLocale(req, conf.Locales, conf.SupportedLocales)
+
+	// The admin dashboard is disabled without an admin password or an admin role
+	if !conf.adminEnabled() {
+		conf.FrontErrorPage(writer, req, http.StatusNotFound, locale.ErrNotFound, "/")
+
+		return
+	}
+
+	action := req.FormValue("action")
+
+	// Logging in is the only action that doesn't require a session
+	if action == "login" {
+		conf.adminLogin(writer, req, locale)
+
+		return
+	}
+
+	if !conf.isAdmin(req) {
+		conf.frontAdminLogin(writer, http.StatusUnauthorized, locale.ErrAdminLoginRequired, locale)
+
+		return
+	}
+
+	// Get back to the filter that was in use, it is re-encoded to make sure it only points to the dashboard
+	returnURL := "/admin"
+	if values, err := url.ParseQuery(req.FormValue("query")); err == nil {
+		returnURL = adminURL(values, 1)
+	}
+
+	switch action {
+	case "logout":
+		http.SetCookie(writer, &http.Cookie{
+			Name:     admin.SessionCookie,
+			Value:    "",
+			Path:     "/admin",
+			MaxAge:   -1,
+			HttpOnly: true,
+			Secure:   strings.HasPrefix(conf.InstanceURL, "https://"),
+			SameSite: http.SameSiteStrictMode,
+		})
+
+		// The users having the admin role log out from their account
+		returnURL = "/admin"
+		if conf.isAdminUser(req) {
+			returnURL = "/account"
+		}
+	case "delete", "expire":
+		selected := req.PostForm["selected"]
+		if len(selected) == 0 {
+			conf.FrontErrorPage(writer, req, http.StatusBadRequest, locale.ErrNoSelection, returnURL)
+
+			return
+		}
+
+		var err error
+		if action == "delete" {
+			_, err = conf.Store.DeleteLinks(selected)
+		} else {
+			_, err = conf.Store.ExpireLinks(selected, time.Now().UTC())
+		}
+
+		if err != nil {
+			conf.FrontErrorPage(writer, req, http.StatusInternalServerError, locale.ErrAdminAction, returnURL)
+
+			return
+		}
+	case "create_key":
+		conf.adminCreateKey(writer, req, locale)
+
+		return
+	case "revoke_key":
+		id, err := uuid.Parse(req.FormValue("key"))
+		if err != nil {
+			conf.FrontErrorPage(writer, req, http.StatusBadRequest, locale.ErrRevokeAPIKey, returnURL)
+
+			return
+		}
+
+		if err := conf.Store.DeleteAPIKey(id); errors.Is(err, sql.ErrNoRows) {
+			conf.FrontErrorPage(writer, req, http.StatusNotFound, locale.ErrNotFound, returnURL)
+
+			return
+		} else if err != nil {
+			conf.FrontErrorPage(writer, req, http.StatusInternalServerError, locale.ErrRevokeAPIKey, returnURL)
+
+			return
+		}
+	default:
+		conf.FrontErrorPage(writer, req, http.StatusBadRequest, locale.ErrUnknownAction, returnURL)
+
+		return
+	}
+
+	// Go back to the dashboard
+	http.Redirect(writer, req, returnURL, http.StatusSeeOther)
+}
+
+// adminLogin checks the posted admin password and opens a session if it matches.
+func (conf Configuration) adminLogin(writer http.ResponseWriter, req *http.Request, locale utils.PageLocaleTl) {
+	// Only the admin role gives access to the dashboard without an admin password
+	if conf.Admin == nil {
+		conf.frontAdminLogin(writer, http.StatusUnauthorized, locale.ErrWrongAdminPass, locale)
+
+		return
+	}
+
+	// Limit how often a client can try a password
+	if !conf.allow(writer, req, ratelimit.RoutePassword) {
+		conf.frontAdminLogin(writer, http.StatusTooManyRequests, locale.ErrRateLimited, locale)
+
+		return
+	}
+
+	// Check the password
+	match, err := conf.Admin.CheckPassword(req.FormValue("password"))
+	if err != nil {
+		conf.FrontErrorPage(writer, req, http.StatusInternalServerError, locale.ErrCompHash, "/admin")
+
+		return
+	}
+
+	if !match {
+		conf.frontAdminLogin(writer, http.StatusUnauthorized, locale.ErrWrongAdminPass, locale)
+
+		return
+	}
+
+	// Open the session, the cookie is only sent to the admin dashboard
+	session, expireAt := conf.Admin.NewSession()
+	http.SetCookie(writer, &http.Cookie{
+		Name:     admin.SessionCookie,
+		Value:    session,
+		Path:     "/admin",
+		Expires:  expireAt,
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(conf.InstanceURL, "https://"),
+		SameSite: http.SameSiteStrictMode,
+	})
+
+	http.Redirect(writer, req, "/admin", http.StatusSeeOther)
+}
+
+// adminCreateKey creates an API key posted from the admin dashboard and displays it once on the dashboard.
+//
+// The key must be given a label, see [apikeys.Generate], and at least one scope, its expiration date is optional
+// and formatted as YYYY-MM-DD, the key expires at the beginning of this day in UTC.
+func (conf Configuration) adminCreateKey(writer http.ResponseWriter, req *http.Request, locale utils.PageLocaleTl) {
+	scopes, err := apikeys.ParseScopes(strings.Join(req.PostForm["scopes"], ","))
+	if err != nil {
+		conf.FrontErrorPage(writer, req, http.StatusBadRequest, locale.ErrInvalidScopes, "/admin")
+
+		return
+	}
+
+	// Parse the optional expiration date, which must be in the future
+	var expireAt time.Time
+	if expiry := req.FormValue("expire_at"); expiry != "" {
+		expireAt, err = time.Parse(adminDateFormat, expiry)
+		if err != nil || !expireAt.After(time.Now()) {
+			conf.FrontErrorPage(writer, req, http.StatusBadRequest, locale.ErrInvalidKeyExpiry, "/admin")
+
+			return
+		}
+	}
+
+	// Generate and store the key
+	plain, key, err := apikeys.Generate(req.FormValue("label"), scopes, expireAt)
+	if errors.Is(err, apikeys.ErrInvalidLabel) {
+		conf.FrontErrorPage(writer, req, http.StatusBadRequest, locale.ErrInvalidLabel, "/admin")
+
+		return
+	} else if err != nil {
+		conf.FrontErrorPage(writer, req, http.StatusInternalServerError, locale.ErrCreateAPIKey, "/admin")
+
+		return
+	}
+
+	if err := conf.Store.CreateAPIKey(key); err != nil {
+		conf.FrontErrorPage(writer, req, http.StatusInternalServerError, locale.ErrCreateAPIKey, "/admin")
+
+		return
+	}
+
+	// The plain key can't be recovered, it is displayed now instead of redirecting
+	conf.frontAdminDashboard(writer, req, plain, locale)
+}
@@ -17,38 +17,48 @@
 package http
 
 import (
+	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"regexp"
 	"strings"
 	"time"
 
 	"github.com/alexedwards/argon2id"
-	"github.com/redds-be/reddlinks/internal/database" // Local database package
+	"github.com/google/uuid"
+	"github.com/redds-be/reddlinks/internal/accounts"
+	"github.com/redds-be/reddlinks/internal/apikeys"
+	"github.com/redds-be/reddlinks/internal/database"
 	"github.com/redds-be/reddlinks/internal/json"
 	"github.com/redds-be/reddlinks/internal/links"
+	"github.com/redds-be/reddlinks/internal/ratelimit"
 	"github.com/redds-be/reddlinks/internal/utils"
 )
 
+// ManagementTokenHeader is the header used by clients to give the management token of a link.
+const ManagementTokenHeader = "X-Management-Token"
+
+// APIPrefix is the prefix of the routes of the versioned API, whose errors are typed objects, see [json.APIErrResponse].
+const APIPrefix = "/api/v1/"
+
 // APIRedirectToURL redirects the client to the URL corresponding to given shortened link.
 //
 // It first starts by getting the short from the request (GET /{short}),
 // then it checks if there's a '+' at the end, meaning an info request. If it is, the '+' is trimmed and then
-// its password's hash using [database.GetHashByShort], if there is one,
+// the link is retrieved using [database.LinkStore.GetLinkByShort], expired links are not found. If the link has a password's hash,
 // it firsts checks if there's a json payload to get a password from,
 // if not, redirect to /access handled by FrontAskForPassword which is going to ask for a password using a form.
 // Once the JSON payload is decoded using [utils.DecodeJSON], if there's a password, its hash will be compared to the hash corresponding
 // to the short using [argon2id.ComparePasswordAndHash], if it's the case,
 // if it's an info request, the information associated with the URL is sent to the client, without redirection, if not, the client will be redirected.
+// The password isn't asked for info requests authenticated with an API key having the stats scope, or made by the owner of the link.
 // If there's no hash associated with the short,
 // if it's an info request, the information associated with the URL is sent to the client, without redirection, if not, the client will be redirected.
 func (conf Configuration) APIRedirectToURL( //nolint:funlen,cyclop
 	writer http.ResponseWriter,
 	req *http.Request,
 ) {
-	// Get the locale
-	locale := utils.GetLocale(req, conf.Locales, conf.SupportedLocales)
-
 	// Get the requested short
 	requestedShort := req.PathValue("short")
 
@@ -60,20 +70,68 @@ func (conf Configuration) APIRedirectToURL( //nolint:funlen,cyclop
 		requestedShort = requestedShort[:len(requestedShort)-1]
 	}
 
-	// Check if there is a hash associated with the short, if there is a hash, we will require a password
-	hash, err := database.GetHashByShort(conf.DB, requestedShort)
+	// Get the link, if there is a hash associated with it, we will require a password
+	link, err := conf.Store.GetLinkByShort(requestedShort)
 	if err != nil {
 		conf.RespondWithError(
 			writer,
 			req,
 			http.StatusNotFound,
-			locale.ErrNotFound,
+			json.CodeNotFound,
 		)
 
 		return
 	}
 
-	if hash != "" {
+	// Check the password of protected links
+	if !conf.unlockLink(writer, req, link, infoRequest) {
+		return
+	}
+
+	// If it's an info request, we send the info
+	if infoRequest {
+		// CLI clients usually use only '*/*' whilst web browser typically uses a list containing both '*/*' and "text/html".
+		// if "text/html" is present, it is safe to assume it's a web browser
+		if strings.Contains(req.Header.Get("Accept"), "text/html") {
+			conf.FrontHandlerURLInfo(writer, req, link)
+
+			return
+		}
+
+		conf.respondWithInfo(writer, req, link)
+
+		return
+	}
+
+	// Record the access if analytics are enabled
+	if conf.Hits != nil {
+		conf.Hits.Record(req, requestedShort)
+	}
+
+	// Record the redirection if metrics are enabled
+	conf.Metrics.Redirect()
+
+	// Redirect the client to the URL associated with the short of the database
+	http.Redirect(writer, req, link.URL, http.StatusSeeOther)
+}
+
+// unlockLink checks the password of a protected link, responding to the client if it can't be accessed.
+//
+// The password is taken from a JSON payload decoded using [utils.DecodeJSON], or from the "pass" query value,
+// and compared to the hash of the link using [argon2id.ComparePasswordAndHash]. Without a password,
+// the versioned API responds with an unauthorized error while the other routes ask for it using FrontAskForPassword.
+// The password isn't asked for info requests authenticated with an API key having the stats scope, or made by the owner of the link.
+//
+// Returns:
+//   - bool: Whether the link can be accessed
+func (conf Configuration) unlockLink( //nolint:cyclop
+	writer http.ResponseWriter,
+	req *http.Request,
+	link database.Link,
+	infoRequest bool,
+) bool {
+	// The API keys having the stats scope and the owners get the information of protected links without their password
+	if link.Password != "" && !(infoRequest && (apikeys.Allows(req, apikeys.ScopeStats) || accounts.Owns(req, link))) {
 		// Decode the JSON, client error if it can't, most likely an invalid syntax or no password given at all
 		isJSON := false
 		for _, contentType := range req.Header["Content-Type"] {
@@ -90,88 +148,106 @@ func (conf Configuration) APIRedirectToURL( //nolint:funlen,cyclop
 		var password string
 		switch {
 		case isJSON:
-			params, err := utils.DecodeJSON(req)
-			if err != nil {
+			params, err := utils.DecodeJSON(req, conf.MaxBodyBytes)
+			if isTooLarge(err) {
+				conf.RespondWithError(writer, req, http.StatusRequestEntityTooLarge, json.CodePayloadTooLarge)
+
+				return false
+			} else if err != nil {
 				conf.RespondWithError(
 					writer,
 					req,
 					http.StatusBadRequest,
-					locale.ErrPassAccess,
+					json.CodePasswordRequired,
 				)
 
-				return
+				return false
 			}
 			password = params.Password
 		case req.URL.Query().Get("pass") != "":
 			password = req.URL.Query().Get("pass")
+		case isAPIv1(req):
+			conf.RespondWithError(writer, req, http.StatusUnauthorized, json.CodePasswordRequired)
+
+			return false
 		default:
 			conf.FrontAskForPassword(writer, req, infoRequest)
 
-			return
+			return false
+		}
+
+		// Limit how often a client can try a password
+		if !conf.allow(writer, req, ratelimit.RoutePassword) {
+			conf.RespondWithError(writer, req, http.StatusTooManyRequests, json.CodeRateLimited)
+
+			return false
 		}
 
 		// Check if the password matches the hash
-		if match, err := argon2id.ComparePasswordAndHash(password, hash); err == nil &&
+		if match, err := argon2id.ComparePasswordAndHash(password, link.Password); err == nil &&
 			!match {
-			conf.RespondWithError(writer, req, http.StatusBadRequest, "Wrong password has been given.")
+			conf.Metrics.PasswordFailure()
+			conf.RespondWithError(writer, req, http.StatusBadRequest, json.CodeWrongPassword)
 
-			return
+			return false
 		} else if err != nil {
-			conf.RespondWithError(writer, req, http.StatusInternalServerError, "Could not compare the password against corresponding hash.")
+			conf.RespondWithError(writer, req, http.StatusInternalServerError, json.CodeHashCheckFailed)
 
-			return
+			return false
 		}
 	}
 
-	// If it's an info request, we send the info
-	if infoRequest {
-		// CLI clients usually use only '*/*' whilst web browser typically uses a list containing both '*/*' and "text/html".
-		// if "text/html" is present, it is safe to assume it's a web browser
-		if strings.Contains(req.Header.Get("Accept"), "text/html") {
-			conf.FrontHandlerURLInfo(writer, req, requestedShort)
+	return true
+}
 
-			return
-		}
+// respondWithInfo sends the information of a link to the client in JSON, see [json.InfoResponse].
+func (conf Configuration) respondWithInfo(
+	writer http.ResponseWriter,
+	req *http.Request,
+	link database.Link,
+) {
+	// Get the accesses to the link
+	hits, err := conf.getHitsInfo(link.Short)
+	if err != nil {
+		conf.RespondWithError(
+			writer,
+			req,
+			http.StatusInternalServerError,
+			json.CodeGetLinkFailed,
+		)
 
-		// Get the information
-		url, createdAt, expireAt, err := database.GetURLInfo(conf.DB, requestedShort)
-		if err != nil {
-			conf.RespondWithError(
-				writer,
-				req,
-				http.StatusInternalServerError,
-				locale.ErrGetInfo,
-			)
+		return
+	}
 
-			return
-		}
+	// Send the information to the client
+	json.RespondWithJSON(writer, http.StatusOK, json.InfoResponse{
+		DstURL:    link.URL,
+		Short:     link.Short,
+		CreatedAt: link.CreatedAt.Format(time.RFC822),
+		ExpiresAt: link.ExpireAt.Format(time.RFC822),
+		Hits:      hits,
+	})
+}
 
-		// Send the information to the client
-		json.RespondWithJSON(writer, http.StatusOK, json.InfoResponse{
-			DstURL:    url,
-			Short:     requestedShort,
-			CreatedAt: createdAt.Format(time.RFC822),
-			ExpiresAt: expireAt.Format(time.RFC822),
-		})
+// APIGetLink sends the information of a link to the client in JSON (GET /api/v1/links/{short}).
+//
+// It works like an info request on [Configuration.APIRedirectToURL], except that it always responds in JSON,
+// protected links respond with an unauthorized error instead of asking for their password.
+func (conf Configuration) APIGetLink(writer http.ResponseWriter, req *http.Request) {
+	// Get the link, expired links are not found
+	link, err := conf.Store.GetLinkByShort(req.PathValue("short"))
+	if err != nil {
+		conf.RespondWithError(writer, req, http.StatusNotFound, json.CodeNotFound)
 
 		return
 	}
 
-	// Get the URL
-	url, err := database.GetURLByShort(conf.DB, requestedShort)
-	if err != nil {
-		conf.RespondWithError(
-			writer,
-			req,
-			http.StatusNotFound,
-			locale.ErrNotFound,
-		)
-
+	// Check the password of protected links
+	if !conf.unlockLink(writer, req, link, true) {
 		return
 	}
 
-	// Redirect the client to the URL associated with the short of the database
-	http.Redirect(writer, req, url, http.StatusSeeOther)
+	conf.respondWithInfo(writer, req, link)
 }
 
 // APICreateLink creates a link entry in the database using given json parameters.
@@ -188,45 +264,39 @@ func (conf Configuration) APICreateLink( //nolint:funlen
 	locale := utils.GetLocale(req, conf.Locales, conf.SupportedLocales)
 
 	// Get the JSON parameters
-	params, err := utils.DecodeJSON(req)
-	if err != nil {
-		conf.RespondWithError(writer, req, http.StatusBadRequest, locale.ErrInvalidJSON)
+	params, err := utils.DecodeJSON(req, conf.MaxBodyBytes)
+	if isTooLarge(err) {
+		conf.RespondWithError(writer, req, http.StatusRequestEntityTooLarge, json.CodePayloadTooLarge)
 
 		return
-	}
+	} else if err != nil {
+		conf.RespondWithError(writer, req, http.StatusBadRequest, json.CodeInvalidJSON)
 
-	// Create a configuration struct for the links adapter
-	linksConf := utils.Configuration{
-		DB:                     conf.DB,
-		InstanceName:           conf.InstanceName,
-		InstanceURL:            conf.InstanceURL,
-		Version:                conf.Version,
-		AddrAndPort:            conf.AddrAndPort,
-		DefaultShortLength:     conf.DefaultShortLength,
-		DefaultMaxShortLength:  conf.DefaultMaxShortLength,
-		DefaultMaxCustomLength: conf.DefaultMaxCustomLength,
-		DefaultExpiryTime:      conf.DefaultExpiryTime,
-		ContactEmail:           conf.ContactEmail,
-		Static:                 conf.Static,
+		return
 	}
 
-	// Create an adapter using the configuration struct
-	linksAdapter := links.NewAdapter(linksConf)
+	// Links created by a logged in user belong to them
+	params.Owner = accounts.Owner(req)
+
+	// Create an adapter for links
+	linksAdapter := conf.newLinksAdapter()
 
 	// Create the link entry
-	link, code, addInfo, errMsg := linksAdapter.CreateLink(params, locale)
-	if errMsg != "" {
-		conf.RespondWithError(writer, req, code, errMsg)
+	link, addInfo, err := linksAdapter.CreateLink(params, locale)
+	if err != nil {
+		conf.respondWithLinkError(writer, req, err)
 
 		return
 	}
 
-	// If there's additional information, display it
-	if addInfo != "" {
+	// If there's additional information, display it, the versioned API gives it along with the link instead
+	if addInfo != "" && !isAPIv1(req) {
 		type informationResponse struct {
 			Information string `json:"information"`
 		}
 		json.RespondWithJSON(writer, http.StatusContinue, informationResponse{Information: addInfo})
+
+		addInfo = ""
 	}
 
 	// Format the shortened link
@@ -248,33 +318,158 @@ func (conf Configuration) APICreateLink( //nolint:funlen
 			Password:      params.Password,
 			ExpireAt:      expireAt,
 			URL:           link.URL,
+			Token:         link.Token,
+			Information:   addInfo,
 		}
 
 		// Return the expiry time, the url and the short to the user
-		json.RespondWithJSON(writer, code, linkToReturn)
+		json.RespondWithJSON(writer, http.StatusCreated, linkToReturn)
 	} else {
 		linkToReturn := links.SimpleJSONLink{
 			ShortenedLink: shortenedLink,
 			ExpireAt:      expireAt,
 			URL:           link.URL,
+			Token:         link.Token,
+			Information:   addInfo,
 		}
 
 		// Return the expiry time, the url and the short to the user
-		json.RespondWithJSON(writer, code, linkToReturn)
+		json.RespondWithJSON(writer, http.StatusCreated, linkToReturn)
+	}
+}
+
+// APIUpdateLink updates the URL, the expiration date or the password of a link using given json parameters.
+//
+// The management token returned at the link creation must be given using the [ManagementTokenHeader] header,
+// unless the request was authenticated with an API key having the manage scope, or without a token by the owner of the link.
+// It decodes the JSON payload from the client using [utils.DecodeJSON], then calls [links.UpdateLink]
+// which only applies the non-empty parameters, the updated link is then returned as a [links.SimpleJSONLink].
+func (conf Configuration) APIUpdateLink(writer http.ResponseWriter, req *http.Request) {
+	// Get the JSON parameters
+	params, err := utils.DecodeJSON(req, conf.MaxBodyBytes)
+	if isTooLarge(err) {
+		conf.RespondWithError(writer, req, http.StatusRequestEntityTooLarge, json.CodePayloadTooLarge)
+
+		return
+	} else if err != nil {
+		conf.RespondWithError(writer, req, http.StatusBadRequest, json.CodeInvalidJSON)
+
+		return
+	}
+
+	// Create an adapter for links
+	linksAdapter := conf.newLinksAdapter()
+
+	// Update the link entry, the API keys having the manage scope and the owners don't need the management token
+	var link links.Link
+
+	token := req.Header.Get(ManagementTokenHeader)
+	owner := accounts.Owner(req)
+
+	switch {
+	case apikeys.Allows(req, apikeys.ScopeManage):
+		link, err = linksAdapter.UpdateAnyLink(req.PathValue("short"), params)
+	case token == "" && owner != uuid.Nil:
+		link, err = linksAdapter.UpdateOwnedLink(req.PathValue("short"), owner, params)
+	default:
+		link, err = linksAdapter.UpdateLink(req.PathValue("short"), token, params)
+	}
+
+	if err != nil {
+		conf.respondWithLinkError(writer, req, err)
+
+		return
+	}
+
+	// Return the updated link to the user
+	json.RespondWithJSON(writer, http.StatusOK, links.SimpleJSONLink{
+		ShortenedLink: regexp.MustCompile("^https://|http://").
+			ReplaceAllString(fmt.Sprintf("%s%s", conf.InstanceURL, link.Short), ""),
+		ExpireAt: link.ExpireAt.Format(time.RFC822),
+		URL:      link.URL,
+	})
+}
+
+// APIDeleteLink deletes a link before its expiration.
+//
+// The management token returned at the link creation must be given using the [ManagementTokenHeader] header,
+// unless the request was authenticated with an API key having the manage scope, or without a token by the owner of the link.
+// It calls [links.DeleteLink] and responds with no content if the link got deleted.
+func (conf Configuration) APIDeleteLink(writer http.ResponseWriter, req *http.Request) {
+	// Create an adapter for links
+	linksAdapter := conf.newLinksAdapter()
+
+	// Delete the link entry, the API keys having the manage scope and the owners don't need the management token
+	var err error
+
+	token := req.Header.Get(ManagementTokenHeader)
+	owner := accounts.Owner(req)
+
+	switch {
+	case apikeys.Allows(req, apikeys.ScopeManage):
+		err = linksAdapter.DeleteAnyLink(req.PathValue("short"))
+	case token == "" && owner != uuid.Nil:
+		err = linksAdapter.DeleteOwnedLink(req.PathValue("short"), owner)
+	default:
+		err = linksAdapter.DeleteLink(req.PathValue("short"), token)
 	}
+
+	if err != nil {
+		conf.respondWithLinkError(writer, req, err)
+
+		return
+	}
+
+	writer.WriteHeader(http.StatusNoContent)
+}
+
+// getHitsInfo returns the accesses to a given short, nil if analytics are disabled.
+func (conf Configuration) getHitsInfo(short string) (*json.HitsInfo, error) {
+	if conf.Hits == nil {
+		return nil, nil //nolint:nilnil // No hits to return when analytics are disabled
+	}
+
+	stats, err := conf.Store.GetHitStats(short)
+	if err != nil {
+		return nil, err
+	}
+
+	return &json.HitsInfo{
+		Total:     stats.Total,
+		Referrers: stats.Referrers,
+		Agents:    stats.Agents,
+	}, nil
+}
+
+// newLinksAdapter returns a configuration to be used by the link handling functions, see [links.NewAdapter].
+func (conf Configuration) newLinksAdapter() links.Configuration {
+	return links.NewAdapter(utils.Configuration(conf))
 }
 
 // RespondWithError sends an appropriate error response to the client based on the
-// Accept header in the request. If the Accept header contains "text/html", it renders
+// route and the Accept header in the request. The message of the error is the text of its code
+// in the locale of the client, see [json.ErrorCode.Message]. The routes of the versioned API, see [APIPrefix], always respond
+// with a typed JSON error using json.RespondWithAPIError. If the Accept header contains "text/html", it renders
 // an HTML error page using FrontErrorPage. Otherwise, it responds with a JSON error
-// using json.RespondWithError.
+// using json.RespondWithError. In every case, the error is logged along with the request ID, see [logError].
 //
 // Parameters:
 //   - writer: The http.ResponseWriter to write the response to
 //   - req: The incoming HTTP request
 //   - code: The HTTP status code to return
-//   - errMsg: The error message to display
-func (conf Configuration) RespondWithError(writer http.ResponseWriter, req *http.Request, code int, errMsg string) {
+//   - errCode: The stable code of the error
+func (conf Configuration) RespondWithError(writer http.ResponseWriter, req *http.Request, code int, errCode json.ErrorCode) {
+	// Get the message of the error in the locale of the client
+	errMsg := errCode.Message(utils.GetLocale(req, conf.Locales, conf.SupportedLocales))
+
+	// The versioned API only speaks JSON, with a stable code for each error
+	if isAPIv1(req) {
+		logError(req, code, string(errCode))
+		json.RespondWithAPIError(writer, code, errCode, errMsg)
+
+		return
+	}
+
 	// CLI clients usually use only '*/*' whilst web browser typically uses a list containing both '*/*' and "text/html".
 	// if "text/html" is present, it is safe to assume it's a web browser, so we give render an error page
 	if strings.Contains(req.Header.Get("Accept"), "text/html") {
@@ -283,6 +478,42 @@ func (conf Configuration) RespondWithError(writer http.ResponseWriter, req *http
 		return
 	}
 
-	// Otherwise, respond in JSON
-	json.RespondWithError(writer, code, errMsg)
+	// Otherwise, log the error and respond in JSON
+	logError(req, code, string(errCode))
+	json.RespondWithError(writer, code, errCode, errMsg)
+}
+
+// respondWithLinkError sends the error returned by a link handling function to the client, see [links.Error].
+func (conf Configuration) respondWithLinkError(writer http.ResponseWriter, req *http.Request, err error) {
+	linkErr := links.AsError(err)
+	conf.RespondWithError(writer, req, linkErr.Status, linkErr.Code)
+}
+
+// isTooLarge tells if a request body couldn't be decoded because it is larger than allowed, see [http.MaxBytesReader].
+func isTooLarge(err error) bool {
+	var maxBytesErr *http.MaxBytesError
+
+	return errors.As(err, &maxBytesErr)
+}
+
+// isAPIv1 tells if a request was made to a route of the versioned API.
+func isAPIv1(req *http.Request) bool {
+	return strings.HasPrefix(req.URL.Path, APIPrefix)
+}
+
+// logError logs an error response sent to the client using the context of its request, adding the request ID.
+//
+// Server errors are logged at the error level, client errors at the info level.
+func logError(req *http.Request, code int, errMsg string) {
+	level := slog.LevelInfo
+	if code >= http.StatusInternalServerError {
+		level = slog.LevelError
+	}
+
+	slog.LogAttrs(req.Context(), level, "Request failed",
+		slog.String("method", req.Method),
+		slog.String("path", req.URL.Path),
+		slog.Int("status", code),
+		slog.String("error", errMsg),
+	)
 }
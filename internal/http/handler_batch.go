@@ -0,0 +1,158 @@
+//    reddlinks, a simple link shortener written in Go.
+//    Copyright (C) 2025 redd
+//
+//    This program is free software: you can redistribute it and/or modify
+//    it under the terms of the GNU General Public License as published by
+//    the Free Software Foundation, either version 3 of the License, or
+//    (at your option) any later version.
+//
+//    This program is distributed in the hope that it will be useful,
+//    but WITHOUT ANY WARRANTY; without even the implied warranty of
+//    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//    GNU General Public License for more details.
+//
+//    You should have received a copy of the GNU General Public License
+//    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package http
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/redds-be/reddlinks/internal/accounts"
+	"github.com/redds-be/reddlinks/internal/json"
+	"github.com/redds-be/reddlinks/internal/links"
+	"github.com/redds-be/reddlinks/internal/utils"
+)
+
+// APICreateLinks creates several links at once using a JSON array or a CSV file of parameters.
+//
+// The batch is decoded using [utils.DecodeBatch], the links are then created using [links.CreateLinks]
+// which inserts them within a single transaction when possible. The outcome of each row is returned
+// to the client in a [links.BatchJSONResponse], a row that can't be created doesn't prevent the others from being created.
+// The batch can't be larger than MaxBatchBytes, the route is given BatchTimeout to complete, see [ExtendDeadlines].
+func (conf Configuration) APICreateLinks(writer http.ResponseWriter, req *http.Request) {
+	// Get the locale
+	locale := utils.GetLocale(req, conf.Locales, conf.SupportedLocales)
+
+	// Decode the batch and create its links
+	response, err := conf.createBatch(req, locale)
+	if err != nil {
+		conf.respondWithLinkError(writer, req, err)
+
+		return
+	}
+
+	// Return the outcome of each row to the user
+	json.RespondWithJSON(writer, http.StatusOK, response)
+}
+
+// FrontHandlerBatch displays the outcome of the creation of a batch of links uploaded from the front page.
+//
+// It works like [Configuration.APICreateLinks], using the CSV file of the upload form of the front page,
+// the outcome of each row is displayed in a table on a web page.
+func (conf Configuration) FrontHandlerBatch(writer http.ResponseWriter, req *http.Request) {
+	// Get the locale
+	locale := utils.GetLocale(req, conf.Locales, conf.SupportedLocales)
+
+	// Decode the batch and create its links, display an error page if it can't
+	response, err := conf.createBatch(req, locale)
+	if err != nil {
+		linkErr := links.AsError(err)
+		conf.FrontErrorPage(writer, req, linkErr.Status, linkErr.Code.Message(locale), "/")
+
+		return
+	}
+
+	// Set what is going to be displayed on the batch page
+	pageParams := &PageParameters{
+		InstanceTitle: conf.InstanceName,
+		InstanceURL:   conf.InstanceURL,
+		Version:       conf.Version,
+		Batch:         &response,
+	}
+
+	// Display the batch page which will display the outcome of each row
+	RenderTemplate(writer, "batch", pageParams, http.StatusOK, locale)
+}
+
+// createBatch decodes the batch of a request and creates its links.
+//
+// Parameters:
+//   - req: The incoming HTTP request
+//   - locale: Contains localized text messages for the outcome of each row
+//
+// Returns:
+//   - links.BatchJSONResponse: The outcome of each row
+//   - error: The reason why the batch can't be decoded (if any), see [links.Error]
+func (conf Configuration) createBatch(req *http.Request, locale utils.PageLocaleTl) (links.BatchJSONResponse, error) {
+	// Decode the batch
+	batch, err := utils.DecodeBatch(req, conf.MaxBatchBytes)
+	if errors.Is(err, utils.ErrBatchTooLarge) || isTooLarge(err) {
+		return links.BatchJSONResponse{}, &links.Error{Status: http.StatusRequestEntityTooLarge, Code: json.CodeBatchTooLarge}
+	} else if err != nil {
+		return links.BatchJSONResponse{}, &links.Error{Status: http.StatusBadRequest, Code: json.CodeInvalidBatch}
+	}
+
+	// Links created by a logged in user belong to them
+	owner := accounts.Owner(req)
+	for index := range batch {
+		batch[index].Owner = owner
+	}
+
+	// Create an adapter for links
+	linksAdapter := conf.newLinksAdapter()
+
+	// Create the links
+	results := linksAdapter.CreateLinks(batch, locale)
+
+	// Format the outcome of each row
+	response := links.BatchJSONResponse{Results: make([]links.BatchJSONResult, len(results))}
+
+	for index, result := range results {
+		params := batch[index]
+
+		row := links.BatchJSONResult{
+			Row:  index + 1,
+			Code: http.StatusCreated,
+			URL:  params.URL,
+		}
+
+		// Count the rows that couldn't be created
+		if result.Err != nil {
+			linkErr := links.AsError(result.Err)
+			row.Code = linkErr.Status
+			row.Error = linkErr.Code.Message(locale)
+			row.ErrorCode = linkErr.Code
+			response.Failed++
+			response.Results[index] = row
+
+			continue
+		}
+
+		response.Created++
+
+		// Format the shortened link
+		row.ShortenedLink = regexp.MustCompile("^https://|http://").
+			ReplaceAllString(fmt.Sprintf("%s%s", conf.InstanceURL, result.Link.Short), "")
+
+		// Format the expiration date that will be displayed to the user
+		if params.ExpireDate == "" && params.ExpireAfter == "" && conf.DefaultExpiryTime == 0 {
+			row.ExpireAt = "Never"
+		} else {
+			row.ExpireAt = result.Link.ExpireAt.Format(time.RFC822)
+		}
+
+		row.Short = result.Link.Short
+		row.Password = params.Password
+		row.Token = result.Link.Token
+		row.Information = result.AddInfo
+		response.Results[index] = row
+	}
+
+	return response, nil
+}
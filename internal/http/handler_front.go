@@ -19,15 +19,19 @@ package http
 import (
 	"fmt"
 	"html/template"
+	"log/slog"
 	"net/http"
 	"regexp"
 	"strconv"
 	"time"
 
 	"github.com/alexedwards/argon2id"
+	"github.com/redds-be/reddlinks/internal/accounts"
 	"github.com/redds-be/reddlinks/internal/database"
 	"github.com/redds-be/reddlinks/internal/json"
 	"github.com/redds-be/reddlinks/internal/links"
+	"github.com/redds-be/reddlinks/internal/logging"
+	"github.com/redds-be/reddlinks/internal/ratelimit"
 	"github.com/redds-be/reddlinks/internal/utils"
 )
 
@@ -43,6 +47,7 @@ var Templates *template.Template //nolint:gochecknoglobals
 // URL is the URL to be shortened,
 // ExpireAt is the formatted date of expiration of a link,
 // Password is the password used by the user to create a link,
+// Token is the management token of a newly created link,
 // Error is an error message,
 // AddInfo is an information to be displayed to the user after link creation,
 // Version is the version of reddlinks used by this instance,
@@ -51,7 +56,19 @@ var Templates *template.Template //nolint:gochecknoglobals
 // DefaultMaxCustomLength refers to the maximum length of custom strings for a short URL,
 // DefaultExpiryTime refers to the default expiry time of links records,
 // DefaultExpiryDate refers to the default expiry date,
-// ContactEmail refers to an optional admin contact email.
+// ContactEmail refers to an optional admin contact email,
+// Hits refers to the accesses to a link, nil if analytics are disabled,
+// Analytics tells if the accesses to links are recorded,
+// Admin is what is displayed on the admin dashboard, nil on the login form,
+// Batch is the outcome of the creation of a batch of links,
+// RequestID is the ID of the failed request displayed on the error page,
+// CreationRestricted tells if the creation of links is restricted and the client isn't allowed to create links, hiding the forms of the main page,
+// Account is what is displayed on the account page of a logged in user, nil on the login form,
+// Accounts tells if the user accounts are enabled, showing a link to the account page,
+// Registration tells if anybody can register an account, showing the registration form,
+// Username is the name of the logged in user, empty for anonymous clients,
+// SingleSignOn tells if the users can log in using the OpenID Connect provider, showing a link to its login page,
+// AdminPassword tells if an admin password is configured, showing the password form of the admin dashboard.
 type PageParameters struct {
 	InstanceTitle          string
 	InstanceURL            string
@@ -61,6 +78,7 @@ type PageParameters struct {
 	URL                    string
 	ExpireAt               string
 	Password               string
+	Token                  string
 	Error                  string
 	AddInfo                string
 	Version                string
@@ -73,6 +91,18 @@ type PageParameters struct {
 	DstURL                 string
 	CreationDate           string
 	ExpirationDate         string
+	Hits                   *json.HitsInfo
+	Analytics              bool
+	Admin                  *AdminPage
+	Batch                  *links.BatchJSONResponse
+	RequestID              string
+	CreationRestricted     bool
+	Account                *AccountPage
+	Accounts               bool
+	Registration           bool
+	Username               string
+	SingleSignOn           bool
+	AdminPassword          bool
 }
 
 // RenderTemplate renders the templates using a given PageParameters struct.
@@ -105,13 +135,17 @@ func RenderTemplate(
 		map[string]interface{}{"PageParams": pageParams, "Locales": locale},
 	)
 	if err != nil {
-		json.RespondWithError(writer, http.StatusInternalServerError, locale.ErrUnableLoadPage)
+		slog.Error("Failed to render template", slog.String("template", tmpl), slog.Any("error", err),
+			slog.String(logging.RequestIDKey, writer.Header().Get(logging.RequestIDHeader)))
+		json.RespondWithError(writer, http.StatusInternalServerError, json.CodeLoadPageFailed, locale.ErrUnableLoadPage)
 
 		return
 	}
 }
 
 // FrontErrorPage returns an error page to the user using a given code and message with [RenderTemplate].
+//
+// The error is logged and the request ID is displayed so that it can be given when reporting the error.
 func (conf Configuration) FrontErrorPage(
 	writer http.ResponseWriter,
 	req *http.Request,
@@ -122,6 +156,9 @@ func (conf Configuration) FrontErrorPage(
 	// Get the locale
 	locale := utils.GetLocale(req, conf.Locales, conf.SupportedLocales)
 
+	// Log the error along with the request ID
+	logError(req, code, errMsg)
+
 	// Set what is going to be displayed on the error page
 	pageParams := &PageParameters{
 		InstanceTitle: conf.InstanceName,
@@ -129,6 +166,7 @@ func (conf Configuration) FrontErrorPage(
 		Error:         fmt.Sprintf("%s (%d)", errMsg, code),
 		Version:       conf.Version,
 		URL:           url,
+		RequestID:     logging.RequestID(req.Context()),
 	}
 
 	// Display the error page
@@ -151,6 +189,9 @@ func (conf Configuration) FrontHandlerMainPage(writer http.ResponseWriter, req *
 			Format("2006-01-02T15:04")
 	}
 
+	// The logged in users can create links even if the creation is restricted, unless they lack the creator role
+	user, _ := accounts.FromContext(req.Context())
+
 	// Set what is going to be displayed on the main page
 	pageParams := &PageParameters{
 		InstanceTitle: conf.InstanceName,
@@ -162,6 +203,9 @@ func (conf Configuration) FrontHandlerMainPage(writer http.ResponseWriter, req *
 		DefaultMaxCustomLength: conf.DefaultMaxCustomLength,
 		DefaultExpiryDate:      defaultExpiryDate,
 		Version:                conf.Version,
+		CreationRestricted:     conf.RestrictCreation && !conf.isCreator(req),
+		Accounts:               conf.Accounts,
+		Username:               user.Username,
 	}
 
 	// Display the front page
@@ -179,6 +223,7 @@ func (conf Configuration) FrontHandlerPrivacyPage(writer http.ResponseWriter, re
 		InstanceURL:   conf.InstanceURL,
 		Version:       conf.Version,
 		ContactEmail:  conf.ContactEmail,
+		Analytics:     conf.Hits != nil,
 	}
 
 	// Display the front page
@@ -222,30 +267,17 @@ func (conf Configuration) FrontHandlerAdd( //nolint:funlen
 		ExpireDate:  req.FormValue("expire_datetime"),
 		ExpireAfter: req.FormValue("expire_after"),
 		Password:    req.FormValue("password"),
+		Owner:       accounts.Owner(req),
 	}
 
-	// Create a configuration struct for the links adapter
-	linksConf := utils.Configuration{
-		DB:                     conf.DB,
-		InstanceName:           conf.InstanceName,
-		InstanceURL:            conf.InstanceURL,
-		Version:                conf.Version,
-		AddrAndPort:            conf.AddrAndPort,
-		DefaultShortLength:     conf.DefaultShortLength,
-		DefaultMaxShortLength:  conf.DefaultMaxShortLength,
-		DefaultMaxCustomLength: conf.DefaultMaxCustomLength,
-		DefaultExpiryTime:      conf.DefaultExpiryTime,
-		ContactEmail:           conf.ContactEmail,
-		Static:                 conf.Static,
-	}
-
-	// Create an adapter using the configuration struct
-	linksAdapter := links.NewAdapter(linksConf)
+	// Create an adapter for links
+	linksAdapter := conf.newLinksAdapter()
 
 	// Create a link entry into the database, display an error page if it can't
-	link, code, addInfo, errMsg := linksAdapter.CreateLink(params, locale)
-	if errMsg != "" {
-		conf.FrontErrorPage(writer, req, code, errMsg, "/")
+	link, addInfo, err := linksAdapter.CreateLink(params, locale)
+	if err != nil {
+		linkErr := links.AsError(err)
+		conf.FrontErrorPage(writer, req, linkErr.Status, linkErr.Code.Message(locale), "/")
 
 		return
 	}
@@ -260,7 +292,7 @@ func (conf Configuration) FrontHandlerAdd( //nolint:funlen
 
 	qr, err := utils.TextToB64QR(conf.InstanceURL + link.Short) //nolint:varnamelen // The name is self-explanatory
 	if err != nil {
-		conf.FrontErrorPage(writer, req, code, errMsg, "/")
+		conf.FrontErrorPage(writer, req, http.StatusInternalServerError, locale.ErrUnableLoadPage, "/")
 
 		return
 	}
@@ -275,6 +307,7 @@ func (conf Configuration) FrontHandlerAdd( //nolint:funlen
 		URL:         link.URL,
 		ExpireAt:    expireAt,
 		Password:    params.Password,
+		Token:       link.Token,
 		AddInfo:     addInfo,
 		Version:     conf.Version,
 		ShortenedQR: qr,
@@ -313,13 +346,13 @@ func (conf Configuration) FrontAskForPassword(writer http.ResponseWriter, req *h
 	RenderTemplate(writer, "pass", pageParams, http.StatusOK, locale)
 }
 
-// FrontHandlerURLInfo displays basic information about a given short.
-func (conf Configuration) FrontHandlerURLInfo(writer http.ResponseWriter, req *http.Request, short string) {
+// FrontHandlerURLInfo displays basic information about a given link.
+func (conf Configuration) FrontHandlerURLInfo(writer http.ResponseWriter, req *http.Request, link database.Link) {
 	// Get the locale
 	locale := utils.GetLocale(req, conf.Locales, conf.SupportedLocales)
 
-	// Get short information
-	url, createdAt, expireAt, err := database.GetURLInfo(conf.DB, short)
+	// Get the accesses to the link
+	hits, err := conf.getHitsInfo(link.Short)
 	if err != nil {
 		conf.FrontErrorPage(
 			writer,
@@ -336,11 +369,12 @@ func (conf Configuration) FrontHandlerURLInfo(writer http.ResponseWriter, req *h
 	pageParams := &PageParameters{
 		InstanceTitle:  conf.InstanceName,
 		InstanceURL:    conf.InstanceURL,
-		Short:          short,
+		Short:          link.Short,
 		Version:        conf.Version,
-		DstURL:         url,
-		CreationDate:   createdAt.Format(time.RFC822),
-		ExpirationDate: expireAt.Format(time.RFC822),
+		DstURL:         link.URL,
+		CreationDate:   link.CreatedAt.Format(time.RFC822),
+		ExpirationDate: link.ExpireAt.Format(time.RFC822),
+		Hits:           hits,
 	}
 
 	// Display the shortened link info page
@@ -349,10 +383,10 @@ func (conf Configuration) FrontHandlerURLInfo(writer http.ResponseWriter, req *h
 
 // FrontHandlerRedirectToURL redirects the client to the URL corresponding to given shortened link.
 //
-// It starts by getting the hash of the short using [database.GetHashByShort],
+// It starts by getting the link using [database.LinkStore.GetLinkByShort], expired links are not found,
 // then it gets the password from [FrontAskForPassword],
-// it then compares the hash of the given password with the short's hash using [argon2id.ComparePasswordAndHash],
-// if the password matches, it uses [database.GetURLByShort] to get the URL to redirect to before redirect to said URL.
+// it then compares the hash of the given password with the link's hash using [argon2id.ComparePasswordAndHash],
+// if the password matches, the client is redirected to the URL of the link.
 func (conf Configuration) FrontHandlerRedirectToURL(
 	writer http.ResponseWriter,
 	req *http.Request,
@@ -360,8 +394,8 @@ func (conf Configuration) FrontHandlerRedirectToURL(
 	// Get the locale
 	locale := utils.GetLocale(req, conf.Locales, conf.SupportedLocales)
 
-	// Get the hash corresponding to the short
-	hash, err := database.GetHashByShort(conf.DB, req.FormValue("short"))
+	// Get the link corresponding to the short
+	link, err := conf.Store.GetLinkByShort(req.FormValue("short"))
 	if err != nil {
 		conf.FrontErrorPage(writer, req, http.StatusNotFound, locale.ErrNotFound, "/")
 
@@ -380,9 +414,17 @@ func (conf Configuration) FrontHandlerRedirectToURL(
 		return
 	}
 
+	// Limit how often a client can try a password
+	if !conf.allow(writer, req, ratelimit.RoutePassword) {
+		conf.FrontErrorPage(writer, req, http.StatusTooManyRequests, locale.ErrRateLimited, returnURL)
+
+		return
+	}
+
 	// Check if the password matches the hash
-	if match, err := argon2id.ComparePasswordAndHash(password, hash); err == nil &&
+	if match, err := argon2id.ComparePasswordAndHash(password, link.Password); err == nil &&
 		!match {
+		conf.Metrics.PasswordFailure()
 		conf.FrontErrorPage(writer, req, http.StatusBadRequest, locale.ErrWrongPass, returnURL)
 
 		return
@@ -395,25 +437,19 @@ func (conf Configuration) FrontHandlerRedirectToURL(
 	// If it's an info request, go directly to info page
 	infoRequest := req.FormValue("info")
 	if infoRequest == "true" {
-		conf.FrontHandlerURLInfo(writer, req, returnURL)
+		conf.FrontHandlerURLInfo(writer, req, link)
 
 		return
 	}
 
-	// Get the URL corresponding to the short
-	url, err := database.GetURLByShort(conf.DB, returnURL)
-	if err != nil {
-		conf.FrontErrorPage(
-			writer,
-			req,
-			http.StatusNotFound,
-			locale.ErrNotFound,
-			req.URL.Path,
-		)
-
-		return
+	// Record the access if analytics are enabled
+	if conf.Hits != nil {
+		conf.Hits.Record(req, returnURL)
 	}
 
+	// Record the redirection if metrics are enabled
+	conf.Metrics.Redirect()
+
 	// Redirect the client to the URL associated with the short of the database
-	http.Redirect(writer, req, url, http.StatusSeeOther)
+	http.Redirect(writer, req, link.URL, http.StatusSeeOther)
 }
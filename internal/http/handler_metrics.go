@@ -0,0 +1,53 @@
+//    reddlinks, a simple link shortener written in Go.
+//    Copyright (C) 2025 redd
+//
+//    This program is free software: you can redistribute it and/or modify
+//    it under the terms of the GNU General Public License as published by
+//    the Free Software Foundation, either version 3 of the License, or
+//    (at your option) any later version.
+//
+//    This program is distributed in the hope that it will be useful,
+//    but WITHOUT ANY WARRANTY; without even the implied warranty of
+//    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//    GNU General Public License for more details.
+//
+//    You should have received a copy of the GNU General Public License
+//    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package http
+
+import (
+	"crypto/subtle"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/redds-be/reddlinks/internal/apikeys"
+	"github.com/redds-be/reddlinks/internal/json"
+	"github.com/redds-be/reddlinks/internal/metrics"
+)
+
+// HandlerMetrics writes the metrics in the Prometheus text format, see [metrics.Metrics.Write].
+//
+// If a metrics token is configured, it must be given using the Authorization header as a bearer token,
+// or the request must be authenticated with an API key having the stats scope, the client gets an unauthorized error otherwise.
+// The token is compared in constant time.
+func (conf Configuration) HandlerMetrics(writer http.ResponseWriter, req *http.Request) {
+	// Check the token if the metrics are protected
+	if conf.MetricsToken != "" && !apikeys.Allows(req, apikeys.ScopeStats) {
+		token, found := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+		if !found || subtle.ConstantTimeCompare([]byte(token), []byte(conf.MetricsToken)) != 1 {
+			writer.Header().Set("WWW-Authenticate", `Bearer realm="metrics"`)
+			conf.RespondWithError(writer, req, http.StatusUnauthorized, json.CodeMetricsTokenRequired)
+
+			return
+		}
+	}
+
+	// Write the metrics
+	writer.Header().Set("Content-Type", metrics.ContentType)
+
+	if err := conf.Metrics.Write(writer); err != nil {
+		slog.ErrorContext(req.Context(), "Failed to write metrics", slog.Any("error", err))
+	}
+}
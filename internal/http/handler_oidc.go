@@ -0,0 +1,150 @@
+//    reddlinks, a simple link shortener written in Go.
+//    Copyright (C) 2025 redd
+//
+//    This program is free software: you can redistribute it and/or modify
+//    it under the terms of the GNU General Public License as published by
+//    the Free Software Foundation, either version 3 of the License, or
+//    (at your option) any later version.
+//
+//    This program is distributed in the hope that it will be useful,
+//    but WITHOUT ANY WARRANTY; without even the implied warranty of
+//    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//    GNU General Public License for more details.
+//
+//    You should have received a copy of the GNU General Public License
+//    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package http
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/redds-be/reddlinks/internal/accounts"
+	"github.com/redds-be/reddlinks/internal/oidc"
+	"github.com/redds-be/reddlinks/internal/utils"
+)
+
+// oidcFlowCookie is the name of the cookie holding the login flow while the user is at the identity provider.
+const oidcFlowCookie = "reddlinks_oidc"
+
+// oidcFlowLifetime is the time given to the user to log in at the identity provider, in seconds.
+const oidcFlowLifetime = 600
+
+// oidcFlowPath is the path the flow cookie is sent to, covering the start of the flow and its callback.
+const oidcFlowPath = "/account/oidc"
+
+// FrontHandlerOIDCLogin starts a login flow and redirects the client to the OpenID Connect provider.
+//
+// The flow, see [oidc.NewFlow], is kept in a short-lived cookie until the provider sends the client back
+// to [Configuration.FrontHandlerOIDCCallback]. The "next" query value sends the user to the admin dashboard
+// once logged in, any other value sends them to the account page.
+// The route is not found if the single sign-on is disabled.
+func (conf Configuration) FrontHandlerOIDCLogin(writer http.ResponseWriter, req *http.Request) {
+	// Get the locale
+	locale := utils.GetLocale(req, conf.Locales, conf.SupportedLocales)
+
+	// The route is disabled without an OpenID Connect provider
+	if conf.OIDC == nil {
+		conf.FrontErrorPage(writer, req, http.StatusNotFound, locale.ErrNotFound, "/")
+
+		return
+	}
+
+	// Only allow local destinations
+	next := "/account"
+	if req.URL.Query().Get("next") == "/admin" {
+		next = "/admin"
+	}
+
+	flow, err := oidc.NewFlow(next)
+	if err != nil {
+		conf.FrontErrorPage(writer, req, http.StatusInternalServerError, locale.ErrOIDCLogin, "/account")
+
+		return
+	}
+
+	// The cookie must be sent back when the provider redirects the client, which is a cross-site top-level navigation
+	http.SetCookie(writer, &http.Cookie{
+		Name:     oidcFlowCookie,
+		Value:    flow.Encode(),
+		Path:     oidcFlowPath,
+		MaxAge:   oidcFlowLifetime,
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(conf.InstanceURL, "https://"),
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	http.Redirect(writer, req, conf.OIDC.AuthCodeURL(flow), http.StatusFound)
+}
+
+// FrontHandlerOIDCCallback finishes a login flow once the OpenID Connect provider sent the client back.
+//
+// The state given back must match the flow kept in the cookie, the code is then exchanged for an ID token
+// using [oidc.Provider.Exchange], and the user it identifies is found or created using [accounts.SignIn],
+// before a session is opened like with a password.
+// The route is not found if the single sign-on is disabled.
+func (conf Configuration) FrontHandlerOIDCCallback(writer http.ResponseWriter, req *http.Request) { //nolint:funlen
+	// Get the locale
+	locale := utils.GetLocale(req, conf.Locales, conf.SupportedLocales)
+
+	// The route is disabled without an OpenID Connect provider
+	if conf.OIDC == nil {
+		conf.FrontErrorPage(writer, req, http.StatusNotFound, locale.ErrNotFound, "/")
+
+		return
+	}
+
+	// The flow can only be used once
+	http.SetCookie(writer, &http.Cookie{
+		Name:     oidcFlowCookie,
+		Value:    "",
+		Path:     oidcFlowPath,
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(conf.InstanceURL, "https://"),
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	cookie, err := req.Cookie(oidcFlowCookie)
+	if err != nil {
+		conf.FrontErrorPage(writer, req, http.StatusBadRequest, locale.ErrOIDCState, "/account")
+
+		return
+	}
+
+	flow, err := oidc.DecodeFlow(cookie.Value)
+	if err != nil || !flow.CheckState(req.URL.Query().Get("state")) {
+		conf.FrontErrorPage(writer, req, http.StatusBadRequest, locale.ErrOIDCState, "/account")
+
+		return
+	}
+
+	// The provider tells why the user wasn't logged in
+	if reason := req.URL.Query().Get("error"); reason != "" {
+		slog.InfoContext(req.Context(), "The identity provider refused a login",
+			slog.String("error", reason), slog.String("description", req.URL.Query().Get("error_description")))
+		conf.FrontErrorPage(writer, req, http.StatusUnauthorized, locale.ErrOIDCDenied, "/account")
+
+		return
+	}
+
+	claims, err := conf.OIDC.Exchange(req.Context(), req.URL.Query().Get("code"), flow)
+	if err != nil {
+		slog.WarnContext(req.Context(), "Failed to log in with the identity provider", slog.Any("error", err))
+		conf.FrontErrorPage(writer, req, http.StatusUnauthorized, locale.ErrOIDCLogin, "/account")
+
+		return
+	}
+
+	user, err := accounts.SignIn(conf.Store, claims.Subject, claims.Username, claims.Roles)
+	if err != nil {
+		slog.ErrorContext(req.Context(), "Failed to sign a user in", slog.Any("error", err))
+		conf.FrontErrorPage(writer, req, http.StatusInternalServerError, locale.ErrCreateUser, "/account")
+
+		return
+	}
+
+	conf.openSession(writer, req, user, flow.Next, locale)
+}
@@ -0,0 +1,40 @@
+//    reddlinks, a simple link shortener written in Go.
+//    Copyright (C) 2025 redd
+//
+//    This program is free software: you can redistribute it and/or modify
+//    it under the terms of the GNU General Public License as published by
+//    the Free Software Foundation, either version 3 of the License, or
+//    (at your option) any later version.
+//
+//    This program is distributed in the hope that it will be useful,
+//    but WITHOUT ANY WARRANTY; without even the implied warranty of
+//    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//    GNU General Public License for more details.
+//
+//    You should have received a copy of the GNU General Public License
+//    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package http
+
+import (
+	_ "embed"
+	"log/slog"
+	"net/http"
+)
+
+// openAPISpec is the OpenAPI 3 document describing the versioned API.
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+// APIOpenAPI sends the OpenAPI 3 document describing the versioned API (GET /api/v1/openapi.json).
+//
+// The server of the document is relative, so that the clients generated from it reach the instance serving it.
+func APIOpenAPI(writer http.ResponseWriter, req *http.Request) {
+	writer.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	writer.WriteHeader(http.StatusOK)
+
+	if _, err := writer.Write(openAPISpec); err != nil {
+		slog.ErrorContext(req.Context(), "Failed to write the OpenAPI document", slog.Any("error", err))
+	}
+}
@@ -17,12 +17,21 @@
 package http
 
 import (
+	"context"
+	"log/slog"
 	"net/http"
+	"sort"
+	"time"
 
 	"github.com/redds-be/reddlinks/internal/json"
 )
 
+// pingTimeout is the time given to the store to answer the readiness probe.
+const pingTimeout = 2 * time.Second
+
 // HandlerReadiness sends a positive JSON response to indicate its readiness.
+//
+// It is kept at /status for compatibility, it doesn't check anything, see [Configuration.HandlerReady].
 func HandlerReadiness(writer http.ResponseWriter, _ *http.Request) {
 	// Define a JSON structure for the status
 	type statusResponse struct {
@@ -32,3 +41,73 @@ func HandlerReadiness(writer http.ResponseWriter, _ *http.Request) {
 	// Respond to the client with the 'Alive.' message at '/status'
 	json.RespondWithJSON(writer, http.StatusOK, statusResponse{Status: "Alive."})
 }
+
+// HandlerLive tells that the process is alive, it doesn't check any dependency.
+//
+// It is meant for liveness probes, a failing dependency must not get the process restarted.
+func HandlerLive(writer http.ResponseWriter, _ *http.Request) {
+	// Define a JSON structure for the status
+	type statusResponse struct {
+		Status string `json:"status"`
+	}
+
+	json.RespondWithJSON(writer, http.StatusOK, statusResponse{Status: "ok"})
+}
+
+// HandlerReady tells if the instance is ready to serve clients.
+//
+// The store is pinged using [database.LinkStore.Ping], giving up after pingTimeout,
+// and the templates and the locales must be loaded. The outcome of each check is sent in a [json.ReadinessResponse]
+// along with the date of the last successful garbage collection, the status code is 200 if every check passed
+// and 503 otherwise, so that the instance gets pulled out of rotation.
+func (conf Configuration) HandlerReady(writer http.ResponseWriter, req *http.Request) {
+	checks := map[string]string{
+		"database":  "ok",
+		"templates": "ok",
+		"locales":   "ok",
+	}
+
+	// Ping the store
+	ctx, cancel := context.WithTimeout(req.Context(), pingTimeout)
+	defer cancel()
+
+	// The error of the driver may tell where the database is, it is only logged
+	if err := conf.Store.Ping(ctx); err != nil {
+		slog.ErrorContext(req.Context(), "The store can't be reached", slog.Any("error", err))
+
+		checks["database"] = "unreachable"
+	}
+
+	// Check that the pages can be rendered
+	if Templates == nil || Templates.Lookup("index.tmpl") == nil || Templates.Lookup("error.tmpl") == nil {
+		checks["templates"] = "the templates are not loaded"
+	}
+
+	// Check that the default locale is loaded, it is used when the locale of the client isn't supported
+	if _, loaded := conf.Locales["en"]; !loaded {
+		checks["locales"] = "the default locale is not loaded"
+	}
+
+	response := json.ReadinessResponse{Status: "ready", Checks: checks}
+
+	for name, outcome := range checks {
+		if outcome != "ok" {
+			response.Failing = append(response.Failing, name)
+		}
+	}
+
+	sort.Strings(response.Failing)
+
+	// Report the last successful garbage collection, if any
+	if lastRun := conf.GC.LastRun(); !lastRun.IsZero() {
+		response.LastGCRun = lastRun.UTC().Format(time.RFC3339)
+	}
+
+	code := http.StatusOK
+	if len(response.Failing) != 0 {
+		response.Status = "unavailable"
+		code = http.StatusServiceUnavailable
+	}
+
+	json.RespondWithJSON(writer, code, response)
+}
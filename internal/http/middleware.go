@@ -0,0 +1,321 @@
+//    reddlinks, a simple link shortener written in Go.
+//    Copyright (C) 2025 redd
+//
+//    This program is free software: you can redistribute it and/or modify
+//    it under the terms of the GNU General Public License as published by
+//    the Free Software Foundation, either version 3 of the License, or
+//    (at your option) any later version.
+//
+//    This program is distributed in the hope that it will be useful,
+//    but WITHOUT ANY WARRANTY; without even the implied warranty of
+//    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//    GNU General Public License for more details.
+//
+//    You should have received a copy of the GNU General Public License
+//    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package http
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redds-be/reddlinks/internal/accounts"
+	"github.com/redds-be/reddlinks/internal/apikeys"
+	"github.com/redds-be/reddlinks/internal/json"
+	"github.com/redds-be/reddlinks/internal/logging"
+	"github.com/redds-be/reddlinks/internal/proxy"
+	"github.com/redds-be/reddlinks/internal/ratelimit"
+)
+
+// RequestID gives an ID to each request before calling the next handler.
+//
+// The ID given by the client using the [logging.RequestIDHeader] header is kept if it is valid,
+// see [logging.IsValidRequestID], a new one is generated otherwise. The ID is stored in the context
+// of the request, see [logging.WithRequestID], and echoed in the [logging.RequestIDHeader] header of the response.
+// Once the request is handled, a debug line giving its method, path, status and duration is logged.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
+		start := time.Now()
+
+		// Keep the ID given by the client, if it can be trusted to be logged as is
+		requestID := req.Header.Get(logging.RequestIDHeader)
+		if !logging.IsValidRequestID(requestID) {
+			requestID = logging.NewRequestID()
+		}
+
+		// Echo the ID and make it available to the handlers
+		writer.Header().Set(logging.RequestIDHeader, requestID)
+		req = req.WithContext(logging.WithRequestID(req.Context(), requestID))
+
+		// Record the status code sent by the handler
+		recorder := &statusRecorder{ResponseWriter: writer, status: http.StatusOK}
+
+		next.ServeHTTP(recorder, req)
+
+		slog.DebugContext(req.Context(), "Request handled",
+			slog.String("method", req.Method),
+			slog.String("path", req.URL.Path),
+			slog.Int("status", recorder.status),
+			slog.Duration("duration", time.Since(start)),
+		)
+	})
+}
+
+// ResolveClient resolves the address and the scheme of the client of each request before calling the next handler.
+//
+// The forwarding headers are only trusted if the request comes from one of the TrustedProxies, see [proxy.Resolve].
+// The client is stored in the context of the request, see [proxy.WithClient], the handlers get it using [proxy.ClientOf].
+func (conf Configuration) ResolveClient(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
+		client := proxy.Resolve(req, conf.TrustedProxies)
+
+		next.ServeHTTP(writer, req.WithContext(proxy.WithClient(req.Context(), client)))
+	})
+}
+
+// Authenticate resolves the API key given as a bearer token by each request before calling the next handler.
+//
+// The key is checked using [apikeys.Authenticate] and stored in the context of the request, see [apikeys.WithKey],
+// the handlers then check its scopes using [apikeys.Allows]. Requests without an API key are handled as usual,
+// the ones giving a key that doesn't exist or has expired get an unauthorized error.
+func (conf Configuration) Authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
+		key, err := apikeys.Authenticate(conf.Store, req)
+
+		switch {
+		case err == nil:
+			req = req.WithContext(apikeys.WithKey(req.Context(), key))
+		case errors.Is(err, apikeys.ErrNoKey):
+			// Anonymous requests are handled as usual
+		case errors.Is(err, apikeys.ErrInvalidKey), errors.Is(err, apikeys.ErrExpiredKey):
+			writer.Header().Set("WWW-Authenticate", `Bearer realm="reddlinks", error="invalid_token"`)
+			conf.RespondWithError(writer, req, http.StatusUnauthorized, json.CodeInvalidAPIKey)
+
+			return
+		default:
+			slog.ErrorContext(req.Context(), "Failed to check an API key", slog.Any("error", err))
+			conf.RespondWithError(writer, req, http.StatusInternalServerError, json.CodeCheckAPIKeyFailed)
+
+			return
+		}
+
+		next.ServeHTTP(writer, req)
+	})
+}
+
+// Session resolves the user logged in by the session cookie of each request before calling the next handler.
+//
+// The session is checked using [accounts.Authenticate] and the user is stored in the context of the request,
+// see [accounts.WithUser], the handlers then get it using [accounts.FromContext]. Requests without a valid session
+// are handled as anonymous ones. The next handler is returned as is if the accounts are disabled.
+func (conf Configuration) Session(next http.Handler) http.Handler {
+	if !conf.Accounts {
+		return next
+	}
+
+	return http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
+		user, err := accounts.Authenticate(conf.Store, req)
+
+		switch {
+		case err == nil:
+			req = req.WithContext(accounts.WithUser(req.Context(), user))
+		case errors.Is(err, accounts.ErrNoSession):
+			// Anonymous requests are handled as usual
+		default:
+			slog.ErrorContext(req.Context(), "Failed to check a session", slog.Any("error", err))
+			conf.RespondWithError(writer, req, http.StatusInternalServerError, json.CodeCheckSessionFailed)
+
+			return
+		}
+
+		next.ServeHTTP(writer, req)
+	})
+}
+
+// isCreator tells if the client can create links when the creation is restricted: it was authenticated with an API key
+// having the create scope, see [Configuration.Authenticate], or it is logged in, see [Configuration.Session],
+// as a user having the creator role if one is configured.
+func (conf Configuration) isCreator(req *http.Request) bool {
+	if apikeys.Allows(req, apikeys.ScopeCreate) {
+		return true
+	}
+
+	user, loggedIn := accounts.FromContext(req.Context())
+
+	return loggedIn && (conf.CreatorRole == "" || accounts.HasRole(user, conf.CreatorRole))
+}
+
+// RequireCreator refuses the requests of the clients that can't create links, see [Configuration.isCreator],
+// before calling the next handler.
+//
+// Refused requests get an unauthorized error, or a forbidden error if the user is logged in without the creator role.
+// The next handler is returned as is if the creation isn't restricted.
+func (conf Configuration) RequireCreator(next http.Handler) http.Handler {
+	if !conf.RestrictCreation {
+		return next
+	}
+
+	return http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
+		if !conf.isCreator(req) {
+			// Logging in again won't give the role
+			if _, loggedIn := accounts.FromContext(req.Context()); loggedIn {
+				conf.RespondWithError(writer, req, http.StatusForbidden, json.CodeCreatorRoleRequired)
+
+				return
+			}
+
+			writer.Header().Set("WWW-Authenticate", `Bearer realm="reddlinks"`)
+
+			// Tell the browsers that an account is enough when the accounts are enabled
+			errCode := json.CodeAPIKeyRequired
+			if conf.Accounts {
+				errCode = json.CodeLoginRequired
+			}
+
+			conf.RespondWithError(writer, req, http.StatusUnauthorized, errCode)
+
+			return
+		}
+
+		next.ServeHTTP(writer, req)
+	})
+}
+
+// Instrument records the count and the duration of the requests handled by the next handler in the metrics.
+//
+// The requests are grouped by the pattern of the route which handled them, without its method,
+// the requests not matching any route are grouped under "unmatched". The next handler is returned as is
+// if the metrics are disabled.
+func (conf Configuration) Instrument(next http.Handler) http.Handler {
+	if conf.Metrics == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
+		start := time.Now()
+
+		// Record the status code sent by the handler
+		recorder := &statusRecorder{ResponseWriter: writer, status: http.StatusOK}
+
+		next.ServeHTTP(recorder, req)
+
+		// The pattern is set by the multiplexer once the request is routed, ex: "GET /{short}"
+		route := req.Pattern
+		if _, path, found := strings.Cut(route, " "); found {
+			route = path
+		}
+
+		if route == "" {
+			route = "unmatched"
+		}
+
+		conf.Metrics.ObserveRequest(route, req.Method, recorder.status, time.Since(start))
+	})
+}
+
+// HSTS tells the browsers to only use HTTPS for the instance during HSTSMaxAge seconds,
+// using the Strict-Transport-Security header, before calling the next handler.
+//
+// The header is only sent to the clients using HTTPS, either directly or through a trusted proxy terminating TLS,
+// see [proxy.ClientOf]. The next handler is returned as is if HSTS is disabled.
+func (conf Configuration) HSTS(next http.Handler) http.Handler {
+	if conf.HSTSMaxAge <= 0 {
+		return next
+	}
+
+	value := "max-age=" + strconv.Itoa(conf.HSTSMaxAge)
+
+	return http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
+		if proxy.ClientOf(req).Scheme == proxy.SchemeHTTPS {
+			writer.Header().Set("Strict-Transport-Security", value)
+		}
+
+		next.ServeHTTP(writer, req)
+	})
+}
+
+// ExtendDeadlines overrides the read and write timeouts of the server for a route, giving the next handler
+// the given time from now to read the request and to write the response.
+//
+// It is meant for long-running routes. The deadlines are set using [http.ResponseController],
+// the ones of the server are kept if the writer doesn't support it.
+// The next handler is returned as is if the timeout isn't positive.
+func ExtendDeadlines(timeout time.Duration, next http.Handler) http.Handler {
+	if timeout <= 0 {
+		return next
+	}
+
+	return http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
+		deadline := time.Now().Add(timeout)
+
+		controller := http.NewResponseController(writer)
+		_ = controller.SetReadDeadline(deadline)
+		_ = controller.SetWriteDeadline(deadline)
+
+		next.ServeHTTP(writer, req)
+	})
+}
+
+// RateLimit refuses the requests of the clients exceeding the rate limit of a route before calling the next handler.
+//
+// Refused requests get a too many requests error and a Retry-After header, see [Configuration.allow].
+// The requests authenticated with an API key having the create scope aren't limited.
+// The next handler is returned as is if the rate limits are disabled.
+func (conf Configuration) RateLimit(route ratelimit.Route, next http.Handler) http.Handler {
+	if conf.RateLimiter == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
+		if !apikeys.Allows(req, apikeys.ScopeCreate) && !conf.allow(writer, req, route) {
+			conf.RespondWithError(writer, req, http.StatusTooManyRequests, json.CodeRateLimited)
+
+			return
+		}
+
+		next.ServeHTTP(writer, req)
+	})
+}
+
+// allow takes a token from the bucket of the client of a request for a route, see [ratelimit.Limiter.Allow].
+//
+// If the request is refused, the Retry-After header is set to the number of seconds the client has to wait,
+// the caller then has to respond with a too many requests error.
+func (conf Configuration) allow(writer http.ResponseWriter, req *http.Request, route ratelimit.Route) bool {
+	wait := conf.RateLimiter.Allow(req, route)
+	if wait <= 0 {
+		return true
+	}
+
+	writer.Header().Set("Retry-After", ratelimit.RetryAfter(wait))
+
+	return false
+}
+
+// statusRecorder is a [http.ResponseWriter] recording the status code of the response.
+type statusRecorder struct {
+	http.ResponseWriter
+
+	status      int
+	wroteHeader bool
+}
+
+// WriteHeader records the final status code before writing it, informational ones are ignored.
+func (recorder *statusRecorder) WriteHeader(code int) {
+	if !recorder.wroteHeader && code >= http.StatusOK {
+		recorder.status = code
+		recorder.wroteHeader = true
+	}
+
+	recorder.ResponseWriter.WriteHeader(code)
+}
+
+// Unwrap returns the underlying writer, used by [http.ResponseController].
+func (recorder *statusRecorder) Unwrap() http.ResponseWriter {
+	return recorder.ResponseWriter
+}
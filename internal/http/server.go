@@ -19,12 +19,17 @@
 package http
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"io/fs"
-	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
-	"time"
 
+	"github.com/redds-be/reddlinks/internal/certs"
+	"github.com/redds-be/reddlinks/internal/ratelimit"
 	"github.com/redds-be/reddlinks/internal/utils"
 )
 
@@ -35,7 +40,7 @@ type Configuration utils.Configuration
 // Check [utils.Configuration] to know about these fields.
 func NewAdapter(configuration utils.Configuration) Configuration {
 	return Configuration{
-		DB:                     configuration.DB,
+		Store:                  configuration.Store,
 		InstanceName:           configuration.InstanceName,
 		InstanceURL:            configuration.InstanceURL,
 		Version:                configuration.Version,
@@ -48,33 +53,84 @@ func NewAdapter(configuration utils.Configuration) Configuration {
 		Static:                 configuration.Static,
 		Locales:                configuration.Locales,
 		SupportedLocales:       configuration.SupportedLocales,
+		Hits:                   configuration.Hits,
+		Admin:                  configuration.Admin,
+		Metrics:                configuration.Metrics,
+		MetricsToken:           configuration.MetricsToken,
+		GC:                     configuration.GC,
+		ShutdownTimeout:        configuration.ShutdownTimeout,
+		TLS:                    configuration.TLS,
+		RedirectAddr:           configuration.RedirectAddr,
+		HSTSMaxAge:             configuration.HSTSMaxAge,
+		ReadTimeout:            configuration.ReadTimeout,
+		ReadHeaderTimeout:      configuration.ReadHeaderTimeout,
+		WriteTimeout:           configuration.WriteTimeout,
+		IdleTimeout:            configuration.IdleTimeout,
+		BatchTimeout:           configuration.BatchTimeout,
+		MaxHeaderBytes:         configuration.MaxHeaderBytes,
+		MaxBodyBytes:           configuration.MaxBodyBytes,
+		MaxBatchBytes:          configuration.MaxBatchBytes,
+		RateLimiter:            configuration.RateLimiter,
+		TrustedProxies:         configuration.TrustedProxies,
+		RestrictCreation:       configuration.RestrictCreation,
+		Accounts:               configuration.Accounts,
+		Registration:           configuration.Registration,
+		OIDC:                   configuration.OIDC,
+		AdminRole:              configuration.AdminRole,
+		CreatorRole:            configuration.CreatorRole,
 	}
 }
 
 // Run starts configures the HTTP server and starts listening and serving.
 //
-// It starts by setting constants for the timeouts, after that,
-// a filesystem is created for the assets, followed by the creation of
+// It starts by creating a filesystem is created for the assets, followed by the creation of
 // a file server using the new filesystem. It is followed by the creation of a multiplexer
 // which will handle the endpoints.
 // GET /assets/ if for serving the assets,
 // GET /status calls [HandlerReadiness] for health check,
+// GET /livez calls [HandlerLive] for liveness probes,
+// GET /readyz calls HandlerReady, which checks the dependencies for readiness probes,
+// GET /metrics calls HandlerMetrics, which writes the metrics in the Prometheus format, only if the metrics are enabled,
 // POST /add calls FrontHandlerAdd, which creates a link and displays the information in a browser,
 // POST /access calls FrontHandlerRedirectToURL, which is used to access a password protected link,
 // GET /privacy calls FrontHandlerPrivacyPage, which is used to display the privacy policy,
+// GET /admin calls FrontHandlerAdmin, which is used to display the admin dashboard,
+// POST /admin calls FrontHandlerAdminAction, which is used to log in and to manage links and API keys from the admin dashboard,
+// GET /account calls FrontHandlerAccount, which is used to log in, to register and to list the links of a user,
+// POST /account calls FrontHandlerAccountAction, which is used to log in, to register, to log out and to manage the links of a user,
+// GET /account/oidc calls FrontHandlerOIDCLogin, which sends the client to the OpenID Connect provider to log in,
+// GET /account/oidc/callback calls FrontHandlerOIDCCallback, which logs the client in once the provider sent it back,
+// POST /batch calls FrontHandlerBatch, which creates the links of an uploaded CSV file and displays the outcome in a browser,
+// POST /api/batch calls APICreateLinks, which is used to create several links at once from a JSON array or a CSV file,
+// the versioned API, see [APIPrefix], whose errors are typed objects with a stable code, is served under /api/v1/:
+// POST /api/v1/links calls APICreateLink, POST /api/v1/links/batch calls APICreateLinks,
+// GET /api/v1/links/{short} calls APIGetLink, which always sends the information of a link in JSON,
+// PATCH /api/v1/links/{short} calls APIUpdateLink, DELETE /api/v1/links/{short} calls APIDeleteLink,
+// and GET /api/v1/openapi.json calls [APIOpenAPI], which sends the OpenAPI document describing these routes,
+// the batch routes are given BatchTimeout instead of the read and write timeouts using [ExtendDeadlines],
+// the routes creating links are limited by [Configuration.RateLimit], the password attempts being limited by their handlers,
+// they are restricted to the API keys having the create scope and the logged in users by [Configuration.RequireCreator]
+// if RestrictCreation is set,
 // GET / calls FrontHandlerMainPage, which is used to serve a form to shorten a link,
 // GET /{short} calls APIRedirectToURL, which is used to access a url based on the give short,
+// PATCH /{short} calls APIUpdateLink, which is used to update a link using its management token, an API key or the session of its owner,
+// DELETE /{short} calls APIDeleteLink, which is used to delete a link using its management token, an API key or the session of its owner,
 // POST / calls APICreateLink, which is used to create a link record in the database.
 // After the multiplexer is configured, the HTTP server needs to be configured with the address and port,
-// the timeouts constants and the multiplexer as the handler. After the configuration is set,
-// [http.ListenAndServe] is called.
-func (conf Configuration) Run() error {
-	// Set default timeout time in seconds
-	const readTimeout = 1 * time.Second
-	const WriteTimeout = 1 * time.Second
-	const IdleTimeout = 30 * time.Second
-	const ReadHeaderTimeout = 2 * time.Second
-
+// the configured timeouts and header size limit, and the multiplexer behind the [RequestID], [Configuration.ResolveClient],
+// [Configuration.Authenticate], [Configuration.Session], [Configuration.Instrument] and [Configuration.HSTS] middlewares
+// as the handler. After the configuration is set, [http.Server.ListenAndServe] is called, or [http.Server.ListenAndServeTLS]
+// if TLS is enabled, in which case a plain HTTP server redirecting to HTTPS and answering the ACME challenges is also started
+// if RedirectAddr is set.
+// Once the given context is done, or once a server fails, the servers stop accepting connections and waits for the in-flight requests
+// to be handled using [http.Server.Shutdown], giving up after ShutdownTimeout.
+//
+// Parameters:
+//   - ctx: The context whose end stops the server, usually done on SIGINT or SIGTERM
+//
+// Returns:
+//   - error: Any error encountered while listening, or while draining the connections
+func (conf Configuration) Run(ctx context.Context) error {
 	var assetsHTTPFS http.Handler
 	var err error
 
@@ -86,7 +142,7 @@ func (conf Configuration) Run() error {
 		// Create the filesystem for the assets
 		assetsFS, err := fs.Sub(conf.Static, "static/assets")
 		if err != nil {
-			log.Panic(err)
+			return err
 		}
 
 		// Create a file server using the assets filesystem
@@ -100,10 +156,18 @@ func (conf Configuration) Run() error {
 	mux.Handle("GET /assets/", http.StripPrefix("/assets/", assetsHTTPFS))
 
 	// Assign a handler to these different paths
-	mux.HandleFunc("GET /status", HandlerReadiness) // Check the status of the server
-	mux.HandleFunc(
+	mux.HandleFunc("GET /status", HandlerReadiness)  // Check the status of the server
+	mux.HandleFunc("GET /livez", HandlerLive)        // Liveness probe
+	mux.HandleFunc("GET /readyz", conf.HandlerReady) // Readiness probe, checking the dependencies
+
+	// Expose the metrics if they are enabled
+	if conf.Metrics != nil {
+		mux.HandleFunc("GET /metrics", conf.HandlerMetrics)
+	}
+
+	mux.Handle(
 		"POST /add",
-		conf.FrontHandlerAdd,
+		conf.RequireCreator(conf.RateLimit(ratelimit.RouteCreate, http.HandlerFunc(conf.FrontHandlerAdd))),
 	) // Front page for adding a link that returns the basic info
 	mux.HandleFunc(
 		"POST /access",
@@ -113,26 +177,148 @@ func (conf Configuration) Run() error {
 		"GET /privacy",
 		conf.FrontHandlerPrivacyPage,
 	) // Display Privacy policy information page
+	mux.HandleFunc("GET /admin", conf.FrontHandlerAdmin)            // Display the admin dashboard
+	mux.HandleFunc("POST /admin", conf.FrontHandlerAdminAction)     // Manage links and API keys from the admin dashboard
+	mux.HandleFunc("GET /account", conf.FrontHandlerAccount)        // Display the account page of a user
+	mux.HandleFunc("POST /account", conf.FrontHandlerAccountAction) // Log in and manage the links of a user
+	mux.HandleFunc("GET /account/oidc", conf.FrontHandlerOIDCLogin) // Log in using the OpenID Connect provider
+	mux.HandleFunc(
+		"GET /account/oidc/callback",
+		conf.FrontHandlerOIDCCallback,
+	) // Log in once the OpenID Connect provider sent the client back
+	mux.Handle(
+		"POST /batch",
+		conf.RequireCreator(
+			conf.RateLimit(ratelimit.RouteBatch, ExtendDeadlines(conf.BatchTimeout, http.HandlerFunc(conf.FrontHandlerBatch))),
+		),
+	) // Create the links of an uploaded CSV file, hashing is slow on large batches
+	mux.Handle(
+		"POST /api/batch",
+		conf.RequireCreator(
+			conf.RateLimit(ratelimit.RouteBatch, ExtendDeadlines(conf.BatchTimeout, http.HandlerFunc(conf.APICreateLinks))),
+		),
+	) // Create several links at once
+	mux.Handle(
+		"POST /api/v1/links",
+		conf.RequireCreator(conf.RateLimit(ratelimit.RouteCreate, http.HandlerFunc(conf.APICreateLink))),
+	) // Create a link using the versioned API
+	mux.Handle(
+		"POST /api/v1/links/batch",
+		conf.RequireCreator(
+			conf.RateLimit(ratelimit.RouteBatch, ExtendDeadlines(conf.BatchTimeout, http.HandlerFunc(conf.APICreateLinks))),
+		),
+	) // Create several links at once using the versioned API
+	mux.HandleFunc("GET /api/v1/links/{short}", conf.APIGetLink)       // Get the information of a link
+	mux.HandleFunc("PATCH /api/v1/links/{short}", conf.APIUpdateLink)  // Update a link using the versioned API
+	mux.HandleFunc("DELETE /api/v1/links/{short}", conf.APIDeleteLink) // Delete a link using the versioned API
+	mux.HandleFunc("GET /api/v1/openapi.json", APIOpenAPI)             // Describe the versioned API
 	mux.HandleFunc(
 		"GET /",
 		conf.FrontHandlerMainPage,
 	) // Main page with the form to create a link
 	mux.HandleFunc("GET /{short}", conf.APIRedirectToURL) // Access a url
-	mux.HandleFunc("POST /", conf.APICreateLink)          // Create a link
+	mux.HandleFunc("PATCH /{short}", conf.APIUpdateLink)  // Update a link
+	mux.HandleFunc("DELETE /{short}", conf.APIDeleteLink) // Delete a link
+	mux.Handle(
+		"POST /",
+		conf.RequireCreator(conf.RateLimit(ratelimit.RouteCreate, http.HandlerFunc(conf.APICreateLink))),
+	) // Create a link
 
 	// Set the settings for the http server
 	srv := &http.Server{
 		Addr:              conf.AddrAndPort,
-		ReadTimeout:       readTimeout,
-		WriteTimeout:      WriteTimeout,
-		IdleTimeout:       IdleTimeout,
-		ReadHeaderTimeout: ReadHeaderTimeout,
-		Handler:           mux,
+		ReadTimeout:       conf.ReadTimeout,
+		WriteTimeout:      conf.WriteTimeout,
+		IdleTimeout:       conf.IdleTimeout,
+		ReadHeaderTimeout: conf.ReadHeaderTimeout,
+		MaxHeaderBytes:    conf.MaxHeaderBytes,
+		Handler:           RequestID(conf.ResolveClient(conf.Authenticate(conf.Session(conf.Instrument(conf.HSTS(mux)))))),
+	}
+
+	servers := []*http.Server{srv}
+
+	if conf.TLS != nil {
+		srv.TLSConfig = conf.TLS.Config
+
+		// Redirect the plain HTTP requests to HTTPS, except the ACME challenges
+		if conf.RedirectAddr != "" {
+			_, port, err := net.SplitHostPort(conf.AddrAndPort)
+			if err != nil {
+				return fmt.Errorf("failed to get the HTTPS port: %w", err)
+			}
+
+			servers = append(servers, &http.Server{
+				Addr:              conf.RedirectAddr,
+				ReadTimeout:       conf.ReadTimeout,
+				WriteTimeout:      conf.WriteTimeout,
+				IdleTimeout:       conf.IdleTimeout,
+				ReadHeaderTimeout: conf.ReadHeaderTimeout,
+				MaxHeaderBytes:    conf.MaxHeaderBytes,
+				Handler:           conf.TLS.ChallengeHandler(certs.RedirectToHTTPS(port)),
+			})
+		}
 	}
 
 	// Start to listen
-	log.Printf("Listening on: '%s'.", conf.AddrAndPort)
-	err = srv.ListenAndServe()
+	served := make(chan error, len(servers))
+
+	for _, server := range servers {
+		go func() {
+			served <- conf.listenAndServe(server)
+		}()
+	}
+
+	// Wait for a server to fail or to be stopped
+	var failed error
+
+	select {
+	case failed = <-served:
+	case <-ctx.Done():
+	}
+
+	// Stop accepting connections and drain the in-flight requests
+	slog.Info("Shutting down", slog.Duration("timeout", conf.ShutdownTimeout))
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), conf.ShutdownTimeout)
+	defer cancel()
+
+	errs := []error{failed}
+
+	for _, server := range servers {
+		if err = server.Shutdown(shutdownCtx); err != nil {
+			errs = append(errs, fmt.Errorf("failed to drain the connections: %w", err))
+		}
+	}
+
+	// ListenAndServe returns as soon as Shutdown is called
+	remaining := len(servers)
+	if failed != nil {
+		remaining--
+	}
+
+	for range remaining {
+		if err = <-served; !errors.Is(err, http.ErrServerClosed) {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// listenAndServe starts a server over HTTPS if it has a TLS configuration, over plain HTTP otherwise.
+func (conf Configuration) listenAndServe(srv *http.Server) error {
+	if srv.TLSConfig != nil {
+		slog.Info("Listening over HTTPS", slog.String("addr", srv.Addr))
+
+		// The certificates are given by the TLS configuration
+		return srv.ListenAndServeTLS("", "")
+	}
+
+	if conf.TLS != nil {
+		slog.Info("Redirecting to HTTPS", slog.String("addr", srv.Addr))
+	} else {
+		slog.Info("Listening", slog.String("addr", srv.Addr))
+	}
 
-	return err
+	return srv.ListenAndServe()
 }
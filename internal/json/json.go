@@ -5,9 +5,14 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
+	"maps"
 	"net/http"
+	"slices"
 	"sync"
+
+	"github.com/redds-be/reddlinks/internal/logging"
+	"github.com/redds-be/reddlinks/internal/utils"
 )
 
 // bufferPool creates a sync.Pool for reusing byte buffers during JSON marshaling.
@@ -19,27 +24,199 @@ var bufferPool = sync.Pool{ //nolint:gochecknoglobals
 }
 
 // ErrResponse defines a standardized structure for error messages.
+//
+// Error is the HTTP status code followed by the localized message, Code is the stable code of the error, see [ErrorCode].
+// RequestID is the ID of the failed request, to be given when reporting the error, see [logging.RequestIDHeader].
 type ErrResponse struct {
-	Error string `json:"error"`
+	Error     string    `json:"error"`
+	Code      ErrorCode `json:"code"`
+	RequestID string    `json:"requestId,omitempty"`
+}
+
+// ErrorCode is a stable machine-readable identifier of an error, unlike its message which is localized.
+//
+// The message of a code is the text of its locale key, see [ErrorCode.LocaleKey].
+type ErrorCode string
+
+// Error codes that aren't specific to an error.
+const (
+	CodePayloadTooLarge ErrorCode = "payload_too_large"
+	CodeInternal        ErrorCode = "internal_error"
+)
+
+// Error codes of the client errors.
+const (
+	CodeNotFound             ErrorCode = "not_found"
+	CodeInvalidJSON          ErrorCode = "invalid_json"
+	CodeInvalidURL           ErrorCode = "invalid_url"
+	CodeInvalidPath          ErrorCode = "invalid_path"
+	CodeReservedPath         ErrorCode = "reserved_path"
+	CodePathInUse            ErrorCode = "path_in_use"
+	CodeRedirectionLoop      ErrorCode = "redirection_loop"
+	CodeInvalidExpireAfter   ErrorCode = "invalid_expire_after"
+	CodeInvalidExpireDate    ErrorCode = "invalid_expire_date"
+	CodePasswordRequired     ErrorCode = "password_required"
+	CodeWrongPassword        ErrorCode = "wrong_password"
+	CodeTokenRequired        ErrorCode = "token_required"
+	CodeWrongToken           ErrorCode = "wrong_token"
+	CodeNotManageable        ErrorCode = "not_manageable"
+	CodeInvalidBatch         ErrorCode = "invalid_batch"
+	CodeBatchTooLarge        ErrorCode = "batch_too_large"
+	CodeRateLimited          ErrorCode = "rate_limited"
+	CodeInvalidAPIKey        ErrorCode = "invalid_api_key"
+	CodeAPIKeyRequired       ErrorCode = "api_key_required"
+	CodeLoginRequired        ErrorCode = "login_required"
+	CodeCreatorRoleRequired  ErrorCode = "creator_role_required"
+	CodeMetricsTokenRequired ErrorCode = "metrics_token_required"
+)
+
+// Error codes of the server errors.
+const (
+	CodeNoSpaceLeft        ErrorCode = "no_space_left"
+	CodeGenerationFailed   ErrorCode = "generation_failed"
+	CodeExpiryFailed       ErrorCode = "expiry_failed"
+	CodePasswordHashFailed ErrorCode = "password_hash_failed"
+	CodeTokenHashFailed    ErrorCode = "token_hash_failed"
+	CodeHashCheckFailed    ErrorCode = "hash_check_failed"
+	CodeGetLinkFailed      ErrorCode = "get_link_failed"
+	CodeUpdateLinkFailed   ErrorCode = "update_link_failed"
+	CodeDeleteLinkFailed   ErrorCode = "delete_link_failed"
+	CodeCheckAPIKeyFailed  ErrorCode = "check_api_key_failed"
+	CodeCheckSessionFailed ErrorCode = "check_session_failed"
+	CodeLoadPageFailed     ErrorCode = "load_page_failed"
+)
+
+// localeKeys maps the error codes to the keys of their message in the locales.
+var localeKeys = map[ErrorCode]string{ //nolint:gochecknoglobals
+	CodeNotFound:             "err_not_found",
+	CodeInvalidJSON:          "err_invalid_json",
+	CodePayloadTooLarge:      "err_payload_too_large",
+	CodeInvalidURL:           "err_invalid_url",
+	CodeInvalidPath:          "err_alpha_numeric",
+	CodeReservedPath:         "err_reserved_path",
+	CodePathInUse:            "err_path_in_use",
+	CodeRedirectionLoop:      "err_redirection_loop",
+	CodeInvalidExpireAfter:   "err_parse_time",
+	CodeInvalidExpireDate:    "err_parse_expiry",
+	CodePasswordRequired:     "err_pass_access",
+	CodeWrongPassword:        "err_wrong_pass",
+	CodeTokenRequired:        "err_missing_token",
+	CodeWrongToken:           "err_wrong_token",
+	CodeNotManageable:        "err_not_manageable",
+	CodeInvalidBatch:         "err_invalid_batch",
+	CodeBatchTooLarge:        "err_batch_too_large",
+	CodeRateLimited:          "err_rate_limited",
+	CodeInvalidAPIKey:        "err_invalid_api_key",
+	CodeAPIKeyRequired:       "err_api_key_required",
+	CodeLoginRequired:        "err_login_required",
+	CodeCreatorRoleRequired:  "err_creator_role_required",
+	CodeMetricsTokenRequired: "err_metrics_token_required",
+	CodeNoSpaceLeft:          "err_no_space_left",
+	CodeGenerationFailed:     "err_unable_gen",
+	CodeExpiryFailed:         "err_unable_tell_eow",
+	CodePasswordHashFailed:   "err_hash_pass",
+	CodeTokenHashFailed:      "err_hash_token",
+	CodeHashCheckFailed:      "err_comp_hash",
+	CodeGetLinkFailed:        "err_get_info",
+	CodeUpdateLinkFailed:     "err_update_link",
+	CodeDeleteLinkFailed:     "err_delete_link",
+	CodeCheckAPIKeyFailed:    "err_check_api_key",
+	CodeCheckSessionFailed:   "err_check_session",
+	CodeLoadPageFailed:       "err_unable_load_page",
+}
+
+// LocaleKey returns the key of the message of an error code in the locales,
+// the generic "error" key for the codes that aren't specific to an error.
+func (code ErrorCode) LocaleKey() string {
+	if key, found := localeKeys[code]; found {
+		return key
+	}
+
+	return "error"
+}
+
+// Message returns the localized message of an error code, see [ErrorCode.LocaleKey].
+func (code ErrorCode) Message(locale utils.PageLocaleTl) string {
+	return locale.Text(code.LocaleKey())
+}
+
+// ErrorCodes returns every error code having its own message in the locales.
+func ErrorCodes() []ErrorCode {
+	return slices.Sorted(maps.Keys(localeKeys))
+}
+
+// APIError defines the typed error object of the versioned API.
+//
+// Code is meant to be checked by the clients, Message is localized using the Accept-Language header
+// and is only meant to be displayed.
+type APIError struct {
+	Status  int       `json:"status"`
+	Code    ErrorCode `json:"code"`
+	Message string    `json:"message"`
+}
+
+// APIErrResponse defines the structure of the error responses of the versioned API.
+//
+// RequestID is the ID of the failed request, to be given when reporting the error, see [logging.RequestIDHeader].
+type APIErrResponse struct {
+	Error     APIError `json:"error"`
+	RequestID string   `json:"requestId,omitempty"`
 }
 
 // InfoResponse defines the structure for URL shortener information responses.
 type InfoResponse struct {
-	DstURL    string `json:"dstUrl"`    // The destination URL that the short URL redirects to
-	Short     string `json:"short"`     // The shortened URL identifier
-	CreatedAt string `json:"createdAt"` // Timestamp when the shortened URL was created
-	ExpiresAt string `json:"expiresAt"` // Timestamp when the shortened URL will expire
+	DstURL    string    `json:"dstUrl"`         // The destination URL that the short URL redirects to
+	Short     string    `json:"short"`          // The shortened URL identifier
+	CreatedAt string    `json:"createdAt"`      // Timestamp when the shortened URL was created
+	ExpiresAt string    `json:"expiresAt"`      // Timestamp when the shortened URL will expire
+	Hits      *HitsInfo `json:"hits,omitempty"` // Accesses to the shortened URL, only when analytics are enabled
+}
+
+// ReadinessResponse defines the structure of the readiness probe responses.
+type ReadinessResponse struct {
+	Status    string            `json:"status"`              // "ready" if every check passed, "unavailable" otherwise
+	Checks    map[string]string `json:"checks"`              // Outcome of each check, "ok" or the reason it failed
+	Failing   []string          `json:"failing,omitempty"`   // Names of the failing checks
+	LastGCRun string            `json:"lastGcRun,omitempty"` // Date of the last successful garbage collection (RFC 3339)
+}
+
+// HitsInfo defines the structure for the accesses to a shortened URL.
+type HitsInfo struct {
+	Total     int            `json:"total"`     // Total number of accesses
+	Referrers map[string]int `json:"referrers"` // Number of accesses per referrer host, empty for direct accesses
+	Agents    map[string]int `json:"agents"`    // Number of accesses per client class
 }
 
 // RespondWithError sends a standardized error response to the client.
-// It formats the error message with the HTTP status code and sends it as JSON.
+// It formats the error message with the HTTP status code and sends it as JSON along with the code of the error,
+// and the request ID set in the [logging.RequestIDHeader] header of the response, if any.
 //
 // Parameters:
 //   - writer: The HTTP response writer to send the response through
 //   - code: The HTTP status code to return
+//   - errCode: The stable code of the error
 //   - msg: The error message to include in the response
-func RespondWithError(writer http.ResponseWriter, code int, msg string) {
-	RespondWithJSON(writer, code, ErrResponse{Error: fmt.Sprintf("%d %s", code, msg)})
+func RespondWithError(writer http.ResponseWriter, code int, errCode ErrorCode, msg string) {
+	RespondWithJSON(writer, code, ErrResponse{
+		Error:     fmt.Sprintf("%d %s", code, msg),
+		Code:      errCode,
+		RequestID: writer.Header().Get(logging.RequestIDHeader),
+	})
+}
+
+// RespondWithAPIError sends a typed error response of the versioned API to the client, see [APIErrResponse],
+// along with the request ID set in the [logging.RequestIDHeader] header of the response, if any.
+//
+// Parameters:
+//   - writer: The HTTP response writer to send the response through
+//   - code: The HTTP status code to return
+//   - errCode: The stable code of the error
+//   - msg: The localized error message to include in the response
+func RespondWithAPIError(writer http.ResponseWriter, code int, errCode ErrorCode, msg string) {
+	RespondWithJSON(writer, code, APIErrResponse{
+		Error:     APIError{Status: code, Code: errCode, Message: msg},
+		RequestID: writer.Header().Get(logging.RequestIDHeader),
+	})
 }
 
 // RespondWithJSON marshals the provided payload into JSON and sends it to the client.
@@ -65,7 +242,8 @@ func RespondWithJSON(writer http.ResponseWriter, code int, payload interface{})
 
 	// Encode directly into the buffer
 	if err := json.NewEncoder(buf).Encode(payload); err != nil {
-		log.Printf("Failed to marshal JSON response: %v", err)
+		slog.Error("Failed to marshal JSON response", slog.Any("error", err),
+			slog.String(logging.RequestIDKey, writer.Header().Get(logging.RequestIDHeader)))
 		writer.WriteHeader(http.StatusInternalServerError)
 
 		return
@@ -74,6 +252,7 @@ func RespondWithJSON(writer http.ResponseWriter, code int, payload interface{})
 	// Set status code and write the response
 	writer.WriteHeader(code)
 	if _, err := writer.Write(buf.Bytes()); err != nil {
-		log.Printf("Failed to write JSON response: %v", err)
+		slog.Error("Failed to write JSON response", slog.Any("error", err),
+			slog.String(logging.RequestIDKey, writer.Header().Get(logging.RequestIDHeader)))
 	}
 }
@@ -18,6 +18,8 @@
 package links
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
 	"net/http"
 	"regexp"
@@ -26,6 +28,7 @@ import (
 	"github.com/alexedwards/argon2id"
 	"github.com/google/uuid"
 	"github.com/redds-be/reddlinks/internal/database"
+	"github.com/redds-be/reddlinks/internal/json"
 	"github.com/redds-be/reddlinks/internal/utils"
 	"gitlab.gnous.eu/ada/atp"
 )
@@ -33,11 +36,17 @@ import (
 // Common validation patterns compiled once for reuse.
 var (
 	urlPattern    = regexp.MustCompile(`^https?://.*\..*$`)
-	reservedPaths = regexp.MustCompile(`^status$|^error$|^add$|^access$|^privacy$|^assets.*$`)
+	reservedPaths = regexp.MustCompile(`^status$|^error$|^add$|^access$|^privacy$|^admin$|^account$|^batch$|^api$|^metrics$|^livez$|^readyz$|^assets.*$`)
 	alphaNumeric  = regexp.MustCompile(`^[A-Za-z0-9]*$`)
 	protocolRegex = regexp.MustCompile(`^https://|http://`)
 )
 
+// Characters and length used for generated paths and management tokens.
+const (
+	allowedChars = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+	tokenLength  = 32
+)
+
 // Link defines the structure of a link entry.
 type Link struct {
 	// ExpireAt is the date at which the link will expire
@@ -46,6 +55,8 @@ type Link struct {
 	URL string `json:"url"`
 	// Short is the shortened path
 	Short string `json:"short"`
+	// Token is the clear management token, only known right after the link creation
+	Token string `json:"token"`
 }
 
 // SimpleJSONLink defines the structure of a link entry that will be served to the client in JSON.
@@ -56,6 +67,10 @@ type SimpleJSONLink struct {
 	ExpireAt string `json:"expireAt"`
 	// URL is the original URL
 	URL string `json:"url"`
+	// Token is the management token used to update or delete the link
+	Token string `json:"token,omitempty"`
+	// Information is an information to be displayed to the user, only given by the versioned API
+	Information string `json:"information,omitempty"`
 }
 
 // PassJSONLink defines the structure of a link entry with password that will be served to the client in JSON.
@@ -68,6 +83,40 @@ type PassJSONLink struct {
 	ExpireAt string `json:"expireAt"`
 	// URL is the original URL
 	URL string `json:"url"`
+	// Token is the management token used to update or delete the link
+	Token string `json:"token,omitempty"`
+	// Information is an information to be displayed to the user, only given by the versioned API
+	Information string `json:"information,omitempty"`
+}
+
+// Error is the error returned by the link handling functions.
+//
+// Status is the HTTP status code to respond with and Code is the stable code of the error,
+// whose localized message is given by [json.ErrorCode.Message].
+type Error struct {
+	Status int
+	Code   json.ErrorCode
+}
+
+// Error returns the status and the code of the error.
+func (err *Error) Error() string {
+	return fmt.Sprintf("%d %s", err.Status, err.Code)
+}
+
+// newError returns an error of the link handling functions.
+func newError(status int, code json.ErrorCode) error {
+	return &Error{Status: status, Code: code}
+}
+
+// AsError returns the [Error] wrapped by an error returned by the link handling functions,
+// an internal error for the errors that aren't one.
+func AsError(err error) *Error {
+	var linkErr *Error
+	if errors.As(err, &linkErr) {
+		return linkErr
+	}
+
+	return &Error{Status: http.StatusInternalServerError, Code: json.CodeInternal}
 }
 
 // Configuration redefines utils.Configuration to be used for methods within the package.
@@ -80,36 +129,18 @@ func NewAdapter(configuration utils.Configuration) Configuration {
 	return Configuration(configuration)
 }
 
-// CreateLink returns a Link struct along with an HTTP code, optional information and an optional error code.
+// expiryDate returns the expiration date corresponding to the given parameters, or an [Error] if it can't be determined.
 //
-// It performs the following validations and operations:
-//   - Validates URL format (must use http/https protocol)
-//   - Determines link expiration time based on provided parameters or defaults
-//   - Validates or generates a path for the shortened URL
-//   - Prevents creation of redirection loops
-//   - Hashes passwords if provided for protected links
-//   - Creates the link entry in the database, handling collisions for generated paths
+// An explicit expiration date has priority over a duration, if none of them are given,
+// the default expiry time is used, if there is none, the link never expires.
 //
 // Parameters:
-//   - params: Contains all link creation parameters (URL, path, expiry, etc.)
-//   - locale: Contains localized text messages for error reporting
+//   - params: Contains the ExpireAfter and ExpireDate parameters
 //
 // Returns:
-//   - Link: The created link structure (empty if error occurred)
-//   - int: HTTP status code
-//   - string: Additional information message (if any)
-//   - string: Error message (if any)
-func (conf *Configuration) CreateLink( //nolint:gocognit,gocyclo,cyclop,funlen
-	params utils.Parameters,
-	locale utils.PageLocaleTl,
-) (Link, int, string, string) {
-	// Check if the url is valid using pre-compiled regex
-	isValid := urlPattern.MatchString(params.URL)
-	if !isValid {
-		return Link{}, http.StatusBadRequest, "", locale.ErrInvalidURL
-	}
-
-	// Set the expiry date, handling different expiration scenarios
+//   - time.Time: The expiration date
+//   - error: The reason why the date can't be determined (if any)
+func (conf *Configuration) expiryDate(params utils.Parameters) (time.Time, error) {
 	var expireAt time.Time
 	var err error
 
@@ -118,7 +149,7 @@ func (conf *Configuration) CreateLink( //nolint:gocognit,gocyclo,cyclop,funlen
 		// No expiration specified and no default - use max date
 		expireAt, err = time.Parse("2006-01-02", "9999-12-31")
 		if err != nil {
-			return Link{}, http.StatusInternalServerError, "", locale.ErrUnableTellEOW
+			return time.Time{}, newError(http.StatusInternalServerError, json.CodeExpiryFailed)
 		}
 	case params.ExpireAfter == "" && params.ExpireDate == "":
 		// Use default expiration time
@@ -127,17 +158,114 @@ func (conf *Configuration) CreateLink( //nolint:gocognit,gocyclo,cyclop,funlen
 		// Parse and use custom duration
 		expireDuration, err := atp.ParseDuration(params.ExpireAfter)
 		if err != nil {
-			return Link{}, http.StatusInternalServerError, "", locale.ErrParseTime
+			return time.Time{}, newError(http.StatusInternalServerError, json.CodeInvalidExpireAfter)
 		}
 		expireAt = time.Now().UTC().Add(expireDuration)
 	case params.ExpireDate != "":
 		// Parse and use explicit expiration date (priority over duration)
 		expireAt, err = time.Parse("2006-01-02T15:04", params.ExpireDate)
 		if err != nil {
-			return Link{}, http.StatusInternalServerError, "", locale.ErrParseExpiry
+			return time.Time{}, newError(http.StatusInternalServerError, json.CodeInvalidExpireDate)
 		}
 	}
 
+	return expireAt, nil
+}
+
+// isRedirectionLoop tells if the given URL points to the given short of this instance.
+func (conf *Configuration) isRedirectionLoop(url, short string) bool {
+	normalizedOriginal := protocolRegex.ReplaceAllString(url, "")
+	normalizedShortened := protocolRegex.ReplaceAllString(fmt.Sprintf("%s%s", conf.InstanceURL, short), "")
+
+	return normalizedOriginal == normalizedShortened
+}
+
+// BatchResult defines the outcome of the creation of one of the links of a batch.
+//
+// Link is the created link, AddInfo is an information to be displayed to the user
+// and Err is the reason why the link wasn't created, see [Error].
+type BatchResult struct {
+	Link    Link
+	AddInfo string
+	Err     error
+}
+
+// BatchJSONResult defines the outcome of the creation of one of the links of a batch that will be served to the client in JSON.
+type BatchJSONResult struct {
+	// Row is the number of the row in the batch, starting at 1
+	Row int `json:"row"`
+	// Code is the HTTP code the creation would have returned on its own
+	Code int `json:"code"`
+	// ShortenedLink is the full shortened link, empty if the link wasn't created
+	ShortenedLink string `json:"shortenedLink,omitempty"`
+	// Short is the shortened path, empty if the link wasn't created
+	Short string `json:"short,omitempty"`
+	// Password is the password needed to access the url
+	Password string `json:"password,omitempty"`
+	// ExpireAt is the formatted date at which the link will expire
+	ExpireAt string `json:"expireAt,omitempty"`
+	// URL is the original URL
+	URL string `json:"url"`
+	// Token is the management token used to update or delete the link
+	Token string `json:"token,omitempty"`
+	// Information is an information to be displayed to the user
+	Information string `json:"information,omitempty"`
+	// Error is the reason why the link wasn't created
+	Error string `json:"error,omitempty"`
+	// ErrorCode is the stable code of the reason why the link wasn't created
+	ErrorCode json.ErrorCode `json:"errorCode,omitempty"`
+}
+
+// BatchJSONResponse defines the outcome of the creation of a batch of links that will be served to the client in JSON.
+type BatchJSONResponse struct {
+	// Created is the number of created links
+	Created int `json:"created"`
+	// Failed is the number of links that couldn't be created
+	Failed int `json:"failed"`
+	// Results are the outcomes of each row, in the order of the batch
+	Results []BatchJSONResult `json:"results"`
+}
+
+// pendingLink defines a validated link that is ready to be inserted.
+//
+// Token is the clear management token, AutoGen tells if the short was generated
+// and Length is the requested length of the generated short.
+type pendingLink struct {
+	Link    database.Link
+	Token   string
+	AutoGen bool
+	Length  int
+}
+
+// newLink validates the given parameters and returns the link to insert, or an [Error] if they aren't valid.
+//
+// It performs the following validations and operations:
+//   - Validates URL format (must use http/https protocol)
+//   - Determines link expiration time based on provided parameters or defaults
+//   - Validates or generates a path for the shortened URL
+//   - Prevents creation of redirection loops
+//   - Hashes passwords if provided for protected links
+//   - Generates and hashes a management token used to update or delete the link later on
+//
+// Parameters:
+//   - params: Contains all link creation parameters (URL, path, expiry, etc.)
+//
+// Returns:
+//   - pendingLink: The link to insert (empty if error occurred)
+//   - error: The reason why the link can't be created (if any)
+func (conf *Configuration) newLink(params utils.Parameters) (pendingLink, error) { //nolint:cyclop
+	// Check if the url is valid using pre-compiled regex
+	isValid := urlPattern.MatchString(params.URL)
+	if !isValid {
+		return pendingLink{}, newError(http.StatusBadRequest, json.CodeInvalidURL)
+	}
+
+	// Set the expiry date, handling different expiration scenarios
+	expireAt, err := conf.expiryDate(params)
+	if err != nil {
+		return pendingLink{}, err
+	}
+
 	// Adjust length parameter to be within valid bounds
 	if params.Length <= 0 {
 		params.Length = conf.DefaultShortLength
@@ -147,22 +275,18 @@ func (conf *Configuration) CreateLink( //nolint:gocognit,gocyclo,cyclop,funlen
 
 	// Process custom path or generate a random one
 	autoGen := false
-	allowedChars := "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
 
-	if params.Path != "" { //nolint:nestif
+	if params.Path != "" {
 		// Check if the path is reserved
 		reservedMatch := reservedPaths.MatchString(params.Path)
 		if reservedMatch {
-			return Link{}, http.StatusBadRequest, "", fmt.Sprintf(
-				"The path '/%s' is reserved.",
-				params.Path,
-			)
+			return pendingLink{}, newError(http.StatusBadRequest, json.CodeReservedPath)
 		}
 
 		// Check if path contains only alphanumeric characters
 		specialCharMatch := alphaNumeric.MatchString(params.Path)
 		if !specialCharMatch {
-			return Link{}, http.StatusBadRequest, "", locale.ErrAlphaNumeric
+			return pendingLink{}, newError(http.StatusBadRequest, json.CodeInvalidPath)
 		}
 
 		// Trim path if it exceeds maximum length
@@ -174,15 +298,13 @@ func (conf *Configuration) CreateLink( //nolint:gocognit,gocyclo,cyclop,funlen
 		autoGen = true
 		params.Path, err = utils.GenStr(params.Length, allowedChars)
 		if err != nil {
-			return Link{}, http.StatusInternalServerError, "", locale.ErrUnableGen
+			return pendingLink{}, newError(http.StatusInternalServerError, json.CodeGenerationFailed)
 		}
 	}
 
 	// Check for redirection loops
-	normalizedOriginal := protocolRegex.ReplaceAllString(params.URL, "")
-	normalizedShortened := protocolRegex.ReplaceAllString(fmt.Sprintf("%s%s", conf.InstanceURL, params.Path), "")
-	if normalizedOriginal == normalizedShortened {
-		return Link{}, http.StatusBadRequest, "", locale.ErrRedirectionLoop
+	if conf.isRedirectionLoop(params.URL, params.Path) {
+		return pendingLink{}, newError(http.StatusBadRequest, json.CodeRedirectionLoop)
 	}
 
 	// Hash password if provided
@@ -190,63 +312,422 @@ func (conf *Configuration) CreateLink( //nolint:gocognit,gocyclo,cyclop,funlen
 	if params.Password != "" {
 		hash, err = argon2id.CreateHash(params.Password, argon2id.DefaultParams)
 		if err != nil {
-			return Link{}, http.StatusInternalServerError, "", locale.ErrPathInUse
+			return pendingLink{}, newError(http.StatusInternalServerError, json.CodePasswordHashFailed)
 		}
 	}
 
+	// Generate the management token and hash it like a password
+	token, err := utils.GenStr(tokenLength, allowedChars)
+	if err != nil {
+		return pendingLink{}, newError(http.StatusInternalServerError, json.CodeGenerationFailed)
+	}
+
+	tokenHash, err := argon2id.CreateHash(token, argon2id.DefaultParams)
+	if err != nil {
+		return pendingLink{}, newError(http.StatusInternalServerError, json.CodeTokenHashFailed)
+	}
+
+	return pendingLink{
+		Link: database.Link{
+			ID:        uuid.New(),
+			CreatedAt: time.Now().UTC(),
+			ExpireAt:  expireAt,
+			URL:       params.URL,
+			Short:     params.Path,
+			Password:  hash,
+			Token:     tokenHash,
+			Owner:     params.Owner,
+		},
+		Token:   token,
+		AutoGen: autoGen,
+		Length:  params.Length,
+	}, nil
+}
+
+// insertLink creates a validated link entry in the database, handling collisions for generated paths.
+//
+// If a generated path is already in use, new paths are generated with increasing lengths
+// until one is free, the short of the pending link is updated accordingly.
+//
+// Parameters:
+//   - pending: The link to insert, as returned by [Configuration.newLink]
+//   - locale: Contains localized text messages for the additional information
+//
+// Returns:
+//   - string: Additional information message (if any)
+//   - error: The reason why the link can't be inserted (if any)
+func (conf *Configuration) insertLink(pending *pendingLink, locale utils.PageLocaleTl) (string, error) {
 	// Create link in database
-	addInfo := ""
-	err = database.CreateLink(
-		conf.DB,
-		uuid.New(),
-		time.Now().UTC(),
-		expireAt,
-		params.URL,
-		params.Path,
-		hash,
-	)
+	err := conf.Store.CreateLink(pending.Link)
 
 	// Handle collision for custom path
-	if err != nil && !autoGen {
-		return Link{}, http.StatusBadRequest, "", locale.ErrPathInUse
-	} else if err != nil && autoGen {
+	if err != nil && !pending.AutoGen {
+		return "", newError(http.StatusBadRequest, json.CodePathInUse)
+	} else if err != nil {
 		// Handle collision for auto-generated path by trying different lengths
-	loop:
 		for index := conf.DefaultShortLength; index <= conf.DefaultMaxShortLength; index++ {
-			params.Path, err = utils.GenStr(index, allowedChars)
+			pending.Link.Short, err = utils.GenStr(index, allowedChars)
 			if err != nil {
-				return Link{}, http.StatusInternalServerError, "", locale.ErrUnableGen
+				return "", newError(http.StatusInternalServerError, json.CodeGenerationFailed)
 			}
 
-			err = database.CreateLink(
-				conf.DB,
-				uuid.New(),
-				time.Now().UTC(),
-				expireAt,
-				params.URL,
-				params.Path,
-				hash,
-			)
+			pending.Link.ID = uuid.New()
+
+			err = conf.Store.CreateLink(pending.Link)
 
 			switch {
 			case err != nil && index == conf.DefaultMaxShortLength:
-				return Link{}, http.StatusInternalServerError, "", locale.ErrNoSpaceLeft
-			case err == nil && index != params.Length:
-				addInfo = locale.InfoLengthChange
-
-				break loop
+				return "", newError(http.StatusInternalServerError, json.CodeNoSpaceLeft)
+			case err == nil && index != pending.Length:
+				return locale.InfoLengthChange, nil
 			case err == nil:
-				break loop
+				return "", nil
 			}
 		}
 	}
 
+	return "", nil
+}
+
+// created returns the link that is given back to the client once the pending link is inserted.
+func (pending pendingLink) created() Link {
+	return Link{
+		ExpireAt: pending.Link.ExpireAt,
+		URL:      pending.Link.URL,
+		Short:    pending.Link.Short,
+		Token:    pending.Token,
+	}
+}
+
+// CreateLink returns a Link struct along with optional information, or an [Error] if the link can't be created.
+//
+// The parameters are validated by [Configuration.newLink], the link entry is then created in the database,
+// handling collisions for generated paths.
+//
+// Parameters:
+//   - params: Contains all link creation parameters (URL, path, expiry, etc.)
+//   - locale: Contains localized text messages for the additional information
+//
+// Returns:
+//   - Link: The created link structure, including the clear management token (empty if error occurred)
+//   - string: Additional information message (if any)
+//   - error: The reason why the link can't be created (if any)
+func (conf *Configuration) CreateLink(params utils.Parameters, locale utils.PageLocaleTl) (Link, string, error) {
+	// Validate the parameters
+	pending, err := conf.newLink(params)
+	if err != nil {
+		return Link{}, "", err
+	}
+
+	// Create the link entry
+	addInfo, err := conf.insertLink(&pending, locale)
+	if err != nil {
+		return Link{}, "", err
+	}
+
+	// Record the creation if metrics are enabled
+	conf.Metrics.LinkCreated(pending.Link.Password != "", !pending.AutoGen)
+
 	// Return the created link
+	return pending.created(), addInfo, nil
+}
+
+// CreateLinks creates several links at once and returns the outcome of each of them, in the order of the batch.
+//
+// Each set of parameters is validated like in [Configuration.CreateLink], the valid links are then inserted
+// within a single transaction using [database.LinkStore.CreateLinks]. If the transaction fails, for example because
+// one of the paths is already in use, the valid links are inserted one by one instead so that only the
+// conflicting ones fail.
+//
+// Parameters:
+//   - batch: Contains the link creation parameters of each link
+//   - locale: Contains localized text messages for the additional information
+//
+// Returns:
+//   - []BatchResult: The outcome of the creation of each link
+func (conf *Configuration) CreateLinks(batch []utils.Parameters, locale utils.PageLocaleTl) []BatchResult {
+	results := make([]BatchResult, len(batch))
+	pendings := make([]pendingLink, len(batch))
+	valid := make([]database.Link, 0, len(batch))
+
+	// Validate every link, the invalid ones are left out of the insertion
+	for index, params := range batch {
+		var err error
+
+		pendings[index], err = conf.newLink(params)
+		if err != nil {
+			results[index] = BatchResult{Err: err}
+
+			continue
+		}
+
+		valid = append(valid, pendings[index].Link)
+	}
+
+	if len(valid) == 0 {
+		return results
+	}
+
+	// Try to insert every valid link at once
+	if err := conf.Store.CreateLinks(valid); err == nil {
+		for index := range batch {
+			if results[index].Err == nil {
+				conf.Metrics.LinkCreated(pendings[index].Link.Password != "", !pendings[index].AutoGen)
+				results[index] = BatchResult{Link: pendings[index].created()}
+			}
+		}
+
+		return results
+	}
+
+	// Insert the links one by one if they can't be inserted at once
+	for index := range batch {
+		if results[index].Err != nil {
+			continue
+		}
+
+		addInfo, err := conf.insertLink(&pendings[index], locale)
+		if err != nil {
+			results[index] = BatchResult{Err: err}
+
+			continue
+		}
+
+		conf.Metrics.LinkCreated(pendings[index].Link.Password != "", !pendings[index].AutoGen)
+		results[index] = BatchResult{Link: pendings[index].created(), AddInfo: addInfo}
+	}
+
+	return results
+}
+
+// checkToken verifies that the given management token matches the one of the given short.
+//
+// Parameters:
+//   - short: The short of the link to manage
+//   - token: The clear management token given by the client
+//
+// Returns:
+//   - error: The reason why the link can't be managed (if any)
+func (conf *Configuration) checkToken(short, token string) error {
+	// A token is always required
+	if token == "" {
+		return newError(http.StatusUnauthorized, json.CodeTokenRequired)
+	}
+
+	// Get the hash of the token
+	tokenHash, err := conf.Store.GetTokenHashByShort(short)
+	if err != nil {
+		return newError(http.StatusNotFound, json.CodeNotFound)
+	}
+
+	// Links created before management tokens existed can't be managed
+	if tokenHash == "" {
+		return newError(http.StatusForbidden, json.CodeNotManageable)
+	}
+
+	// Check if the token matches the hash
+	if match, err := argon2id.ComparePasswordAndHash(token, tokenHash); err == nil && !match {
+		return newError(http.StatusForbidden, json.CodeWrongToken)
+	} else if err != nil {
+		return newError(http.StatusInternalServerError, json.CodeHashCheckFailed)
+	}
+
+	return nil
+}
+
+// checkOwner verifies that the given short was created by the given user.
+//
+// A link owned by another user, or by nobody, is reported as not found so that
+// the links of the other users can't be discovered.
+//
+// Parameters:
+//   - short: The short of the link to manage
+//   - owner: The ID of the logged in user
+//
+// Returns:
+//   - error: The reason why the link can't be managed (if any)
+func (conf *Configuration) checkOwner(short string, owner uuid.UUID) error {
+	link, err := conf.Store.GetLinkByShort(short)
+	if errors.Is(err, sql.ErrNoRows) {
+		return newError(http.StatusNotFound, json.CodeNotFound)
+	} else if err != nil {
+		return newError(http.StatusInternalServerError, json.CodeGetLinkFailed)
+	}
+
+	if owner == uuid.Nil || link.Owner != owner {
+		return newError(http.StatusNotFound, json.CodeNotFound)
+	}
+
+	return nil
+}
+
+// UpdateLink changes the URL, the expiration date or the password of an existing link.
+//
+// Only the non-empty parameters are applied, the others keep their current value,
+// the password is removed if RemovePassword is set and no new password is given.
+// The URL is validated the same way as in [Configuration.CreateLink].
+//
+// Parameters:
+//   - short: The short of the link to update
+//   - token: The clear management token given by the client
+//   - params: Contains the URL, expiry and password to apply
+//
+// Returns:
+//   - Link: The updated link structure (empty if error occurred)
+//   - error: The reason why the link can't be updated (if any), see [Error]
+func (conf *Configuration) UpdateLink(short, token string, params utils.Parameters) (Link, error) {
+	// Check if the client is allowed to manage the link
+	if err := conf.checkToken(short, token); err != nil {
+		return Link{}, err
+	}
+
+	return conf.UpdateAnyLink(short, params)
+}
+
+// UpdateOwnedLink changes the URL, the expiration date or the password of a link created by a user,
+// see [Configuration.UpdateLink].
+//
+// Parameters:
+//   - short: The short of the link to update
+//   - owner: The ID of the logged in user
+//   - params: Contains the URL, expiry and password to apply
+//
+// Returns:
+//   - Link: The updated link structure (empty if error occurred)
+//   - error: The reason why the link can't be updated (if any), see [Error]
+func (conf *Configuration) UpdateOwnedLink(short string, owner uuid.UUID, params utils.Parameters) (Link, error) {
+	// Check if the user created the link
+	if err := conf.checkOwner(short, owner); err != nil {
+		return Link{}, err
+	}
+
+	return conf.UpdateAnyLink(short, params)
+}
+
+// UpdateAnyLink changes the URL, the expiration date or the password of an existing link
+// without checking its management token, see [Configuration.UpdateLink].
+//
+// It is meant for the clients allowed to manage every link, such as the API keys having the manage scope.
+//
+// Parameters:
+//   - short: The short of the link to update
+//   - params: Contains the URL, expiry and password to apply
+//
+// Returns:
+//   - Link: The updated link structure (empty if error occurred)
+//   - error: The reason why the link can't be updated (if any), see [Error]
+func (conf *Configuration) UpdateAnyLink(short string, params utils.Parameters) (Link, error) { //nolint:cyclop
+	// Get the current values, expired links can't be updated anymore
+	current, err := conf.Store.GetLinkByShort(short)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Link{}, newError(http.StatusNotFound, json.CodeNotFound)
+	} else if err != nil {
+		return Link{}, newError(http.StatusInternalServerError, json.CodeGetLinkFailed)
+	}
+
+	url, expireAt, hash := current.URL, current.ExpireAt, current.Password
+
+	// Check and apply the new URL
+	if params.URL != "" {
+		if !urlPattern.MatchString(params.URL) {
+			return Link{}, newError(http.StatusBadRequest, json.CodeInvalidURL)
+		}
+
+		if conf.isRedirectionLoop(params.URL, short) {
+			return Link{}, newError(http.StatusBadRequest, json.CodeRedirectionLoop)
+		}
+
+		url = params.URL
+	}
+
+	// Apply the new expiration date
+	if params.ExpireAfter != "" || params.ExpireDate != "" {
+		expireAt, err = conf.expiryDate(params)
+		if err != nil {
+			return Link{}, err
+		}
+	}
+
+	// Hash and apply the new password, or remove the current one
+	switch {
+	case params.Password != "":
+		hash, err = argon2id.CreateHash(params.Password, argon2id.DefaultParams)
+		if err != nil {
+			return Link{}, newError(http.StatusInternalServerError, json.CodePasswordHashFailed)
+		}
+	case params.RemovePassword:
+		hash = ""
+	}
+
+	// Update the link in the database
+	err = conf.Store.UpdateLink(short, url, expireAt, hash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Link{}, newError(http.StatusNotFound, json.CodeNotFound)
+	} else if err != nil {
+		return Link{}, newError(http.StatusInternalServerError, json.CodeUpdateLinkFailed)
+	}
+
 	link := Link{
 		ExpireAt: expireAt,
-		URL:      params.URL,
-		Short:    params.Path,
+		URL:      url,
+		Short:    short,
+	}
+
+	return link, nil
+}
+
+// DeleteLink deletes an existing link before its expiration.
+//
+// Parameters:
+//   - short: The short of the link to delete
+//   - token: The clear management token given by the client
+//
+// Returns:
+//   - error: The reason why the link can't be deleted (if any), see [Error]
+func (conf *Configuration) DeleteLink(short, token string) error {
+	// Check if the client is allowed to manage the link
+	if err := conf.checkToken(short, token); err != nil {
+		return err
+	}
+
+	return conf.DeleteAnyLink(short)
+}
+
+// DeleteOwnedLink deletes a link created by a user before its expiration, see [Configuration.DeleteLink].
+//
+// Parameters:
+//   - short: The short of the link to delete
+//   - owner: The ID of the logged in user
+//
+// Returns:
+//   - error: The reason why the link can't be deleted (if any), see [Error]
+func (conf *Configuration) DeleteOwnedLink(short string, owner uuid.UUID) error {
+	// Check if the user created the link
+	if err := conf.checkOwner(short, owner); err != nil {
+		return err
+	}
+
+	return conf.DeleteAnyLink(short)
+}
+
+// DeleteAnyLink deletes an existing link before its expiration without checking its management token,
+// see [Configuration.DeleteLink].
+//
+// It is meant for the clients allowed to manage every link, such as the API keys having the manage scope.
+//
+// Parameters:
+//   - short: The short of the link to delete
+//
+// Returns:
+//   - error: The reason why the link can't be deleted (if any), see [Error]
+func (conf *Configuration) DeleteAnyLink(short string) error {
+	// Delete the link from the database
+	err := conf.Store.DeleteLink(short)
+	if errors.Is(err, sql.ErrNoRows) {
+		return newError(http.StatusNotFound, json.CodeNotFound)
+	} else if err != nil {
+		return newError(http.StatusInternalServerError, json.CodeDeleteLinkFailed)
 	}
 
-	return link, http.StatusCreated, addInfo, ""
+	return nil
 }
@@ -0,0 +1,179 @@
+//    reddlinks, a simple link shortener written in Go.
+//    Copyright (C) 2025 redd
+//
+//    This program is free software: you can redistribute it and/or modify
+//    it under the terms of the GNU General Public License as published by
+//    the Free Software Foundation, either version 3 of the License, or
+//    (at your option) any later version.
+//
+//    This program is distributed in the hope that it will be useful,
+//    but WITHOUT ANY WARRANTY; without even the implied warranty of
+//    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//    GNU General Public License for more details.
+//
+//    You should have received a copy of the GNU General Public License
+//    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+// Package logging configures the structured logger used by the application.
+//
+// Log lines are written using [log/slog] either as text or as JSON. Each HTTP request is given an ID
+// which is kept in its context, every line logged with that context carries the ID under the "request_id" key,
+// so that a failed request reported by a user can be found in the logs.
+package logging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// RequestIDHeader is the header giving the ID of a request, it is set on every response.
+const RequestIDHeader = "X-Request-ID"
+
+// RequestIDKey is the key of the request ID in the log lines.
+const RequestIDKey = "request_id"
+
+// Log formats supported by [NewLogger].
+const (
+	// FormatText writes log lines as key=value pairs
+	FormatText = "text"
+	// FormatJSON writes log lines as JSON objects
+	FormatJSON = "json"
+)
+
+var (
+	// ErrUnknownFormat is returned when a log format is not one of the supported formats.
+	ErrUnknownFormat = errors.New("unknown log format")
+
+	// ErrUnknownLevel is returned when a log level is not one of the supported levels.
+	ErrUnknownLevel = errors.New("unknown log level")
+)
+
+// requestIDPattern matches the request IDs given by clients that can be kept as is.
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// contextKey is the type of the keys of the values stored in a context by this package.
+type contextKey struct{}
+
+// ParseLevel returns the level corresponding to its name.
+//
+// Parameters:
+//   - level: The name of the level ("debug", "info", "warn" or "error"), case-insensitive, "info" if empty
+//
+// Returns:
+//   - slog.Level: The level
+//   - error: [ErrUnknownLevel] if the level isn't supported
+func ParseLevel(level string) (slog.Level, error) {
+	switch strings.ToLower(level) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "info", "":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	default:
+		return slog.LevelInfo, fmt.Errorf("%w: %q", ErrUnknownLevel, level)
+	}
+}
+
+// NewLogger returns a logger writing lines of a given format and level.
+//
+// Parameters:
+//   - writer: Where the log lines are written
+//   - format: The format of the lines, [FormatText] or [FormatJSON], text if empty
+//   - level: The minimum level of the written lines, see [ParseLevel]
+//
+// Returns:
+//   - *slog.Logger: The logger, adding the request ID of the context to the lines
+//   - error: [ErrUnknownFormat] or [ErrUnknownLevel] if the format or the level isn't supported
+func NewLogger(writer io.Writer, format, level string) (*slog.Logger, error) {
+	parsedLevel, err := ParseLevel(level)
+	if err != nil {
+		return nil, err
+	}
+
+	options := &slog.HandlerOptions{Level: parsedLevel}
+
+	var handler slog.Handler
+
+	switch strings.ToLower(format) {
+	case FormatText, "":
+		handler = slog.NewTextHandler(writer, options)
+	case FormatJSON:
+		handler = slog.NewJSONHandler(writer, options)
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownFormat, format)
+	}
+
+	return slog.New(contextHandler{Handler: handler}), nil
+}
+
+// Setup makes a logger writing to the standard error the default one, see [NewLogger].
+//
+// The lines written through the [log] package go through this logger as well.
+func Setup(format, level string) error {
+	logger, err := NewLogger(os.Stderr, format, level)
+	if err != nil {
+		return err
+	}
+
+	slog.SetDefault(logger)
+
+	return nil
+}
+
+// NewRequestID returns a new random request ID.
+func NewRequestID() string {
+	return uuid.NewString()
+}
+
+// IsValidRequestID tells if a request ID given by a client can be kept as is,
+// it must be made of at most 128 letters, digits, dots, underscores, colons or dashes.
+func IsValidRequestID(requestID string) bool {
+	return requestIDPattern.MatchString(requestID)
+}
+
+// WithRequestID returns a copy of a context holding a request ID.
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, contextKey{}, requestID)
+}
+
+// RequestID returns the request ID held by a context, an empty string if there's none.
+func RequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(contextKey{}).(string)
+
+	return requestID
+}
+
+// contextHandler is a [slog.Handler] adding the request ID of the context to the records.
+type contextHandler struct {
+	slog.Handler
+}
+
+// Handle adds the request ID of the context, if any, to the record before handling it.
+func (handler contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if requestID := RequestID(ctx); requestID != "" {
+		record.AddAttrs(slog.String(RequestIDKey, requestID))
+	}
+
+	return handler.Handler.Handle(ctx, record)
+}
+
+// WithAttrs returns a handler adding the request ID along with the given attributes.
+func (handler contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{Handler: handler.Handler.WithAttrs(attrs)}
+}
+
+// WithGroup returns a handler adding the request ID along with the given group.
+func (handler contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{Handler: handler.Handler.WithGroup(name)}
+}
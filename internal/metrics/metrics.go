@@ -0,0 +1,456 @@
+//    reddlinks, a simple link shortener written in Go.
+//    Copyright (C) 2025 redd
+//
+//    This program is free software: you can redistribute it and/or modify
+//    it under the terms of the GNU General Public License as published by
+//    the Free Software Foundation, either version 3 of the License, or
+//    (at your option) any later version.
+//
+//    This program is distributed in the hope that it will be useful,
+//    but WITHOUT ANY WARRANTY; without even the implied warranty of
+//    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//    GNU General Public License for more details.
+//
+//    You should have received a copy of the GNU General Public License
+//    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+// Package metrics collects the metrics of the application and writes them in the Prometheus text format.
+//
+// The metrics are kept in memory and written by hand, following the text exposition format
+// (https://prometheus.io/docs/instrumenting/exposition_formats/), no client library is needed.
+// Every method recording a metric can be called on a nil [*Metrics], it then does nothing,
+// so that callers don't have to check whether the metrics are enabled.
+package metrics
+
+import (
+	"bufio"
+	"database/sql"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redds-be/reddlinks/internal/database"
+)
+
+// ContentType is the content type of the text exposition format written by [Metrics.Write].
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// namespace prefixes the name of every metric.
+const namespace = "reddlinks_"
+
+// durationBuckets are the upper bounds, in seconds, of the buckets of the duration histograms.
+var durationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10} //nolint:gochecknoglobals
+
+// otherMethod is the method label of the requests whose method isn't a standard one.
+const otherMethod = "OTHER"
+
+// Metrics holds the metrics of the application.
+//
+// DBStats returns the statistics of the database connection pool, nil if the store has no pool,
+// CacheStats returns the counters of the link cache, nil if the cache is disabled.
+// Both are called each time the metrics are written and must be set before the metrics are used.
+type Metrics struct {
+	DBStats    func() sql.DBStats
+	CacheStats func() database.CacheStats
+
+	mutex            sync.Mutex
+	requests         map[requestKey]uint64
+	latencies        map[routeKey]*histogram
+	redirects        uint64
+	created          map[creationKey]uint64
+	passwordFailures uint64
+	gcDuration       *histogram
+	gcDeleted        uint64
+	gcFailures       uint64
+}
+
+// routeKey identifies a route of the HTTP server.
+type routeKey struct {
+	Route  string
+	Method string
+}
+
+// requestKey identifies the requests of a route answered with a given status code.
+type requestKey struct {
+	routeKey
+
+	Code int
+}
+
+// creationKey identifies the created links by their kind.
+type creationKey struct {
+	Password bool
+	Custom   bool
+}
+
+// New returns empty metrics.
+func New() *Metrics {
+	return &Metrics{
+		requests:   make(map[requestKey]uint64),
+		latencies:  make(map[routeKey]*histogram),
+		created:    make(map[creationKey]uint64),
+		gcDuration: newHistogram(durationBuckets),
+	}
+}
+
+// ObserveRequest records a request handled by the HTTP server.
+//
+// Parameters:
+//   - route: The pattern of the route that handled the request, without its method (ex: "/{short}")
+//   - method: The method of the request, the methods that aren't standard are all recorded as "OTHER"
+//   - code: The status code of the response
+//   - duration: The time taken to handle the request
+func (metrics *Metrics) ObserveRequest(route, method string, code int, duration time.Duration) {
+	if metrics == nil {
+		return
+	}
+
+	metrics.mutex.Lock()
+	defer metrics.mutex.Unlock()
+
+	key := routeKey{Route: route, Method: methodLabel(method)}
+
+	metrics.requests[requestKey{routeKey: key, Code: code}]++
+
+	latency, exists := metrics.latencies[key]
+	if !exists {
+		latency = newHistogram(durationBuckets)
+		metrics.latencies[key] = latency
+	}
+
+	latency.observe(duration.Seconds())
+}
+
+// methodLabel returns the label of a request method, so that clients can't create a series for any method they make up.
+func methodLabel(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return method
+	default:
+		return otherMethod
+	}
+}
+
+// Redirect records a client redirected to the URL of a link.
+func (metrics *Metrics) Redirect() {
+	if metrics == nil {
+		return
+	}
+
+	metrics.mutex.Lock()
+	defer metrics.mutex.Unlock()
+
+	metrics.redirects++
+}
+
+// LinkCreated records a created link, telling if it is protected by a password and if its short is a custom one.
+func (metrics *Metrics) LinkCreated(password, custom bool) {
+	if metrics == nil {
+		return
+	}
+
+	metrics.mutex.Lock()
+	defer metrics.mutex.Unlock()
+
+	metrics.created[creationKey{Password: password, Custom: custom}]++
+}
+
+// PasswordFailure records a wrong password given to access a protected link.
+func (metrics *Metrics) PasswordFailure() {
+	if metrics == nil {
+		return
+	}
+
+	metrics.mutex.Lock()
+	defer metrics.mutex.Unlock()
+
+	metrics.passwordFailures++
+}
+
+// ObserveGC records a garbage collection sweep, its deleted links are only counted if it succeeded.
+//
+// Parameters:
+//   - duration: The time taken by the sweep
+//   - deleted: The number of deleted expired links
+//   - err: The error encountered by the sweep, if any
+func (metrics *Metrics) ObserveGC(duration time.Duration, deleted int64, err error) {
+	if metrics == nil {
+		return
+	}
+
+	metrics.mutex.Lock()
+	defer metrics.mutex.Unlock()
+
+	metrics.gcDuration.observe(duration.Seconds())
+
+	if err != nil {
+		metrics.gcFailures++
+
+		return
+	}
+
+	metrics.gcDeleted += uint64(deleted) //nolint:gosec // The number of deleted links is never negative
+}
+
+// Write writes every metric in the Prometheus text exposition format, see [ContentType].
+//
+// Parameters:
+//   - writer: Where the metrics are written
+//
+// Returns:
+//   - error: Any error encountered while writing the metrics
+func (metrics *Metrics) Write(writer io.Writer) error {
+	buffered := bufio.NewWriter(writer)
+	exposition := &expositionWriter{writer: buffered}
+
+	metrics.writeHTTP(exposition)
+	metrics.writeLinks(exposition)
+	metrics.writeGC(exposition)
+	metrics.writeDB(exposition)
+	metrics.writeCache(exposition)
+
+	if exposition.err != nil {
+		return exposition.err
+	}
+
+	return buffered.Flush()
+}
+
+// writeHTTP writes the metrics of the HTTP server.
+func (metrics *Metrics) writeHTTP(exposition *expositionWriter) {
+	metrics.mutex.Lock()
+	defer metrics.mutex.Unlock()
+
+	// Write the requests sorted by route, method and code so that the output is stable
+	requestKeys := make([]requestKey, 0, len(metrics.requests))
+	for key := range metrics.requests {
+		requestKeys = append(requestKeys, key)
+	}
+
+	sort.Slice(requestKeys, func(first, second int) bool {
+		if requestKeys[first].routeKey != requestKeys[second].routeKey {
+			return requestKeys[first].routeKey.less(requestKeys[second].routeKey)
+		}
+
+		return requestKeys[first].Code < requestKeys[second].Code
+	})
+
+	exposition.header("http_requests_total", "counter", "Number of HTTP requests by route, method and status code.")
+
+	for _, key := range requestKeys {
+		exposition.sample("http_requests_total",
+			labels("route", key.Route, "method", key.Method, "code", strconv.Itoa(key.Code)),
+			float64(metrics.requests[key]))
+	}
+
+	routeKeys := make([]routeKey, 0, len(metrics.latencies))
+	for key := range metrics.latencies {
+		routeKeys = append(routeKeys, key)
+	}
+
+	sort.Slice(routeKeys, func(first, second int) bool { return routeKeys[first].less(routeKeys[second]) })
+
+	exposition.header("http_request_duration_seconds", "histogram", "Time taken to handle HTTP requests by route and method.")
+
+	for _, key := range routeKeys {
+		exposition.histogram("http_request_duration_seconds",
+			labels("route", key.Route, "method", key.Method), metrics.latencies[key])
+	}
+}
+
+// writeLinks writes the metrics of the links.
+func (metrics *Metrics) writeLinks(exposition *expositionWriter) {
+	metrics.mutex.Lock()
+	defer metrics.mutex.Unlock()
+
+	exposition.header("redirects_total", "counter", "Number of clients redirected to the URL of a link.")
+	exposition.sample("redirects_total", "", float64(metrics.redirects))
+
+	exposition.header("links_created_total", "counter", "Number of created links by protection and kind of short.")
+
+	for _, password := range []bool{false, true} {
+		for _, custom := range []bool{false, true} {
+			exposition.sample("links_created_total",
+				labels("password", strconv.FormatBool(password), "custom", strconv.FormatBool(custom)),
+				float64(metrics.created[creationKey{Password: password, Custom: custom}]))
+		}
+	}
+
+	exposition.header("password_failures_total", "counter", "Number of wrong passwords given to access a protected link.")
+	exposition.sample("password_failures_total", "", float64(metrics.passwordFailures))
+}
+
+// writeGC writes the metrics of the garbage collection.
+func (metrics *Metrics) writeGC(exposition *expositionWriter) {
+	metrics.mutex.Lock()
+	defer metrics.mutex.Unlock()
+
+	exposition.header("gc_duration_seconds", "histogram", "Time taken by the garbage collection sweeps.")
+	exposition.histogram("gc_duration_seconds", "", metrics.gcDuration)
+
+	exposition.header("gc_deleted_links_total", "counter", "Number of expired links deleted by the garbage collection.")
+	exposition.sample("gc_deleted_links_total", "", float64(metrics.gcDeleted))
+
+	exposition.header("gc_failures_total", "counter", "Number of garbage collection sweeps that failed.")
+	exposition.sample("gc_failures_total", "", float64(metrics.gcFailures))
+}
+
+// writeDB writes the statistics of the database connection pool, if any.
+func (metrics *Metrics) writeDB(exposition *expositionWriter) {
+	if metrics.DBStats == nil {
+		return
+	}
+
+	stats := metrics.DBStats()
+
+	for _, metric := range []struct {
+		name, kind, help string
+		value            float64
+	}{
+		{"db_max_open_connections", "gauge", "Maximum number of open connections to the database.", float64(stats.MaxOpenConnections)},
+		{"db_open_connections", "gauge", "Number of established connections to the database.", float64(stats.OpenConnections)},
+		{"db_in_use_connections", "gauge", "Number of connections to the database currently in use.", float64(stats.InUse)},
+		{"db_idle_connections", "gauge", "Number of idle connections to the database.", float64(stats.Idle)},
+		{"db_wait_count_total", "counter", "Number of connections waited for.", float64(stats.WaitCount)},
+		{"db_wait_duration_seconds_total", "counter", "Time spent waiting for new connections.", stats.WaitDuration.Seconds()},
+		{"db_max_idle_closed_total", "counter", "Number of connections closed because of the maximum of idle connections.", float64(stats.MaxIdleClosed)},
+		{"db_max_idle_time_closed_total", "counter", "Number of connections closed because of the maximum idle time.", float64(stats.MaxIdleTimeClosed)},
+		{"db_max_lifetime_closed_total", "counter", "Number of connections closed because of the maximum lifetime.", float64(stats.MaxLifetimeClosed)},
+	} {
+		exposition.header(metric.name, metric.kind, metric.help)
+		exposition.sample(metric.name, "", metric.value)
+	}
+}
+
+// writeCache writes the counters of the link cache, if any.
+func (metrics *Metrics) writeCache(exposition *expositionWriter) {
+	if metrics.CacheStats == nil {
+		return
+	}
+
+	stats := metrics.CacheStats()
+
+	exposition.header("cache_hits_total", "counter", "Number of link lookups answered by the cache.")
+	exposition.sample("cache_hits_total", "", float64(stats.Hits))
+
+	exposition.header("cache_misses_total", "counter", "Number of link lookups that went to the store.")
+	exposition.sample("cache_misses_total", "", float64(stats.Misses))
+
+	exposition.header("cache_size", "gauge", "Number of cached links.")
+	exposition.sample("cache_size", "", float64(stats.Size))
+
+	exposition.header("cache_capacity", "gauge", "Maximum number of cached links.")
+	exposition.sample("cache_capacity", "", float64(stats.Capacity))
+}
+
+// less tells if a route is sorted before another one.
+func (key routeKey) less(other routeKey) bool {
+	if key.Route != other.Route {
+		return key.Route < other.Route
+	}
+
+	return key.Method < other.Method
+}
+
+// histogram counts observations in buckets of given upper bounds.
+type histogram struct {
+	bounds []float64
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// newHistogram returns an empty histogram using given sorted upper bounds.
+func newHistogram(bounds []float64) *histogram {
+	return &histogram{bounds: bounds, counts: make([]uint64, len(bounds))}
+}
+
+// observe counts a value in the first bucket whose upper bound is greater than or equal to it.
+func (hist *histogram) observe(value float64) {
+	hist.sum += value
+	hist.count++
+
+	index := sort.SearchFloat64s(hist.bounds, value)
+	if index < len(hist.counts) {
+		hist.counts[index]++
+	}
+}
+
+// expositionWriter writes metrics in the text exposition format, keeping the first error encountered.
+type expositionWriter struct {
+	writer io.Writer
+	err    error
+}
+
+// printf writes a formatted line unless an error was already encountered.
+func (exposition *expositionWriter) printf(format string, args ...any) {
+	if exposition.err != nil {
+		return
+	}
+
+	_, exposition.err = fmt.Fprintf(exposition.writer, format, args...)
+}
+
+// header writes the help and the type of a metric.
+func (exposition *expositionWriter) header(name, kind, help string) {
+	exposition.printf("# HELP %s%s %s\n# TYPE %s%s %s\n", namespace, name, help, namespace, name, kind)
+}
+
+// sample writes a value of a metric with given formatted labels.
+func (exposition *expositionWriter) sample(name, labels string, value float64) {
+	exposition.printf("%s%s%s %s\n", namespace, name, labels, strconv.FormatFloat(value, 'g', -1, 64))
+}
+
+// histogram writes the cumulative buckets, the sum and the count of a histogram with given formatted labels.
+func (exposition *expositionWriter) histogram(name, labelSet string, hist *histogram) {
+	// Add the upper bound label to the given ones
+	withBound := func(bound string) string {
+		if labelSet == "" {
+			return labels("le", bound)
+		}
+
+		return labelSet[:len(labelSet)-1] + "," + labels("le", bound)[1:]
+	}
+
+	var cumulative uint64
+
+	for index, bound := range hist.bounds {
+		cumulative += hist.counts[index]
+		exposition.sample(name+"_bucket", withBound(strconv.FormatFloat(bound, 'g', -1, 64)), float64(cumulative))
+	}
+
+	exposition.sample(name+"_bucket", withBound("+Inf"), float64(hist.count))
+	exposition.sample(name+"_sum", labelSet, hist.sum)
+	exposition.sample(name+"_count", labelSet, float64(hist.count))
+}
+
+// labelEscaper escapes the label values as required by the text exposition format.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`) //nolint:gochecknoglobals
+
+// labels formats pairs of label names and values, ex: {route="/",method="GET"}.
+func labels(pairs ...string) string {
+	var builder strings.Builder
+
+	builder.WriteByte('{')
+
+	for index := 0; index+1 < len(pairs); index += 2 {
+		if index != 0 {
+			builder.WriteByte(',')
+		}
+
+		builder.WriteString(pairs[index])
+		builder.WriteString(`="`)
+		builder.WriteString(labelEscaper.Replace(pairs[index+1]))
+		builder.WriteByte('"')
+	}
+
+	builder.WriteByte('}')
+
+	return builder.String()
+}
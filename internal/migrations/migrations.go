@@ -0,0 +1,353 @@
+//    reddlinks, a simple link shortener written in Go.
+//    Copyright (C) 2025 redd
+//
+//    This program is free software: you can redistribute it and/or modify
+//    it under the terms of the GNU General Public License as published by
+//    the Free Software Foundation, either version 3 of the License, or
+//    (at your option) any later version.
+//
+//    This program is distributed in the hope that it will be useful,
+//    but WITHOUT ANY WARRANTY; without even the implied warranty of
+//    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//    GNU General Public License for more details.
+//
+//    You should have received a copy of the GNU General Public License
+//    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+// Package migrations applies versioned schema migrations to the database.
+//
+// Migrations are SQL files embedded within the binary, there is one directory per dialect
+// (sql/postgres, sql/sqlite, sql/mysql) and each file is named "<version>_<name>.sql", e.g. "0001_create_links.sql".
+// The files are parsed as [text/template] templates using [Params], which allows some configuration
+// values to be used in the schema. Applied versions are recorded in the schema_migrations table.
+package migrations
+
+import (
+	"bytes"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// files holds the SQL migration files of every dialect.
+//
+//go:embed sql
+var files embed.FS
+
+// Define all the errors for the migrations package.
+//
+// ErrSchemaTooNew defines an error for databases migrated by a newer version of reddlinks,
+// ErrUnsupportedDialect defines an error for dialects without migrations,
+// ErrInvalidFileName defines an error for migration files that don't follow the naming scheme,
+// ErrDuplicateVersion defines an error for two migration files sharing the same version.
+var (
+	ErrSchemaTooNew       = errors.New("the database schema is newer than what this version of reddlinks supports")
+	ErrUnsupportedDialect = errors.New("no migrations for this database type")
+	ErrInvalidFileName    = errors.New("invalid migration file name")
+	ErrDuplicateVersion   = errors.New("duplicate migration version")
+)
+
+// placeholderRegexp matches the numbered placeholders ($1, $2, ...) of a query.
+var placeholderRegexp = regexp.MustCompile(`\$\d+`)
+
+// Params defines the values that can be used within the migration files.
+//
+// MaxShortLength refers to the maximum length of a short.
+type Params struct {
+	MaxShortLength int
+}
+
+// Migration defines a single up-migration.
+//
+// Version is the version the schema will be at once the migration is applied,
+// Name is the descriptive part of the file name,
+// SQL is the rendered SQL of the migration.
+type Migration struct {
+	Version int
+	Name    string
+	SQL     string
+}
+
+// Load returns the migrations of a given dialect, ordered by version.
+//
+// Parameters:
+//   - dialect: The type of database being used ("postgres", "sqlite" or "mysql")
+//   - params: The values used to render the migration files
+//
+// Returns:
+//   - []Migration: The ordered migrations
+//   - error: Any error encountered while reading, parsing or rendering the files
+func Load(dialect string, params Params) ([]Migration, error) {
+	entries, err := fs.ReadDir(files, path.Join("sql", dialect))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedDialect, dialect)
+	}
+
+	migrations := make([]Migration, 0, len(entries))
+	seen := make(map[int]bool, len(entries))
+
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".sql") {
+			continue
+		}
+
+		// Get the version and the name from the file name
+		versionStr, name, found := strings.Cut(strings.TrimSuffix(entry.Name(), ".sql"), "_")
+		if !found {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidFileName, entry.Name())
+		}
+
+		version, err := strconv.Atoi(versionStr)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidFileName, entry.Name())
+		}
+
+		if seen[version] {
+			return nil, fmt.Errorf("%w: %d", ErrDuplicateVersion, version)
+		}
+		seen[version] = true
+
+		// Render the migration
+		content, err := files.ReadFile(path.Join("sql", dialect, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		tmpl, err := template.New(entry.Name()).Parse(string(content))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse migration %s: %w", entry.Name(), err)
+		}
+
+		var rendered bytes.Buffer
+		if err := tmpl.Execute(&rendered, params); err != nil {
+			return nil, fmt.Errorf("failed to render migration %s: %w", entry.Name(), err)
+		}
+
+		migrations = append(migrations, Migration{Version: version, Name: name, SQL: rendered.String()})
+	}
+
+	// Make sure the migrations are applied in order
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return migrations, nil
+}
+
+// Rebind converts the numbered placeholders ($1, $2, ...) of a query to the ones used by a dialect.
+//
+// PostgreSQL and SQLite support numbered placeholders, MySQL only supports "?" ones,
+// which means the placeholders of the queries must be numbered in the order of their arguments.
+func Rebind(dialect, query string) string {
+	if dialect != "mysql" {
+		return query
+	}
+
+	return placeholderRegexp.ReplaceAllString(query, "?")
+}
+
+// Latest returns the latest version known for a given dialect, 0 if there is none.
+func Latest(dialect string) (int, error) {
+	migrations, err := Load(dialect, Params{})
+	if err != nil {
+		return 0, err
+	}
+
+	if len(migrations) == 0 {
+		return 0, nil
+	}
+
+	return migrations[len(migrations)-1].Version, nil
+}
+
+// CurrentVersion returns the version of the database schema, 0 if no migration has been applied.
+//
+// Parameters:
+//   - dbase: A pointer to the SQL database connection
+//
+// Returns:
+//   - int: The highest applied version
+//   - error: Any error encountered during the query, including a missing schema_migrations table
+func CurrentVersion(dbase *sql.DB) (int, error) {
+	var version int
+
+	err := dbase.QueryRow("SELECT COALESCE(MAX(version), 0) FROM schema_migrations;").Scan(&version)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get schema version: %w", err)
+	}
+
+	return version, nil
+}
+
+// Up applies every pending migration of a given dialect.
+//
+// It starts by creating the schema_migrations table if it doesn't exist, databases that were
+// created before the migrations existed get their version detected using [baseline].
+// Startup is refused with [ErrSchemaTooNew] if the database has been migrated by a newer version.
+// Each migration is applied and recorded within its own transaction.
+//
+// Parameters:
+//   - dbase: A pointer to the SQL database connection
+//   - dialect: The type of database being used ("postgres", "sqlite" or "mysql")
+//   - params: The values used to render the migration files
+//
+// Returns:
+//   - int: The number of applied migrations
+//   - error: Any error encountered during the process
+func Up(dbase *sql.DB, dialect string, params Params) (int, error) { //nolint:cyclop
+	migrations, err := Load(dialect, params)
+	if err != nil {
+		return 0, err
+	}
+
+	// Create the table used to keep track of the applied migrations
+	const sqlCreateMigrationsTable = `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at TIMESTAMP NOT NULL
+		);`
+
+	if _, err := dbase.Exec(sqlCreateMigrationsTable); err != nil {
+		return 0, fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+
+	current, err := CurrentVersion(dbase)
+	if err != nil {
+		return 0, err
+	}
+
+	// Detect the version of databases created before the migrations
+	if current == 0 {
+		current, err = baseline(dbase, dialect, migrations)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	// Refuse to touch a schema that this version doesn't know about
+	if len(migrations) != 0 && current > migrations[len(migrations)-1].Version {
+		return 0, fmt.Errorf("%w (database: %d, supported: %d)",
+			ErrSchemaTooNew, current, migrations[len(migrations)-1].Version)
+	}
+
+	applied := 0
+
+	for _, migration := range migrations {
+		if migration.Version <= current {
+			continue
+		}
+
+		if err := apply(dbase, dialect, migration); err != nil {
+			return applied, err
+		}
+
+		applied++
+	}
+
+	return applied, nil
+}
+
+// apply runs a migration and records it within a single transaction.
+//
+// MySQL commits schema changes implicitly, a failed migration may therefore be partially applied with it.
+func apply(dbase *sql.DB, dialect string, migration Migration) error {
+	trans, err := dbase.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	if _, err := trans.Exec(migration.SQL); err != nil {
+		_ = trans.Rollback()
+
+		return fmt.Errorf("failed to apply migration %04d_%s: %w", migration.Version, migration.Name, err)
+	}
+
+	const sqlRecordMigration = `INSERT INTO schema_migrations (version, name, applied_at) VALUES ($1, $2, $3);`
+
+	if _, err := trans.Exec(Rebind(dialect, sqlRecordMigration), migration.Version, migration.Name, time.Now().UTC()); err != nil {
+		_ = trans.Rollback()
+
+		return fmt.Errorf("failed to record migration %04d_%s: %w", migration.Version, migration.Name, err)
+	}
+
+	if err := trans.Commit(); err != nil {
+		return fmt.Errorf("failed to commit migration %04d_%s: %w", migration.Version, migration.Name, err)
+	}
+
+	return nil
+}
+
+// baseline detects and records the version of a database created before the migrations existed.
+//
+// Such databases have a links table but an empty schema_migrations table. Their links table
+// either matches version 1, or version 2 if it already has the token column.
+// Nothing is recorded for empty databases.
+//
+// Parameters:
+//   - dbase: A pointer to the SQL database connection
+//   - dialect: The type of database being used ("postgres", "sqlite" or "mysql")
+//   - migrations: The known migrations, used to record their names
+//
+// Returns:
+//   - int: The detected version
+//   - error: Any error encountered during the detection
+func baseline(dbase *sql.DB, dialect string, migrations []Migration) (int, error) {
+	var sqlCountTable, sqlCountColumn string
+
+	switch dialect {
+	case "postgres":
+		sqlCountTable = `SELECT COUNT(*) FROM information_schema.tables
+			WHERE table_schema = current_schema() AND table_name = 'links';`
+		sqlCountColumn = `SELECT COUNT(*) FROM information_schema.columns
+			WHERE table_schema = current_schema() AND table_name = 'links' AND column_name = 'token';`
+	case "sqlite":
+		sqlCountTable = `SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'links';`
+		sqlCountColumn = `SELECT COUNT(*) FROM pragma_table_info('links') WHERE name = 'token';`
+	default:
+		// The other dialects were added along with the migrations, there is nothing to detect
+		return 0, nil
+	}
+
+	var count int
+	if err := dbase.QueryRow(sqlCountTable).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to look for the links table: %w", err)
+	}
+
+	// Nothing to detect, it's a new database
+	if count == 0 {
+		return 0, nil
+	}
+
+	version := 1
+
+	if err := dbase.QueryRow(sqlCountColumn).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to look for the token column: %w", err)
+	}
+
+	if count != 0 {
+		version = 2
+	}
+
+	// Record the detected versions as applied
+	for _, migration := range migrations {
+		if migration.Version > version {
+			break
+		}
+
+		const sqlRecordMigration = `INSERT INTO schema_migrations (version, name, applied_at) VALUES ($1, $2, $3);`
+
+		if _, err := dbase.Exec(Rebind(dialect, sqlRecordMigration), migration.Version, migration.Name, time.Now().UTC()); err != nil {
+			return 0, fmt.Errorf("failed to record migration %04d_%s: %w", migration.Version, migration.Name, err)
+		}
+	}
+
+	return version, nil
+}
@@ -0,0 +1,21 @@
+--    reddlinks, a simple link shortener written in Go.
+--    Copyright (C) 2025 redd
+--
+--    This program is free software: you can redistribute it and/or modify
+--    it under the terms of the GNU General Public License as published by
+--    the Free Software Foundation, either version 3 of the License, or
+--    (at your option) any later version.
+--
+--    This program is distributed in the hope that it will be useful,
+--    but WITHOUT ANY WARRANTY; without even the implied warranty of
+--    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+--    GNU General Public License for more details.
+--
+--    You should have received a copy of the GNU General Public License
+--    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+CREATE TABLE IF NOT EXISTS cert_cache (
+    name VARCHAR(255) PRIMARY KEY,
+    data MEDIUMBLOB NOT NULL,
+    updated_at DATETIME(6) NOT NULL
+);
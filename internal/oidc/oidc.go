@@ -0,0 +1,612 @@
+//    reddlinks, a simple link shortener written in Go.
+//    Copyright (C) 2025 redd
+//
+//    This program is free software: you can redistribute it and/or modify
+//    it under the terms of the GNU General Public License as published by
+//    the Free Software Foundation, either version 3 of the License, or
+//    (at your option) any later version.
+//
+//    This program is distributed in the hope that it will be useful,
+//    but WITHOUT ANY WARRANTY; without even the implied warranty of
+//    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//    GNU General Public License for more details.
+//
+//    You should have received a copy of the GNU General Public License
+//    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+// Package oidc logs the users in using an OpenID Connect provider, such as the single sign-on of a company.
+//
+// The endpoints of the provider are found using the discovery document of its issuer. The users are sent
+// to the provider using the authorization code flow protected by PKCE, the code given back is then exchanged
+// for an ID token. The signature of the ID token is checked using the keys published by the provider,
+// along with its issuer, audience, expiration and nonce. Only the RS256 and ES256 algorithms are accepted.
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Settings of the requests sent to the provider.
+const (
+	discoveryPath   = "/.well-known/openid-configuration"
+	clientTimeout   = 10 * time.Second
+	maxResponseSize = 1 << 20
+	randomLength    = 32
+	clockSkew       = time.Minute
+	keysMinInterval = time.Minute
+)
+
+// Define all the errors for the oidc package.
+//
+// ErrDiscovery defines an error for a discovery document that can't be fetched or that isn't valid,
+// ErrExchange defines an error for a code that the provider refused to exchange for an ID token,
+// ErrInvalidToken defines an error for an ID token that is malformed or whose claims aren't valid,
+// ErrInvalidSignature defines an error for an ID token whose signature doesn't match,
+// ErrUnsupportedAlgorithm defines an error for an ID token signed with an algorithm other than RS256 and ES256,
+// ErrUnknownKey defines an error for an ID token signed with a key that the provider doesn't publish,
+// ErrInvalidFlow defines an error for a flow cookie that can't be decoded.
+var (
+	ErrDiscovery            = errors.New("invalid discovery document")
+	ErrExchange             = errors.New("the code couldn't be exchanged for an ID token")
+	ErrInvalidToken         = errors.New("invalid ID token")
+	ErrInvalidSignature     = errors.New("invalid ID token signature")
+	ErrUnsupportedAlgorithm = errors.New("unsupported ID token signing algorithm")
+	ErrUnknownKey           = errors.New("unknown ID token signing key")
+	ErrInvalidFlow          = errors.New("invalid login flow")
+)
+
+// Settings defines the provider and how its claims are mapped.
+//
+// Issuer is the URL of the provider, its discovery document is found under it,
+// ClientID and ClientSecret identify reddlinks with the provider, the secret is optional for public clients,
+// RedirectURL is where the provider sends the users back, it must be registered with the provider,
+// Scopes are the scopes requested, openid is required,
+// UsernameClaim is the claim giving the preferred username of the users,
+// RolesClaim is the claim giving the roles of the users, nested claims are separated by dots (realm_access.roles).
+type Settings struct {
+	Issuer        string
+	ClientID      string
+	ClientSecret  string
+	RedirectURL   string
+	Scopes        []string
+	UsernameClaim string
+	RolesClaim    string
+}
+
+// Claims holds what is known about a user logged in by the provider.
+type Claims struct {
+	// Subject is the identifier of the user at the provider, it never changes
+	Subject string
+
+	// Username is the preferred username of the user, it may be empty or already taken
+	Username string
+
+	// Roles are the roles given to the user by the provider
+	Roles []string
+}
+
+// Flow holds what must be kept by the client between the redirection to the provider and its return.
+type Flow struct {
+	// State is given back by the provider, it binds the callback to the client that started the flow
+	State string `json:"state"`
+
+	// Nonce is copied into the ID token, it binds the ID token to the flow
+	Nonce string `json:"nonce"`
+
+	// Verifier is the PKCE code verifier, only its hash is sent along with the authorization request
+	Verifier string `json:"verifier"`
+
+	// Next is the path the user is sent to once logged in
+	Next string `json:"next"`
+}
+
+// discovery is the part of the discovery document of the provider used by reddlinks.
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Provider logs the users in using an OpenID Connect provider.
+type Provider struct {
+	settings  Settings
+	endpoints discovery
+	client    *http.Client
+
+	// keys are the signing keys of the provider by key ID, they are fetched again when an unknown key is used
+	mutex     sync.Mutex
+	keys      map[string]crypto.PublicKey
+	fetchedAt time.Time
+}
+
+// New fetches the discovery document of a provider and returns the provider.
+//
+// Parameters:
+//   - ctx: The context of the discovery request
+//   - settings: The provider and how its claims are mapped
+//
+// Returns:
+//   - *Provider: The provider
+//   - error: [ErrDiscovery] if the document can't be fetched, isn't valid or doesn't match the issuer
+func New(ctx context.Context, settings Settings) (*Provider, error) {
+	provider := &Provider{settings: settings, client: &http.Client{Timeout: clientTimeout}}
+
+	if err := provider.getJSON(ctx, strings.TrimSuffix(settings.Issuer, "/")+discoveryPath, &provider.endpoints); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrDiscovery, err)
+	}
+
+	// The issuer must be the one that was configured, the ID tokens are checked against it
+	if provider.endpoints.Issuer != settings.Issuer {
+		return nil, fmt.Errorf("%w: the issuer %q doesn't match %q", ErrDiscovery, provider.endpoints.Issuer, settings.Issuer)
+	}
+
+	if provider.endpoints.AuthorizationEndpoint == "" || provider.endpoints.TokenEndpoint == "" || provider.endpoints.JWKSURI == "" {
+		return nil, fmt.Errorf("%w: missing endpoints", ErrDiscovery)
+	}
+
+	return provider, nil
+}
+
+// NewFlow starts a login flow sending the user to a given path once logged in.
+func NewFlow(next string) (Flow, error) {
+	flow := Flow{Next: next}
+
+	for _, value := range []*string{&flow.State, &flow.Nonce, &flow.Verifier} {
+		random := make([]byte, randomLength)
+		if _, err := rand.Read(random); err != nil {
+			return Flow{}, fmt.Errorf("could not start the login flow: %w", err)
+		}
+
+		*value = base64.RawURLEncoding.EncodeToString(random)
+	}
+
+	return flow, nil
+}
+
+// Encode returns the flow encoded to be kept in a cookie.
+func (flow Flow) Encode() string {
+	data, _ := json.Marshal(flow) //nolint:errchkjson // A flow only holds strings
+
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeFlow decodes a flow encoded by [Flow.Encode], [ErrInvalidFlow] is returned if it can't be decoded.
+func DecodeFlow(value string) (Flow, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return Flow{}, ErrInvalidFlow
+	}
+
+	var flow Flow
+	if err := json.Unmarshal(data, &flow); err != nil || flow.State == "" || flow.Nonce == "" || flow.Verifier == "" {
+		return Flow{}, ErrInvalidFlow
+	}
+
+	return flow, nil
+}
+
+// CheckState tells if the state given back by the provider matches the one of the flow, in constant time.
+func (flow Flow) CheckState(state string) bool {
+	return state != "" && subtle.ConstantTimeCompare([]byte(state), []byte(flow.State)) == 1
+}
+
+// AuthCodeURL returns the URL of the provider the user is sent to in order to log in.
+func (provider *Provider) AuthCodeURL(flow Flow) string {
+	challenge := sha256.Sum256([]byte(flow.Verifier))
+
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {provider.settings.ClientID},
+		"redirect_uri":          {provider.settings.RedirectURL},
+		"scope":                 {strings.Join(provider.settings.Scopes, " ")},
+		"state":                 {flow.State},
+		"nonce":                 {flow.Nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+
+	// Keep the parameters the authorization endpoint may already have
+	separator := "?"
+	if strings.Contains(provider.endpoints.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+
+	return provider.endpoints.AuthorizationEndpoint + separator + query.Encode()
+}
+
+// Exchange exchanges the code given back by the provider for an ID token, and returns its claims once verified.
+//
+// Parameters:
+//   - ctx: The context of the token request
+//   - code: The authorization code given back by the provider
+//   - flow: The flow started by the user, see [NewFlow]
+//
+// Returns:
+//   - Claims: The claims of the verified ID token
+//   - error: [ErrExchange] if the provider refused the code, or the errors of [Provider.Verify]
+func (provider *Provider) Exchange(ctx context.Context, code string, flow Flow) (Claims, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {provider.settings.RedirectURL},
+		"code_verifier": {flow.Verifier},
+		"client_id":     {provider.settings.ClientID},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, provider.endpoints.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return Claims{}, fmt.Errorf("%w: %w", ErrExchange, err)
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	// Confidential clients authenticate using HTTP basic authentication, see RFC 6749 section 2.3.1
+	if provider.settings.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(provider.settings.ClientID), url.QueryEscape(provider.settings.ClientSecret))
+	}
+
+	resp, err := provider.client.Do(req)
+	if err != nil {
+		return Claims{}, fmt.Errorf("%w: %w", ErrExchange, err)
+	}
+	defer resp.Body.Close()
+
+	var token struct {
+		IDToken     string `json:"id_token"`
+		Error       string `json:"error"`
+		Description string `json:"error_description"`
+	}
+
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(&token); err != nil {
+		return Claims{}, fmt.Errorf("%w: status %d: %w", ErrExchange, resp.StatusCode, err)
+	}
+
+	if resp.StatusCode != http.StatusOK || token.IDToken == "" {
+		return Claims{}, fmt.Errorf("%w: status %d: %s %s", ErrExchange, resp.StatusCode, token.Error, token.Description)
+	}
+
+	return provider.Verify(ctx, token.IDToken, flow.Nonce)
+}
+
+// Verify verifies an ID token and returns its claims.
+//
+// The signature must have been made by a key published by the provider using RS256 or ES256,
+// the issuer must be the one of the provider, the audience must include the client ID,
+// the token must not have expired and its nonce must match, a minute of clock skew is allowed.
+//
+// Parameters:
+//   - ctx: The context of the request fetching the keys if needed
+//   - idToken: The ID token in its compact serialization
+//   - nonce: The nonce of the flow, see [Flow]
+//
+// Returns:
+//   - Claims: The claims of the ID token
+//   - error: [ErrInvalidToken], [ErrInvalidSignature], [ErrUnsupportedAlgorithm] or [ErrUnknownKey] if it isn't valid
+func (provider *Provider) Verify(ctx context.Context, idToken, nonce string) (Claims, error) {
+	parts := strings.Split(idToken, ".")
+	if len(parts) != 3 { //nolint:mnd // Header, payload and signature
+		return Claims{}, fmt.Errorf("%w: not a signed JWT", ErrInvalidToken)
+	}
+
+	var header struct {
+		Algorithm string `json:"alg"`
+		KeyID     string `json:"kid"`
+	}
+
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return Claims{}, err
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return Claims{}, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+
+	// Check the signature before looking at the claims
+	key, err := provider.key(ctx, header.KeyID)
+	if err != nil {
+		return Claims{}, err
+	}
+
+	if err := verifySignature(header.Algorithm, key, parts[0]+"."+parts[1], signature); err != nil {
+		return Claims{}, err
+	}
+
+	var payload map[string]any
+	if err := decodeSegment(parts[1], &payload); err != nil {
+		return Claims{}, err
+	}
+
+	if err := provider.checkClaims(payload, nonce); err != nil {
+		return Claims{}, err
+	}
+
+	claims := Claims{Subject: payload["sub"].(string)} //nolint:forcetypeassert // Checked by checkClaims
+	claims.Username, _ = lookup(payload, provider.settings.UsernameClaim).(string)
+	claims.Roles = stringList(lookup(payload, provider.settings.RolesClaim))
+
+	return claims, nil
+}
+
+// checkClaims checks the registered claims of an ID token.
+func (provider *Provider) checkClaims(payload map[string]any, nonce string) error {
+	if issuer, _ := payload["iss"].(string); issuer != provider.endpoints.Issuer {
+		return fmt.Errorf("%w: unexpected issuer %q", ErrInvalidToken, issuer)
+	}
+
+	if subject, _ := payload["sub"].(string); subject == "" {
+		return fmt.Errorf("%w: missing subject", ErrInvalidToken)
+	}
+
+	// The audience is either a string or an array of strings
+	audiences := stringList(payload["aud"])
+	found := false
+
+	for _, audience := range audiences {
+		found = found || audience == provider.settings.ClientID
+	}
+
+	if !found {
+		return fmt.Errorf("%w: the audience doesn't include the client", ErrInvalidToken)
+	}
+
+	if party, ok := payload["azp"].(string); ok && len(audiences) > 1 && party != provider.settings.ClientID {
+		return fmt.Errorf("%w: issued to another party", ErrInvalidToken)
+	}
+
+	now := time.Now()
+
+	expiration, ok := payload["exp"].(float64)
+	if !ok || now.After(time.Unix(int64(expiration), 0).Add(clockSkew)) {
+		return fmt.Errorf("%w: expired", ErrInvalidToken)
+	}
+
+	if issuedAt, ok := payload["iat"].(float64); ok && now.Before(time.Unix(int64(issuedAt), 0).Add(-clockSkew)) {
+		return fmt.Errorf("%w: issued in the future", ErrInvalidToken)
+	}
+
+	if tokenNonce, _ := payload["nonce"].(string); subtle.ConstantTimeCompare([]byte(tokenNonce), []byte(nonce)) != 1 {
+		return fmt.Errorf("%w: the nonce doesn't match", ErrInvalidToken)
+	}
+
+	return nil
+}
+
+// key returns the signing key of the provider having a given ID, the keys are fetched again if it is unknown.
+// A token without key ID is accepted if the provider publishes a single key.
+func (provider *Provider) key(ctx context.Context, keyID string) (crypto.PublicKey, error) {
+	provider.mutex.Lock()
+	defer provider.mutex.Unlock()
+
+	if key := findKey(provider.keys, keyID); key != nil {
+		return key, nil
+	}
+
+	// Don't let unknown key IDs make reddlinks hammer the provider
+	if !provider.fetchedAt.IsZero() && time.Since(provider.fetchedAt) < keysMinInterval {
+		return nil, ErrUnknownKey
+	}
+
+	keys, err := provider.fetchKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	provider.keys = keys
+	provider.fetchedAt = time.Now()
+
+	if key := findKey(keys, keyID); key != nil {
+		return key, nil
+	}
+
+	return nil, ErrUnknownKey
+}
+
+// findKey returns the key having a given ID, or the only key if the ID is empty, nil if there is none.
+func findKey(keys map[string]crypto.PublicKey, keyID string) crypto.PublicKey {
+	if keyID == "" && len(keys) == 1 {
+		for _, key := range keys {
+			return key
+		}
+	}
+
+	return keys[keyID]
+}
+
+// jsonWebKey is the part of a JSON web key used to verify signatures, see RFC 7517.
+type jsonWebKey struct {
+	KeyType string `json:"kty"`
+	KeyID   string `json:"kid"`
+	Use     string `json:"use"`
+	N       string `json:"n"`
+	E       string `json:"e"`
+	Curve   string `json:"crv"`
+	X       string `json:"x"`
+	Y       string `json:"y"`
+}
+
+// fetchKeys fetches the RSA and P-256 signing keys published by the provider, the other keys are ignored.
+func (provider *Provider) fetchKeys(ctx context.Context) (map[string]crypto.PublicKey, error) {
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+
+	if err := provider.getJSON(ctx, provider.endpoints.JWKSURI, &set); err != nil {
+		return nil, fmt.Errorf("could not fetch the signing keys: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+
+		if key := jwk.publicKey(); key != nil {
+			keys[jwk.KeyID] = key
+		}
+	}
+
+	return keys, nil
+}
+
+// publicKey returns the public key of a JSON web key, nil if it isn't a valid RSA or P-256 key.
+func (jwk jsonWebKey) publicKey() crypto.PublicKey {
+	switch jwk.KeyType {
+	case "RSA":
+		modulus, errN := base64.RawURLEncoding.DecodeString(jwk.N)
+		exponent, errE := base64.RawURLEncoding.DecodeString(jwk.E)
+
+		if errN != nil || errE != nil || len(exponent) == 0 || len(exponent) > 4 {
+			return nil
+		}
+
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(modulus), E: int(new(big.Int).SetBytes(exponent).Int64())}
+	case "EC":
+		x, errX := base64.RawURLEncoding.DecodeString(jwk.X)
+		y, errY := base64.RawURLEncoding.DecodeString(jwk.Y)
+
+		if jwk.Curve != "P-256" || errX != nil || errY != nil {
+			return nil
+		}
+
+		key := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !key.Curve.IsOnCurve(key.X, key.Y) { //nolint:staticcheck // The key is only used with ecdsa.Verify
+			return nil
+		}
+
+		return key
+	default:
+		return nil
+	}
+}
+
+// verifySignature verifies the signature of a JWT using RS256 or ES256.
+func verifySignature(algorithm string, key crypto.PublicKey, signed string, signature []byte) error {
+	digest := sha256.Sum256([]byte(signed))
+
+	switch algorithm {
+	case "RS256":
+		rsaKey, ok := key.(*rsa.PublicKey)
+		if !ok || rsa.VerifyPKCS1v15(rsaKey, crypto.SHA256, digest[:], signature) != nil {
+			return ErrInvalidSignature
+		}
+	case "ES256":
+		// The signature is the concatenation of R and S, see RFC 7518 section 3.4
+		ecKey, ok := key.(*ecdsa.PublicKey)
+		if !ok || len(signature) != 64 {
+			return ErrInvalidSignature
+		}
+
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+
+		if !ecdsa.Verify(ecKey, digest[:], r, s) {
+			return ErrInvalidSignature
+		}
+	default:
+		return fmt.Errorf("%w: %q", ErrUnsupportedAlgorithm, algorithm)
+	}
+
+	return nil
+}
+
+// decodeSegment decodes a base64url encoded JSON segment of a JWT.
+func decodeSegment(segment string, value any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+
+	if err := json.Unmarshal(data, value); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+
+	return nil
+}
+
+// lookup returns the value of a claim, nested claims are separated by dots, nil if it doesn't exist.
+func lookup(payload map[string]any, name string) any {
+	if name == "" {
+		return nil
+	}
+
+	var value any = payload
+
+	for _, key := range strings.Split(name, ".") {
+		object, ok := value.(map[string]any)
+		if !ok {
+			return nil
+		}
+
+		value = object[key]
+	}
+
+	return value
+}
+
+// stringList returns the strings of a claim being either a string or an array of strings, the other values are ignored.
+func stringList(value any) []string {
+	switch value := value.(type) {
+	case string:
+		if value == "" {
+			return nil
+		}
+
+		return []string{value}
+	case []any:
+		var values []string
+
+		for _, item := range value {
+			if item, ok := item.(string); ok && item != "" {
+				values = append(values, item)
+			}
+		}
+
+		return values
+	default:
+		return nil
+	}
+}
+
+// getJSON fetches a JSON document.
+func (provider *Provider) getJSON(ctx context.Context, location string, value any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, location, nil)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := provider.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d from %s", resp.StatusCode, location)
+	}
+
+	return json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(value)
+}
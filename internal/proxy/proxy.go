@@ -0,0 +1,267 @@
+//    reddlinks, a simple link shortener written in Go.
+//    Copyright (C) 2025 redd
+//
+//    This program is free software: you can redistribute it and/or modify
+//    it under the terms of the GNU General Public License as published by
+//    the Free Software Foundation, either version 3 of the License, or
+//    (at your option) any later version.
+//
+//    This program is distributed in the hope that it will be useful,
+//    but WITHOUT ANY WARRANTY; without even the implied warranty of
+//    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//    GNU General Public License for more details.
+//
+//    You should have received a copy of the GNU General Public License
+//    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+// Package proxy resolves the address and the scheme of the client of a request sent through reverse proxies.
+//
+// The forwarding headers (Forwarded, see RFC 7239, or X-Forwarded-For and X-Forwarded-Proto) can be forged by any client,
+// so they are only read when the request comes from a trusted proxy, given as a list of networks. The hops are read
+// from the nearest one, the client being the first address which isn't a trusted proxy. The resolved [Client] is kept
+// in the context of the request, see [WithClient], for the handlers to use.
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// Schemes of the requests.
+const (
+	SchemeHTTP  = "http"
+	SchemeHTTPS = "https"
+)
+
+// Client is the client of a request.
+//
+// IP is the address of the client, invalid if it is unknown, and Scheme is the scheme it used, "http" or "https".
+type Client struct {
+	IP     netip.Addr
+	Scheme string
+}
+
+// hop is a hop of a forwarded request, the address of a client and the scheme it used, empty if unknown.
+type hop struct {
+	addr   string
+	scheme string
+}
+
+// contextKey is the type of the keys of the values stored in a context by this package.
+type contextKey struct{}
+
+// ParseTrusted parses a comma-separated list of trusted networks in the CIDR notation, single addresses are also accepted.
+//
+// Parameters:
+//   - list: The networks, the spaces and the empty items are ignored
+//
+// Returns:
+//   - []netip.Prefix: The trusted networks
+//   - error: Any error encountered while parsing a network
+func ParseTrusted(list string) ([]netip.Prefix, error) {
+	var trusted []netip.Prefix
+
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		// Single addresses are networks of a single address
+		if !strings.Contains(item, "/") {
+			addr, err := netip.ParseAddr(item)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse the trusted proxy %q: %w", item, err)
+			}
+
+			addr = addr.Unmap()
+			trusted = append(trusted, netip.PrefixFrom(addr, addr.BitLen()))
+
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(item)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse the trusted proxy %q: %w", item, err)
+		}
+
+		trusted = append(trusted, prefix.Masked())
+	}
+
+	return trusted, nil
+}
+
+// Resolve returns the client of a request.
+//
+// The client is the address of the connection, using HTTPS if the connection is encrypted. As long as the client is
+// a trusted proxy, the hops given by the forwarding headers are read from the nearest one: the address and the scheme
+// of the hop become the client. The Forwarded header is used if set, X-Forwarded-For and X-Forwarded-Proto otherwise.
+// Reading stops at a hop whose address can't be parsed, such as an obfuscated one.
+//
+// Parameters:
+//   - req: The request
+//   - trusted: The trusted proxies
+//
+// Returns:
+//   - Client: The client of the request
+func Resolve(req *http.Request, trusted []netip.Prefix) Client {
+	client := Client{Scheme: SchemeHTTP}
+	if req.TLS != nil {
+		client.Scheme = SchemeHTTPS
+	}
+
+	client.IP = parseAddr(req.RemoteAddr)
+
+	// Nothing can be trusted if the connection doesn't come from a trusted proxy
+	if !isTrusted(client.IP, trusted) {
+		return client
+	}
+
+	hops := forwardedHops(req.Header)
+
+	for index := len(hops) - 1; index >= 0 && isTrusted(client.IP, trusted); index-- {
+		addr := parseAddr(hops[index].addr)
+		if !addr.IsValid() {
+			break
+		}
+
+		client.IP = addr
+
+		if hops[index].scheme != "" {
+			client.Scheme = hops[index].scheme
+		}
+	}
+
+	return client
+}
+
+// isTrusted returns true if an address belongs to a trusted network.
+func isTrusted(addr netip.Addr, trusted []netip.Prefix) bool {
+	if !addr.IsValid() {
+		return false
+	}
+
+	for _, prefix := range trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// forwardedHops returns the hops given by the forwarding headers, the farthest first.
+//
+// The elements of the Forwarded header each give the address and the scheme of a hop. X-Forwarded-For only gives
+// the addresses, the schemes are taken from X-Forwarded-Proto if it has as many items, its last item is used for every hop otherwise.
+func forwardedHops(header http.Header) []hop {
+	var hops []hop
+
+	// Prefer the standard header
+	if forwarded := header.Values("Forwarded"); len(forwarded) > 0 {
+		for _, element := range splitList(forwarded) {
+			var current hop
+
+			for _, pair := range strings.Split(element, ";") {
+				key, value, found := strings.Cut(strings.TrimSpace(pair), "=")
+				if !found {
+					continue
+				}
+
+				value = strings.Trim(value, `"`)
+
+				switch strings.ToLower(key) {
+				case "for":
+					current.addr = value
+				case "proto":
+					current.scheme = parseScheme(value)
+				}
+			}
+
+			hops = append(hops, current)
+		}
+
+		return hops
+	}
+
+	addrs := splitList(header.Values("X-Forwarded-For"))
+	schemes := splitList(header.Values("X-Forwarded-Proto"))
+
+	for index, addr := range addrs {
+		current := hop{addr: addr}
+
+		if len(schemes) == len(addrs) {
+			current.scheme = parseScheme(schemes[index])
+		} else if len(schemes) > 0 {
+			current.scheme = parseScheme(schemes[len(schemes)-1])
+		}
+
+		hops = append(hops, current)
+	}
+
+	return hops
+}
+
+// splitList splits the comma-separated items of the values of a header, trimming their spaces.
+func splitList(values []string) []string {
+	var items []string
+
+	for _, value := range values {
+		for _, item := range strings.Split(value, ",") {
+			items = append(items, strings.TrimSpace(item))
+		}
+	}
+
+	return items
+}
+
+// parseAddr parses an address which may have a port, IPv6 addresses may be in brackets.
+// The returned address is invalid if it can't be parsed.
+func parseAddr(value string) netip.Addr {
+	if host, _, err := net.SplitHostPort(value); err == nil {
+		value = host
+	}
+
+	addr, err := netip.ParseAddr(strings.Trim(value, "[]"))
+	if err != nil {
+		return netip.Addr{}
+	}
+
+	return addr.Unmap().WithZone("")
+}
+
+// parseScheme returns a scheme in lower case, empty if it isn't HTTP or HTTPS.
+func parseScheme(value string) string {
+	switch scheme := strings.ToLower(strings.TrimSpace(value)); scheme {
+	case SchemeHTTP, SchemeHTTPS:
+		return scheme
+	default:
+		return ""
+	}
+}
+
+// WithClient returns a copy of a context holding the client of a request.
+func WithClient(ctx context.Context, client Client) context.Context {
+	return context.WithValue(ctx, contextKey{}, client)
+}
+
+// ClientFromContext returns the client held by a context, the boolean is false if there's none.
+func ClientFromContext(ctx context.Context) (Client, bool) {
+	client, found := ctx.Value(contextKey{}).(Client)
+
+	return client, found
+}
+
+// ClientOf returns the client of a request held by its context,
+// it is resolved without trusting any proxy if the context doesn't hold it.
+func ClientOf(req *http.Request) Client {
+	if client, found := ClientFromContext(req.Context()); found {
+		return client
+	}
+
+	return Resolve(req, nil)
+}
@@ -0,0 +1,343 @@
+//    reddlinks, a simple link shortener written in Go.
+//    Copyright (C) 2025 redd
+//
+//    This program is free software: you can redistribute it and/or modify
+//    it under the terms of the GNU General Public License as published by
+//    the Free Software Foundation, either version 3 of the License, or
+//    (at your option) any later version.
+//
+//    This program is distributed in the hope that it will be useful,
+//    but WITHOUT ANY WARRANTY; without even the implied warranty of
+//    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//    GNU General Public License for more details.
+//
+//    You should have received a copy of the GNU General Public License
+//    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+// Package ratelimit limits how often a client can perform an action, such as creating links or guessing passwords.
+//
+// Each client gets a token bucket per limited action: a request takes a token from the bucket, and is refused
+// if the bucket is empty, the bucket being refilled at a constant rate up to its size. The buckets are kept
+// in memory by [MemoryStore], or in the database by [DBStore] so that several instances share them.
+// Every method of [*Limiter] can be called on a nil limiter, it then allows everything,
+// so that callers don't have to check whether the rate limits are enabled.
+package ratelimit
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/netip"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/redds-be/reddlinks/internal/database"
+	"github.com/redds-be/reddlinks/internal/proxy"
+)
+
+// Settings of the stores.
+const (
+	sweepInterval = time.Minute    // How often the buckets that aren't needed anymore are removed
+	idleTimeout   = 24 * time.Hour // How long a bucket is kept in the database after its last update
+	maxAttempts   = 5              // How many times a database bucket is updated before giving up on conflicts
+	ipv6Prefix    = 64             // The size of the IPv6 networks sharing a bucket, usually given to a single client
+	maxRetryAfter = 24 * time.Hour // The longest wait returned to a client
+	minRetryAfter = time.Second    // The shortest wait returned to a client, Retry-After being in seconds
+)
+
+// Route identifies a limited action.
+type Route string
+
+// Limited actions.
+//
+// RouteCreate is the creation of a single link, RouteBatch is the creation of several links at once
+// and RoutePassword is an attempt to access a password protected link, to log in or to log in to the admin dashboard.
+const (
+	RouteCreate   Route = "create"
+	RouteBatch    Route = "batch"
+	RoutePassword Route = "password"
+)
+
+// Limit defines the token bucket of an action.
+//
+// PerMinute is the number of tokens added to the bucket each minute, zero disables the limit,
+// Burst is the size of the bucket, which is the number of requests that can be made at once.
+type Limit struct {
+	PerMinute int
+	Burst     int
+}
+
+// Enabled returns true if the limit restricts anything.
+func (limit Limit) Enabled() bool {
+	return limit.PerMinute > 0
+}
+
+// take takes a token from a bucket, refilled according to the limit since its last update.
+//
+// Parameters:
+//   - tokens: The tokens left in the bucket at its last update
+//   - updatedAt: The date of the last update of the bucket
+//   - now: The current date
+//
+// Returns:
+//   - float64: The tokens left in the bucket now
+//   - time.Duration: Zero if a token was taken, how long to wait for the next token otherwise
+func (limit Limit) take(tokens float64, updatedAt, now time.Time) (float64, time.Duration) {
+	rate := float64(limit.PerMinute) / time.Minute.Seconds()
+	burst := float64(max(limit.Burst, 1))
+
+	// Refill the bucket since its last update
+	if elapsed := now.Sub(updatedAt).Seconds(); elapsed > 0 {
+		tokens = math.Min(burst, tokens+elapsed*rate)
+	}
+
+	if tokens >= 1 {
+		return tokens - 1, 0
+	}
+
+	wait := time.Duration((1 - tokens) / rate * float64(time.Second))
+
+	return tokens, min(max(wait, minRetryAfter), maxRetryAfter)
+}
+
+// full returns the date a bucket is full again, after which it can be forgotten.
+func (limit Limit) full(tokens float64, now time.Time) time.Time {
+	rate := float64(limit.PerMinute) / time.Minute.Seconds()
+	missing := float64(max(limit.Burst, 1)) - tokens
+
+	return now.Add(time.Duration(missing / rate * float64(time.Second)))
+}
+
+// Store defines where the token buckets are kept.
+type Store interface {
+	// Take takes a token from the bucket of a key, returning how long to wait if the bucket is empty, zero otherwise
+	Take(key string, limit Limit, now time.Time) (time.Duration, error)
+}
+
+// Make sure the implementations satisfy the interface.
+var (
+	_ Store = (*MemoryStore)(nil)
+	_ Store = (*DBStore)(nil)
+)
+
+// memoryBucket is a token bucket kept in memory.
+type memoryBucket struct {
+	tokens    float64
+	updatedAt time.Time
+	fullAt    time.Time
+}
+
+// MemoryStore is a [Store] keeping the buckets in memory, each instance having its own buckets.
+//
+// The buckets that are full again are removed at most once per minute, since they are the same as new ones.
+type MemoryStore struct {
+	mutex     sync.Mutex
+	buckets   map[string]*memoryBucket
+	lastSweep time.Time
+}
+
+// NewMemoryStore returns an empty [MemoryStore].
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: make(map[string]*memoryBucket)}
+}
+
+// Take takes a token from the bucket of a key, creating a full bucket if it doesn't exist.
+func (store *MemoryStore) Take(key string, limit Limit, now time.Time) (time.Duration, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	// Forget the buckets that are full again
+	if now.Sub(store.lastSweep) >= sweepInterval {
+		for name, bucket := range store.buckets {
+			if !bucket.fullAt.After(now) {
+				delete(store.buckets, name)
+			}
+		}
+
+		store.lastSweep = now
+	}
+
+	bucket, exists := store.buckets[key]
+	if !exists {
+		bucket = &memoryBucket{tokens: float64(max(limit.Burst, 1)), updatedAt: now}
+		store.buckets[key] = bucket
+	}
+
+	tokens, wait := limit.take(bucket.tokens, bucket.updatedAt, now)
+	bucket.tokens, bucket.updatedAt, bucket.fullAt = tokens, now, limit.full(tokens, now)
+
+	return wait, nil
+}
+
+// Len returns the number of buckets kept in memory.
+func (store *MemoryStore) Len() int {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	return len(store.buckets)
+}
+
+// DBStore is a [Store] keeping the buckets in the database, see [database.RateLimitStore],
+// so that the instances sharing a database share the limits.
+//
+// Each request reads and updates its bucket, retrying if another instance updated it in between.
+// The buckets that weren't updated for a day are removed at most once per minute.
+type DBStore struct {
+	store     database.RateLimitStore
+	lastSweep atomic.Int64
+}
+
+// NewDBStore returns a [DBStore] using a given store.
+func NewDBStore(store database.RateLimitStore) *DBStore {
+	return &DBStore{store: store}
+}
+
+// Take takes a token from the bucket of a key stored in the database, creating a full bucket if it doesn't exist.
+//
+// Parameters:
+//   - key: The key of the bucket
+//   - limit: The limit defining the bucket
+//   - now: The current date
+//
+// Returns:
+//   - time.Duration: Zero if a token was taken, how long to wait for the next token otherwise
+//   - error: Any error encountered while reading or saving the bucket, [database.ErrBucketConflict]
+//     if the bucket kept being updated by other instances
+func (store *DBStore) Take(key string, limit Limit, now time.Time) (time.Duration, error) {
+	store.sweep(now)
+
+	var err error
+
+	for range maxAttempts {
+		bucket, getErr := store.store.GetBucket(key)
+		if errors.Is(getErr, sql.ErrNoRows) {
+			bucket = database.Bucket{Key: key, Tokens: float64(max(limit.Burst, 1)), UpdatedAt: now}
+		} else if getErr != nil {
+			return 0, getErr
+		}
+
+		var wait time.Duration
+
+		bucket.Tokens, wait = limit.take(bucket.Tokens, bucket.UpdatedAt, now)
+		bucket.UpdatedAt = now
+
+		// Try again with the new state of the bucket if another instance updated it
+		if err = store.store.SaveBucket(bucket); errors.Is(err, database.ErrBucketConflict) {
+			continue
+		} else if err != nil {
+			return 0, err
+		}
+
+		return wait, nil
+	}
+
+	return 0, err
+}
+
+// sweep removes the idle buckets if it wasn't done during the last minute.
+func (store *DBStore) sweep(now time.Time) {
+	last := store.lastSweep.Load()
+	if now.UnixNano()-last < int64(sweepInterval) || !store.lastSweep.CompareAndSwap(last, now.UnixNano()) {
+		return
+	}
+
+	if _, err := store.store.RemoveIdleBuckets(now.Add(-idleTimeout)); err != nil {
+		slog.Error("Failed to remove the idle rate limit buckets", slog.Any("error", err))
+	}
+}
+
+// Limiter applies the limits of the routes to the clients.
+type Limiter struct {
+	store  Store
+	limits map[Route]Limit
+	now    func() time.Time
+}
+
+// New returns a limiter.
+//
+// Parameters:
+//   - store: Where the buckets are kept
+//   - limits: The limit of each route, the routes without an enabled limit aren't limited
+//
+// Returns:
+//   - *Limiter: The limiter, nil if no limit is enabled
+func New(store Store, limits map[Route]Limit) *Limiter {
+	enabled := make(map[Route]Limit)
+
+	for route, limit := range limits {
+		if limit.Enabled() {
+			enabled[route] = limit
+		}
+	}
+
+	if len(enabled) == 0 {
+		return nil
+	}
+
+	return &Limiter{store: store, limits: enabled, now: time.Now}
+}
+
+// Allow takes a token from the bucket of the client of a request for a route.
+//
+// The request is allowed if the route isn't limited. If the store fails, the error is logged
+// and the request is allowed, a failing database shouldn't make the whole service unavailable.
+//
+// Parameters:
+//   - req: The request of the client
+//   - route: The limited action
+//
+// Returns:
+//   - time.Duration: Zero if the request is allowed, how long the client has to wait otherwise
+func (limiter *Limiter) Allow(req *http.Request, route Route) time.Duration {
+	if limiter == nil {
+		return 0
+	}
+
+	limit, limited := limiter.limits[route]
+	if !limited {
+		return 0
+	}
+
+	key := fmt.Sprintf("%s:%s", route, ClientKey(req))
+
+	wait, err := limiter.store.Take(key, limit, limiter.now())
+	if err != nil {
+		slog.ErrorContext(req.Context(), "Failed to apply the rate limit", slog.String("route", string(route)), slog.Any("error", err))
+
+		return 0
+	}
+
+	return wait
+}
+
+// ClientKey returns the key identifying the client of a request.
+//
+// The address of the client is resolved from the trusted proxies, see [proxy.ClientOf]. IPv6 addresses are reduced
+// to their /64 network, which is usually given to a single client.
+//
+// Parameters:
+//   - req: The request of the client
+//
+// Returns:
+//   - string: The key of the client, "unknown" if its address is unknown
+func ClientKey(req *http.Request) string {
+	addr := proxy.ClientOf(req).IP
+	if !addr.IsValid() {
+		return "unknown"
+	}
+
+	if addr.Is6() {
+		return netip.PrefixFrom(addr, ipv6Prefix).Masked().String()
+	}
+
+	return addr.String()
+}
+
+// RetryAfter returns the value of the Retry-After header for a wait, rounded up to the second.
+func RetryAfter(wait time.Duration) string {
+	return fmt.Sprint(int64(math.Ceil(wait.Seconds())))
+}
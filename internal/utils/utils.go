@@ -20,27 +20,52 @@ package utils
 import (
 	"bytes"
 	"crypto/rand"
-	"database/sql"
 	"embed"
 	"encoding/base64"
+	"encoding/csv"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"math/big"
+	"mime"
 	"net"
 	"net/http"
+	"net/netip"
 	"net/url"
 	"os"
 	"path/filepath"
+	"reflect"
 	"regexp"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
+	"github.com/google/uuid"
+	"github.com/redds-be/reddlinks/internal/admin"
+	"github.com/redds-be/reddlinks/internal/analytics"
+	"github.com/redds-be/reddlinks/internal/certs"
 	"github.com/redds-be/reddlinks/internal/database"
+	"github.com/redds-be/reddlinks/internal/metrics"
+	"github.com/redds-be/reddlinks/internal/oidc"
+	"github.com/redds-be/reddlinks/internal/ratelimit"
 	"github.com/yeqown/go-qrcode/v2"
 	"github.com/yeqown/go-qrcode/writer/standard"
 )
 
+// Limits of the requests decoded by DecodeJSON and DecodeBatch.
+const (
+	// MaxBatchSize is the maximum number of links in a batch.
+	MaxBatchSize = 500
+
+	// DefaultMaxBodyBytes is the default maximum size of a JSON payload in bytes.
+	DefaultMaxBodyBytes = 1 << 20
+
+	// DefaultMaxBatchBytes is the default maximum size of a batch in bytes.
+	DefaultMaxBatchBytes = 4 << 20
+)
+
 var (
 	// ErrInvalidURLScheme is returned when the URL scheme is not http or https.
 	ErrInvalidURLScheme = errors.New("URL scheme is invalid")
@@ -51,6 +76,15 @@ var (
 	// ErrEmpty is returned when the source URL is an empty string.
 	ErrEmpty = errors.New("can't be empty")
 
+	// ErrBatchTooLarge is returned when a batch contains more than MaxBatchSize links.
+	ErrBatchTooLarge = errors.New("batch contains too many links")
+
+	// ErrUnsupportedBatch is returned when a batch is neither in JSON nor in CSV.
+	ErrUnsupportedBatch = errors.New("unsupported batch format")
+
+	// ErrUnknownColumn is returned when a column of a CSV batch doesn't match any parameter.
+	ErrUnknownColumn = errors.New("unknown column")
+
 	// urlRegex is a precompiled regular expression to quickly match http/https URLs.
 	urlRegex = regexp.MustCompile(`^https?://`)
 
@@ -64,7 +98,7 @@ var (
 
 // Configuration defines what is going to be sent to the handlers.
 //
-// DB is a pointer to the database connection,
+// Store is where the links are stored,
 // InstanceName refers to the name of the reddlinks instance,
 // InstanceURL refers to the public URL of the reddlinks instance,
 // Version refers to the actual version of the reddlinks instance,
@@ -74,9 +108,32 @@ var (
 // DefaultMaxCustomLength refers to the maximum length of custom strings for a short URL,
 // DefaultExpiryTime refers to the default expiry time of links records,
 // ContactEmail refers to an optional admin's contact email,
-// Static contains the embedded static filesystem.
+// Static contains the embedded static filesystem,
+// Hits records the accesses to links, it is nil when analytics are disabled,
+// Admin authenticates the operator of the instance, it is nil when the admin dashboard is disabled,
+// Metrics collects the metrics exposed on /metrics, it is nil when the metrics are disabled,
+// MetricsToken is the bearer token required to read the metrics, they are public if it is empty,
+// GC records the last successful garbage collection, reported by the readiness probe,
+// ShutdownTimeout is the time given to the in-flight requests to be handled once the server is stopped,
+// TLS is the TLS configuration of the server, it is nil when the server only speaks plain HTTP,
+// RedirectAddr is the address of the plain HTTP server redirecting to HTTPS, it is disabled if empty,
+// HSTSMaxAge is the number of seconds browsers must only use HTTPS for, sent when TLS is enabled (0 to disable),
+// ReadTimeout, ReadHeaderTimeout, WriteTimeout and IdleTimeout are the timeouts of the HTTP server, see [http.Server],
+// BatchTimeout is the time given to read a batch and to create its links, overriding the read and write timeouts,
+// MaxHeaderBytes is the maximum size of the headers of a request,
+// MaxBodyBytes is the maximum size of a JSON payload, see [DecodeJSON],
+// MaxBatchBytes is the maximum size of a batch, see [DecodeBatch],
+// RateLimiter limits how often a client can create links and try passwords, it is nil when the rate limits are disabled,
+// TrustedProxies are the networks of the reverse proxies whose forwarding headers are trusted to give the client,
+// RestrictCreation restricts the creation of links to the clients having an API key with the create scope, or logged in users,
+// Accounts enables the user accounts, owning the links they create,
+// Registration allows anybody to register an account when the accounts are enabled,
+// OIDC logs the users in using an OpenID Connect provider, it is nil when the single sign-on is disabled,
+// AdminRole is the role given by the provider which grants access to the admin dashboard, nobody has it if empty,
+// CreatorRole is the role given by the provider which logged in users need to create links when the creation is restricted,
+// any logged in user can if empty.
 type Configuration struct {
-	DB                     *sql.DB
+	Store                  database.LinkStore
 	InstanceName           string
 	InstanceURL            string
 	Version                string
@@ -90,6 +147,63 @@ type Configuration struct {
 	LocalesDir             string
 	Locales                map[string]PageLocaleTl
 	SupportedLocales       map[string]bool
+	Hits                   *analytics.Recorder
+	Admin                  *admin.Auth
+	Metrics                *metrics.Metrics
+	MetricsToken           string
+	GC                     *GCStatus
+	ShutdownTimeout        time.Duration
+	TLS                    *certs.TLS
+	RedirectAddr           string
+	HSTSMaxAge             int
+	ReadTimeout            time.Duration
+	ReadHeaderTimeout      time.Duration
+	WriteTimeout           time.Duration
+	IdleTimeout            time.Duration
+	BatchTimeout           time.Duration
+	MaxHeaderBytes         int
+	MaxBodyBytes           int64
+	MaxBatchBytes          int64
+	RateLimiter            *ratelimit.Limiter
+	TrustedProxies         []netip.Prefix
+	RestrictCreation       bool
+	Accounts               bool
+	Registration           bool
+	OIDC                   *oidc.Provider
+	AdminRole              string
+	CreatorRole            string
+}
+
+// GCStatus records the date of the last successful garbage collection.
+//
+// Its methods can be called on a nil [*GCStatus], nothing is recorded then.
+type GCStatus struct {
+	mutex   sync.Mutex
+	lastRun time.Time
+}
+
+// Succeeded records a successful garbage collection at a given date.
+func (status *GCStatus) Succeeded(at time.Time) {
+	if status == nil {
+		return
+	}
+
+	status.mutex.Lock()
+	defer status.mutex.Unlock()
+
+	status.lastRun = at
+}
+
+// LastRun returns the date of the last successful garbage collection, the zero date if there's none yet.
+func (status *GCStatus) LastRun() time.Time {
+	if status == nil {
+		return time.Time{}
+	}
+
+	status.mutex.Lock()
+	defer status.mutex.Unlock()
+
+	return status.lastRun
 }
 
 // Parameters defines the structure of the JSON payload that will be read from the user.
@@ -99,14 +213,18 @@ type Configuration struct {
 // Path refers to the custom string used in the shortened URL,
 // ExpireAfter refers the time from now after which the link will expire,
 // ExpireDate refers to the exact expiration date for the link,
-// Password refers to a password to protect a link from being accessed by anybody.
+// Password refers to a password to protect a link from being accessed by anybody,
+// RemovePassword removes the password of an updated link, it is ignored if a new password is given,
+// Owner is the user creating the link, it isn't read from the payload but from the session of the request.
 type Parameters struct {
-	URL         string `json:"url"`
-	Length      int    `json:"length"`
-	Path        string `json:"customPath"`
-	ExpireAfter string `json:"expireAfter"`
-	ExpireDate  string `json:"expireDate"`
-	Password    string `json:"password"`
+	URL            string    `json:"url"`
+	Length         int       `json:"length"`
+	Path           string    `json:"customPath"`
+	ExpireAfter    string    `json:"expireAfter"`
+	ExpireDate     string    `json:"expireDate"`
+	Password       string    `json:"password"`
+	RemovePassword bool      `json:"removePassword"`
+	Owner          uuid.UUID `json:"-"`
 }
 
 // PageLocaleTl defines the translatable text content for web pages.
@@ -127,6 +245,7 @@ type PageLocaleTl struct {
 	GetThe                   string `json:"get_the"`
 	SourceCode               string `json:"source_code"`
 	Error                    string `json:"error"`
+	RequestID                string `json:"request_id"`
 	GoBack                   string `json:"go_back"`
 	PasswordRequired         string `json:"password_required"`
 	AccessLink               string `json:"access_link"`
@@ -154,6 +273,9 @@ type PageLocaleTl struct {
 	Path                     string `json:"path"`
 	WillAskPass              string `json:"will_ask_pass"`
 	ShortenURL               string `json:"shorten_url"`
+	BatchUpload              string `json:"batch_upload"`
+	BatchColumns             string `json:"batch_columns"`
+	Upload                   string `json:"upload"`
 	ShortenedLink            string `json:"shortened_link"`
 	LinksTo                  string `json:"links_to"`
 	AccessiblePass           string `json:"accessible_pass"`
@@ -164,6 +286,65 @@ type PageLocaleTl struct {
 	ShortenAnotherURL        string `json:"shorten_another_url"`
 	CopiedLink               string `json:"copied_link"`
 	PasswordRevealed         string `json:"password_revealed"`
+	ManagementToken          string `json:"management_token"`
+	ManagementTokenInfo      string `json:"management_token_info"`
+	TotalHits                string `json:"total_hits"`
+	Referrers                string `json:"referrers"`
+	Clients                  string `json:"clients"`
+	DirectAccess             string `json:"direct_access"`
+	AdminTitle               string `json:"admin_title"`
+	AdminPassword            string `json:"admin_password"`
+	LogIn                    string `json:"log_in"`
+	LogOut                   string `json:"log_out"`
+	Filter                   string `json:"filter"`
+	FilterShort              string `json:"filter_short"`
+	FilterURL                string `json:"filter_url"`
+	CreatedAfter             string `json:"created_after"`
+	CreatedBefore            string `json:"created_before"`
+	ExpiresAfter             string `json:"expires_after"`
+	ExpiresBefore            string `json:"expires_before"`
+	LinksFound               string `json:"links_found"`
+	NoLinks                  string `json:"no_links"`
+	CacheStats               string `json:"cache_stats"`
+	CacheHits                string `json:"cache_hits"`
+	CacheMisses              string `json:"cache_misses"`
+	CachedLinks              string `json:"cached_links"`
+	Protected                string `json:"protected"`
+	Yes                      string `json:"yes"`
+	DeleteSelected           string `json:"delete_selected"`
+	ExpireSelected           string `json:"expire_selected"`
+	PreviousPage             string `json:"previous_page"`
+	NextPage                 string `json:"next_page"`
+	Page                     string `json:"page"`
+	APIKeys                  string `json:"api_keys"`
+	KeyLabel                 string `json:"key_label"`
+	KeyPrefix                string `json:"key_prefix"`
+	KeyScopes                string `json:"key_scopes"`
+	KeyExpires               string `json:"key_expires"`
+	Never                    string `json:"never"`
+	Expired                  string `json:"expired"`
+	NoAPIKeys                string `json:"no_api_keys"`
+	CreateKey                string `json:"create_key"`
+	RevokeKey                string `json:"revoke_key"`
+	NewAPIKey                string `json:"new_api_key"`
+	NewAPIKeyInfo            string `json:"new_api_key_info"`
+	CreationRestricted       string `json:"creation_restricted"`
+	CreationRestrictedLogin  string `json:"creation_restricted_login"`
+	Account                  string `json:"account"`
+	MyLinks                  string `json:"my_links"`
+	Username                 string `json:"username"`
+	AccountPassword          string `json:"account_password"`
+	Register                 string `json:"register"`
+	LoggedInAs               string `json:"logged_in_as"`
+	LogInSSO                 string `json:"log_in_sso"`
+	Edit                     string `json:"edit"`
+	Stats                    string `json:"stats"`
+	Update                   string `json:"update"`
+	Delete                   string `json:"delete"`
+	NoOwnedLinks             string `json:"no_owned_links"`
+	BatchTitle               string `json:"batch_title"`
+	LinksCreated             string `json:"links_created"`
+	Row                      string `json:"row"`
 	PrivacyPolicy            string `json:"privacy_policy"`
 	PrivIntro                string `json:"priv_intro"`
 	PrivDirect               string `json:"priv_direct"`
@@ -174,6 +355,8 @@ type PageLocaleTl struct {
 	PrivExpiration           string `json:"priv_expiration"`
 	PrivCreation             string `json:"priv_creation"`
 	PrivPassword             string `json:"priv_password"`
+	PrivToken                string `json:"priv_token"`
+	PrivAnalytics            string `json:"priv_analytics"`
 	PrivPassive              string `json:"priv_passive"`
 	PrivNotLog               string `json:"priv_not_log"`
 	PrivUnenforceableNote    string `json:"priv_unenforceable_note"`
@@ -192,6 +375,7 @@ type PageLocaleTl struct {
 	ErrCompHash              string `json:"err_comp_hash"`
 	ErrGetInfo               string `json:"err_get_info"`
 	ErrInvalidJSON           string `json:"err_invalid_json"`
+	ErrPayloadTooLarge       string `json:"err_payload_too_large"`
 	ErrUnableCheckURL        string `json:"err_unable_check_url"`
 	ErrInvalidURL            string `json:"err_invalid_url"`
 	ErrUnableTellEOW         string `json:"err_unable_tell_eow"`
@@ -199,6 +383,7 @@ type PageLocaleTl struct {
 	ErrParseExpiry           string `json:"err_parse_expiry"`
 	ErrCheckValidPath        string `json:"err_check_valid_path"`
 	ErrAlphaNumeric          string `json:"err_alpha_numeric"`
+	ErrReservedPath          string `json:"err_reserved_path"`
 	ErrRedirectionLoop       string `json:"err_redirection_loop"`
 	ErrHashPass              string `json:"err_hash_pass"`
 	ErrPathInUse             string `json:"err_path_in_use"`
@@ -208,6 +393,46 @@ type PageLocaleTl struct {
 	ErrUnableReadLength      string `json:"err_unable_read_length"`
 	ErrReadPass              string `json:"err_read_pass"`
 	ErrUnableGen             string `json:"err_unable_gen"`
+	ErrMissingToken          string `json:"err_missing_token"`
+	ErrWrongToken            string `json:"err_wrong_token"`
+	ErrNotManageable         string `json:"err_not_manageable"`
+	ErrHashToken             string `json:"err_hash_token"`
+	ErrUpdateLink            string `json:"err_update_link"`
+	ErrDeleteLink            string `json:"err_delete_link"`
+	ErrWrongAdminPass        string `json:"err_wrong_admin_pass"`
+	ErrAdminLoginRequired    string `json:"err_admin_login_required"`
+	ErrListLinks             string `json:"err_list_links"`
+	ErrInvalidFilter         string `json:"err_invalid_filter"`
+	ErrNoSelection           string `json:"err_no_selection"`
+	ErrAdminAction           string `json:"err_admin_action"`
+	ErrUnknownAction         string `json:"err_unknown_action"`
+	ErrInvalidBatch          string `json:"err_invalid_batch"`
+	ErrBatchTooLarge         string `json:"err_batch_too_large"`
+	ErrRateLimited           string `json:"err_rate_limited"`
+	ErrInvalidAPIKey         string `json:"err_invalid_api_key"`
+	ErrCheckAPIKey           string `json:"err_check_api_key"`
+	ErrAPIKeyRequired        string `json:"err_api_key_required"`
+	ErrMetricsTokenRequired  string `json:"err_metrics_token_required"`
+	ErrInvalidLabel          string `json:"err_invalid_label"`
+	ErrInvalidScopes         string `json:"err_invalid_scopes"`
+	ErrInvalidKeyExpiry      string `json:"err_invalid_key_expiry"`
+	ErrCreateAPIKey          string `json:"err_create_api_key"`
+	ErrListAPIKeys           string `json:"err_list_api_keys"`
+	ErrRevokeAPIKey          string `json:"err_revoke_api_key"`
+	ErrInvalidUsername       string `json:"err_invalid_username"`
+	ErrInvalidPassword       string `json:"err_invalid_password"`
+	ErrUsernameInUse         string `json:"err_username_in_use"`
+	ErrWrongCredentials      string `json:"err_wrong_credentials"`
+	ErrRegistrationClosed    string `json:"err_registration_closed"`
+	ErrLoginRequired         string `json:"err_login_required"`
+	ErrAccountLoginRequired  string `json:"err_account_login_required"`
+	ErrCreateUser            string `json:"err_create_user"`
+	ErrCreateSession         string `json:"err_create_session"`
+	ErrCheckSession          string `json:"err_check_session"`
+	ErrCreatorRoleRequired   string `json:"err_creator_role_required"`
+	ErrOIDCState             string `json:"err_oidc_state"`
+	ErrOIDCDenied            string `json:"err_oidc_denied"`
+	ErrOIDCLogin             string `json:"err_oidc_login"`
 	InfoLengthChange         string `json:"info_length_change"`
 }
 
@@ -426,25 +651,69 @@ func GetLocale(req *http.Request, locales map[string]PageLocaleTl, supportedLoca
 	return locales[lang]
 }
 
+// localeFields maps the keys of the locales to the index of their field in [PageLocaleTl].
+var localeFields = sync.OnceValue(func() map[string]int { //nolint:gochecknoglobals
+	fields := map[string]int{}
+
+	localeType := reflect.TypeFor[PageLocaleTl]()
+	for index := range localeType.NumField() {
+		fields[localeType.Field(index).Tag.Get("json")] = index
+	}
+
+	return fields
+})
+
+// Text returns the text of a key of the locale, the generic error text if the key doesn't exist.
+//
+// Parameters:
+//   - key: The key of the text in the locale files, such as "err_not_found"
+//
+// Returns:
+//   - string: The localized text
+func (locale PageLocaleTl) Text(key string) string {
+	index, found := localeFields()[key]
+	if !found {
+		return locale.Error
+	}
+
+	return reflect.ValueOf(locale).Field(index).String()
+}
+
 // CollectGarbage deletes old expired entries in the database.
 //
 // This method performs database cleanup by removing links that have expired.
-// It uses the database.RemoveExpiredLinks function to delete these outdated entries.
+// It uses the RemoveExpiredLinks method of the store to delete these outdated entries,
+// and the RemoveExpiredSessions method to delete the expired sessions of the users.
 //
 // The method operates on the Configuration receiver and attempts to remove
-// expired links from the associated database.
+// expired links from the associated store.
 //
 // Returns:
 //   - An error if the link removal process fails, otherwise nil.
 //
+// The duration of the sweep and the number of deleted links are recorded in the metrics, if enabled,
+// and the date of the sweep is recorded in GC if it succeeded.
+//
 // Note: The necessity of this method may be subject to review in future iterations.
 func (conf Configuration) CollectGarbage() error {
+	start := time.Now()
+
 	// Delete expired links
-	err := database.RemoveExpiredLinks(conf.DB)
+	deleted, err := conf.Store.RemoveExpiredLinks()
+
+	// Delete expired sessions
+	if err == nil {
+		_, err = conf.Store.RemoveExpiredSessions()
+	}
+
+	conf.Metrics.ObserveGC(time.Since(start), deleted, err)
+
 	if err != nil {
 		return err
 	}
 
+	conf.GC.Succeeded(time.Now())
+
 	return nil
 }
 
@@ -453,18 +722,22 @@ func (conf Configuration) CollectGarbage() error {
 // It handles various error scenarios, including:
 //   - Empty request bodies
 //   - Invalid JSON formatting
-//   - Oversized request payloads (more than 1MB)
+//   - Oversized request payloads (more than maxBytes)
 //
 // Parameters:
 //   - req: A pointer to the http.Request containing the JSON payload
+//   - maxBytes: The maximum size of the payload, [DefaultMaxBodyBytes] if it isn't positive
 //
 // Returns:
 //   - A Parameters struct populated with decoded JSON data
-//   - An error if decoding fails, with detailed error information
-func DecodeJSON(req *http.Request) (Parameters, error) {
-	const oneMB = 1_048_576
-	// Use http.MaxBytesReader to close the connexion if a requests is more than 1MB (prevents DoS attacks)
-	req.Body = http.MaxBytesReader(nil, req.Body, oneMB)
+//   - An error if decoding fails, with detailed error information, wrapping a [*http.MaxBytesError] if the payload is too large
+func DecodeJSON(req *http.Request, maxBytes int64) (Parameters, error) {
+	if maxBytes <= 0 {
+		maxBytes = DefaultMaxBodyBytes
+	}
+
+	// Use http.MaxBytesReader to close the connexion if a requests is too large (prevents DoS attacks)
+	req.Body = http.MaxBytesReader(nil, req.Body, maxBytes)
 
 	// Read the entire request body into memory
 	body, err := io.ReadAll(req.Body)
@@ -488,6 +761,145 @@ func DecodeJSON(req *http.Request) (Parameters, error) {
 	return params, nil
 }
 
+// DecodeBatch decodes a batch of link creation parameters from an HTTP request.
+//
+// The batch is either a JSON array of [Parameters] or a CSV file, sent as the body of the request
+// with the "text/csv" content type or as the "file" field of a multipart form.
+// The first line of a CSV file names its columns using the JSON names of the [Parameters] fields,
+// the url column is required, the others are optional.
+// The request can't be larger than maxBytes and can't contain more than [MaxBatchSize] links.
+//
+// Parameters:
+//   - req: A pointer to the http.Request containing the batch
+//   - maxBytes: The maximum size of the request, [DefaultMaxBatchBytes] if it isn't positive
+//
+// Returns:
+//   - The decoded parameters, in the order of the batch
+//   - An error if decoding fails, [ErrBatchTooLarge] if there are too many links
+func DecodeBatch(req *http.Request, maxBytes int64) ([]Parameters, error) {
+	if maxBytes <= 0 {
+		maxBytes = DefaultMaxBatchBytes
+	}
+
+	// Use http.MaxBytesReader to close the connexion if a requests is too large (prevents DoS attacks)
+	req.Body = http.MaxBytesReader(nil, req.Body, maxBytes)
+	defer req.Body.Close()
+
+	mediaType, _, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, err
+	}
+
+	var batch []Parameters
+
+	switch mediaType {
+	case "application/json":
+		err = json.NewDecoder(req.Body).Decode(&batch)
+	case "text/csv":
+		batch, err = decodeCSV(req.Body)
+	case "multipart/form-data":
+		file, _, formErr := req.FormFile("file")
+		if formErr != nil {
+			return nil, formErr
+		}
+		defer file.Close()
+
+		batch, err = decodeCSV(file)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedBatch, mediaType)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Check the size of the batch
+	switch {
+	case len(batch) == 0:
+		return nil, fmt.Errorf("batch %w", ErrEmpty)
+	case len(batch) > MaxBatchSize:
+		return nil, ErrBatchTooLarge
+	}
+
+	return batch, nil
+}
+
+// decodeCSV decodes link creation parameters from a CSV file whose first line names the columns.
+func decodeCSV(reader io.Reader) ([]Parameters, error) {
+	csvReader := csv.NewReader(reader)
+	csvReader.TrimLeadingSpace = true
+
+	// Read the names of the columns
+	header, err := csvReader.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	hasURL := false
+	for index, column := range header {
+		header[index] = strings.TrimSpace(column)
+
+		switch header[index] {
+		case "url":
+			hasURL = true
+		case "length", "customPath", "expireAfter", "expireDate", "password":
+		default:
+			return nil, fmt.Errorf("%w: %q", ErrUnknownColumn, header[index])
+		}
+	}
+
+	if !hasURL {
+		return nil, fmt.Errorf("url column %w", ErrEmpty)
+	}
+
+	// Read the parameters of each link
+	var batch []Parameters
+
+	for {
+		record, err := csvReader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		// Stop reading once there are too many links, the batch will be refused anyway
+		if len(batch) == MaxBatchSize {
+			return nil, ErrBatchTooLarge
+		}
+
+		var params Parameters
+
+		for index, value := range record {
+			switch header[index] {
+			case "url":
+				params.URL = value
+			case "length":
+				if value == "" {
+					continue
+				}
+
+				params.Length, err = strconv.Atoi(value)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: %w", len(batch)+2, err)
+				}
+			case "customPath":
+				params.Path = value
+			case "expireAfter":
+				params.ExpireAfter = value
+			case "expireDate":
+				params.ExpireDate = value
+			case "password":
+				params.Password = value
+			}
+		}
+
+		batch = append(batch, params)
+	}
+
+	return batch, nil
+}
+
 // GenStr generates a random string of specified length using the given charset.
 //
 // Parameters:
@@ -18,16 +18,27 @@
 package main
 
 import (
-	"database/sql"
+	"context"
 	"embed"
+	"flag"
 	"html/template"
-	"log"
+	"log/slog"
 	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
 	"time"
 
+	"github.com/redds-be/reddlinks/internal/admin"
+	"github.com/redds-be/reddlinks/internal/analytics"
+	"github.com/redds-be/reddlinks/internal/certs"
 	"github.com/redds-be/reddlinks/internal/database"
-	"github.com/redds-be/reddlinks/internal/env"
 	"github.com/redds-be/reddlinks/internal/http"
+	"github.com/redds-be/reddlinks/internal/metrics"
+	"github.com/redds-be/reddlinks/internal/oidc"
+	"github.com/redds-be/reddlinks/internal/proxy"
+	"github.com/redds-be/reddlinks/internal/ratelimit"
 	"github.com/redds-be/reddlinks/internal/utils"
 )
 
@@ -41,46 +52,76 @@ var embeddedStatic embed.FS
 
 // main function drives the application.
 //
-// It starts by loading the environnement variables using [env.GetEnv],
-// then it connects to the dabaase using [database.DBConnect] and creates the links table using [database.CreateLinksTable],
-// following that, the env vars and the database are gathered into a configuration struct [utils.Configuration].
-// It starts a go routines that calls [utils.CollectGarbage] inside an infinite loop with a sleep period defines in the config.
+// The first argument names the subcommand to run, see [runCommand],
+// the server is started by the serve subcommand, which is also the default one when no argument is given.
+func main() {
+	name, args := "serve", []string{}
+	if len(os.Args) > 1 {
+		name, args = os.Args[1], os.Args[2:]
+	}
+
+	if err := runCommand(name, args); err != nil {
+		slog.Error("Command failed", slog.String("command", name), slog.Any("error", err))
+		os.Exit(1)
+	}
+}
+
+// runServe starts the server.
+//
+// It starts by loading the environnement variables and configuring the logger using [loadEnv],
+// then it opens the store using [database.OpenStore], which connects to the database and migrates its schema,
+// the store is put behind a [database.CachedStore] if the cache is enabled, the metrics are collected if enabled,
+// following that, the env vars and the store are gathered into a configuration struct [utils.Configuration],
+// along with the TLS configuration given by [certs.New] if HTTPS is enabled and the rate limiter given by [ratelimit.New].
 // Following that, HTML templates stored in [embeddedStatic] (containing the 'static/' dir) are parsed using [template.Must].
+// It starts a go routine that calls [utils.CollectGarbage] periodically, see [collectGarbagePeriodically].
 // At then end, an adapter for the internal HTTP package is created using [http.NewAdapter],
-// lastly, the HTTP server gets started using [http.Run].
-func main() { //nolint:funlen
-	// Load the env file
-	envFile := ".env"
-	envVars := env.GetEnv(envFile)
-
-	// Connect to the database
-	dbase, err := database.DBConnect(
-		envVars.DBType,
-		envVars.DBURL,
-		envVars.DBUser,
-		envVars.DBPass,
-		envVars.DBHost,
-		envVars.DBPort,
-		envVars.DBName,
-	)
+// lastly, the HTTP server gets started using [http.Run] until a SIGINT or a SIGTERM is received.
+// Once the in-flight requests are drained, the garbage collection is stopped, the pending hits are flushed
+// and the store is closed.
+func runServe(args []string) error { //nolint:funlen
+	flags := flag.NewFlagSet("serve", flag.ContinueOnError)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	// Load the env file and configure the logger
+	envVars := loadEnv()
+
+	// Open the store, connecting to the database and applying the migrations if needed
+	store, err := openStore(envVars)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
-	// Defer the closing of the database connection
-	defer func(db *sql.DB) {
-		err := db.Close()
-		if err != nil {
-			log.Fatal(err)
+	// Collect the metrics if they are enabled, along with the statistics of the database connection pool
+	var collected *metrics.Metrics
+	if envVars.Metrics {
+		collected = metrics.New()
+
+		if sqlStore, isSQL := store.(*database.SQLStore); isSQL {
+			collected.DBStats = sqlStore.DB().Stats
 		}
-	}(dbase)
+	}
 
-	// Create the links table if it doesn't exist
-	err = database.CreateLinksTable(dbase, envVars.DBType, envVars.DefaultMaxLength)
-	if err != nil {
-		log.Panic(err)
+	// Keep the most recently accessed links in memory if the cache is enabled
+	if envVars.CacheSize > 0 {
+		cache := database.NewCachedStore(store, envVars.CacheSize)
+		store = cache
+
+		if collected != nil {
+			collected.CacheStats = cache.Stats
+		}
 	}
 
+	// Defer the closing of the store
+	defer func(store database.LinkStore) {
+		err := store.Close()
+		if err != nil {
+			slog.Error("Could not close the store", slog.Any("error", err))
+		}
+	}(store)
+
 	// Parse html templates and get the locales
 	var locales map[string]utils.PageLocaleTl
 	var supportedLocales map[string]bool
@@ -88,20 +129,20 @@ func main() { //nolint:funlen
 		http.Templates = template.Must(template.ParseGlob("custom_static/templates/*.tmpl"))
 		locales, supportedLocales, err = utils.GetLocales("custom_static/locales/", embeddedStatic)
 		if err != nil {
-			log.Panic(err)
+			return err
 		}
 	} else {
 		http.Templates = template.Must(template.ParseFS(embeddedStatic, "static/templates/*.tmpl"))
 		// Get locales and the list of supported ones
 		locales, supportedLocales, err = utils.GetLocales("", embeddedStatic)
 		if err != nil {
-			log.Panic(err)
+			return err
 		}
 	}
 
-	// Create a struct to connect to the database and send the instance name and url to the handlers
+	// Create a struct to access the store and send the instance name and url to the handlers
 	conf := &utils.Configuration{
-		DB:                     dbase,
+		Store:                  store,
 		AddrAndPort:            envVars.AddrAndPort,
 		InstanceName:           envVars.InstanceName,
 		InstanceURL:            envVars.InstanceURL,
@@ -114,25 +155,137 @@ func main() { //nolint:funlen
 		Version:                version,
 		SupportedLocales:       supportedLocales,
 		Locales:                locales,
+		Metrics:                collected,
+		MetricsToken:           envVars.MetricsToken,
+		GC:                     &utils.GCStatus{},
+		ShutdownTimeout:        time.Duration(envVars.ShutdownTimeout) * time.Second,
+		RedirectAddr:           envVars.RedirectAddr,
+		HSTSMaxAge:             envVars.HSTSMaxAge,
+		ReadTimeout:            time.Duration(envVars.ReadTimeout) * time.Second,
+		ReadHeaderTimeout:      time.Duration(envVars.ReadHeaderTimeout) * time.Second,
+		WriteTimeout:           time.Duration(envVars.WriteTimeout) * time.Second,
+		IdleTimeout:            time.Duration(envVars.IdleTimeout) * time.Second,
+		BatchTimeout:           time.Duration(envVars.BatchTimeout) * time.Second,
+		MaxHeaderBytes:         envVars.MaxHeaderBytes,
+		MaxBodyBytes:           int64(envVars.MaxBodyBytes),
+		MaxBatchBytes:          int64(envVars.MaxBatchBytes),
+		RestrictCreation:       envVars.RestrictCreation,
+		Accounts:               envVars.Accounts,
+		Registration:           envVars.Registration,
+		AdminRole:              envVars.OIDCAdminRole,
+		CreatorRole:            envVars.OIDCCreatorRole,
 	}
 
-	// Periodically clean the database
-	go func(duration time.Duration) {
-		for {
-			err := conf.CollectGarbage()
-			if err != nil {
-				log.Println("Could not collect garbage:", err)
-			}
-			time.Sleep(duration)
+	// Serve over HTTPS if a certificate is given or obtained using ACME
+	if envVars.TLSCertFile != "" || envVars.ACMEDomains != "" {
+		conf.TLS, err = certs.New(certs.Settings{
+			CertFile:     envVars.TLSCertFile,
+			KeyFile:      envVars.TLSKeyFile,
+			Domains:      certs.ParseDomains(envVars.ACMEDomains),
+			Email:        envVars.ACMEEmail,
+			DirectoryURL: envVars.ACMEDirectory,
+			DirectoryCA:  envVars.ACMEDirectoryCA,
+			CacheDir:     envVars.ACMECacheDir,
+		}, store)
+		if err != nil {
+			return err
 		}
-	}(time.Duration(envVars.TimeBetweenCleanups) * time.Minute)
+	}
+
+	// Trust the forwarding headers of the reverse proxies, the list was checked along with the configuration
+	conf.TrustedProxies, err = proxy.ParseTrusted(envVars.TrustedProxies)
+	if err != nil {
+		return err
+	}
+
+	// Limit how often a client can create links and try passwords, sharing the limits through the database if asked to
+	var limitStore ratelimit.Store = ratelimit.NewMemoryStore()
+	if envVars.RateLimitStore == "database" {
+		limitStore = ratelimit.NewDBStore(store)
+	}
+
+	conf.RateLimiter = ratelimit.New(limitStore, map[ratelimit.Route]ratelimit.Limit{
+		ratelimit.RouteCreate:   {PerMinute: envVars.RateLimitCreate, Burst: envVars.RateLimitCreateBurst},
+		ratelimit.RouteBatch:    {PerMinute: envVars.RateLimitBatch, Burst: envVars.RateLimitBatchBurst},
+		ratelimit.RoutePassword: {PerMinute: envVars.RateLimitPassword, Burst: envVars.RateLimitPasswordBurst},
+	})
+
+	// Record the accesses to links if analytics are enabled, flushing the pending ones on exit
+	if envVars.Analytics {
+		conf.Hits = analytics.NewRecorder(store)
+		defer conf.Hits.Close()
+	}
+
+	// Enable the admin dashboard if an admin password is set
+	if envVars.AdminPassword != "" || envVars.AdminPasswordHash != "" {
+		conf.Admin, err = admin.NewAuth(envVars.AdminPassword, envVars.AdminPasswordHash)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Log the users in using the OpenID Connect provider if one is set, its endpoints are discovered once
+	if envVars.OIDCIssuer != "" {
+		conf.OIDC, err = oidc.New(context.Background(), oidc.Settings{
+			Issuer:        envVars.OIDCIssuer,
+			ClientID:      envVars.OIDCClientID,
+			ClientSecret:  envVars.OIDCClientSecret,
+			RedirectURL:   strings.TrimSuffix(envVars.InstanceURL, "/") + "/account/oidc/callback",
+			Scopes:        strings.Fields(envVars.OIDCScopes),
+			UsernameClaim: envVars.OIDCUsernameClaim,
+			RolesClaim:    envVars.OIDCRolesClaim,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	// Stop on SIGINT and SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Periodically clean the database until the server stops
+	cleanerCtx, stopCleaner := context.WithCancel(ctx)
+
+	var cleaner sync.WaitGroup
+
+	cleaner.Add(1)
+
+	go func() {
+		defer cleaner.Done()
+
+		collectGarbagePeriodically(cleanerCtx, conf, time.Duration(envVars.TimeBetweenCleanups)*time.Minute)
+	}()
 
 	// Create an adapter for the server
 	httpAdapter := http.NewAdapter(*conf)
 
-	// Start the server
-	err = httpAdapter.Run()
-	if err != nil {
-		log.Panic(err)
+	// Start the server, it returns once the in-flight requests are drained
+	err = httpAdapter.Run(ctx)
+	stop()
+
+	// Stop the garbage collection before the pending hits are flushed and the store is closed by the deferred calls
+	stopCleaner()
+	cleaner.Wait()
+
+	return err
+}
+
+// collectGarbagePeriodically calls [utils.Configuration.CollectGarbage] right away, then once per period
+// until the context is done. A sweep in progress is not interrupted.
+func collectGarbagePeriodically(ctx context.Context, conf *utils.Configuration, period time.Duration) {
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+
+	for {
+		if err := conf.CollectGarbage(); err != nil {
+			slog.Error("Could not collect garbage", slog.Any("error", err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
 	}
 }
@@ -0,0 +1,215 @@
+//    reddlinks, a simple link shortener written in Go.
+//    Copyright (C) 2025 redd
+//
+//    This program is free software: you can redistribute it and/or modify
+//    it under the terms of the GNU General Public License as published by
+//    the Free Software Foundation, either version 3 of the License, or
+//    (at your option) any later version.
+//
+//    This program is distributed in the hope that it will be useful,
+//    but WITHOUT ANY WARRANTY; without even the implied warranty of
+//    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//    GNU General Public License for more details.
+//
+//    You should have received a copy of the GNU General Public License
+//    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package accounts_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redds-be/reddlinks/internal/accounts"
+	"github.com/redds-be/reddlinks/internal/database"
+	"github.com/redds-be/reddlinks/test/helper"
+)
+
+// newRequest returns a request giving a session cookie if its token isn't empty.
+func newRequest(token string) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/account", nil)
+	if token != "" {
+		req.AddCookie(&http.Cookie{Name: accounts.SessionCookie, Value: token})
+	}
+
+	return req
+}
+
+func (suite accountsTestSuite) TestNewUser() {
+	// Test if the username is normalized and the password hashed
+	user, err := accounts.NewUser(" Redd.Be ", "password")
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(user.Username, "redd.be")
+	suite.a.Assert(user.Password != "password", true)
+	suite.a.Assert(strings.HasPrefix(user.Password, "$argon2id$"), true)
+	suite.a.Assert(user.ID != uuid.Nil, true)
+
+	// Test with invalid usernames and passwords
+	for _, username := range []string{"ab", "redd be", "redd/be", strings.Repeat("a", 33)} {
+		_, err = accounts.NewUser(username, "password")
+		suite.a.AssertErrIs(err, accounts.ErrInvalidUsername)
+	}
+
+	_, err = accounts.NewUser("redd", "short")
+	suite.a.AssertErrIs(err, accounts.ErrInvalidPassword)
+
+	_, err = accounts.NewUser("redd", strings.Repeat("a", accounts.MaxPasswordLength+1))
+	suite.a.AssertErrIs(err, accounts.ErrInvalidPassword)
+}
+
+func (suite accountsTestSuite) TestRegisterAndLogin() {
+	store := database.NewMemoryStore()
+
+	// Test if a user can register only once, regardless of the case
+	user, err := accounts.Register(store, "redd", "password")
+	suite.a.AssertNoErr(err)
+
+	_, err = accounts.Register(store, "REDD", "otherpassword")
+	suite.a.AssertErrIs(err, database.ErrUsernameInUse)
+
+	// Test if the user can log in, regardless of the case of the username
+	found, err := accounts.Login(store, "Redd", "password")
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(found.ID, user.ID)
+
+	// Test with a wrong password and an unknown user
+	_, err = accounts.Login(store, "redd", "wrongpassword")
+	suite.a.AssertErrIs(err, accounts.ErrWrongCredentials)
+
+	_, err = accounts.Login(store, "unknown", "password")
+	suite.a.AssertErrIs(err, accounts.ErrWrongCredentials)
+}
+
+func (suite accountsTestSuite) TestSignIn() {
+	store := database.NewMemoryStore()
+
+	_, err := accounts.Register(store, "redd", "password")
+	suite.a.AssertNoErrf(err)
+
+	// Test if a user is created on its first sign-in, named after its preferred username
+	user, err := accounts.SignIn(store, "subject-1", "Jane", []string{"admin", "staff"})
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(user.Username, "jane")
+	suite.a.Assert(user.Roles, "admin,staff")
+	suite.a.Assert(accounts.HasRole(user, "admin"), true)
+	suite.a.Assert(accounts.HasRole(user, "creator"), false)
+	suite.a.Assert(accounts.HasRole(user, ""), false)
+
+	// Test if the same user is found again, with its new roles
+	again, err := accounts.SignIn(store, "subject-1", "renamed", nil)
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(again.ID, user.ID)
+	suite.a.Assert(again.Username, "jane")
+	suite.a.Assert(again.Roles, "")
+
+	stored, err := store.GetUserByID(user.ID)
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(stored.Roles, "")
+
+	// Test if a taken or invalid username is replaced by a name derived from the subject
+	other, err := accounts.SignIn(store, "subject-2", "redd", nil)
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(strings.HasPrefix(other.Username, "sso-"), true)
+
+	other, err = accounts.SignIn(store, "subject-3", "not valid", nil)
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(strings.HasPrefix(other.Username, "sso-"), true)
+
+	// Test if the users without a password can't log in with one
+	_, err = accounts.Login(store, "jane", "")
+	suite.a.AssertErrIs(err, accounts.ErrWrongCredentials)
+}
+
+func (suite accountsTestSuite) TestAuthenticate() {
+	store := database.NewMemoryStore()
+
+	user, err := accounts.Register(store, "redd", "password")
+	suite.a.AssertNoErrf(err)
+
+	token, session, err := accounts.NewSession(user.ID)
+	suite.a.AssertNoErrf(err)
+	suite.a.AssertNoErrf(store.CreateSession(session))
+
+	// Test if only the hash of the token is stored
+	suite.a.Assert(session.Hash, accounts.Hash(token))
+	suite.a.Assert(session.Hash != token, true)
+	suite.a.Assert(session.ExpireAt.After(time.Now().Add(24*time.Hour)), true)
+
+	// Test if the user of a session is found
+	found, err := accounts.Authenticate(store, newRequest(token))
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(found.ID, user.ID)
+
+	// Test without a cookie and with an unknown, expired or closed session
+	_, err = accounts.Authenticate(store, newRequest(""))
+	suite.a.AssertErrIs(err, accounts.ErrNoSession)
+
+	_, err = accounts.Authenticate(store, newRequest("unknown"))
+	suite.a.AssertErrIs(err, accounts.ErrNoSession)
+
+	expired, expiredSession, err := accounts.NewSession(user.ID)
+	suite.a.AssertNoErrf(err)
+
+	expiredSession.ExpireAt = time.Now().Add(-time.Minute)
+	suite.a.AssertNoErrf(store.CreateSession(expiredSession))
+
+	_, err = accounts.Authenticate(store, newRequest(expired))
+	suite.a.AssertErrIs(err, accounts.ErrNoSession)
+
+	suite.a.AssertNoErrf(store.DeleteSession(session.Hash))
+
+	_, err = accounts.Authenticate(store, newRequest(token))
+	suite.a.AssertErrIs(err, accounts.ErrNoSession)
+}
+
+func (suite accountsTestSuite) TestContext() {
+	user := database.User{ID: uuid.New(), Username: "redd"}
+
+	// Test if the user is found in the context
+	found, ok := accounts.FromContext(accounts.WithUser(context.Background(), user))
+	suite.a.Assert(ok, true)
+	suite.a.Assert(found.Username, "redd")
+
+	_, ok = accounts.FromContext(context.Background())
+	suite.a.Assert(ok, false)
+
+	// Test if the owner of the links is checked
+	req := newRequest("")
+	suite.a.Assert(accounts.Owner(req), uuid.Nil)
+	suite.a.Assert(accounts.Owns(req, database.Link{}), false)
+
+	req = req.WithContext(accounts.WithUser(req.Context(), user))
+	suite.a.Assert(accounts.Owner(req), user.ID)
+	suite.a.Assert(accounts.Owns(req, database.Link{Owner: user.ID}), true)
+	suite.a.Assert(accounts.Owns(req, database.Link{Owner: uuid.New()}), false)
+	suite.a.Assert(accounts.Owns(req, database.Link{}), false)
+}
+
+// Test suite structure.
+type accountsTestSuite struct {
+	t *testing.T
+	a helper.Adapter
+}
+
+func TestAccountsSuite(t *testing.T) {
+	// Enable parallelism
+	t.Parallel()
+
+	// Initialize the helper's adapter
+	assertHelper := helper.NewAdapter(t)
+
+	// Initialize the test suite
+	suite := accountsTestSuite{t: t, a: assertHelper}
+
+	// Call the tests
+	suite.TestNewUser()
+	suite.TestRegisterAndLogin()
+	suite.TestSignIn()
+	suite.TestAuthenticate()
+	suite.TestContext()
+}
@@ -0,0 +1,101 @@
+//    reddlinks, a simple link shortener written in Go.
+//    Copyright (C) 2025 redd
+//
+//    This program is free software: you can redistribute it and/or modify
+//    it under the terms of the GNU General Public License as published by
+//    the Free Software Foundation, either version 3 of the License, or
+//    (at your option) any later version.
+//
+//    This program is distributed in the hope that it will be useful,
+//    but WITHOUT ANY WARRANTY; without even the implied warranty of
+//    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//    GNU General Public License for more details.
+//
+//    You should have received a copy of the GNU General Public License
+//    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package admin_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/alexedwards/argon2id"
+	"github.com/redds-be/reddlinks/internal/admin"
+	"github.com/redds-be/reddlinks/test/helper"
+)
+
+func (suite adminTestSuite) TestNewAuth() {
+	// Test without any password
+	_, err := admin.NewAuth("", "")
+	suite.a.AssertErrIs(err, admin.ErrNoPassword)
+
+	// Test with an invalid hash
+	_, err = admin.NewAuth("", "notahash")
+	suite.a.AssertErr(err)
+
+	// Test with a plain password
+	auth, err := admin.NewAuth("secret", "")
+	suite.a.AssertNoErrf(err)
+
+	match, err := auth.CheckPassword("secret")
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(match, true)
+
+	match, err = auth.CheckPassword("wrong")
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(match, false)
+
+	// Test with a hash, which takes precedence over the password
+	hash, err := argon2id.CreateHash("hashed", argon2id.DefaultParams)
+	suite.a.AssertNoErrf(err)
+
+	auth, err = admin.NewAuth("ignored", hash)
+	suite.a.AssertNoErrf(err)
+
+	match, err = auth.CheckPassword("hashed")
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(match, true)
+}
+
+func (suite adminTestSuite) TestSessions() {
+	auth, err := admin.NewAuth("secret", "")
+	suite.a.AssertNoErrf(err)
+
+	// Test a valid session
+	session, _ := auth.NewSession()
+	suite.a.Assert(auth.ValidSession(session), true)
+
+	// Test invalid sessions
+	suite.a.Assert(auth.ValidSession(""), false)
+	suite.a.Assert(auth.ValidSession("nodot"), false)
+
+	expiry, signature, _ := strings.Cut(session, ".")
+	suite.a.Assert(auth.ValidSession("9"+expiry+"."+signature), false)
+
+	// Test that sessions are only valid for the authenticator that signed them
+	otherAuth, err := admin.NewAuth("secret", "")
+	suite.a.AssertNoErrf(err)
+	suite.a.Assert(otherAuth.ValidSession(session), false)
+}
+
+// Test suite structure.
+type adminTestSuite struct {
+	t *testing.T
+	a helper.Adapter
+}
+
+func TestAdminSuite(t *testing.T) {
+	// Enable parallelism
+	t.Parallel()
+
+	// Initialize the helper's adapter
+	assertHelper := helper.NewAdapter(t)
+
+	// Initialize the test suite
+	suite := adminTestSuite{t: t, a: assertHelper}
+
+	// Call the tests
+	suite.TestNewAuth()
+	suite.TestSessions()
+}
@@ -0,0 +1,124 @@
+//    reddlinks, a simple link shortener written in Go.
+//    Copyright (C) 2025 redd
+//
+//    This program is free software: you can redistribute it and/or modify
+//    it under the terms of the GNU General Public License as published by
+//    the Free Software Foundation, either version 3 of the License, or
+//    (at your option) any later version.
+//
+//    This program is distributed in the hope that it will be useful,
+//    but WITHOUT ANY WARRANTY; without even the implied warranty of
+//    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//    GNU General Public License for more details.
+//
+//    You should have received a copy of the GNU General Public License
+//    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package analytics_test
+
+import (
+	"errors"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redds-be/reddlinks/internal/analytics"
+	"github.com/redds-be/reddlinks/internal/database"
+	"github.com/redds-be/reddlinks/test/helper"
+)
+
+func (suite analyticsTestSuite) TestClassifyAgent() {
+	// Test the classification of common user agents
+	suite.a.Assert(analytics.ClassifyAgent(""), analytics.AgentOther)
+	suite.a.Assert(analytics.ClassifyAgent("curl/8.5.0"), analytics.AgentCLI)
+	suite.a.Assert(analytics.ClassifyAgent("Wget/1.21.4"), analytics.AgentCLI)
+	suite.a.Assert(analytics.ClassifyAgent(
+		"Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)"), analytics.AgentBot)
+	suite.a.Assert(analytics.ClassifyAgent(
+		"Mozilla/5.0 (X11; Linux x86_64; rv:128.0) Gecko/20100101 Firefox/128.0"), analytics.AgentBrowser)
+	suite.a.Assert(analytics.ClassifyAgent(
+		"Mozilla/5.0 (iPhone; CPU iPhone OS 17_5 like Mac OS X) AppleWebKit/605.1.15 Mobile/15E148"),
+		analytics.AgentMobile)
+	suite.a.Assert(analytics.ClassifyAgent("SomeUnknownClient/1.0"), analytics.AgentOther)
+}
+
+func (suite analyticsTestSuite) TestReferrerHost() {
+	// Test that only the host of the referrer is kept
+	suite.a.Assert(analytics.ReferrerHost(""), "")
+	suite.a.Assert(analytics.ReferrerHost("https://www.Example.com/some/path?query=secret"), "example.com")
+	suite.a.Assert(analytics.ReferrerHost("http://sub.example.org:8080/"), "sub.example.org")
+	suite.a.Assert(analytics.ReferrerHost("://invalid"), "")
+}
+
+func (suite analyticsTestSuite) TestRecorder() {
+	// If the test db already exists, delete it as it will cause errors
+	dbName := "analytics_test.db"
+	if _, err := os.Stat(dbName); !errors.Is(err, os.ErrNotExist) {
+		err = os.Remove(dbName)
+		suite.a.AssertNoErrf(err)
+	}
+
+	dataBase, err := database.DBConnect("sqlite", dbName, "", "", "", "", "")
+	suite.a.AssertNoErrf(err)
+
+	err = database.CreateLinksTable(dataBase, "sqlite", 12)
+	suite.a.AssertNoErrf(err)
+
+	store := database.NewSQLStore(dataBase, "sqlite")
+
+	err = store.CreateLink(database.Link{
+		ID:        uuid.New(),
+		CreatedAt: time.Now().UTC(),
+		ExpireAt:  time.Now().UTC().Add(time.Hour),
+		URL:       "http://example.com",
+		Short:     "recorded",
+	})
+	suite.a.AssertNoErrf(err)
+
+	// Record some accesses
+	recorder := analytics.NewRecorder(store)
+
+	req := httptest.NewRequest("GET", "/recorded", nil)
+	req.Header.Set("Referer", "https://www.example.org/page")
+	req.Header.Set("User-Agent", "curl/8.5.0")
+	recorder.Record(req, "recorded")
+	recorder.Record(httptest.NewRequest("GET", "/recorded", nil), "recorded")
+
+	// Test that the pending hits are flushed when closing the recorder
+	recorder.Close()
+
+	stats, err := store.GetHitStats("recorded")
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(stats.Total, 2)
+	suite.a.Assert(stats.Referrers["example.org"], 1)
+	suite.a.Assert(stats.Referrers[""], 1)
+	suite.a.Assert(stats.Agents[analytics.AgentCLI], 1)
+	suite.a.Assert(stats.Agents[analytics.AgentOther], 1)
+
+	// Test that closing twice doesn't panic
+	recorder.Close()
+}
+
+// Test suite structure.
+type analyticsTestSuite struct {
+	t *testing.T
+	a helper.Adapter
+}
+
+func TestAnalyticsSuite(t *testing.T) {
+	// Enable parallelism
+	t.Parallel()
+
+	// Initialize the helper's adapter
+	assertHelper := helper.NewAdapter(t)
+
+	// Initialize the test suite
+	suite := analyticsTestSuite{t: t, a: assertHelper}
+
+	// Call the tests
+	suite.TestClassifyAgent()
+	suite.TestReferrerHost()
+	suite.TestRecorder()
+}
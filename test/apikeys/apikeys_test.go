@@ -0,0 +1,179 @@
+//    reddlinks, a simple link shortener written in Go.
+//    Copyright (C) 2025 redd
+//
+//    This program is free software: you can redistribute it and/or modify
+//    it under the terms of the GNU General Public License as published by
+//    the Free Software Foundation, either version 3 of the License, or
+//    (at your option) any later version.
+//
+//    This program is distributed in the hope that it will be useful,
+//    but WITHOUT ANY WARRANTY; without even the implied warranty of
+//    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//    GNU General Public License for more details.
+//
+//    You should have received a copy of the GNU General Public License
+//    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package apikeys_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/redds-be/reddlinks/internal/apikeys"
+	"github.com/redds-be/reddlinks/internal/database"
+	"github.com/redds-be/reddlinks/test/helper"
+)
+
+// newRequest returns a request giving an Authorization header if it isn't empty.
+func newRequest(authorization string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+
+	return req
+}
+
+func (suite apikeysTestSuite) TestParseScopes() {
+	// Test if the spaces, the case, the empty items and the duplicates are ignored
+	scopes, err := apikeys.ParseScopes(" create,, Manage ,create,stats")
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(len(scopes), 3)
+	suite.a.Assert(scopes[0], apikeys.ScopeCreate)
+	suite.a.Assert(scopes[1], apikeys.ScopeManage)
+	suite.a.Assert(scopes[2], apikeys.ScopeStats)
+
+	// Test with unknown and missing scopes
+	_, err = apikeys.ParseScopes("create,delete")
+	suite.a.AssertErrIs(err, apikeys.ErrUnknownScope)
+
+	_, err = apikeys.ParseScopes(" , ")
+	suite.a.AssertErrIs(err, apikeys.ErrNoScope)
+}
+
+func (suite apikeysTestSuite) TestGenerate() {
+	expireAt := time.Now().Add(time.Hour)
+
+	// Test if the stored key matches the plain key
+	plain, key, err := apikeys.Generate(" ci ", []apikeys.Scope{apikeys.ScopeCreate, apikeys.ScopeAdmin}, expireAt)
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(strings.HasPrefix(plain, apikeys.KeyPrefix), true)
+	suite.a.Assert(strings.HasPrefix(plain, key.Prefix), true)
+	suite.a.Assert(key.Prefix != plain, true)
+	suite.a.Assert(key.Hash, apikeys.Hash(plain))
+	suite.a.Assert(key.Label, "ci")
+	suite.a.Assert(key.Scopes, "create,admin")
+	suite.a.Assert(key.ExpireAt.Equal(expireAt), true)
+
+	// Test if the keys are random
+	other, _, err := apikeys.Generate("ci", []apikeys.Scope{apikeys.ScopeCreate}, time.Time{})
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(other != plain, true)
+
+	// Test the scopes of the key
+	suite.a.Assert(apikeys.Has(key, apikeys.ScopeCreate), true)
+	suite.a.Assert(apikeys.Has(key, apikeys.ScopeAdmin), true)
+	suite.a.Assert(apikeys.Has(key, apikeys.ScopeManage), false)
+
+	// Test the expiration of the key
+	suite.a.Assert(apikeys.Expired(key, time.Now()), false)
+	suite.a.Assert(apikeys.Expired(key, expireAt), true)
+	suite.a.Assert(apikeys.Expired(database.APIKey{}, time.Now()), false)
+
+	// Test with an invalid label and without scopes
+	_, _, err = apikeys.Generate(" ", []apikeys.Scope{apikeys.ScopeCreate}, time.Time{})
+	suite.a.AssertErrIs(err, apikeys.ErrInvalidLabel)
+
+	_, _, err = apikeys.Generate(strings.Repeat("a", apikeys.MaxLabelLength+1), []apikeys.Scope{apikeys.ScopeCreate}, time.Time{})
+	suite.a.AssertErrIs(err, apikeys.ErrInvalidLabel)
+
+	_, _, err = apikeys.Generate("ci", nil, time.Time{})
+	suite.a.AssertErrIs(err, apikeys.ErrNoScope)
+}
+
+func (suite apikeysTestSuite) TestAuthenticate() {
+	store := database.NewMemoryStore()
+
+	valid, key, err := apikeys.Generate("ci", []apikeys.Scope{apikeys.ScopeCreate}, time.Time{})
+	suite.a.AssertNoErrf(err)
+	suite.a.AssertNoErrf(store.CreateAPIKey(key))
+
+	expired, expiredKey, err := apikeys.Generate("old", []apikeys.Scope{apikeys.ScopeCreate}, time.Now().Add(-time.Minute))
+	suite.a.AssertNoErrf(err)
+	suite.a.AssertNoErrf(store.CreateAPIKey(expiredKey))
+
+	// Test if a stored key is found
+	found, err := apikeys.Authenticate(store, newRequest("Bearer "+valid))
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(found.ID, key.ID)
+
+	// Test if the requests without a key, or with another kind of token, are anonymous
+	_, err = apikeys.Authenticate(store, newRequest(""))
+	suite.a.AssertErrIs(err, apikeys.ErrNoKey)
+
+	_, err = apikeys.Authenticate(store, newRequest("Bearer metrics-token"))
+	suite.a.AssertErrIs(err, apikeys.ErrNoKey)
+
+	_, err = apikeys.Authenticate(store, newRequest("Basic "+valid))
+	suite.a.AssertErrIs(err, apikeys.ErrNoKey)
+
+	// Test with unknown, expired and revoked keys
+	_, err = apikeys.Authenticate(store, newRequest("Bearer "+apikeys.KeyPrefix+"unknown"))
+	suite.a.AssertErrIs(err, apikeys.ErrInvalidKey)
+
+	_, err = apikeys.Authenticate(store, newRequest("Bearer "+expired))
+	suite.a.AssertErrIs(err, apikeys.ErrExpiredKey)
+
+	suite.a.AssertNoErrf(store.DeleteAPIKey(key.ID))
+
+	_, err = apikeys.Authenticate(store, newRequest("Bearer "+valid))
+	suite.a.AssertErrIs(err, apikeys.ErrInvalidKey)
+}
+
+func (suite apikeysTestSuite) TestContext() {
+	key := database.APIKey{Scopes: "manage,stats"}
+
+	// Test if the key is found in the context
+	found, ok := apikeys.FromContext(apikeys.WithKey(context.Background(), key))
+	suite.a.Assert(ok, true)
+	suite.a.Assert(found.Scopes, "manage,stats")
+
+	_, ok = apikeys.FromContext(context.Background())
+	suite.a.Assert(ok, false)
+
+	// Test if the scopes of the key of a request are checked
+	req := newRequest("")
+	suite.a.Assert(apikeys.Allows(req, apikeys.ScopeManage), false)
+
+	req = req.WithContext(apikeys.WithKey(req.Context(), key))
+	suite.a.Assert(apikeys.Allows(req, apikeys.ScopeManage), true)
+	suite.a.Assert(apikeys.Allows(req, apikeys.ScopeCreate), false)
+}
+
+// Test suite structure.
+type apikeysTestSuite struct {
+	t *testing.T
+	a helper.Adapter
+}
+
+func TestAPIKeysSuite(t *testing.T) {
+	// Enable parallelism
+	t.Parallel()
+
+	// Initialize the helper's adapter
+	assertHelper := helper.NewAdapter(t)
+
+	// Initialize the test suite
+	suite := apikeysTestSuite{t: t, a: assertHelper}
+
+	// Call the tests
+	suite.TestParseScopes()
+	suite.TestGenerate()
+	suite.TestAuthenticate()
+	suite.TestContext()
+}
@@ -0,0 +1,288 @@
+//    reddlinks, a simple link shortener written in Go.
+//    Copyright (C) 2025 redd
+//
+//    This program is free software: you can redistribute it and/or modify
+//    it under the terms of the GNU General Public License as published by
+//    the Free Software Foundation, either version 3 of the License, or
+//    (at your option) any later version.
+//
+//    This program is distributed in the hope that it will be useful,
+//    but WITHOUT ANY WARRANTY; without even the implied warranty of
+//    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//    GNU General Public License for more details.
+//
+//    You should have received a copy of the GNU General Public License
+//    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package archive_test
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redds-be/reddlinks/internal/archive"
+	"github.com/redds-be/reddlinks/internal/database"
+	"github.com/redds-be/reddlinks/test/helper"
+)
+
+func (suite archiveTestSuite) TestExportImport() { //nolint:funlen
+	// Prepare a store with a protected link and an expired one
+	source := database.NewMemoryStore()
+	createdAt := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	protected := database.Link{
+		ID:        uuid.New(),
+		CreatedAt: createdAt,
+		ExpireAt:  createdAt.Add(24 * time.Hour * 365 * 100),
+		URL:       "http://example.com/",
+		Short:     "protected",
+		Password:  "passwordhash",
+		Token:     "tokenhash",
+		Owner:     uuid.New(),
+	}
+
+	for _, link := range []database.Link{
+		protected,
+		{ID: uuid.New(), CreatedAt: createdAt, ExpireAt: createdAt, URL: "http://example.org/", Short: "expired"},
+	} {
+		err := source.CreateLink(link)
+		suite.a.AssertNoErr(err)
+	}
+
+	// Test the export
+	var buffer bytes.Buffer
+	exported, err := archive.Export(source, &buffer)
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(exported, 2)
+
+	lines := strings.Split(strings.TrimSpace(buffer.String()), "\n")
+	suite.a.Assert(len(lines), 3)
+	suite.a.Assert(strings.Contains(lines[0], `"version":2`), true)
+
+	// Test the import into an empty store, the hashes must be kept as is
+	destination := database.NewMemoryStore()
+	stats, err := archive.Import(destination, bytes.NewReader(buffer.Bytes()), archive.ConflictFail)
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(stats, archive.ImportStats{Imported: 2})
+
+	links, _, err := destination.ListLinks(database.LinkFilter{Short: "protected"})
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(len(links), 1)
+	suite.a.Assert(links[0].ID, protected.ID)
+	suite.a.Assert(links[0].CreatedAt.Equal(protected.CreatedAt), true)
+	suite.a.Assert(links[0].ExpireAt.Equal(protected.ExpireAt), true)
+	suite.a.Assert(links[0].Password, protected.Password)
+	suite.a.Assert(links[0].Token, protected.Token)
+	suite.a.Assert(links[0].Owner, protected.Owner)
+
+	links, _, err = destination.ListLinks(database.LinkFilter{Short: "expired"})
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(len(links), 1)
+	suite.a.Assert(links[0].Owner, uuid.Nil)
+
+	// Test that nothing is imported on conflict with the fail mode
+	err = destination.UpdateLink("protected", "http://example.net/", protected.ExpireAt, "")
+	suite.a.AssertNoErr(err)
+
+	err = destination.DeleteLink("expired")
+	suite.a.AssertNoErr(err)
+
+	_, err = archive.Import(destination, bytes.NewReader(buffer.Bytes()), archive.ConflictFail)
+	suite.a.AssertErrIs(err, archive.ErrConflict)
+
+	_, err = destination.GetURLByShort("expired")
+	suite.a.AssertErrIs(err, sql.ErrNoRows)
+
+	// Test that existing links are kept with the skip mode
+	stats, err = archive.Import(destination, bytes.NewReader(buffer.Bytes()), archive.ConflictSkip)
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(stats, archive.ImportStats{Imported: 1, Skipped: 1})
+
+	URL, err := destination.GetURLByShort("protected")
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(URL, "http://example.net/")
+
+	// Test that existing links are replaced with the overwrite mode
+	stats, err = archive.Import(destination, bytes.NewReader(buffer.Bytes()), archive.ConflictOverwrite)
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(stats, archive.ImportStats{Overwritten: 2})
+
+	URL, err = destination.GetURLByShort("protected")
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(URL, protected.URL)
+}
+
+func (suite archiveTestSuite) TestVersion1() {
+	store := database.NewMemoryStore()
+
+	// Test if the archives written before the owners were exported can still be imported, as anonymous links
+	version1 := `{"format":"reddlinks","version":1}` + "\n" +
+		`{"id":"` + uuid.NewString() + `","short":"old","url":"http://example.com/","token":"tokenhash"}` + "\n"
+
+	stats, err := archive.Import(store, strings.NewReader(version1), archive.ConflictFail)
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(stats, archive.ImportStats{Imported: 1})
+
+	links, _, err := store.ListLinks(database.LinkFilter{Short: "old"})
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(len(links), 1)
+	suite.a.Assert(links[0].Token, "tokenhash")
+	suite.a.Assert(links[0].Owner, uuid.Nil)
+}
+
+func (suite archiveTestSuite) TestFailedOverwrite() {
+	store, err := database.OpenStore("sqlite", filepath.Join(suite.t.TempDir(), "archive.db"), "", "", "", "", "", 12)
+	suite.a.AssertNoErrf(err)
+
+	defer store.Close()
+
+	createdAt := time.Now().UTC()
+	taken := uuid.New()
+
+	for _, link := range []database.Link{
+		{ID: uuid.New(), CreatedAt: createdAt, ExpireAt: createdAt.Add(time.Hour), URL: "http://example.com/", Short: "existing"},
+		{ID: taken, CreatedAt: createdAt, ExpireAt: createdAt.Add(time.Hour), URL: "http://example.org/", Short: "taken"},
+	} {
+		err = store.CreateLink(link)
+		suite.a.AssertNoErrf(err)
+	}
+
+	// The second link can't be inserted as its ID is already used, the link it would have replaced must be kept
+	conflicting := `{"format":"reddlinks","version":1}` + "\n" +
+		`{"id":"` + uuid.NewString() + `","short":"existing","url":"http://example.net/"}` + "\n" +
+		`{"id":"` + taken.String() + `","short":"other","url":"http://example.net/"}` + "\n"
+
+	_, err = archive.Import(store, strings.NewReader(conflicting), archive.ConflictOverwrite)
+	suite.a.AssertErr(err)
+
+	URL, err := store.GetURLByShort("existing")
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(URL, "http://example.com/")
+
+	_, err = store.GetURLByShort("other")
+	suite.a.AssertErrIs(err, sql.ErrNoRows)
+}
+
+// growingStore creates a new link, the most recent one, each time the links are listed.
+type growingStore struct {
+	database.LinkStore
+
+	created int
+}
+
+func (store *growingStore) ListLinks(filter database.LinkFilter) ([]database.Link, int, error) {
+	store.created++
+
+	now := time.Now().UTC()
+
+	err := store.CreateLink(database.Link{
+		ID:        uuid.New(),
+		CreatedAt: now,
+		ExpireAt:  now.Add(time.Hour),
+		URL:       "http://example.net/",
+		Short:     fmt.Sprintf("new%d", store.created),
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return store.LinkStore.ListLinks(filter)
+}
+
+func (suite archiveTestSuite) TestExportDuringCreation() {
+	source, err := database.OpenStore("sqlite", filepath.Join(suite.t.TempDir(), "export.db"), "", "", "", "", "", 12)
+	suite.a.AssertNoErrf(err)
+
+	defer source.Close()
+
+	// Prepare more links than a page, older than the ones created during the export
+	createdAt := time.Now().UTC().Add(-time.Hour)
+	links := make([]database.Link, 0, 2500)
+
+	for index := range cap(links) {
+		links = append(links, database.Link{
+			ID:        uuid.New(),
+			CreatedAt: createdAt,
+			ExpireAt:  createdAt.Add(24 * time.Hour),
+			URL:       "http://example.com/",
+			Short:     fmt.Sprintf("link%04d", index),
+		})
+	}
+
+	err = source.CreateLinks(links)
+	suite.a.AssertNoErrf(err)
+
+	// Test if the links created while the pages are read don't shift them
+	var buffer bytes.Buffer
+	exported, err := archive.Export(&growingStore{LinkStore: source}, &buffer)
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(exported >= len(links), true)
+
+	// The archive must not contain the same short twice, nor miss one of the existing links
+	target := database.NewMemoryStore()
+	stats, err := archive.Import(target, bytes.NewReader(buffer.Bytes()), archive.ConflictFail)
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(stats.Imported, exported)
+
+	for _, link := range links {
+		_, err = target.GetURLByShort(link.Short)
+		suite.a.AssertNoErr(err)
+	}
+}
+
+func (suite archiveTestSuite) TestInvalidArchives() {
+	store := database.NewMemoryStore()
+
+	// Test an archive without header
+	_, err := archive.Import(store, strings.NewReader(`{"short":"a"}`+"\n"), archive.ConflictFail)
+	suite.a.AssertErrIs(err, archive.ErrInvalidArchive)
+
+	// Test an archive written by a newer version of the format
+	_, err = archive.Import(store, strings.NewReader(`{"format":"reddlinks","version":99}`+"\n"), archive.ConflictFail)
+	suite.a.AssertErrIs(err, archive.ErrUnsupportedVersion)
+
+	// Test an archive with an invalid record, nothing must be imported
+	invalid := `{"format":"reddlinks","version":1}` + "\n" +
+		`{"id":"` + uuid.NewString() + `","short":"valid","url":"http://example.com/"}` + "\n" +
+		`{"id":"` + uuid.NewString() + `","short":"nourl"}` + "\n"
+
+	_, err = archive.Import(store, strings.NewReader(invalid), archive.ConflictFail)
+	suite.a.AssertErrIs(err, archive.ErrInvalidArchive)
+
+	_, err = store.GetURLByShort("valid")
+	suite.a.AssertErrIs(err, sql.ErrNoRows)
+
+	// Test an unknown conflict mode
+	_, err = archive.ParseConflictMode("merge")
+	suite.a.AssertErrIs(err, archive.ErrUnknownConflictMode)
+}
+
+// Test suite structure.
+type archiveTestSuite struct {
+	t *testing.T
+	a helper.Adapter
+}
+
+func TestArchiveSuite(t *testing.T) {
+	// Enable parallelism
+	t.Parallel()
+
+	// Initialize the helper's adapter
+	assertHelper := helper.NewAdapter(t)
+
+	// Initialize the test suite
+	suite := archiveTestSuite{t: t, a: assertHelper}
+
+	// Call the tests
+	suite.TestExportImport()
+	suite.TestVersion1()
+	suite.TestFailedOverwrite()
+	suite.TestExportDuringCreation()
+	suite.TestInvalidArchives()
+}
@@ -0,0 +1,258 @@
+//    reddlinks, a simple link shortener written in Go.
+//    Copyright (C) 2025 redd
+//
+//    This program is free software: you can redistribute it and/or modify
+//    it under the terms of the GNU General Public License as published by
+//    the Free Software Foundation, either version 3 of the License, or
+//    (at your option) any later version.
+//
+//    This program is distributed in the hope that it will be useful,
+//    but WITHOUT ANY WARRANTY; without even the implied warranty of
+//    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//    GNU General Public License for more details.
+//
+//    You should have received a copy of the GNU General Public License
+//    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package certs_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/redds-be/reddlinks/internal/certs"
+	"github.com/redds-be/reddlinks/internal/database"
+	"github.com/redds-be/reddlinks/test/helper"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// writeSelfSigned writes a self-signed certificate and its key as PEM files in a directory.
+func (suite certsTestSuite) writeSelfSigned(dir string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	suite.a.AssertNoErrf(err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	suite.a.AssertNoErrf(err)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	suite.a.AssertNoErrf(err)
+
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+
+	err = os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600)
+	suite.a.AssertNoErrf(err)
+	err = os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600)
+	suite.a.AssertNoErrf(err)
+
+	return certFile, keyFile
+}
+
+func (suite certsTestSuite) TestCache() {
+	cache := certs.Cache{Store: database.NewMemoryStore()}
+	ctx := context.Background()
+
+	// Test if a missing entry is a cache miss
+	_, err := cache.Get(ctx, "example.com")
+	suite.a.AssertErrIs(err, autocert.ErrCacheMiss)
+
+	// Test if an entry can be stored, replaced and deleted
+	err = cache.Put(ctx, "example.com", []byte("first"))
+	suite.a.AssertNoErr(err)
+
+	err = cache.Put(ctx, "example.com", []byte("second"))
+	suite.a.AssertNoErr(err)
+
+	data, err := cache.Get(ctx, "example.com")
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(string(data), "second")
+
+	err = cache.Delete(ctx, "example.com")
+	suite.a.AssertNoErr(err)
+
+	_, err = cache.Get(ctx, "example.com")
+	suite.a.AssertErrIs(err, autocert.ErrCacheMiss)
+
+	// Test if a cancelled context is honored
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+
+	_, err = cache.Get(cancelled, "example.com")
+	suite.a.AssertErrIs(err, context.Canceled)
+}
+
+func (suite certsTestSuite) TestNew() {
+	dir := suite.t.TempDir()
+	certFile, keyFile := suite.writeSelfSigned(dir)
+
+	// Test the loading of certificate files
+	config, err := certs.New(certs.Settings{CertFile: certFile, KeyFile: keyFile}, database.NewMemoryStore())
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(len(config.Config.Certificates), 1)
+
+	// Test if the challenge handler falls back to the given one without ACME
+	fallback := http.NotFoundHandler()
+	resp := httptest.NewRecorder()
+	config.ChallengeHandler(fallback).ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/.well-known/acme-challenge/token", nil))
+	suite.a.Assert(resp.Code, http.StatusNotFound)
+
+	// Test with missing files
+	_, err = certs.New(certs.Settings{CertFile: filepath.Join(dir, "missing.pem"), KeyFile: keyFile}, nil)
+	suite.a.AssertErrIs(err, os.ErrNotExist)
+
+	// Test without any certificate source
+	_, err = certs.New(certs.Settings{}, nil)
+	suite.a.AssertErrIs(err, certs.ErrNoCertificate)
+
+	// Test with a CA file which doesn't contain any certificate
+	_, err = certs.New(certs.Settings{
+		Domains:      []string{"example.com"},
+		DirectoryURL: "https://127.0.0.1/directory",
+		DirectoryCA:  keyFile,
+	}, nil)
+	suite.a.AssertErrIs(err, certs.ErrInvalidCA)
+}
+
+func (suite certsTestSuite) TestACME() { //nolint:funlen
+	// Listen before starting the mock directory, which checks the challenges on the listener
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	suite.a.AssertNoErrf(err)
+
+	directory := helper.NewMockACME(suite.t, listener.Addr().String())
+
+	// Trust the certificate of the mock directory
+	caFile := filepath.Join(suite.t.TempDir(), "ca.pem")
+	err = os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: directory.Server.Certificate().Raw}), 0o600)
+	suite.a.AssertNoErrf(err)
+
+	store := database.NewMemoryStore()
+
+	config, err := certs.New(certs.Settings{
+		Domains:      []string{"example.com"},
+		DirectoryURL: directory.URL(),
+		DirectoryCA:  caFile,
+	}, store)
+	suite.a.AssertNoErrf(err)
+
+	// Serve HTTPS on the listener like the server does
+	server := &http.Server{Handler: http.NotFoundHandler(), TLSConfig: config.Config, ReadHeaderTimeout: time.Second}
+
+	go func() { _ = server.ServeTLS(listener, "", "") }()
+	defer server.Close()
+
+	// Test if the tls-alpn-01 challenges are accepted
+	suite.a.Assert(config.Config.NextProtos[len(config.Config.NextProtos)-1], "acme-tls/1")
+
+	// Test if a certificate isn't requested for a domain which isn't allowed
+	_, err = config.Config.GetCertificate(&tls.ClientHelloInfo{ServerName: "other.example.org"})
+	suite.a.AssertErr(err)
+	suite.a.Assert(directory.Requests(), 0)
+
+	// Test if a certificate is obtained from the directory on the first handshake for an allowed domain
+	roots := x509.NewCertPool()
+	roots.AddCert(directory.CA)
+
+	conn, err := tls.Dial("tcp", listener.Addr().String(), &tls.Config{MinVersion: tls.VersionTLS12, ServerName: "example.com", RootCAs: roots})
+	suite.a.AssertNoErrf(err)
+
+	served := conn.ConnectionState().PeerCertificates[0]
+	suite.a.AssertNoErr(served.VerifyHostname("example.com"))
+	suite.a.AssertNoErr(conn.Close())
+
+	// Test if the ACME account key and the certificate are cached in the store
+	_, err = store.GetCert("acme_account+key")
+	suite.a.AssertNoErr(err)
+
+	cached, err := store.GetCert("example.com")
+	suite.a.AssertNoErrf(err)
+	suite.a.Assert(bytes.Contains(cached, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: served.Raw})), true)
+
+	// Test if the certificate is served again without asking the directory
+	requests := directory.Requests()
+
+	conn, err = tls.Dial("tcp", listener.Addr().String(), &tls.Config{MinVersion: tls.VersionTLS12, ServerName: "example.com", RootCAs: roots})
+	suite.a.AssertNoErrf(err)
+	suite.a.Assert(conn.ConnectionState().PeerCertificates[0].Equal(served), true)
+	suite.a.AssertNoErr(conn.Close())
+	suite.a.Assert(directory.Requests(), requests)
+
+	// Test if the http-01 challenges are answered for unknown tokens, other requests go to the fallback handler
+	handler := config.ChallengeHandler(certs.RedirectToHTTPS("443"))
+
+	resp := httptest.NewRecorder()
+	handler.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "http://example.com/.well-known/acme-challenge/token", nil))
+	suite.a.Assert(resp.Code, http.StatusNotFound)
+
+	resp = httptest.NewRecorder()
+	handler.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "http://example.com/short", nil))
+	suite.a.Assert(resp.Code, http.StatusPermanentRedirect)
+}
+
+func (suite certsTestSuite) TestParseDomains() {
+	// Test if the spaces and the empty items are ignored
+	domains := certs.ParseDomains(" ls.example.com,, www.example.com ,")
+	suite.a.Assert(len(domains), 2)
+	suite.a.Assert(domains[0], "ls.example.com")
+	suite.a.Assert(domains[1], "www.example.com")
+
+	suite.a.Assert(len(certs.ParseDomains("")), 0)
+}
+
+func (suite certsTestSuite) TestRedirectToHTTPS() {
+	// Test the redirection to the default HTTPS port
+	resp := httptest.NewRecorder()
+	certs.RedirectToHTTPS("443").ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "http://example.com:80/short?lang=fr", nil))
+	suite.a.Assert(resp.Code, http.StatusPermanentRedirect)
+	suite.a.Assert(resp.Header().Get("Location"), "https://example.com/short?lang=fr")
+
+	// Test the redirection to another HTTPS port
+	resp = httptest.NewRecorder()
+	certs.RedirectToHTTPS("8443").ServeHTTP(resp, httptest.NewRequest(http.MethodPost, "http://example.com:8080/", nil))
+	suite.a.Assert(resp.Code, http.StatusPermanentRedirect)
+	suite.a.Assert(resp.Header().Get("Location"), "https://example.com:8443/")
+}
+
+// Test suite structure.
+type certsTestSuite struct {
+	t *testing.T
+	a helper.Adapter
+}
+
+func TestCertsSuite(t *testing.T) {
+	// Enable parallelism
+	t.Parallel()
+
+	// Initialize the helper's adapter
+	assertHelper := helper.NewAdapter(t)
+
+	// Initialize the test suite
+	suite := certsTestSuite{t: t, a: assertHelper}
+
+	// Call the tests
+	suite.TestCache()
+	suite.TestNew()
+	suite.TestACME()
+	suite.TestParseDomains()
+	suite.TestRedirectToHTTPS()
+}
@@ -17,6 +17,11 @@
 package database_test
 
 import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -57,63 +62,680 @@ func (suite dbTestSuite) TestDB() { //nolint:funlen
 	err = database.CreateLinksTable(dataBase, testEnv.DBType, testEnv.DefaultMaxLength)
 	suite.a.AssertNoErr(err)
 
+	// Testing the SQL store
+	suite.testStore(database.NewSQLStore(dataBase, testEnv.DBType))
+}
+
+func (suite dbTestSuite) TestMemoryStore() {
+	// Testing the opening of a memory store, no connection parameter is needed
+	store, err := database.OpenStore("memory", "", "", "", "", "", "", 12)
+	suite.a.AssertNoErr(err)
+
+	// Testing the memory store
+	suite.testStore(store)
+
+	err = store.Close()
+	suite.a.AssertNoErr(err)
+}
+
+func (suite dbTestSuite) TestBoltStore() {
+	// The store is kept in a temporary directory, removed along with the test
+	dbName := filepath.Join(suite.t.TempDir(), "db_test.bolt")
+
+	// Testing the opening of a bolt store, only the file is needed
+	store, err := database.OpenStore("bolt", dbName, "", "", "", "", "", 12)
+	suite.a.AssertNoErr(err)
+
+	// Testing the bolt store
+	suite.testStore(store)
+
+	err = store.CreateLink(database.Link{
+		ID:        uuid.New(),
+		CreatedAt: time.Now().UTC(),
+		ExpireAt:  time.Now().UTC().Add(time.Hour),
+		URL:       "http://example.com/kept",
+		Short:     "kept",
+	})
+	suite.a.AssertNoErr(err)
+
+	err = store.Close()
+	suite.a.AssertNoErr(err)
+
+	// Testing that the links are still there once the store is reopened
+	store, err = database.OpenStore("bolt", dbName, "", "", "", "", "", 12)
+	suite.a.AssertNoErr(err)
+
+	URL, err := store.GetURLByShort("kept")
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(URL, "http://example.com/kept")
+
+	err = store.Close()
+	suite.a.AssertNoErr(err)
+}
+
+func (suite dbTestSuite) TestCachedStore() { //nolint:funlen
+	// Testing that the cache behaves like the store behind it
+	suite.testStore(database.NewCachedStore(database.NewMemoryStore(), 2))
+
+	store := database.NewMemoryStore()
+	cache := database.NewCachedStore(store, 2)
+
+	for _, short := range []string{"first", "second", "third"} {
+		err := cache.CreateLink(database.Link{
+			ID:        uuid.New(),
+			CreatedAt: time.Now().UTC(),
+			ExpireAt:  time.Now().UTC().Add(time.Hour),
+			URL:       "http://example.com/" + short,
+			Short:     short,
+			Password:  "hash",
+		})
+		suite.a.AssertNoErr(err)
+	}
+
+	// Testing a miss followed by hits
+	link, err := cache.GetLinkByShort("first")
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(link.URL, "http://example.com/first")
+	suite.a.Assert(link.Password, "hash")
+
+	for range 2 {
+		_, err = cache.GetLinkByShort("first")
+		suite.a.AssertNoErr(err)
+	}
+
+	stats := cache.Stats()
+	suite.a.Assert(stats.Misses, uint64(1))
+	suite.a.Assert(stats.Hits, uint64(2))
+	suite.a.Assert(stats.Size, 1)
+	suite.a.Assert(stats.Capacity, 2)
+
+	// Testing that the least recently used link is evicted
+	for _, short := range []string{"second", "first", "third"} {
+		_, err = cache.GetLinkByShort(short)
+		suite.a.AssertNoErr(err)
+	}
+
+	stats = cache.Stats()
+	suite.a.Assert(stats.Size, 2)
+
+	_, err = cache.GetLinkByShort("second")
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(cache.Stats().Misses, stats.Misses+1)
+
+	// Testing that an update drops the entry
+	err = cache.UpdateLink("second", "http://example.org/", time.Now().UTC().Add(time.Hour), "")
+	suite.a.AssertNoErr(err)
+
+	link, err = cache.GetLinkByShort("second")
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(link.URL, "http://example.org/")
+
+	// Testing that a deletion drops the entry
+	err = cache.DeleteLink("second")
+	suite.a.AssertNoErr(err)
+
+	_, err = cache.GetLinkByShort("second")
+	suite.a.AssertErrIs(err, sql.ErrNoRows)
+
+	// Testing that expired entries aren't served
+	_, err = cache.GetLinkByShort("third")
+	suite.a.AssertNoErr(err)
+
+	_, err = cache.ExpireLinks([]string{"third"}, time.Now().UTC())
+	suite.a.AssertNoErr(err)
+
+	_, err = cache.GetLinkByShort("third")
+	suite.a.AssertErrIs(err, sql.ErrNoRows)
+
+	// Testing that the garbage collection drops the expired entries
+	removed, err := cache.RemoveExpiredLinks()
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(removed, int64(1))
+
+	_, err = store.GetURLByShort("third")
+	suite.a.AssertErrIs(err, sql.ErrNoRows)
+}
+
+func (suite dbTestSuite) TestMySQLStore() {
+	// The tests run against an embedded MySQL-compatible server, unless the connection string of a real one is given
+	dsn := os.Getenv("REDDLINKS_TEST_MYSQL_DSN")
+	if dsn == "" {
+		dsn = helper.NewMockMySQL(suite.t)
+	}
+
+	dataBase, err := database.DBConnect("mysql", dsn, "", "", "", "", "")
+	suite.a.AssertNoErrf(err)
+
+	// Start from an empty database
+	_, err = dataBase.Exec("DROP TABLE IF EXISTS sessions, users, api_keys, rate_limits, cert_cache, link_hits, links, schema_migrations;")
+	suite.a.AssertNoErrf(err)
+
+	// Testing the creation of the links table
+	err = database.CreateLinksTable(dataBase, "mysql", 12)
+	suite.a.AssertNoErr(err)
+
+	// Testing the MySQL store
+	suite.testStore(database.NewSQLStore(dataBase, "mysql"))
+
+	// Testing the resizing of the short column
+	err = database.CreateLinksTable(dataBase, "mysql", 24)
+	suite.a.AssertNoErr(err)
+
+	err = dataBase.Close()
+	suite.a.AssertNoErr(err)
+}
+
+// testStore runs the same tests against any implementation of [database.LinkStore].
+func (suite dbTestSuite) testStore(store database.LinkStore) { //nolint:funlen,maintidx
+	// Testing that the store can be reached
+	err := store.Ping(context.Background())
+	suite.a.AssertNoErr(err)
+
 	// Testing the creation of a link entry
-	err = database.CreateLink(
-		dataBase,
-		uuid.New(),
-		time.Now().UTC(),
-		time.Now().UTC(),
-		"http://example.com",
-		"custom",
-		"pass",
-	)
+	err = store.CreateLink(database.Link{
+		ID:        uuid.New(),
+		CreatedAt: time.Now().UTC(),
+		ExpireAt:  time.Now().UTC(),
+		URL:       "http://example.com",
+		Short:     "custom",
+		Password:  "pass",
+		Token:     "token",
+	})
 	suite.a.AssertNoErr(err)
 
 	// Testing the creation of a link entry that will cause an error
-	err = database.CreateLink(
-		dataBase,
-		uuid.New(),
-		time.Now().UTC(),
-		time.Now().UTC(),
-		"http://example.com",
-		"custom",
-		"pass",
-	)
+	err = store.CreateLink(database.Link{
+		ID:        uuid.New(),
+		CreatedAt: time.Now().UTC(),
+		ExpireAt:  time.Now().UTC(),
+		URL:       "http://example.com",
+		Short:     "custom",
+		Password:  "pass",
+		Token:     "token",
+	})
 	suite.a.AssertErr(err)
 
 	// Testing the creation of an expired link
-	err = database.CreateLink(
-		dataBase,
-		uuid.New(),
-		time.Now().UTC(),
-		time.Now().Add(time.Duration(-1)*time.Hour),
-		"http://example.com",
-		"willExpire",
-		"pass",
-	)
+	err = store.CreateLink(database.Link{
+		ID:        uuid.New(),
+		CreatedAt: time.Now().UTC(),
+		ExpireAt:  time.Now().Add(time.Duration(-1) * time.Hour),
+		URL:       "http://example.com",
+		Short:     "willExpire",
+		Password:  "pass",
+		Token:     "token",
+	})
 	suite.a.AssertErr(err)
 
+	// Testing the creation of several links at once
+	err = store.CreateLinks([]database.Link{
+		{
+			ID:        uuid.New(),
+			CreatedAt: time.Now().UTC(),
+			ExpireAt:  time.Now().UTC().Add(time.Hour),
+			URL:       "http://example.com/1",
+			Short:     "batch1",
+		},
+		{
+			ID:        uuid.New(),
+			CreatedAt: time.Now().UTC(),
+			ExpireAt:  time.Now().UTC().Add(time.Hour),
+			URL:       "http://example.com/2",
+			Short:     "batch2",
+		},
+	})
+	suite.a.AssertNoErr(err)
+
+	URL, err := store.GetURLByShort("batch2")
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(URL, "http://example.com/2")
+
+	// Testing that nothing is inserted if one of the shorts is already in use
+	err = store.CreateLinks([]database.Link{
+		{
+			ID:        uuid.New(),
+			CreatedAt: time.Now().UTC(),
+			ExpireAt:  time.Now().UTC().Add(time.Hour),
+			URL:       "http://example.com/3",
+			Short:     "batch3",
+		},
+		{
+			ID:        uuid.New(),
+			CreatedAt: time.Now().UTC(),
+			ExpireAt:  time.Now().UTC().Add(time.Hour),
+			URL:       "http://example.com/1",
+			Short:     "batch1",
+		},
+	})
+	suite.a.Assert(err != nil, true)
+
+	_, err = store.GetURLByShort("batch3")
+	suite.a.AssertErrIs(err, sql.ErrNoRows)
+
+	// Testing the replacement of an existing link
+	err = store.ReplaceLinks([]database.Link{
+		{
+			ID:        uuid.New(),
+			CreatedAt: time.Now().UTC(),
+			ExpireAt:  time.Now().UTC().Add(time.Hour),
+			URL:       "http://example.com/4",
+			Short:     "batch2",
+		},
+	})
+	suite.a.AssertNoErr(err)
+
+	URL, err = store.GetURLByShort("batch2")
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(URL, "http://example.com/4")
+
+	affected, err := store.DeleteLinks([]string{"batch1", "batch2"})
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(affected, int64(2))
+
 	// Testing the query to get an url by its short
-	URL, err := database.GetURLByShort(dataBase, "custom")
+	URL, err = store.GetURLByShort("custom")
 	suite.a.AssertNoErr(err)
 	suite.a.Assert(URL, "http://example.com")
 
+	// Testing that an expired link isn't found by a complete lookup
+	_, err = store.GetLinkByShort("willExpire")
+	suite.a.AssertErrIs(err, sql.ErrNoRows)
+
+	// Testing the complete lookup of an unknown short
+	_, err = store.GetLinkByShort("doesnotexist")
+	suite.a.AssertErrIs(err, sql.ErrNoRows)
+
 	// Testing the query to get an url by its short that will cause an error
-	_, err = database.GetURLByShort(dataBase, "doesnotexist")
+	_, err = store.GetURLByShort("doesnotexist")
 	suite.a.AssertErr(err)
 
 	// Testing the query to get a hash by its short
-	pass, err := database.GetHashByShort(dataBase, "custom")
+	pass, err := store.GetHashByShort("custom")
 	suite.a.AssertNoErr(err)
 	suite.a.Assert(pass, "pass")
 
 	// Testing the query to get a hash by its short that will cause an error
-	_, err = database.GetHashByShort(dataBase, "doesnotexist")
+	_, err = store.GetHashByShort("doesnotexist")
+	suite.a.AssertErr(err)
+
+	// Testing the query to get a token hash by its short
+	token, err := store.GetTokenHashByShort("custom")
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(token, "token")
+
+	// Testing the query to get a token hash by its short that will cause an error
+	_, err = store.GetTokenHashByShort("doesnotexist")
+	suite.a.AssertErr(err)
+
+	// Testing the update of a link
+	err = store.UpdateLink("custom", "http://example.org", time.Now().UTC().Add(time.Hour), "newpass")
+	suite.a.AssertNoErr(err)
+
+	URL, err = store.GetURLByShort("custom")
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(URL, "http://example.org")
+
+	pass, err = store.GetHashByShort("custom")
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(pass, "newpass")
+
+	// Testing the complete lookup of a link that is not expired anymore
+	link, err := store.GetLinkByShort("custom")
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(link.URL, "http://example.org")
+	suite.a.Assert(link.Short, "custom")
+	suite.a.Assert(link.Password, "newpass")
+	suite.a.Assert(link.Token, "token")
+
+	// Testing an update which doesn't change anything, MySQL doesn't count the row as affected by default
+	err = store.UpdateLink("custom", link.URL, link.ExpireAt, link.Password)
+	suite.a.AssertNoErr(err)
+
+	// Testing the update of a link that does not exist
+	err = store.UpdateLink("doesnotexist", "http://example.org", time.Now().UTC(), "")
+	suite.a.AssertErrIs(err, sql.ErrNoRows)
+
+	// Testing the insertion of hits, the ones of unknown links are ignored
+	hitAt := time.Now().UTC().Truncate(time.Hour)
+	err = store.AddHits([]database.Hit{
+		{Short: "custom", HitAt: hitAt, Referrer: "example.com", Agent: "browser"},
+		{Short: "custom", HitAt: hitAt, Referrer: "example.com", Agent: "bot"},
+		{Short: "custom", HitAt: hitAt, Referrer: "", Agent: "browser"},
+		{Short: "doesnotexist", HitAt: hitAt, Referrer: "", Agent: "browser"},
+	})
+	suite.a.AssertNoErr(err)
+
+	// Testing the aggregation of hits
+	stats, err := store.GetHitStats("custom")
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(stats.Total, 3)
+	suite.a.Assert(stats.Referrers["example.com"], 2)
+	suite.a.Assert(stats.Referrers[""], 1)
+	suite.a.Assert(stats.Agents["browser"], 2)
+	suite.a.Assert(stats.Agents["bot"], 1)
+
+	// Testing the listing of links, expired links are listed too
+	_, total, err := store.ListLinks(database.LinkFilter{})
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(total, 2)
+
+	links, total, err := store.ListLinks(database.LinkFilter{Short: "custom"})
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(total, 1)
+	suite.a.Assertf(len(links), 1)
+	suite.a.Assert(links[0].URL, "http://example.org")
+	suite.a.Assert(links[0].Token, "token")
+
+	for _, short := range []string{"listed_1", "listed%2"} {
+		err = store.CreateLink(database.Link{
+			ID:        uuid.New(),
+			CreatedAt: time.Now().UTC(),
+			ExpireAt:  time.Now().UTC().Add(time.Hour),
+			URL:       "http://example.net/" + short,
+			Short:     short,
+		})
+		suite.a.AssertNoErr(err)
+	}
+
+	// Testing the filters, wildcards must be matched literally
+	_, total, err = store.ListLinks(database.LinkFilter{Short: "listed"})
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(total, 2)
+
+	links, total, err = store.ListLinks(database.LinkFilter{Short: "%"})
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(total, 1)
+	suite.a.Assert(links[0].Short, "listed%2")
+
+	_, total, err = store.ListLinks(database.LinkFilter{URL: "example.net"})
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(total, 2)
+
+	_, total, err = store.ListLinks(database.LinkFilter{CreatedAfter: time.Now().UTC().Add(time.Hour)})
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(total, 0)
+
+	_, total, err = store.ListLinks(database.LinkFilter{ExpireBefore: time.Now().UTC().Add(2 * time.Hour)})
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(total, 4)
+
+	// Testing the pagination
+	links, total, err = store.ListLinks(database.LinkFilter{Limit: 3, Offset: 2})
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(total, 4)
+	suite.a.Assert(len(links), 2)
+
+	// Testing the bulk force-expire, unknown shorts are ignored
+	affected, err = store.ExpireLinks([]string{"listed_1", "doesnotexist"}, time.Now().UTC())
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(affected, int64(1))
+
+	// Testing the bulk deletion
+	affected, err = store.DeleteLinks([]string{"listed_1", "listed%2"})
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(affected, int64(2))
+
+	// Testing the deletion of a link
+	err = store.DeleteLink("custom")
+	suite.a.AssertNoErr(err)
+
+	stats, err = store.GetHitStats("custom")
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(stats.Total, 0)
+
+	_, err = store.GetURLByShort("custom")
+	suite.a.AssertErrIs(err, sql.ErrNoRows)
+
+	// Testing the deletion of a link that does not exist
+	err = store.DeleteLink("custom")
+	suite.a.AssertErrIs(err, sql.ErrNoRows)
+
+	// Testing the certificate cache
+	_, err = store.GetCert("example.com")
+	suite.a.AssertErrIs(err, sql.ErrNoRows)
+
+	err = store.PutCert("example.com", []byte("first"))
+	suite.a.AssertNoErr(err)
+
+	err = store.PutCert("example.com", []byte("second"))
+	suite.a.AssertNoErr(err)
+
+	cert, err := store.GetCert("example.com")
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(string(cert), "second")
+
+	err = store.DeleteCert("example.com")
+	suite.a.AssertNoErr(err)
+
+	_, err = store.GetCert("example.com")
+	suite.a.AssertErrIs(err, sql.ErrNoRows)
+
+	// Testing the deletion of a certificate that does not exist
+	err = store.DeleteCert("example.com")
+	suite.a.AssertNoErr(err)
+
+	// Testing the rate limit buckets
+	_, err = store.GetBucket("create:192.0.2.1")
+	suite.a.AssertErrIs(err, sql.ErrNoRows)
+
+	updatedAt := time.Now().UTC().Truncate(time.Millisecond)
+
+	err = store.SaveBucket(database.Bucket{Key: "create:192.0.2.1", Tokens: 4.5, UpdatedAt: updatedAt})
+	suite.a.AssertNoErr(err)
+
+	bucket, err := store.GetBucket("create:192.0.2.1")
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(bucket.Tokens, 4.5)
+	suite.a.Assert(bucket.UpdatedAt.Equal(updatedAt), true)
+	suite.a.Assert(bucket.Version, int64(1))
+
+	// Testing that a bucket can't be inserted twice nor updated from an outdated version
+	err = store.SaveBucket(database.Bucket{Key: "create:192.0.2.1", Tokens: 9, UpdatedAt: updatedAt})
+	suite.a.AssertErrIs(err, database.ErrBucketConflict)
+
+	bucket.Tokens = 3.5
+	err = store.SaveBucket(bucket)
+	suite.a.AssertNoErr(err)
+
+	err = store.SaveBucket(bucket)
+	suite.a.AssertErrIs(err, database.ErrBucketConflict)
+
+	bucket, err = store.GetBucket("create:192.0.2.1")
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(bucket.Tokens, 3.5)
+	suite.a.Assert(bucket.Version, int64(2))
+
+	// Testing the removal of idle buckets
+	removed, err := store.RemoveIdleBuckets(updatedAt)
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(removed, int64(0))
+
+	removed, err = store.RemoveIdleBuckets(updatedAt.Add(time.Second))
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(removed, int64(1))
+
+	_, err = store.GetBucket("create:192.0.2.1")
+	suite.a.AssertErrIs(err, sql.ErrNoRows)
+
+	// Testing the API keys
+	_, err = store.GetAPIKeyByHash("unknown")
+	suite.a.AssertErrIs(err, sql.ErrNoRows)
+
+	createdAt := time.Now().UTC().Truncate(time.Millisecond)
+	firstKey := database.APIKey{
+		ID:        uuid.New(),
+		Label:     "ci",
+		Prefix:    "rlk_abcdefgh",
+		Hash:      "aa",
+		Scopes:    "create,manage",
+		CreatedAt: createdAt,
+	}
+	secondKey := database.APIKey{
+		ID:        uuid.New(),
+		Label:     "stats",
+		Prefix:    "rlk_ijklmnop",
+		Hash:      "bb",
+		Scopes:    "stats",
+		CreatedAt: createdAt.Add(time.Second),
+		ExpireAt:  createdAt.Add(time.Hour),
+	}
+
+	suite.a.AssertNoErr(store.CreateAPIKey(secondKey))
+	suite.a.AssertNoErr(store.CreateAPIKey(firstKey))
+
+	// Testing that two keys can't have the same hash
+	err = store.CreateAPIKey(database.APIKey{ID: uuid.New(), Label: "copy", Hash: "aa", Scopes: "admin", CreatedAt: createdAt})
 	suite.a.AssertErr(err)
 
+	key, err := store.GetAPIKeyByHash("aa")
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(key.ID, firstKey.ID)
+	suite.a.Assert(key.Label, "ci")
+	suite.a.Assert(key.Prefix, "rlk_abcdefgh")
+	suite.a.Assert(key.Scopes, "create,manage")
+	suite.a.Assert(key.CreatedAt.Equal(createdAt), true)
+	suite.a.Assert(key.ExpireAt.IsZero(), true)
+
+	key, err = store.GetAPIKeyByHash("bb")
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(key.ExpireAt.Equal(createdAt.Add(time.Hour)), true)
+
+	keys, err := store.ListAPIKeys()
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(len(keys), 2)
+	suite.a.Assert(keys[0].ID, firstKey.ID)
+	suite.a.Assert(keys[1].ID, secondKey.ID)
+
+	// Testing the revocation of a key
+	suite.a.AssertNoErr(store.DeleteAPIKey(firstKey.ID))
+
+	_, err = store.GetAPIKeyByHash("aa")
+	suite.a.AssertErrIs(err, sql.ErrNoRows)
+
+	err = store.DeleteAPIKey(firstKey.ID)
+	suite.a.AssertErrIs(err, sql.ErrNoRows)
+
+	suite.a.AssertNoErr(store.DeleteAPIKey(secondKey.ID))
+
+	// Testing the users
+	_, err = store.GetUserByName("redd")
+	suite.a.AssertErrIs(err, sql.ErrNoRows)
+
+	user := database.User{ID: uuid.New(), Username: "redd", Password: "hash", CreatedAt: createdAt}
+	suite.a.AssertNoErr(store.CreateUser(user))
+
+	// Testing that two users can't have the same username
+	err = store.CreateUser(database.User{ID: uuid.New(), Username: "redd", Password: "other", CreatedAt: createdAt})
+	suite.a.AssertErr(err)
+
+	gotUser, err := store.GetUserByName("redd")
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(gotUser.ID, user.ID)
+	suite.a.Assert(gotUser.Password, "hash")
+	suite.a.Assert(gotUser.CreatedAt.Equal(createdAt), true)
+
+	gotUser, err = store.GetUserByID(user.ID)
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(gotUser.Username, "redd")
+
+	_, err = store.GetUserByID(uuid.New())
+	suite.a.AssertErrIs(err, sql.ErrNoRows)
+
+	// Testing the users created by the single sign-on, found by their subject
+	_, err = store.GetUserBySubject("subject")
+	suite.a.AssertErrIs(err, sql.ErrNoRows)
+
+	ssoUser := database.User{ID: uuid.New(), Username: "sso", Subject: "subject", Roles: "admin", CreatedAt: createdAt}
+	suite.a.AssertNoErr(store.CreateUser(ssoUser))
+
+	// Testing that two users can't have the same subject, while several local users have none
+	err = store.CreateUser(database.User{ID: uuid.New(), Username: "sso2", Subject: "subject", CreatedAt: createdAt})
+	suite.a.AssertErr(err)
+
+	err = store.CreateUser(database.User{ID: uuid.New(), Username: "local", Password: "hash", CreatedAt: createdAt})
+	suite.a.AssertNoErr(err)
+
+	gotUser, err = store.GetUserBySubject("subject")
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(gotUser.ID, ssoUser.ID)
+	suite.a.Assert(gotUser.Password, "")
+	suite.a.Assert(gotUser.Roles, "admin")
+
+	gotUser, err = store.GetUserByID(user.ID)
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(gotUser.Subject, "")
+
+	suite.a.AssertNoErr(store.UpdateUserRoles(ssoUser.ID, "admin,creator"))
+
+	gotUser, err = store.GetUserByName("sso")
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(gotUser.Roles, "admin,creator")
+
+	err = store.UpdateUserRoles(uuid.New(), "admin")
+	suite.a.AssertErrIs(err, sql.ErrNoRows)
+
+	// Testing the sessions, expired sessions are not found
+	session := database.Session{Hash: strings.Repeat("a", 64), UserID: user.ID, ExpireAt: createdAt.Add(time.Hour)}
+	expiredSession := database.Session{Hash: strings.Repeat("b", 64), UserID: user.ID, ExpireAt: createdAt.Add(-time.Hour)}
+
+	suite.a.AssertNoErr(store.CreateSession(session))
+	suite.a.AssertNoErr(store.CreateSession(expiredSession))
+
+	gotSession, err := store.GetSession(session.Hash)
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(gotSession.UserID, user.ID)
+	suite.a.Assert(gotSession.ExpireAt.Equal(session.ExpireAt), true)
+
+	_, err = store.GetSession(expiredSession.Hash)
+	suite.a.AssertErrIs(err, sql.ErrNoRows)
+
+	removed, err = store.RemoveExpiredSessions()
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(removed, int64(1))
+
+	suite.a.AssertNoErr(store.DeleteSession(session.Hash))
+
+	_, err = store.GetSession(session.Hash)
+	suite.a.AssertErrIs(err, sql.ErrNoRows)
+
+	err = store.DeleteSession(session.Hash)
+	suite.a.AssertErrIs(err, sql.ErrNoRows)
+
+	// Testing the owner of the links
+	err = store.CreateLink(database.Link{
+		ID:        uuid.New(),
+		CreatedAt: time.Now().UTC(),
+		ExpireAt:  time.Now().UTC().Add(time.Hour),
+		URL:       "http://example.com/owned",
+		Short:     "owned",
+		Owner:     user.ID,
+	})
+	suite.a.AssertNoErr(err)
+
+	link, err = store.GetLinkByShort("owned")
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(link.Owner, user.ID)
+
+	links, total, err = store.ListLinks(database.LinkFilter{Owner: user.ID})
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(total, 1)
+	suite.a.Assertf(len(links), 1)
+	suite.a.Assert(links[0].Short, "owned")
+	suite.a.Assert(links[0].Owner, user.ID)
+
+	_, total, err = store.ListLinks(database.LinkFilter{Owner: uuid.New()})
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(total, 0)
+
+	suite.a.AssertNoErr(store.DeleteLink("owned"))
+
 	// Testing the removal of expired entries
-	err = database.RemoveExpiredLinks(dataBase)
+	removed, err = store.RemoveExpiredLinks()
 	suite.a.AssertNoErr(err)
+	suite.a.Assert(removed, int64(1))
+
+	_, err = store.GetURLByShort("willExpire")
+	suite.a.AssertErrIs(err, sql.ErrNoRows)
 }
 
 // Test suite structure.
@@ -134,4 +756,8 @@ func TestDBSuite(t *testing.T) {
 
 	// Call the tests
 	suite.TestDB()
+	suite.TestMemoryStore()
+	suite.TestBoltStore()
+	suite.TestCachedStore()
+	suite.TestMySQLStore()
 }
@@ -20,6 +20,7 @@ import (
 	"testing"
 
 	"github.com/redds-be/reddlinks/internal/env"
+	"github.com/redds-be/reddlinks/internal/logging"
 	"github.com/redds-be/reddlinks/internal/utils"
 	"github.com/redds-be/reddlinks/test/helper"
 )
@@ -41,6 +42,29 @@ func (suite envTestSuite) TestAreValuesFromFiles() {
 		DefaultMaxLength:       255,
 		DefaultMaxCustomLength: 255,
 		DefaultExpiryTime:      2880,
+		LogFormat:              "text",
+		LogLevel:               "info",
+		ShutdownTimeout:        30,
+		ReadTimeout:            10,
+		ReadHeaderTimeout:      2,
+		WriteTimeout:           30,
+		IdleTimeout:            30,
+		BatchTimeout:           120,
+		MaxHeaderBytes:         1048576,
+		MaxBodyBytes:           1048576,
+		MaxBatchBytes:          4194304,
+		HSTSMaxAge:             31536000,
+		RateLimitCreate:        30,
+		RateLimitCreateBurst:   10,
+		RateLimitBatch:         2,
+		RateLimitBatchBurst:    2,
+		RateLimitPassword:      10,
+		RateLimitPasswordBurst: 5,
+		RateLimitStore:         "memory",
+		Registration:           true,
+		OIDCScopes:             "openid profile email",
+		OIDCUsernameClaim:      "preferred_username",
+		OIDCRolesClaim:         "roles",
 	}
 
 	envToCheck := env.GetEnv("../.env.test")
@@ -61,6 +85,16 @@ func (suite envTestSuite) TestIsErrorForCorrectEnv() {
 		DefaultMaxLength:       255,
 		DefaultMaxCustomLength: 255,
 		DefaultExpiryTime:      2880,
+		ShutdownTimeout:        30,
+		ReadTimeout:            10,
+		ReadHeaderTimeout:      2,
+		WriteTimeout:           30,
+		IdleTimeout:            30,
+		BatchTimeout:           120,
+		MaxHeaderBytes:         1048576,
+		MaxBodyBytes:           1048576,
+		MaxBatchBytes:          4194304,
+		RateLimitStore:         "memory",
 	}
 
 	err := envToCheck.EnvCheck()
@@ -79,6 +113,16 @@ func (suite envTestSuite) TestAreErrorsCorrect() { //nolint:funlen
 		DefaultMaxLength:       255,
 		DefaultMaxCustomLength: 255,
 		DefaultExpiryTime:      2880,
+		ShutdownTimeout:        30,
+		ReadTimeout:            10,
+		ReadHeaderTimeout:      2,
+		WriteTimeout:           30,
+		IdleTimeout:            30,
+		BatchTimeout:           120,
+		MaxHeaderBytes:         1048576,
+		MaxBodyBytes:           1048576,
+		MaxBatchBytes:          4194304,
+		RateLimitStore:         "memory",
 	}
 
 	// Test if the instance name errors are correct
@@ -114,6 +158,22 @@ func (suite envTestSuite) TestAreErrorsCorrect() { //nolint:funlen
 	err = envToCheck.EnvCheck()
 	suite.a.AssertErrIs(err, env.ErrInvalidOrUnsupported)
 
+	envToCheck.DBType = "mysql"
+	envToCheck.DefaultMaxLength = 4000
+	err = envToCheck.EnvCheck()
+	suite.a.AssertErrIs(err, env.ErrSuperior)
+
+	// Reset the max length
+	envToCheck.DefaultMaxLength = 255
+
+	envToCheck.DBType = "bolt"
+	envToCheck.DBURL = ""
+	err = envToCheck.EnvCheck()
+	suite.a.AssertErrIs(err, env.ErrEmpty)
+
+	// Reset the database string
+	envToCheck.DBURL = "test.db"
+
 	// Reset the database type
 	envToCheck.DBType = "postgres"
 
@@ -129,6 +189,128 @@ func (suite envTestSuite) TestAreErrorsCorrect() { //nolint:funlen
 	// Reset the time between cleanups
 	envToCheck.TimeBetweenCleanups = 1
 
+	// Test if the shutdown timeout errors are correct
+	envToCheck.ShutdownTimeout = 0
+	err = envToCheck.EnvCheck()
+	suite.a.AssertErrIs(err, env.ErrNullOrNegative)
+
+	envToCheck.ShutdownTimeout = -5
+	err = envToCheck.EnvCheck()
+	suite.a.AssertErrIs(err, env.ErrNullOrNegative)
+
+	// Reset the shutdown timeout
+	envToCheck.ShutdownTimeout = 30
+
+	// Test if the server timeouts and limits errors are correct
+	envToCheck.WriteTimeout = 0
+	err = envToCheck.EnvCheck()
+	suite.a.AssertErrIs(err, env.ErrNullOrNegative)
+
+	envToCheck.WriteTimeout = 300
+	err = envToCheck.EnvCheck()
+	suite.a.AssertErrIs(err, env.ErrInferior)
+
+	// Reset the write timeout
+	envToCheck.WriteTimeout = 30
+
+	envToCheck.ReadHeaderTimeout = 20
+	err = envToCheck.EnvCheck()
+	suite.a.AssertErrIs(err, env.ErrSuperior)
+
+	// Reset the read header timeout
+	envToCheck.ReadHeaderTimeout = 2
+
+	envToCheck.MaxBodyBytes = -1
+	err = envToCheck.EnvCheck()
+	suite.a.AssertErrIs(err, env.ErrNullOrNegative)
+
+	// Reset the max body bytes
+	envToCheck.MaxBodyBytes = 1048576
+
+	// Test if the TLS settings errors are correct
+	envToCheck.TLSCertFile = "cert.pem"
+	err = envToCheck.EnvCheck()
+	suite.a.AssertErrIs(err, env.ErrRequires)
+
+	envToCheck.TLSKeyFile = "key.pem"
+	err = envToCheck.EnvCheck()
+	suite.a.AssertNoErr(err)
+
+	envToCheck.ACMEDomains = "ls.example.com"
+	err = envToCheck.EnvCheck()
+	suite.a.AssertErrIs(err, env.ErrExclusive)
+
+	// Reset the certificate files
+	envToCheck.TLSCertFile = ""
+	envToCheck.TLSKeyFile = ""
+
+	envToCheck.ACMEDirectory = "http://127.0.0.1:14000/dir"
+	err = envToCheck.EnvCheck()
+	suite.a.AssertErrIs(err, env.ErrInvalid)
+
+	envToCheck.ACMEDirectory = "https://127.0.0.1:14000/dir"
+	envToCheck.RedirectAddr = ":80"
+	err = envToCheck.EnvCheck()
+	suite.a.AssertNoErr(err)
+
+	envToCheck.ACMEDomains = ""
+	err = envToCheck.EnvCheck()
+	suite.a.AssertErrIs(err, env.ErrRequires)
+
+	// Reset the ACME settings and the HTTP redirection
+	envToCheck.ACMEDirectory = ""
+	envToCheck.RedirectAddr = ""
+
+	envToCheck.HSTSMaxAge = -1
+	err = envToCheck.EnvCheck()
+	suite.a.AssertErrIs(err, env.ErrNegative)
+
+	// Reset the HSTS max age
+	envToCheck.HSTSMaxAge = 0
+
+	// Test if the rate limit errors are correct
+	envToCheck.RateLimitPassword = -1
+	err = envToCheck.EnvCheck()
+	suite.a.AssertErrIs(err, env.ErrNegative)
+
+	envToCheck.RateLimitPassword = 10
+	err = envToCheck.EnvCheck()
+	suite.a.AssertErrIs(err, env.ErrNullOrNegative)
+
+	envToCheck.RateLimitPasswordBurst = 5
+	envToCheck.RateLimitStore = "redis"
+	err = envToCheck.EnvCheck()
+	suite.a.AssertErrIs(err, env.ErrInvalidOrUnsupported)
+
+	envToCheck.RateLimitStore = "database"
+	err = envToCheck.EnvCheck()
+	suite.a.AssertNoErr(err)
+
+	// Reset the rate limits
+	envToCheck.RateLimitPassword = 0
+	envToCheck.RateLimitPasswordBurst = 0
+	envToCheck.RateLimitStore = "memory"
+
+	// Test if the trusted proxies errors are correct
+	envToCheck.TrustedProxies = "10.0.0.0/8,proxy.local"
+	err = envToCheck.EnvCheck()
+	suite.a.AssertErrIs(err, env.ErrInvalid)
+
+	envToCheck.TrustedProxies = "10.0.0.0/8, 192.0.2.7, ::1"
+	err = envToCheck.EnvCheck()
+	suite.a.AssertNoErr(err)
+
+	// Reset the trusted proxies
+	envToCheck.TrustedProxies = ""
+
+	// Test if the cache size errors are correct
+	envToCheck.CacheSize = -1
+	err = envToCheck.EnvCheck()
+	suite.a.AssertErrIs(err, env.ErrNegative)
+
+	// Reset the cache size
+	envToCheck.CacheSize = 0
+
 	// Test if the default length errors are correct
 	envToCheck.DefaultLength = 0
 	err = envToCheck.EnvCheck()
@@ -185,6 +367,130 @@ func (suite envTestSuite) TestAreErrorsCorrect() { //nolint:funlen
 	envToCheck.DefaultExpiryTime = -17
 	err = envToCheck.EnvCheck()
 	suite.a.AssertErrIs(err, env.ErrNegative)
+
+	// Reset the default expiry time
+	envToCheck.DefaultExpiryTime = 2880
+
+	// Test if the admin password errors are correct
+	envToCheck.AdminPassword = "secret"
+	envToCheck.AdminPasswordHash = "$argon2id$v=19$m=65536,t=1,p=2$c2FsdHNhbHQ$aGFzaGhhc2hoYXNoaGFzaA"
+	err = envToCheck.EnvCheck()
+	suite.a.AssertErrIs(err, env.ErrExclusive)
+
+	envToCheck.AdminPassword = ""
+	envToCheck.AdminPasswordHash = "notahash"
+	err = envToCheck.EnvCheck()
+	suite.a.AssertErrIs(err, env.ErrInvalid)
+
+	// Reset the admin password hash
+	envToCheck.AdminPasswordHash = ""
+
+	// Test if the creation restriction requires the admin dashboard on an in-memory database
+	dbType := envToCheck.DBType
+	envToCheck.DBType = "memory"
+	envToCheck.RestrictCreation = true
+	err = envToCheck.EnvCheck()
+	suite.a.AssertErrIs(err, env.ErrRequires)
+
+	// Test if the registration of accounts is enough to create links on an in-memory database
+	envToCheck.Accounts = true
+	envToCheck.Registration = true
+	err = envToCheck.EnvCheck()
+	suite.a.AssertNoErr(err)
+
+	envToCheck.Registration = false
+	err = envToCheck.EnvCheck()
+	suite.a.AssertErrIs(err, env.ErrRequires)
+
+	// Test if the single sign-on is enough to create links on an in-memory database
+	envToCheck.OIDCIssuer = "https://sso.example.com/realms/company"
+	envToCheck.OIDCClientID = "reddlinks"
+	envToCheck.OIDCScopes = "openid profile"
+	err = envToCheck.EnvCheck()
+	suite.a.AssertNoErr(err)
+
+	// Reset the creation restriction and the database type
+	envToCheck.RestrictCreation = false
+	envToCheck.DBType = dbType
+
+	// Test if the OpenID Connect errors are correct
+	envToCheck.OIDCClientID = ""
+	err = envToCheck.EnvCheck()
+	suite.a.AssertErrIs(err, env.ErrRequires)
+
+	envToCheck.OIDCClientID = "reddlinks"
+	envToCheck.OIDCIssuer = "ftp://sso.example.com"
+	err = envToCheck.EnvCheck()
+	suite.a.AssertErrIs(err, env.ErrInvalid)
+
+	envToCheck.OIDCIssuer = "https://sso.example.com/realms/company"
+	envToCheck.OIDCScopes = "profile email"
+	err = envToCheck.EnvCheck()
+	suite.a.AssertErrIs(err, env.ErrInvalid)
+
+	envToCheck.OIDCScopes = "openid profile"
+	envToCheck.Accounts = false
+	err = envToCheck.EnvCheck()
+	suite.a.AssertErrIs(err, env.ErrRequires)
+
+	envToCheck.OIDCIssuer = ""
+	envToCheck.OIDCClientID = ""
+	envToCheck.OIDCAdminRole = "reddlinks-admin"
+	err = envToCheck.EnvCheck()
+	suite.a.AssertErrIs(err, env.ErrRequires)
+
+	// Reset the OpenID Connect settings
+	envToCheck.OIDCAdminRole = ""
+	envToCheck.Registration = true
+
+	// Reset the creation restriction, the accounts and the database type
+	envToCheck.RestrictCreation = false
+	envToCheck.Accounts = false
+	envToCheck.DBType = dbType
+
+	// Test if the log settings errors are correct
+	envToCheck.LogFormat = "xml"
+	err = envToCheck.EnvCheck()
+	suite.a.AssertErrIs(err, logging.ErrUnknownFormat)
+
+	envToCheck.LogFormat = "json"
+	envToCheck.LogLevel = "verbose"
+	err = envToCheck.EnvCheck()
+	suite.a.AssertErrIs(err, logging.ErrUnknownLevel)
+}
+
+func (suite envTestSuite) TestAreAllErrorsReported() {
+	envToCheck := env.Env{
+		AddrAndPort:            "127.0.0.1:8080",
+		InstanceName:           "",
+		InstanceURL:            "http://127.0.0.1:8080/",
+		DBType:                 "mssql",
+		DBURL:                  "test.db",
+		TimeBetweenCleanups:    1,
+		DefaultLength:          6,
+		DefaultMaxLength:       255,
+		DefaultMaxCustomLength: 255,
+		DefaultExpiryTime:      -17,
+		AdminPassword:          "secret",
+		AdminPasswordHash:      "$argon2id$v=19$m=65536,t=1,p=2$c2FsdHNhbHQ$aGFzaGhhc2hoYXNoaGFzaA",
+		ShutdownTimeout:        30,
+		ReadTimeout:            10,
+		ReadHeaderTimeout:      2,
+		WriteTimeout:           30,
+		IdleTimeout:            30,
+		BatchTimeout:           120,
+		MaxHeaderBytes:         1048576,
+		MaxBodyBytes:           1048576,
+		MaxBatchBytes:          4194304,
+		RateLimitStore:         "memory",
+	}
+
+	// Test if every error is reported, not only the first one
+	err := envToCheck.EnvCheck()
+	suite.a.AssertErrIs(err, env.ErrEmpty)
+	suite.a.AssertErrIs(err, env.ErrInvalidOrUnsupported)
+	suite.a.AssertErrIs(err, env.ErrNegative)
+	suite.a.AssertErrIs(err, env.ErrExclusive)
 }
 
 // Test suite structure.
@@ -208,4 +514,5 @@ func TestEnvSuite(t *testing.T) {
 	suite.TestAreValuesFromFiles()
 	suite.TestIsErrorForCorrectEnv()
 	suite.TestAreErrorsCorrect()
+	suite.TestAreAllErrorsReported()
 }
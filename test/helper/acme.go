@@ -0,0 +1,425 @@
+//    reddlinks, a simple link shortener written in Go.
+//    Copyright (C) 2025 redd
+//
+//    This program is free software: you can redistribute it and/or modify
+//    it under the terms of the GNU General Public License as published by
+//    the Free Software Foundation, either version 3 of the License, or
+//    (at your option) any later version.
+//
+//    This program is distributed in the hope that it will be useful,
+//    but WITHOUT ANY WARRANTY; without even the implied warranty of
+//    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//    GNU General Public License for more details.
+//
+//    You should have received a copy of the GNU General Public License
+//    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package helper
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// idPeACMEIdentifier is the extension of the tls-alpn-01 challenge certificates, see RFC 8737.
+var idPeACMEIdentifier = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 1, 31}
+
+// MockACME is a local ACME directory for the tests, following RFC 8555.
+//
+// Each order has a single authorization offering a tls-alpn-01 challenge, which is checked by connecting
+// to the target address like a certificate authority would. Once the order is finalized, a certificate
+// signed by the CA of the mock directory is issued for the names of the CSR.
+type MockACME struct {
+	Server *httptest.Server
+	CA     *x509.Certificate
+
+	target   string
+	caKey    *ecdsa.PrivateKey
+	requests atomic.Int32
+
+	mutex      sync.Mutex
+	thumbprint string
+	orders     []*mockOrder
+}
+
+// mockOrder is what the mock directory remembers about an order.
+type mockOrder struct {
+	domain     string
+	token      string
+	authorized bool
+	chain      []byte
+}
+
+// NewMockACME starts a mock directory checking the challenges on a target address, it is closed along with the test.
+func NewMockACME(t *testing.T, target string) *MockACME {
+	t.Helper()
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(365 * 24 * time.Hour), //nolint:mnd
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	caCert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mock := &MockACME{CA: caCert, target: target, caKey: caKey}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /directory", mock.directory)
+	mux.HandleFunc("HEAD /nonce", func(http.ResponseWriter, *http.Request) {})
+	mux.HandleFunc("POST /account", mock.account)
+	mux.HandleFunc("POST /order", mock.newOrder)
+	mux.HandleFunc("POST /order/{id}", mock.order)
+	mux.HandleFunc("POST /authz/{id}", mock.authorization)
+	mux.HandleFunc("POST /challenge/{id}", mock.challenge)
+	mux.HandleFunc("POST /finalize/{id}", mock.finalize)
+	mux.HandleFunc("POST /cert/{id}", mock.certificate)
+
+	// Every response gives a new nonce
+	mock.Server = httptest.NewTLSServer(http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
+		writer.Header().Set("Replay-Nonce", strconv.Itoa(int(mock.requests.Add(1))))
+		mux.ServeHTTP(writer, req)
+	}))
+	t.Cleanup(mock.Server.Close)
+
+	return mock
+}
+
+// Requests returns the number of requests received by the mock directory.
+func (mock *MockACME) Requests() int {
+	return int(mock.requests.Load())
+}
+
+// URL returns the URL of the directory.
+func (mock *MockACME) URL() string {
+	return mock.Server.URL + "/directory"
+}
+
+// directory writes the URLs of the endpoints.
+func (mock *MockACME) directory(writer http.ResponseWriter, _ *http.Request) {
+	writeACME(writer, http.StatusOK, map[string]string{
+		"newNonce":   mock.Server.URL + "/nonce",
+		"newAccount": mock.Server.URL + "/account",
+		"newOrder":   mock.Server.URL + "/order",
+		"revokeCert": mock.Server.URL + "/revoke",
+		"keyChange":  mock.Server.URL + "/key-change",
+	})
+}
+
+// account registers the key of the request, whose thumbprint is then expected in the key authorizations.
+func (mock *MockACME) account(writer http.ResponseWriter, req *http.Request) {
+	var header struct {
+		JWK struct {
+			Crv string `json:"crv"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		} `json:"jwk"`
+	}
+
+	if err := decodeJWS(req, &header, nil); err != nil {
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+
+		return
+	}
+
+	// The members of the key are sorted and without spaces, see RFC 7638
+	thumbprint := sha256.Sum256(fmt.Appendf(nil, `{"crv":%q,"kty":"EC","x":%q,"y":%q}`, header.JWK.Crv, header.JWK.X, header.JWK.Y))
+
+	mock.mutex.Lock()
+	mock.thumbprint = base64.RawURLEncoding.EncodeToString(thumbprint[:])
+	mock.mutex.Unlock()
+
+	writer.Header().Set("Location", mock.Server.URL+"/account/1")
+	writeACME(writer, http.StatusCreated, map[string]string{"status": "valid"})
+}
+
+// newOrder creates an order for the first identifier of the request.
+func (mock *MockACME) newOrder(writer http.ResponseWriter, req *http.Request) {
+	var payload struct {
+		Identifiers []struct {
+			Value string `json:"value"`
+		} `json:"identifiers"`
+	}
+
+	if err := decodeJWS(req, nil, &payload); err != nil || len(payload.Identifiers) == 0 {
+		http.Error(writer, "invalid order", http.StatusBadRequest)
+
+		return
+	}
+
+	mock.mutex.Lock()
+	mock.orders = append(mock.orders, &mockOrder{domain: payload.Identifiers[0].Value, token: rand.Text()})
+	id := strconv.Itoa(len(mock.orders) - 1)
+	mock.mutex.Unlock()
+
+	writer.Header().Set("Location", mock.Server.URL+"/order/"+id)
+	mock.writeOrder(writer, http.StatusCreated, id)
+}
+
+// order writes the state of an order.
+func (mock *MockACME) order(writer http.ResponseWriter, req *http.Request) {
+	mock.writeOrder(writer, http.StatusOK, req.PathValue("id"))
+}
+
+// authorization writes the state of the authorization of an order.
+func (mock *MockACME) authorization(writer http.ResponseWriter, req *http.Request) {
+	order := mock.find(req.PathValue("id"))
+	if order == nil {
+		http.NotFound(writer, req)
+
+		return
+	}
+
+	mock.mutex.Lock()
+	defer mock.mutex.Unlock()
+
+	status := "pending"
+	if order.authorized {
+		status = "valid"
+	}
+
+	writeACME(writer, http.StatusOK, map[string]any{
+		"status":     status,
+		"identifier": map[string]string{"type": "dns", "value": order.domain},
+		"challenges": []map[string]string{{
+			"type":   "tls-alpn-01",
+			"url":    mock.Server.URL + "/challenge/" + req.PathValue("id"),
+			"token":  order.token,
+			"status": status,
+		}},
+	})
+}
+
+// challenge checks the certificate served for the acme-tls/1 protocol on the target address,
+// it must hold the digest of the key authorization of the order.
+func (mock *MockACME) challenge(writer http.ResponseWriter, req *http.Request) {
+	order := mock.find(req.PathValue("id"))
+	if order == nil {
+		http.NotFound(writer, req)
+
+		return
+	}
+
+	conn, err := tls.Dial("tcp", mock.target, &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         order.domain,
+		NextProtos:         []string{"acme-tls/1"},
+		InsecureSkipVerify: true, //nolint:gosec // The challenge certificates are self-signed
+	})
+	if err != nil {
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+
+		return
+	}
+
+	leaf := conn.ConnectionState().PeerCertificates[0]
+	_ = conn.Close()
+
+	mock.mutex.Lock()
+	defer mock.mutex.Unlock()
+
+	digest := sha256.Sum256([]byte(order.token + "." + mock.thumbprint))
+
+	for _, extension := range leaf.Extensions {
+		var value []byte
+		if _, err := asn1.Unmarshal(extension.Value, &value); err == nil &&
+			extension.Id.Equal(idPeACMEIdentifier) && bytes.Equal(value, digest[:]) {
+			order.authorized = true
+		}
+	}
+
+	status := "invalid"
+	if order.authorized {
+		status = "valid"
+	}
+
+	writeACME(writer, http.StatusOK, map[string]string{"type": "tls-alpn-01", "token": order.token, "status": status})
+}
+
+// finalize issues the certificate of an authorized order for the CSR of the request.
+func (mock *MockACME) finalize(writer http.ResponseWriter, req *http.Request) {
+	var payload struct {
+		CSR string `json:"csr"`
+	}
+
+	order := mock.find(req.PathValue("id"))
+	if order == nil || decodeJWS(req, nil, &payload) != nil {
+		http.Error(writer, "invalid finalization", http.StatusBadRequest)
+
+		return
+	}
+
+	der, err := base64.RawURLEncoding.DecodeString(payload.CSR)
+	if err != nil {
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+
+		return
+	}
+
+	csr, err := x509.ParseCertificateRequest(der)
+	if err != nil {
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+
+		return
+	}
+
+	// The certificate must be valid long enough not to be renewed right away
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(int64(mock.requests.Load())),
+		DNSNames:     csr.DNSNames,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(90 * 24 * time.Hour), //nolint:mnd
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+
+	certificate, err := x509.CreateCertificate(rand.Reader, template, mock.CA, csr.PublicKey, mock.caKey)
+	if err != nil {
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+
+		return
+	}
+
+	mock.mutex.Lock()
+	if order.authorized {
+		order.chain = append(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificate}),
+			pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: mock.CA.Raw})...)
+	}
+	mock.mutex.Unlock()
+
+	mock.writeOrder(writer, http.StatusOK, req.PathValue("id"))
+}
+
+// certificate writes the certificate chain of a finalized order.
+func (mock *MockACME) certificate(writer http.ResponseWriter, req *http.Request) {
+	order := mock.find(req.PathValue("id"))
+	if order == nil {
+		http.NotFound(writer, req)
+
+		return
+	}
+
+	mock.mutex.Lock()
+	defer mock.mutex.Unlock()
+
+	writer.Header().Set("Content-Type", "application/pem-certificate-chain")
+	_, _ = writer.Write(order.chain)
+}
+
+// find returns the order of an ID, nil if it doesn't exist.
+func (mock *MockACME) find(id string) *mockOrder {
+	mock.mutex.Lock()
+	defer mock.mutex.Unlock()
+
+	index, err := strconv.Atoi(id)
+	if err != nil || index < 0 || index >= len(mock.orders) {
+		return nil
+	}
+
+	return mock.orders[index]
+}
+
+// writeOrder writes the state of an order, which is ready once authorized and valid once finalized.
+func (mock *MockACME) writeOrder(writer http.ResponseWriter, status int, id string) {
+	order := mock.find(id)
+	if order == nil {
+		http.Error(writer, "unknown order", http.StatusNotFound)
+
+		return
+	}
+
+	mock.mutex.Lock()
+	defer mock.mutex.Unlock()
+
+	state := map[string]any{
+		"status":         "pending",
+		"identifiers":    []map[string]string{{"type": "dns", "value": order.domain}},
+		"authorizations": []string{mock.Server.URL + "/authz/" + id},
+		"finalize":       mock.Server.URL + "/finalize/" + id,
+	}
+
+	switch {
+	case order.chain != nil:
+		state["status"] = "valid"
+		state["certificate"] = mock.Server.URL + "/cert/" + id
+	case order.authorized:
+		state["status"] = "ready"
+	}
+
+	writeACME(writer, status, state)
+}
+
+// decodeJWS decodes the protected header and the payload of a JWS request, the signature isn't checked.
+func decodeJWS(req *http.Request, header, payload any) error {
+	var jws struct {
+		Protected string `json:"protected"`
+		Payload   string `json:"payload"`
+	}
+
+	if err := json.NewDecoder(req.Body).Decode(&jws); err != nil {
+		return err
+	}
+
+	parts := []struct {
+		encoded string
+		value   any
+	}{{jws.Protected, header}, {jws.Payload, payload}}
+
+	for _, part := range parts {
+		if part.value == nil {
+			continue
+		}
+
+		decoded, err := base64.RawURLEncoding.DecodeString(part.encoded)
+		if err != nil {
+			return err
+		}
+
+		if err := json.Unmarshal(decoded, part.value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeACME writes a JSON response of the mock directory.
+func writeACME(writer http.ResponseWriter, status int, body any) {
+	writer.Header().Set("Content-Type", "application/json")
+	writer.WriteHeader(status)
+	_ = json.NewEncoder(writer).Encode(body)
+}
@@ -0,0 +1,64 @@
+//    reddlinks, a simple link shortener written in Go.
+//    Copyright (C) 2025 redd
+//
+//    This program is free software: you can redistribute it and/or modify
+//    it under the terms of the GNU General Public License as published by
+//    the Free Software Foundation, either version 3 of the License, or
+//    (at your option) any later version.
+//
+//    This program is distributed in the hope that it will be useful,
+//    but WITHOUT ANY WARRANTY; without even the implied warranty of
+//    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//    GNU General Public License for more details.
+//
+//    You should have received a copy of the GNU General Public License
+//    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package helper
+
+import (
+	"net"
+	"testing"
+
+	sqle "github.com/dolthub/go-mysql-server"
+	"github.com/dolthub/go-mysql-server/memory"
+	"github.com/dolthub/go-mysql-server/server"
+	"github.com/dolthub/go-mysql-server/sql"
+)
+
+// MockMySQLDatabase is the name of the database served by the embedded MySQL server.
+const MockMySQLDatabase = "reddlinks"
+
+// NewMockMySQL starts an embedded MySQL-compatible server keeping an empty database in memory,
+// it is closed along with the test.
+//
+// Returns:
+//   - string: The DSN of the database, to be given to the MySQL driver
+func NewMockMySQL(t *testing.T) string {
+	t.Helper()
+
+	provider := memory.NewDBProvider(memory.NewDatabase(MockMySQLDatabase))
+	engine := sqle.NewDefault(provider)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mysqlServer, err := server.NewServer(
+		server.Config{Protocol: "tcp", Listener: listener},
+		engine,
+		sql.NewContext,
+		memory.NewSessionBuilder(provider),
+		nil,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() { _ = mysqlServer.Start() }()
+
+	t.Cleanup(func() { _ = mysqlServer.Close() })
+
+	return "root@tcp(" + listener.Addr().String() + ")/" + MockMySQLDatabase
+}
@@ -0,0 +1,262 @@
+//    reddlinks, a simple link shortener written in Go.
+//    Copyright (C) 2025 redd
+//
+//    This program is free software: you can redistribute it and/or modify
+//    it under the terms of the GNU General Public License as published by
+//    the Free Software Foundation, either version 3 of the License, or
+//    (at your option) any later version.
+//
+//    This program is distributed in the hope that it will be useful,
+//    but WITHOUT ANY WARRANTY; without even the implied warranty of
+//    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//    GNU General Public License for more details.
+//
+//    You should have received a copy of the GNU General Public License
+//    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package helper
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Key IDs of the signing keys published by the mock provider.
+const (
+	MockRSAKeyID = "rsa"
+	MockECKeyID  = "ec"
+)
+
+// MockOIDC is a local OpenID Connect provider for the tests.
+//
+// Its authorization endpoint logs the configured user in right away and redirects back with a code,
+// its token endpoint checks the PKCE verifier of the code and issues an ID token signed using RS256.
+type MockOIDC struct {
+	Server   *httptest.Server
+	ClientID string
+	Secret   string
+
+	rsaKey *rsa.PrivateKey
+	ecKey  *ecdsa.PrivateKey
+
+	mutex    sync.Mutex
+	subject  string
+	username string
+	roles    []string
+	modify   func(header, claims map[string]any)
+	codes    map[string]mockCode
+}
+
+// mockCode is what the mock provider remembers about an authorization code.
+type mockCode struct {
+	nonce       string
+	challenge   string
+	redirectURI string
+}
+
+// NewMockOIDC starts a mock provider for a client, it is closed along with the test.
+func NewMockOIDC(t *testing.T, clientID, secret string) *MockOIDC {
+	t.Helper()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048) //nolint:mnd
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mock := &MockOIDC{
+		ClientID: clientID,
+		Secret:   secret,
+		rsaKey:   rsaKey,
+		ecKey:    ecKey,
+		codes:    map[string]mockCode{},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", mock.discovery)
+	mux.HandleFunc("GET /keys", mock.keys)
+	mux.HandleFunc("GET /authorize", mock.authorize)
+	mux.HandleFunc("POST /token", mock.token)
+
+	mock.Server = httptest.NewServer(mux)
+	t.Cleanup(mock.Server.Close)
+
+	return mock
+}
+
+// Issuer returns the issuer of the mock provider.
+func (mock *MockOIDC) Issuer() string {
+	return mock.Server.URL
+}
+
+// SetUser sets the user logged in by the next authorizations.
+func (mock *MockOIDC) SetUser(subject, username string, roles []string) {
+	mock.mutex.Lock()
+	defer mock.mutex.Unlock()
+
+	mock.subject, mock.username, mock.roles = subject, username, roles
+}
+
+// SetModify sets a function changing the header and the claims of the next ID tokens, nil to issue valid ones.
+func (mock *MockOIDC) SetModify(modify func(header, claims map[string]any)) {
+	mock.mutex.Lock()
+	defer mock.mutex.Unlock()
+
+	mock.modify = modify
+}
+
+// Sign returns a JWT made of a header and claims, signed using ES256 if the header asks for it, RS256 otherwise.
+func (mock *MockOIDC) Sign(header, claims map[string]any) string {
+	headerJSON, _ := json.Marshal(header) //nolint:errchkjson
+	claimsJSON, _ := json.Marshal(claims) //nolint:errchkjson
+
+	signed := base64.RawURLEncoding.EncodeToString(headerJSON) + "." + base64.RawURLEncoding.EncodeToString(claimsJSON)
+	digest := sha256.Sum256([]byte(signed))
+
+	var signature []byte
+
+	if header["alg"] == "ES256" {
+		r, s, err := ecdsa.Sign(rand.Reader, mock.ecKey, digest[:])
+		if err == nil {
+			signature = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...) //nolint:mnd
+		}
+	} else {
+		signature, _ = rsa.SignPKCS1v15(rand.Reader, mock.rsaKey, crypto.SHA256, digest[:])
+	}
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// IDToken returns the header and the claims of a valid ID token for the configured user.
+func (mock *MockOIDC) IDToken(nonce string) (map[string]any, map[string]any) {
+	mock.mutex.Lock()
+	defer mock.mutex.Unlock()
+
+	claims := map[string]any{
+		"iss":                mock.Issuer(),
+		"sub":                mock.subject,
+		"aud":                mock.ClientID,
+		"exp":                time.Now().Add(5 * time.Minute).Unix(), //nolint:mnd
+		"iat":                time.Now().Unix(),
+		"nonce":              nonce,
+		"preferred_username": mock.username,
+		"roles":              mock.roles,
+	}
+
+	return map[string]any{"alg": "RS256", "typ": "JWT", "kid": MockRSAKeyID}, claims
+}
+
+// discovery writes the discovery document.
+func (mock *MockOIDC) discovery(writer http.ResponseWriter, _ *http.Request) {
+	writer.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(writer).Encode(map[string]any{
+		"issuer":                 mock.Issuer(),
+		"authorization_endpoint": mock.Issuer() + "/authorize",
+		"token_endpoint":         mock.Issuer() + "/token",
+		"jwks_uri":               mock.Issuer() + "/keys",
+	})
+}
+
+// keys writes the public signing keys, along with an encryption key that must be ignored.
+func (mock *MockOIDC) keys(writer http.ResponseWriter, _ *http.Request) {
+	encode := func(value *big.Int) string { return base64.RawURLEncoding.EncodeToString(value.Bytes()) }
+
+	writer.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(writer).Encode(map[string]any{"keys": []map[string]any{
+		{"kty": "RSA", "kid": MockRSAKeyID, "use": "sig", "n": encode(mock.rsaKey.N), "e": encode(big.NewInt(int64(mock.rsaKey.E)))},
+		{"kty": "EC", "kid": MockECKeyID, "crv": "P-256", "x": encode(mock.ecKey.X), "y": encode(mock.ecKey.Y)},
+		{"kty": "RSA", "kid": "enc", "use": "enc", "n": encode(mock.rsaKey.N), "e": "AQAB"},
+	}})
+}
+
+// authorize logs the configured user in and redirects back to the client with a code.
+func (mock *MockOIDC) authorize(writer http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+
+	if query.Get("response_type") != "code" || query.Get("client_id") != mock.ClientID ||
+		query.Get("code_challenge_method") != "S256" || !strings.Contains(query.Get("scope"), "openid") {
+		http.Error(writer, "invalid request", http.StatusBadRequest)
+
+		return
+	}
+
+	code := rand.Text()
+
+	mock.mutex.Lock()
+	mock.codes[code] = mockCode{
+		nonce:       query.Get("nonce"),
+		challenge:   query.Get("code_challenge"),
+		redirectURI: query.Get("redirect_uri"),
+	}
+	mock.mutex.Unlock()
+
+	http.Redirect(writer, req, query.Get("redirect_uri")+"?"+url.Values{
+		"code":  {code},
+		"state": {query.Get("state")},
+	}.Encode(), http.StatusFound)
+}
+
+// token exchanges a code for an ID token once the client and the PKCE verifier are checked.
+func (mock *MockOIDC) token(writer http.ResponseWriter, req *http.Request) {
+	writer.Header().Set("Content-Type", "application/json")
+
+	fail := func(reason string) {
+		writer.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(writer).Encode(map[string]string{"error": reason})
+	}
+
+	// Public clients only give their ID
+	clientID, secret, found := req.BasicAuth()
+	if !found {
+		clientID = req.FormValue("client_id")
+	}
+
+	if clientID != mock.ClientID || secret != mock.Secret {
+		fail("invalid_client")
+
+		return
+	}
+
+	mock.mutex.Lock()
+	code, known := mock.codes[req.FormValue("code")]
+	delete(mock.codes, req.FormValue("code"))
+	modify := mock.modify
+	mock.mutex.Unlock()
+
+	verifier := sha256.Sum256([]byte(req.FormValue("code_verifier")))
+	if !known || req.FormValue("grant_type") != "authorization_code" || req.FormValue("redirect_uri") != code.redirectURI ||
+		base64.RawURLEncoding.EncodeToString(verifier[:]) != code.challenge {
+		fail("invalid_grant")
+
+		return
+	}
+
+	header, claims := mock.IDToken(code.nonce)
+	if modify != nil {
+		modify(header, claims)
+	}
+
+	_ = json.NewEncoder(writer).Encode(map[string]any{
+		"access_token": rand.Text(),
+		"token_type":   "Bearer",
+		"id_token":     mock.Sign(header, claims),
+	})
+}
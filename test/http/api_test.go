@@ -18,22 +18,34 @@ package http_test
 
 import (
 	"bytes"
+	"context"
 	"embed"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"html/template"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"regexp"
+	"slices"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/google/uuid"
+	"github.com/redds-be/reddlinks/internal/analytics"
+	"github.com/redds-be/reddlinks/internal/apikeys"
+	"github.com/redds-be/reddlinks/internal/certs"
 	"github.com/redds-be/reddlinks/internal/database"
 	"github.com/redds-be/reddlinks/internal/env"
 	HTTP "github.com/redds-be/reddlinks/internal/http"
+	JSON "github.com/redds-be/reddlinks/internal/json"
 	"github.com/redds-be/reddlinks/internal/links"
+	"github.com/redds-be/reddlinks/internal/logging"
+	"github.com/redds-be/reddlinks/internal/metrics"
+	"github.com/redds-be/reddlinks/internal/proxy"
+	"github.com/redds-be/reddlinks/internal/ratelimit"
 	"github.com/redds-be/reddlinks/internal/utils"
 	"github.com/redds-be/reddlinks/test/helper"
 )
@@ -47,6 +59,393 @@ func (suite apiTestSuite) TestReadiness() {
 	suite.a.Assert(resp.Body.String(), "{\"status\":\"Alive.\"}\n")
 }
 
+func (suite apiTestSuite) TestProbes() { //nolint:funlen
+	// Test the liveness probe
+	req := httptest.NewRequest(http.MethodGet, "/livez", nil)
+	resp := httptest.NewRecorder()
+	HTTP.HandlerLive(resp, req)
+	suite.a.Assert(resp.Code, http.StatusOK)
+	suite.a.Assert(resp.Body.String(), "{\"status\":\"ok\"}\n")
+
+	// Prep a database that can be closed
+	testEnv := env.GetEnv("../.env.test")
+	testEnv.DBURL = "api_probes_test.db"
+
+	if _, err := os.Stat(testEnv.DBURL); !errors.Is(err, os.ErrNotExist) {
+		err = os.Remove(testEnv.DBURL)
+		suite.a.AssertNoErrf(err)
+	}
+
+	store, err := database.OpenStore(testEnv.DBType, testEnv.DBURL, "", "", "", "", "", testEnv.DefaultMaxLength)
+	suite.a.AssertNoErrf(err)
+
+	var emptyEmbed embed.FS
+	locales, supportedLocales, err := utils.GetLocales("./locales/", emptyEmbed)
+	suite.a.AssertNoErrf(err)
+
+	conf := &utils.Configuration{
+		Store:            store,
+		Locales:          locales,
+		SupportedLocales: supportedLocales,
+		GC:               &utils.GCStatus{},
+	}
+
+	HTTP.Templates = template.Must(template.ParseGlob("../../static/**/*.tmpl"))
+
+	// Test the readiness probe before any garbage collection
+	req = httptest.NewRequest(http.MethodGet, "/readyz", nil)
+	resp = httptest.NewRecorder()
+	HTTP.NewAdapter(*conf).HandlerReady(resp, req)
+	suite.a.Assert(resp.Code, http.StatusOK)
+
+	var readiness JSON.ReadinessResponse
+	err = json.NewDecoder(resp.Body).Decode(&readiness)
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(readiness.Status, "ready")
+	suite.a.Assert(len(readiness.Failing), 0)
+	suite.a.Assert(readiness.Checks["database"], "ok")
+	suite.a.Assert(readiness.LastGCRun, "")
+
+	// Test if the last garbage collection is reported
+	err = conf.CollectGarbage()
+	suite.a.AssertNoErr(err)
+
+	resp = httptest.NewRecorder()
+	HTTP.NewAdapter(*conf).HandlerReady(resp, req)
+	suite.a.Assert(resp.Code, http.StatusOK)
+
+	readiness = JSON.ReadinessResponse{}
+	err = json.NewDecoder(resp.Body).Decode(&readiness)
+	suite.a.AssertNoErr(err)
+	suite.a.AssertNotEmpty(readiness.LastGCRun, "")
+
+	// Test the readiness probe once the database is down and the locales are missing
+	err = store.Close()
+	suite.a.AssertNoErr(err)
+
+	conf.Locales = nil
+
+	resp = httptest.NewRecorder()
+	HTTP.NewAdapter(*conf).HandlerReady(resp, req)
+	suite.a.Assert(resp.Code, http.StatusServiceUnavailable)
+
+	readiness = JSON.ReadinessResponse{}
+	err = json.NewDecoder(resp.Body).Decode(&readiness)
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(readiness.Status, "unavailable")
+	suite.a.Assert(len(readiness.Failing), 2)
+	suite.a.Assert(readiness.Failing[0], "database")
+	suite.a.Assert(readiness.Failing[1], "locales")
+	suite.a.Assert(readiness.Checks["database"], "unreachable")
+	suite.a.Assert(readiness.Checks["templates"], "ok")
+}
+
+func (suite apiTestSuite) TestGracefulShutdown() {
+	var emptyEmbed embed.FS
+
+	conf := utils.Configuration{
+		AddrAndPort:     "127.0.0.1:18931",
+		Static:          emptyEmbed,
+		ShutdownTimeout: time.Second,
+	}
+
+	// Start the server until the context is cancelled
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan error, 1)
+
+	go func() {
+		stopped <- HTTP.NewAdapter(conf).Run(ctx)
+	}()
+
+	// Wait for the server to answer
+	var err error
+
+	for range 50 {
+		var resp *http.Response
+
+		resp, err = http.Get("http://" + conf.AddrAndPort + "/livez") //nolint:noctx
+		if err == nil {
+			suite.a.AssertNoErr(resp.Body.Close())
+			suite.a.Assert(resp.StatusCode, http.StatusOK)
+
+			break
+		}
+
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	suite.a.AssertNoErrf(err)
+
+	// Test if the server stops without error once the context is cancelled
+	cancel()
+
+	err = <-stopped
+	suite.a.AssertNoErr(err)
+
+	// Test if the server does not accept connections anymore
+	_, err = http.Get("http://" + conf.AddrAndPort + "/livez") //nolint:noctx
+	suite.a.AssertErr(err)
+}
+
+func (suite apiTestSuite) TestExtendDeadlines() {
+	slow := http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+		writer.WriteHeader(http.StatusOK)
+	})
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /slow", slow)
+	mux.Handle("GET /extended", HTTP.ExtendDeadlines(time.Second, slow))
+
+	// Start a server whose write timeout is shorter than the handler
+	srv := httptest.NewUnstartedServer(mux)
+	srv.Config.WriteTimeout = 50 * time.Millisecond
+	srv.Start()
+	defer srv.Close()
+
+	// Test if the response of the slow route is lost
+	resp, err := http.Get(srv.URL + "/slow") //nolint:noctx
+	if err == nil {
+		suite.a.AssertNoErr(resp.Body.Close())
+	}
+
+	suite.a.AssertErr(err)
+
+	// Test if the route with extended deadlines responds
+	resp, err = http.Get(srv.URL + "/extended") //nolint:noctx
+	suite.a.AssertNoErrf(err)
+	suite.a.AssertNoErr(resp.Body.Close())
+	suite.a.Assert(resp.StatusCode, http.StatusOK)
+}
+
+func (suite apiTestSuite) TestHSTS() {
+	next := http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})
+
+	// Test if the header is only sent over HTTPS
+	handler := HTTP.NewAdapter(utils.Configuration{TLS: &certs.TLS{}, HSTSMaxAge: 3600}).HSTS(next)
+
+	resp := httptest.NewRecorder()
+	handler.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "https://example.com/", nil))
+	suite.a.Assert(resp.Header().Get("Strict-Transport-Security"), "max-age=3600")
+
+	resp = httptest.NewRecorder()
+	handler.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "http://example.com/", nil))
+	suite.a.Assert(resp.Header().Get("Strict-Transport-Security"), "")
+
+	// Test if the header isn't sent when HSTS is disabled
+	handler = HTTP.NewAdapter(utils.Configuration{TLS: &certs.TLS{}}).HSTS(next)
+
+	resp = httptest.NewRecorder()
+	handler.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "https://example.com/", nil))
+	suite.a.Assert(resp.Header().Get("Strict-Transport-Security"), "")
+
+	// Test if the header is sent behind a trusted proxy terminating TLS
+	trusted, err := proxy.ParseTrusted("192.0.2.1")
+	suite.a.AssertNoErrf(err)
+
+	conf := HTTP.NewAdapter(utils.Configuration{TrustedProxies: trusted, HSTSMaxAge: 3600})
+	handler = conf.ResolveClient(conf.HSTS(next))
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	req.Header.Set("X-Forwarded-For", "203.0.113.1")
+	req.Header.Set("X-Forwarded-Proto", "https")
+
+	resp = httptest.NewRecorder()
+	handler.ServeHTTP(resp, req)
+	suite.a.Assert(resp.Header().Get("Strict-Transport-Security"), "max-age=3600")
+
+	// Test if the forwarding headers of an untrusted client are ignored
+	req.RemoteAddr = "198.51.100.1:1234"
+
+	resp = httptest.NewRecorder()
+	handler.ServeHTTP(resp, req)
+	suite.a.Assert(resp.Header().Get("Strict-Transport-Security"), "")
+}
+
+func (suite apiTestSuite) TestRateLimit() { //nolint:funlen
+	var emptyEmbed embed.FS
+	locales, supportedLocales, err := utils.GetLocales("./locales/", emptyEmbed)
+	suite.a.AssertNoErrf(err)
+
+	trusted, err := proxy.ParseTrusted("192.0.2.0/24")
+	suite.a.AssertNoErrf(err)
+
+	limiter := ratelimit.New(ratelimit.NewMemoryStore(), map[ratelimit.Route]ratelimit.Limit{
+		ratelimit.RouteCreate:   {PerMinute: 1, Burst: 2},
+		ratelimit.RoutePassword: {PerMinute: 1, Burst: 1},
+	})
+
+	httpAdapter := HTTP.NewAdapter(utils.Configuration{
+		Store:                  database.NewMemoryStore(),
+		InstanceURL:            "http://127.0.0.1:8080/",
+		DefaultShortLength:     6,
+		DefaultMaxShortLength:  12,
+		DefaultMaxCustomLength: 12,
+		Locales:                locales,
+		SupportedLocales:       supportedLocales,
+		RateLimiter:            limiter,
+		TrustedProxies:         trusted,
+	})
+
+	mux := http.NewServeMux()
+	mux.Handle("POST /", httpAdapter.RateLimit(ratelimit.RouteCreate, http.HandlerFunc(httpAdapter.APICreateLink)))
+	mux.HandleFunc("GET /{short}", httpAdapter.APIRedirectToURL)
+
+	handler := httpAdapter.ResolveClient(mux)
+
+	create := func(clientIP, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		req.Header.Set("X-Forwarded-For", clientIP)
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, req)
+
+		return resp
+	}
+
+	// Test if the burst of creations is allowed, then refused with a Retry-After header
+	suite.a.Assert(create("198.51.100.1", `{"url":"http://example.com/","customPath":"limited","password":"secret"}`).Code, http.StatusCreated)
+	suite.a.Assert(create("198.51.100.1", `{"url":"http://example.com/"}`).Code, http.StatusCreated)
+
+	resp := create("198.51.100.1", `{"url":"http://example.com/"}`)
+	suite.a.Assert(resp.Code, http.StatusTooManyRequests)
+	suite.a.Assert(resp.Header().Get("Retry-After"), "60")
+	suite.a.Assert(strings.Contains(resp.Body.String(), locales["en"].ErrRateLimited), true)
+
+	// Test if the other clients aren't limited, the client being given by the trusted proxy
+	suite.a.Assert(create("198.51.100.2", `{"url":"http://example.com/"}`).Code, http.StatusCreated)
+
+	// Test if the password attempts are limited, the accesses without password aren't
+	access := func(query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/limited"+query, nil)
+		req.Header.Set("X-Forwarded-For", "198.51.100.1")
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, req)
+
+		return resp
+	}
+
+	suite.a.Assert(access("?pass=wrong").Code, http.StatusBadRequest)
+
+	resp = access("?pass=secret")
+	suite.a.Assert(resp.Code, http.StatusTooManyRequests)
+	suite.a.Assert(resp.Header().Get("Retry-After"), "60")
+
+	suite.a.Assert(access("").Code, http.StatusOK)
+}
+
+func (suite apiTestSuite) TestAPIKeys() { //nolint:funlen
+	var emptyEmbed embed.FS
+	locales, supportedLocales, err := utils.GetLocales("./locales/", emptyEmbed)
+	suite.a.AssertNoErrf(err)
+
+	store := database.NewMemoryStore()
+
+	// Create a key for each scope, and an expired one
+	newKey := func(scope apikeys.Scope, expireAt time.Time) string {
+		plain, key, err := apikeys.Generate(string(scope), []apikeys.Scope{scope}, expireAt)
+		suite.a.AssertNoErrf(err)
+		suite.a.AssertNoErrf(store.CreateAPIKey(key))
+
+		return plain
+	}
+
+	creator := newKey(apikeys.ScopeCreate, time.Time{})
+	manager := newKey(apikeys.ScopeManage, time.Time{})
+	stats := newKey(apikeys.ScopeStats, time.Time{})
+	expired := newKey(apikeys.ScopeCreate, time.Now().Add(-time.Minute))
+
+	httpAdapter := HTTP.NewAdapter(utils.Configuration{
+		Store:                  store,
+		InstanceURL:            "http://127.0.0.1:8080/",
+		DefaultShortLength:     6,
+		DefaultMaxShortLength:  12,
+		DefaultMaxCustomLength: 12,
+		Locales:                locales,
+		SupportedLocales:       supportedLocales,
+		Metrics:                metrics.New(),
+		MetricsToken:           "scrape-token",
+		RateLimiter: ratelimit.New(ratelimit.NewMemoryStore(), map[ratelimit.Route]ratelimit.Limit{
+			ratelimit.RouteCreate: {PerMinute: 1, Burst: 1},
+		}),
+		RestrictCreation: true,
+	})
+
+	mux := http.NewServeMux()
+	mux.Handle("POST /", httpAdapter.RequireCreator(
+		httpAdapter.RateLimit(ratelimit.RouteCreate, http.HandlerFunc(httpAdapter.APICreateLink)),
+	))
+	mux.HandleFunc("GET /metrics", httpAdapter.HandlerMetrics)
+	mux.HandleFunc("GET /{short}", httpAdapter.APIRedirectToURL)
+	mux.HandleFunc("PATCH /{short}", httpAdapter.APIUpdateLink)
+	mux.HandleFunc("DELETE /{short}", httpAdapter.APIDeleteLink)
+
+	handler := httpAdapter.Authenticate(mux)
+
+	send := func(method, target, key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if key != "" {
+			req.Header.Set("Authorization", "Bearer "+key)
+		}
+
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, req)
+
+		return resp
+	}
+
+	// Test if the creation is refused without a key having the create scope
+	resp := send(http.MethodPost, "/", "", `{"url":"http://example.com/"}`)
+	suite.a.Assert(resp.Code, http.StatusUnauthorized)
+	suite.a.Assert(resp.Header().Get("WWW-Authenticate") != "", true)
+	suite.a.Assert(strings.Contains(resp.Body.String(), locales["en"].ErrAPIKeyRequired), true)
+
+	suite.a.Assert(send(http.MethodPost, "/", manager, `{"url":"http://example.com/"}`).Code, http.StatusUnauthorized)
+
+	// Test if the unknown and expired keys are refused, whatever the route
+	resp = send(http.MethodPost, "/", apikeys.KeyPrefix+"unknown", `{"url":"http://example.com/"}`)
+	suite.a.Assert(resp.Code, http.StatusUnauthorized)
+	suite.a.Assert(strings.Contains(resp.Body.String(), locales["en"].ErrInvalidAPIKey), true)
+
+	suite.a.Assert(send(http.MethodGet, "/unknown", expired, "").Code, http.StatusUnauthorized)
+
+	// Test if the key having the create scope isn't rate limited
+	suite.a.Assert(send(http.MethodPost, "/", creator, `{"url":"http://example.com/","customPath":"keyed","password":"secret"}`).Code,
+		http.StatusCreated)
+	suite.a.Assert(send(http.MethodPost, "/", creator, `{"url":"http://example.com/"}`).Code, http.StatusCreated)
+	suite.a.Assert(send(http.MethodPost, "/", creator, `{"url":"http://example.com/"}`).Code, http.StatusCreated)
+
+	// Test if the key having the stats scope gets the information of a protected link without its password
+	resp = send(http.MethodGet, "/keyed+", stats, "")
+	suite.a.Assert(resp.Code, http.StatusOK)
+
+	var info JSON.InfoResponse
+	err = json.NewDecoder(resp.Body).Decode(&info)
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(info.DstURL, "http://example.com/")
+
+	// Test if the password is still needed to be redirected, and by the other keys
+	suite.a.Assert(send(http.MethodGet, "/keyed?pass=wrong", stats, "").Code, http.StatusBadRequest)
+	suite.a.Assert(send(http.MethodGet, "/keyed+?pass=wrong", creator, "").Code, http.StatusBadRequest)
+
+	// Test if the key having the stats scope can read the metrics without the metrics token
+	suite.a.Assert(send(http.MethodGet, "/metrics", stats, "").Code, http.StatusOK)
+	suite.a.Assert(send(http.MethodGet, "/metrics", creator, "").Code, http.StatusUnauthorized)
+
+	// Test if the key having the manage scope updates and deletes links without their management token
+	suite.a.Assert(send(http.MethodPatch, "/keyed", creator, `{"url":"http://example.org/"}`).Code, http.StatusUnauthorized)
+
+	resp = send(http.MethodPatch, "/keyed", manager, `{"url":"http://example.org/"}`)
+	suite.a.Assert(resp.Code, http.StatusOK)
+
+	link, err := store.GetLinkByShort("keyed")
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(link.URL, "http://example.org/")
+
+	suite.a.Assert(send(http.MethodDelete, "/keyed", manager, "").Code, http.StatusNoContent)
+	suite.a.Assert(send(http.MethodDelete, "/keyed", manager, "").Code, http.StatusNotFound)
+}
+
 func (suite apiTestSuite) TestMainAPIHandlers() { //nolint:funlen,maintidx
 	testEnv := env.GetEnv("../.env.test")
 	testEnv.DBURL = "api_test.db"
@@ -77,7 +476,7 @@ func (suite apiTestSuite) TestMainAPIHandlers() { //nolint:funlen,maintidx
 	suite.a.AssertNoErrf(err)
 
 	conf := &utils.Configuration{
-		DB:                     dataBase,
+		Store:                  database.NewSQLStore(dataBase, testEnv.DBType),
 		InstanceName:           testEnv.InstanceName,
 		InstanceURL:            testEnv.InstanceURL,
 		Version:                "noVersion",
@@ -392,7 +791,7 @@ func (suite apiTestSuite) TestMainAPIHandlers() { //nolint:funlen,maintidx
 	mux.ServeHTTP(resp, req)
 
 	suite.a.Assert(resp.Code, http.StatusBadRequest)
-	suite.a.Assert(resp.Body.String(), "{\"error\":\"400 The URL is invalid.\"}\n")
+	suite.a.Assert(resp.Body.String(), "{\"error\":\"400 The URL is invalid.\",\"code\":\"invalid_url\"}\n")
 
 	// Test link creation with an invalid custom path
 	params = utils.Parameters{
@@ -413,7 +812,7 @@ func (suite apiTestSuite) TestMainAPIHandlers() { //nolint:funlen,maintidx
 	suite.a.Assert(resp.Code, http.StatusBadRequest)
 	suite.a.Assert(
 		resp.Body.String(),
-		"{\"error\":\"400 Only alphanumerical characters are allowed.\"}\n",
+		"{\"error\":\"400 Only alphanumerical characters are allowed.\",\"code\":\"invalid_path\"}\n",
 	)
 
 	// Test link redirection with a short that does not exist
@@ -443,7 +842,7 @@ func (suite apiTestSuite) TestMainAPIHandlers() { //nolint:funlen,maintidx
 	suite.a.Assert(resp.Code, http.StatusBadRequest)
 	suite.a.Assert(
 		resp.Body.String(),
-		"{\"error\":\"400 Could not create a redirection loop.\"}\n",
+		"{\"error\":\"400 Could not create a redirection loop.\",\"code\":\"redirection_loop\"}\n",
 	)
 }
 
@@ -477,7 +876,7 @@ func (suite apiTestSuite) TestRespondWithError() { //nolint:funlen
 	suite.a.AssertNoErrf(err)
 
 	conf := &utils.Configuration{
-		DB:                     dataBase,
+		Store:                  database.NewSQLStore(dataBase, testEnv.DBType),
 		InstanceName:           testEnv.InstanceName,
 		InstanceURL:            testEnv.InstanceURL,
 		Version:                "noVersion",
@@ -491,6 +890,9 @@ func (suite apiTestSuite) TestRespondWithError() { //nolint:funlen
 		SupportedLocales:       supportedLocales,
 	}
 
+	// The HTML error page needs the templates
+	HTTP.Templates = template.Must(template.ParseGlob("../../static/**/*.tmpl"))
+
 	httpAdapter := HTTP.NewAdapter(*conf)
 	mux := http.NewServeMux()
 	mux.HandleFunc("POST /", httpAdapter.APICreateLink)
@@ -515,7 +917,7 @@ func (suite apiTestSuite) TestRespondWithError() { //nolint:funlen
 	suite.a.Assert(resp.Code, http.StatusBadRequest)
 	suite.a.Assert(
 		resp.Body.String(),
-		"{\"error\":\"400 Could not create a redirection loop.\"}\n",
+		"{\"error\":\"400 Could not create a redirection loop.\",\"code\":\"redirection_loop\"}\n",
 	)
 	suite.a.Assert(resp.Header().Get("Content-Type"), "application/json; charset=UTF-8")
 
@@ -538,6 +940,775 @@ func (suite apiTestSuite) TestRespondWithError() { //nolint:funlen
 
 	suite.a.Assert(resp.Code, http.StatusBadRequest)
 	suite.a.Assert(resp.Header().Get("Content-Type"), "text/html; charset=UTF-8")
+
+	// Test if the request ID given by the client is echoed and attached to the error
+	handler := HTTP.RequestID(mux)
+
+	err = json.NewEncoder(&buf).Encode(params)
+	suite.a.AssertNoErr(err)
+
+	req = httptest.NewRequest(http.MethodPost, "/", &buf)
+	req.Header.Set(logging.RequestIDHeader, "report-me-42")
+	resp = httptest.NewRecorder()
+	handler.ServeHTTP(resp, req)
+
+	suite.a.Assert(resp.Code, http.StatusBadRequest)
+	suite.a.Assert(resp.Header().Get(logging.RequestIDHeader), "report-me-42")
+	suite.a.Assert(
+		resp.Body.String(),
+		"{\"error\":\"400 Could not create a redirection loop.\",\"code\":\"redirection_loop\",\"requestId\":\"report-me-42\"}\n",
+	)
+
+	// Test if an invalid request ID is replaced by a generated one
+	err = json.NewEncoder(&buf).Encode(params)
+	suite.a.AssertNoErr(err)
+
+	req = httptest.NewRequest(http.MethodPost, "/", &buf)
+	req.Header.Set(logging.RequestIDHeader, "not a valid id")
+	resp = httptest.NewRecorder()
+	handler.ServeHTTP(resp, req)
+
+	requestID := resp.Header().Get(logging.RequestIDHeader)
+	suite.a.Assert(logging.IsValidRequestID(requestID), true)
+	suite.a.Assert(strings.Contains(resp.Body.String(), requestID), true)
+}
+
+func (suite apiTestSuite) TestManageAPIHandlers() { //nolint:funlen
+	testEnv := env.GetEnv("../.env.test")
+	testEnv.DBURL = "api_manage_test.db"
+
+	// If the test db already exists, delete it as it will cause errors
+	if _, err := os.Stat(testEnv.DBURL); !errors.Is(err, os.ErrNotExist) {
+		err = os.Remove(testEnv.DBURL)
+		suite.a.AssertNoErrf(err)
+	}
+
+	// Prep everything
+	dataBase, err := database.DBConnect(
+		testEnv.DBType,
+		testEnv.DBURL,
+		testEnv.DBUser,
+		testEnv.DBPass,
+		testEnv.DBHost,
+		testEnv.DBPort,
+		testEnv.DBName,
+	)
+	suite.a.AssertNoErrf(err)
+
+	err = database.CreateLinksTable(dataBase, testEnv.DBType, testEnv.DefaultMaxLength)
+	suite.a.AssertNoErrf(err)
+
+	var emptyEmbed embed.FS
+	locales, supportedLocales, err := utils.GetLocales("./locales/", emptyEmbed)
+	suite.a.AssertNoErrf(err)
+
+	conf := &utils.Configuration{
+		Store:                  database.NewSQLStore(dataBase, testEnv.DBType),
+		InstanceName:           testEnv.InstanceName,
+		InstanceURL:            testEnv.InstanceURL,
+		Version:                "noVersion",
+		AddrAndPort:            testEnv.AddrAndPort,
+		DefaultShortLength:     testEnv.DefaultLength,
+		DefaultMaxShortLength:  testEnv.DefaultMaxLength,
+		DefaultMaxCustomLength: testEnv.DefaultMaxCustomLength,
+		DefaultExpiryTime:      testEnv.DefaultExpiryTime,
+		Locales:                locales,
+		SupportedLocales:       supportedLocales,
+	}
+
+	httpAdapter := HTTP.NewAdapter(*conf)
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /", httpAdapter.APICreateLink)
+	mux.HandleFunc("GET /{short}", httpAdapter.APIRedirectToURL)
+	mux.HandleFunc("PATCH /{short}", httpAdapter.APIUpdateLink)
+	mux.HandleFunc("DELETE /{short}", httpAdapter.APIDeleteLink)
+
+	// Create the link to manage, the management token must be returned
+	params := utils.Parameters{
+		URL:  "http://example.com/",
+		Path: "manage",
+	}
+
+	var buf bytes.Buffer
+	err = json.NewEncoder(&buf).Encode(params)
+	suite.a.AssertNoErr(err)
+
+	req := httptest.NewRequest(http.MethodPost, "/", &buf)
+	resp := httptest.NewRecorder()
+	mux.ServeHTTP(resp, req)
+
+	suite.a.Assertf(resp.Code, http.StatusCreated)
+
+	returnedLink := links.SimpleJSONLink{}
+	err = json.NewDecoder(resp.Body).Decode(&returnedLink)
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(len(returnedLink.Token), 32)
+
+	// Test link update without a token
+	params = utils.Parameters{URL: "http://example.org/"}
+
+	err = json.NewEncoder(&buf).Encode(params)
+	suite.a.AssertNoErr(err)
+
+	req = httptest.NewRequest(http.MethodPatch, "/manage", &buf)
+	resp = httptest.NewRecorder()
+	mux.ServeHTTP(resp, req)
+
+	suite.a.Assert(resp.Code, http.StatusUnauthorized)
+	suite.a.Assert(
+		resp.Body.String(),
+		"{\"error\":\"401 A management token is required to manage this link.\",\"code\":\"token_required\"}\n",
+	)
+
+	// Test link update with a wrong token
+	err = json.NewEncoder(&buf).Encode(params)
+	suite.a.AssertNoErr(err)
+
+	req = httptest.NewRequest(http.MethodPatch, "/manage", &buf)
+	req.Header.Set(HTTP.ManagementTokenHeader, "wrong")
+	resp = httptest.NewRecorder()
+	mux.ServeHTTP(resp, req)
+
+	suite.a.Assert(resp.Code, http.StatusForbidden)
+	suite.a.Assert(resp.Body.String(), "{\"error\":\"403 Wrong management token has been given.\",\"code\":\"wrong_token\"}\n")
+
+	// Test link update with the right token
+	err = json.NewEncoder(&buf).Encode(params)
+	suite.a.AssertNoErr(err)
+
+	req = httptest.NewRequest(http.MethodPatch, "/manage", &buf)
+	req.Header.Set(HTTP.ManagementTokenHeader, returnedLink.Token)
+	resp = httptest.NewRecorder()
+	mux.ServeHTTP(resp, req)
+
+	suite.a.Assert(resp.Code, http.StatusOK)
+
+	updatedLink := links.SimpleJSONLink{}
+	err = json.NewDecoder(resp.Body).Decode(&updatedLink)
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(updatedLink.URL, params.URL)
+	suite.a.Assert(updatedLink.ExpireAt, returnedLink.ExpireAt)
+	suite.a.Assert(updatedLink.Token, "")
+
+	// Test link redirection to the updated url
+	req = httptest.NewRequest(http.MethodGet, "/manage", nil)
+	resp = httptest.NewRecorder()
+	mux.ServeHTTP(resp, req)
+
+	suite.a.Assert(resp.Code, http.StatusSeeOther)
+	suite.a.Assert(resp.Header().Get("Location"), params.URL)
+
+	// Test if a password can be set, the password being then asked, and removed
+	for _, update := range []struct {
+		body   string
+		status int
+	}{
+		{`{"password":"secret123"}`, http.StatusOK},
+		{`{"removePassword":true}`, http.StatusSeeOther},
+	} {
+		req = httptest.NewRequest(http.MethodPatch, "/manage", strings.NewReader(update.body))
+		req.Header.Set(HTTP.ManagementTokenHeader, returnedLink.Token)
+		resp = httptest.NewRecorder()
+		mux.ServeHTTP(resp, req)
+
+		suite.a.Assert(resp.Code, http.StatusOK)
+
+		req = httptest.NewRequest(http.MethodGet, "/manage", nil)
+		resp = httptest.NewRecorder()
+		mux.ServeHTTP(resp, req)
+
+		suite.a.Assert(resp.Code, update.status)
+	}
+
+	// Test link deletion with a wrong token
+	req = httptest.NewRequest(http.MethodDelete, "/manage", nil)
+	req.Header.Set(HTTP.ManagementTokenHeader, "wrong")
+	resp = httptest.NewRecorder()
+	mux.ServeHTTP(resp, req)
+
+	suite.a.Assert(resp.Code, http.StatusForbidden)
+
+	// Test link deletion with the right token
+	req = httptest.NewRequest(http.MethodDelete, "/manage", nil)
+	req.Header.Set(HTTP.ManagementTokenHeader, returnedLink.Token)
+	resp = httptest.NewRecorder()
+	mux.ServeHTTP(resp, req)
+
+	suite.a.Assert(resp.Code, http.StatusNoContent)
+
+	// Test link redirection of the deleted link
+	req = httptest.NewRequest(http.MethodGet, "/manage", nil)
+	resp = httptest.NewRecorder()
+	mux.ServeHTTP(resp, req)
+
+	suite.a.Assert(resp.Code, http.StatusNotFound)
+}
+
+func (suite apiTestSuite) TestAnalyticsAPIHandlers() { //nolint:funlen
+	testEnv := env.GetEnv("../.env.test")
+	testEnv.DBURL = "api_analytics_test.db"
+
+	// If the test db already exists, delete it as it will cause errors
+	if _, err := os.Stat(testEnv.DBURL); !errors.Is(err, os.ErrNotExist) {
+		err = os.Remove(testEnv.DBURL)
+		suite.a.AssertNoErrf(err)
+	}
+
+	// Prep everything
+	dataBase, err := database.DBConnect(
+		testEnv.DBType,
+		testEnv.DBURL,
+		testEnv.DBUser,
+		testEnv.DBPass,
+		testEnv.DBHost,
+		testEnv.DBPort,
+		testEnv.DBName,
+	)
+	suite.a.AssertNoErrf(err)
+
+	err = database.CreateLinksTable(dataBase, testEnv.DBType, testEnv.DefaultMaxLength)
+	suite.a.AssertNoErrf(err)
+
+	store := database.NewSQLStore(dataBase, testEnv.DBType)
+
+	err = store.CreateLink(database.Link{
+		ID:        uuid.New(),
+		CreatedAt: time.Now().UTC(),
+		ExpireAt:  time.Now().UTC().Add(time.Hour),
+		URL:       "http://example.com/",
+		Short:     "counted",
+	})
+	suite.a.AssertNoErrf(err)
+
+	var emptyEmbed embed.FS
+	locales, supportedLocales, err := utils.GetLocales("./locales/", emptyEmbed)
+	suite.a.AssertNoErrf(err)
+
+	conf := &utils.Configuration{
+		Store:                  store,
+		InstanceName:           testEnv.InstanceName,
+		InstanceURL:            testEnv.InstanceURL,
+		Version:                "noVersion",
+		AddrAndPort:            testEnv.AddrAndPort,
+		DefaultShortLength:     testEnv.DefaultLength,
+		DefaultMaxShortLength:  testEnv.DefaultMaxLength,
+		DefaultMaxCustomLength: testEnv.DefaultMaxCustomLength,
+		DefaultExpiryTime:      testEnv.DefaultExpiryTime,
+		Locales:                locales,
+		SupportedLocales:       supportedLocales,
+		Hits:                   analytics.NewRecorder(store),
+	}
+
+	httpAdapter := HTTP.NewAdapter(*conf)
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{short}", httpAdapter.APIRedirectToURL)
+
+	// Access the link twice
+	for range 2 {
+		req := httptest.NewRequest(http.MethodGet, "/counted", nil)
+		req.Header.Set("Referer", "https://example.org/post")
+		req.Header.Set("User-Agent", "curl/8.5.0")
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, req)
+
+		suite.a.Assert(resp.Code, http.StatusSeeOther)
+	}
+
+	// Flush the recorded accesses
+	conf.Hits.Close()
+
+	// Test that the accesses are part of the link information
+	req := httptest.NewRequest(http.MethodGet, "/counted+", nil)
+	resp := httptest.NewRecorder()
+	mux.ServeHTTP(resp, req)
+
+	suite.a.Assert(resp.Code, http.StatusOK)
+
+	var info struct {
+		Hits struct {
+			Total     int            `json:"total"`
+			Referrers map[string]int `json:"referrers"`
+			Agents    map[string]int `json:"agents"`
+		} `json:"hits"`
+	}
+
+	err = json.NewDecoder(resp.Body).Decode(&info)
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(info.Hits.Total, 2)
+	suite.a.Assert(info.Hits.Referrers["example.org"], 2)
+	suite.a.Assert(info.Hits.Agents[analytics.AgentCLI], 2)
+}
+
+func (suite apiTestSuite) TestBatchAPIHandlers() { //nolint:funlen
+	testEnv := env.GetEnv("../.env.test")
+	testEnv.DBURL = "api_batch_test.db"
+
+	// If the test db already exists, delete it as it will cause errors
+	if _, err := os.Stat(testEnv.DBURL); !errors.Is(err, os.ErrNotExist) {
+		err = os.Remove(testEnv.DBURL)
+		suite.a.AssertNoErrf(err)
+	}
+
+	// Prep everything
+	dataBase, err := database.DBConnect(
+		testEnv.DBType,
+		testEnv.DBURL,
+		testEnv.DBUser,
+		testEnv.DBPass,
+		testEnv.DBHost,
+		testEnv.DBPort,
+		testEnv.DBName,
+	)
+	suite.a.AssertNoErrf(err)
+
+	err = database.CreateLinksTable(dataBase, testEnv.DBType, testEnv.DefaultMaxLength)
+	suite.a.AssertNoErrf(err)
+
+	var emptyEmbed embed.FS
+	locales, supportedLocales, err := utils.GetLocales("./locales/", emptyEmbed)
+	suite.a.AssertNoErrf(err)
+
+	conf := &utils.Configuration{
+		Store:                  database.NewSQLStore(dataBase, testEnv.DBType),
+		InstanceName:           testEnv.InstanceName,
+		InstanceURL:            testEnv.InstanceURL,
+		Version:                "noVersion",
+		AddrAndPort:            testEnv.AddrAndPort,
+		DefaultShortLength:     testEnv.DefaultLength,
+		DefaultMaxShortLength:  testEnv.DefaultMaxLength,
+		DefaultMaxCustomLength: testEnv.DefaultMaxCustomLength,
+		DefaultExpiryTime:      testEnv.DefaultExpiryTime,
+		Locales:                locales,
+		SupportedLocales:       supportedLocales,
+	}
+
+	httpAdapter := HTTP.NewAdapter(*conf)
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/batch", httpAdapter.APICreateLinks)
+	mux.HandleFunc("GET /{short}", httpAdapter.APIRedirectToURL)
+
+	// Create a batch sent as a JSON array
+	batch, err := json.Marshal([]utils.Parameters{
+		{URL: "http://example.com/", Path: "jsonbatch"},
+		{URL: "invalid"},
+	})
+	suite.a.AssertNoErrf(err)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/batch", bytes.NewBuffer(batch))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	mux.ServeHTTP(resp, req)
+
+	suite.a.Assert(resp.Code, http.StatusOK)
+
+	var response links.BatchJSONResponse
+	err = json.NewDecoder(resp.Body).Decode(&response)
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(response.Created, 1)
+	suite.a.Assert(response.Failed, 1)
+	suite.a.Assert(response.Results[0].Row, 1)
+	suite.a.Assert(response.Results[0].Code, http.StatusCreated)
+	suite.a.Assert(response.Results[0].Short, "jsonbatch")
+	suite.a.AssertNotEmpty(response.Results[0].Token, "")
+	suite.a.Assert(response.Results[1].Row, 2)
+	suite.a.Assert(response.Results[1].Code, http.StatusBadRequest)
+	suite.a.Assert(response.Results[1].Error, conf.Locales["en"].ErrInvalidURL)
+
+	// The created link can be accessed
+	req = httptest.NewRequest(http.MethodGet, "/jsonbatch", nil)
+	resp = httptest.NewRecorder()
+	mux.ServeHTTP(resp, req)
+
+	suite.a.Assert(resp.Code, http.StatusSeeOther)
+
+	// Create a batch sent as a CSV file, the path already in use must be the only failing row
+	req = httptest.NewRequest(
+		http.MethodPost,
+		"/api/batch",
+		strings.NewReader("url,customPath\nhttp://example.com/,jsonbatch\nhttp://example.org/,csvbatch\n"),
+	)
+	req.Header.Set("Content-Type", "text/csv")
+	resp = httptest.NewRecorder()
+	mux.ServeHTTP(resp, req)
+
+	suite.a.Assert(resp.Code, http.StatusOK)
+
+	response = links.BatchJSONResponse{}
+	err = json.NewDecoder(resp.Body).Decode(&response)
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(response.Created, 1)
+	suite.a.Assert(response.Results[0].Error, conf.Locales["en"].ErrPathInUse)
+	suite.a.Assert(response.Results[1].Short, "csvbatch")
+
+	// Send an invalid batch
+	req = httptest.NewRequest(http.MethodPost, "/api/batch", strings.NewReader("{}"))
+	req.Header.Set("Content-Type", "application/json")
+	resp = httptest.NewRecorder()
+	mux.ServeHTTP(resp, req)
+
+	suite.a.Assert(resp.Code, http.StatusBadRequest)
+}
+
+func (suite apiTestSuite) TestMetricsAPIHandlers() { //nolint:funlen
+	testEnv := env.GetEnv("../.env.test")
+
+	var emptyEmbed embed.FS
+	locales, supportedLocales, err := utils.GetLocales("./locales/", emptyEmbed)
+	suite.a.AssertNoErrf(err)
+
+	conf := &utils.Configuration{
+		Store:                  database.NewMemoryStore(),
+		InstanceName:           testEnv.InstanceName,
+		InstanceURL:            testEnv.InstanceURL,
+		Version:                "noVersion",
+		AddrAndPort:            testEnv.AddrAndPort,
+		DefaultShortLength:     testEnv.DefaultLength,
+		DefaultMaxShortLength:  testEnv.DefaultMaxLength,
+		DefaultMaxCustomLength: testEnv.DefaultMaxCustomLength,
+		DefaultExpiryTime:      testEnv.DefaultExpiryTime,
+		Locales:                locales,
+		SupportedLocales:       supportedLocales,
+		Metrics:                metrics.New(),
+		MetricsToken:           "scrape-token",
+	}
+
+	httpAdapter := HTTP.NewAdapter(*conf)
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /metrics", httpAdapter.HandlerMetrics)
+	mux.HandleFunc("GET /{short}", httpAdapter.APIRedirectToURL)
+	mux.HandleFunc("POST /", httpAdapter.APICreateLink)
+	handler := httpAdapter.Instrument(mux)
+
+	// Create a link with a custom short and a protected one
+	for _, params := range []utils.Parameters{
+		{URL: "http://example.com/", Path: "measured"},
+		{URL: "http://example.org/", Password: "secret"},
+	} {
+		var buf bytes.Buffer
+		err = json.NewEncoder(&buf).Encode(params)
+		suite.a.AssertNoErr(err)
+
+		req := httptest.NewRequest(http.MethodPost, "/", &buf)
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, req)
+
+		suite.a.Assert(resp.Code, http.StatusCreated)
+	}
+
+	// Access the custom link and give a wrong password
+	req := httptest.NewRequest(http.MethodGet, "/measured", nil)
+	resp := httptest.NewRecorder()
+	handler.ServeHTTP(resp, req)
+
+	suite.a.Assert(resp.Code, http.StatusSeeOther)
+
+	found, _, err := conf.Store.ListLinks(database.LinkFilter{URL: "example.org"})
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(len(found), 1)
+
+	req = httptest.NewRequest(http.MethodGet, "/"+found[0].Short+"?pass=wrong", nil)
+	resp = httptest.NewRecorder()
+	handler.ServeHTTP(resp, req)
+
+	suite.a.Assert(resp.Code, http.StatusBadRequest)
+
+	// Test the metrics without the token and with a wrong one
+	req = httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	resp = httptest.NewRecorder()
+	handler.ServeHTTP(resp, req)
+
+	var errResp JSON.ErrResponse
+	err = json.NewDecoder(resp.Body).Decode(&errResp)
+	suite.a.AssertNoErrf(err)
+	suite.a.Assert(resp.Code, http.StatusUnauthorized)
+	suite.a.Assert(errResp.Code, JSON.CodeMetricsTokenRequired)
+	suite.a.Assert(errResp.Error, "401 A valid metrics token is required.")
+
+	req = httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.Header.Set("Authorization", "Bearer wrong")
+	resp = httptest.NewRecorder()
+	handler.ServeHTTP(resp, req)
+
+	suite.a.Assert(resp.Code, http.StatusUnauthorized)
+
+	// Test the metrics with the token
+	req = httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.Header.Set("Authorization", "Bearer scrape-token")
+	resp = httptest.NewRecorder()
+	handler.ServeHTTP(resp, req)
+
+	suite.a.Assert(resp.Code, http.StatusOK)
+	suite.a.Assert(resp.Header().Get("Content-Type"), metrics.ContentType)
+
+	for _, expected := range []string{
+		`reddlinks_http_requests_total{route="/",method="POST",code="201"} 2` + "\n",
+		`reddlinks_http_requests_total{route="/{short}",method="GET",code="303"} 1` + "\n",
+		`reddlinks_http_requests_total{route="/{short}",method="GET",code="400"} 1` + "\n",
+		`reddlinks_http_requests_total{route="/metrics",method="GET",code="401"} 2` + "\n",
+		"reddlinks_redirects_total 1\n",
+		`reddlinks_links_created_total{password="false",custom="true"} 1` + "\n",
+		`reddlinks_links_created_total{password="true",custom="false"} 1` + "\n",
+		"reddlinks_password_failures_total 1\n",
+	} {
+		suite.a.Assert(strings.Contains(resp.Body.String(), expected), true)
+	}
+}
+
+func (suite apiTestSuite) TestAPIv1Handlers() { //nolint:funlen
+	testEnv := env.GetEnv("../.env.test")
+	testEnv.DBURL = "api_v1_test.db"
+
+	// If the test db already exists, delete it as it will cause errors
+	if _, err := os.Stat(testEnv.DBURL); !errors.Is(err, os.ErrNotExist) {
+		err = os.Remove(testEnv.DBURL)
+		suite.a.AssertNoErrf(err)
+	}
+
+	// Prep everything
+	store, err := database.OpenStore(testEnv.DBType, testEnv.DBURL, "", "", "", "", "", testEnv.DefaultMaxLength)
+	suite.a.AssertNoErrf(err)
+
+	var emptyEmbed embed.FS
+	locales, supportedLocales, err := utils.GetLocales("./locales/", emptyEmbed)
+	suite.a.AssertNoErrf(err)
+
+	conf := &utils.Configuration{
+		Store:                  store,
+		InstanceName:           testEnv.InstanceName,
+		InstanceURL:            testEnv.InstanceURL,
+		Version:                "noVersion",
+		DefaultShortLength:     testEnv.DefaultLength,
+		DefaultMaxShortLength:  testEnv.DefaultMaxLength,
+		DefaultMaxCustomLength: testEnv.DefaultMaxCustomLength,
+		DefaultExpiryTime:      testEnv.DefaultExpiryTime,
+		MaxBodyBytes:           256,
+		MaxBatchBytes:          512,
+		Locales:                locales,
+		SupportedLocales:       supportedLocales,
+	}
+
+	httpAdapter := HTTP.NewAdapter(*conf)
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/v1/links", httpAdapter.APICreateLink)
+	mux.HandleFunc("POST /api/v1/links/batch", httpAdapter.APICreateLinks)
+	mux.HandleFunc("GET /api/v1/links/{short}", httpAdapter.APIGetLink)
+	mux.HandleFunc("PATCH /api/v1/links/{short}", httpAdapter.APIUpdateLink)
+	mux.HandleFunc("DELETE /api/v1/links/{short}", httpAdapter.APIDeleteLink)
+	mux.HandleFunc("GET /api/v1/openapi.json", HTTP.APIOpenAPI)
+
+	// decodeError decodes a typed error and checks its status
+	decodeError := func(resp *httptest.ResponseRecorder, status int) JSON.APIError {
+		suite.a.Assertf(resp.Code, status)
+		suite.a.Assert(resp.Header().Get("Content-Type"), "application/json; charset=UTF-8")
+
+		var errResp JSON.APIErrResponse
+		err := json.NewDecoder(resp.Body).Decode(&errResp)
+		suite.a.AssertNoErrf(err)
+		suite.a.Assert(errResp.Error.Status, status)
+		suite.a.AssertNotEmpty(errResp.Error.Message, "")
+
+		return errResp.Error
+	}
+
+	// Test the creation of a protected link
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/links",
+		strings.NewReader(`{"url":"http://example.com/","customPath":"versioned","password":"secret"}`))
+	resp := httptest.NewRecorder()
+	mux.ServeHTTP(resp, req)
+	suite.a.Assertf(resp.Code, http.StatusCreated)
+
+	created := links.PassJSONLink{}
+	err = json.NewDecoder(resp.Body).Decode(&created)
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(strings.HasSuffix(created.ShortenedLink, "/versioned"), true)
+	suite.a.Assert(len(created.Token), 32)
+
+	// Test if the errors of the link creation are typed, even for browsers
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/links", strings.NewReader(`{"url":"http://example.com/","customPath":"versioned"}`))
+	req.Header.Set("Accept", "text/html")
+	resp = httptest.NewRecorder()
+	mux.ServeHTTP(resp, req)
+	suite.a.Assert(decodeError(resp, http.StatusBadRequest).Code, JSON.CodePathInUse)
+
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/links", strings.NewReader(`not json`))
+	resp = httptest.NewRecorder()
+	mux.ServeHTTP(resp, req)
+	suite.a.Assert(decodeError(resp, http.StatusBadRequest).Code, JSON.CodeInvalidJSON)
+
+	// Test if the information of a protected link requires its password, instead of asking for it
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/links/versioned", nil)
+	req.Header.Set("Accept", "text/html")
+	resp = httptest.NewRecorder()
+	mux.ServeHTTP(resp, req)
+	suite.a.Assert(decodeError(resp, http.StatusUnauthorized).Code, JSON.CodePasswordRequired)
+
+	// Test the information of the link with its password, even for browsers
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/links/versioned?pass=secret", nil)
+	req.Header.Set("Accept", "text/html")
+	resp = httptest.NewRecorder()
+	mux.ServeHTTP(resp, req)
+	suite.a.Assertf(resp.Code, http.StatusOK)
+
+	info := JSON.InfoResponse{}
+	err = json.NewDecoder(resp.Body).Decode(&info)
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(info.Short, "versioned")
+	suite.a.Assert(info.DstURL, "http://example.com/")
+
+	// Test the information of a link that doesn't exist
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/links/missing", nil)
+	resp = httptest.NewRecorder()
+	mux.ServeHTTP(resp, req)
+	suite.a.Assert(decodeError(resp, http.StatusNotFound).Code, JSON.CodeNotFound)
+
+	// Test the update of the link without and with its management token
+	req = httptest.NewRequest(http.MethodPatch, "/api/v1/links/versioned", strings.NewReader(`{"url":"http://example.org/"}`))
+	resp = httptest.NewRecorder()
+	mux.ServeHTTP(resp, req)
+	suite.a.Assert(decodeError(resp, http.StatusUnauthorized).Code, JSON.CodeTokenRequired)
+
+	req = httptest.NewRequest(http.MethodPatch, "/api/v1/links/versioned", strings.NewReader(`{"url":"http://example.org/"}`))
+	req.Header.Set(HTTP.ManagementTokenHeader, "wrong")
+	resp = httptest.NewRecorder()
+	mux.ServeHTTP(resp, req)
+	suite.a.Assert(decodeError(resp, http.StatusForbidden).Code, JSON.CodeWrongToken)
+
+	req = httptest.NewRequest(http.MethodPatch, "/api/v1/links/versioned", strings.NewReader(`{"url":"http://example.org/"}`))
+	req.Header.Set(HTTP.ManagementTokenHeader, created.Token)
+	resp = httptest.NewRecorder()
+	mux.ServeHTTP(resp, req)
+	suite.a.Assertf(resp.Code, http.StatusOK)
+
+	// Test if the bodies larger than the configured limit are refused as such
+	tooLarge := `{"url":"http://example.com/` + strings.Repeat("a", 512) + `"}`
+
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/links", strings.NewReader(tooLarge))
+	resp = httptest.NewRecorder()
+	mux.ServeHTTP(resp, req)
+	suite.a.Assert(decodeError(resp, http.StatusRequestEntityTooLarge).Code, JSON.CodePayloadTooLarge)
+
+	req = httptest.NewRequest(http.MethodPatch, "/api/v1/links/versioned", strings.NewReader(tooLarge))
+	req.Header.Set(HTTP.ManagementTokenHeader, created.Token)
+	resp = httptest.NewRecorder()
+	mux.ServeHTTP(resp, req)
+	suite.a.Assert(decodeError(resp, http.StatusRequestEntityTooLarge).Code, JSON.CodePayloadTooLarge)
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/links/versioned", strings.NewReader(tooLarge))
+	req.Header.Set("Content-Type", "application/json")
+	resp = httptest.NewRecorder()
+	mux.ServeHTTP(resp, req)
+	suite.a.Assert(decodeError(resp, http.StatusRequestEntityTooLarge).Code, JSON.CodePayloadTooLarge)
+
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/links/batch", strings.NewReader("["+strings.Repeat(tooLarge+",", 2)+tooLarge+"]"))
+	req.Header.Set("Content-Type", "application/json")
+	resp = httptest.NewRecorder()
+	mux.ServeHTTP(resp, req)
+	suite.a.Assert(decodeError(resp, http.StatusRequestEntityTooLarge).Code, JSON.CodeBatchTooLarge)
+
+	// Test a batch
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/links/batch", strings.NewReader(`[{"url":"http://example.com/"},{"url":"invalid"}]`))
+	req.Header.Set("Content-Type", "application/json")
+	resp = httptest.NewRecorder()
+	mux.ServeHTTP(resp, req)
+	suite.a.Assertf(resp.Code, http.StatusOK)
+
+	batch := links.BatchJSONResponse{}
+	err = json.NewDecoder(resp.Body).Decode(&batch)
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(batch.Created, 1)
+	suite.a.Assert(batch.Failed, 1)
+
+	// Test the deletion of the link
+	req = httptest.NewRequest(http.MethodDelete, "/api/v1/links/versioned", nil)
+	req.Header.Set(HTTP.ManagementTokenHeader, created.Token)
+	resp = httptest.NewRecorder()
+	mux.ServeHTTP(resp, req)
+	suite.a.Assertf(resp.Code, http.StatusNoContent)
+
+	req = httptest.NewRequest(http.MethodDelete, "/api/v1/links/versioned", nil)
+	req.Header.Set(HTTP.ManagementTokenHeader, created.Token)
+	resp = httptest.NewRecorder()
+	mux.ServeHTTP(resp, req)
+	suite.a.Assert(decodeError(resp, http.StatusNotFound).Code, JSON.CodeNotFound)
+
+	// Test if the information about a changed length is given along with the created link, once every short of the length is used
+	full := database.NewMemoryStore()
+	for _, char := range "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789" {
+		err = full.CreateLink(database.Link{ID: uuid.New(), ExpireAt: time.Now().Add(time.Hour), URL: "http://example.com/", Short: string(char)})
+		suite.a.AssertNoErrf(err)
+	}
+
+	fullConf := *conf
+	fullConf.Store = full
+	fullConf.DefaultShortLength = 1
+
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/links", strings.NewReader(`{"url":"http://example.com/","length":1}`))
+	resp = httptest.NewRecorder()
+	HTTP.NewAdapter(fullConf).APICreateLink(resp, req)
+	suite.a.Assertf(resp.Code, http.StatusCreated)
+
+	var lengthened links.SimpleJSONLink
+	decoder := json.NewDecoder(resp.Body)
+	err = decoder.Decode(&lengthened)
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(lengthened.Information, locales["en"].InfoLengthChange)
+	suite.a.Assert(len(lengthened.ShortenedLink)-len(strings.TrimPrefix(testEnv.InstanceURL, "http://")), 2)
+	suite.a.Assert(decoder.More(), false)
+
+	// Test if the OpenAPI document describes the routes
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/openapi.json", nil)
+	resp = httptest.NewRecorder()
+	mux.ServeHTTP(resp, req)
+	suite.a.Assertf(resp.Code, http.StatusOK)
+
+	var spec struct {
+		OpenAPI    string                    `json:"openapi"`
+		Paths      map[string]map[string]any `json:"paths"`
+		Components struct {
+			Schemas struct {
+				Error struct {
+					Properties struct {
+						Code struct {
+							Enum []JSON.ErrorCode `json:"enum"`
+						} `json:"code"`
+					} `json:"properties"`
+				} `json:"Error"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&spec)
+	suite.a.AssertNoErrf(err)
+	suite.a.Assert(strings.HasPrefix(spec.OpenAPI, "3."), true)
+
+	for path, methods := range map[string][]string{
+		"/api/v1/links":         {"post"},
+		"/api/v1/links/batch":   {"post"},
+		"/api/v1/links/{short}": {"get", "patch", "delete"},
+		"/api/v1/openapi.json":  {"get"},
+	} {
+		for _, method := range methods {
+			if _, found := spec.Paths[path][method]; !found {
+				suite.t.Errorf("The OpenAPI document doesn't describe %s %s", method, path)
+			}
+		}
+	}
+
+	// Test if the OpenAPI document lists every error code
+	for _, code := range JSON.ErrorCodes() {
+		if !slices.Contains(spec.Components.Schemas.Error.Properties.Code.Enum, code) {
+			suite.t.Errorf("The OpenAPI document doesn't list the %s error code", code)
+		}
+	}
+
+	// Test if the legacy routes keep their error format
+	legacy := http.NewServeMux()
+	legacy.HandleFunc("GET /{short}", httpAdapter.APIRedirectToURL)
+
+	req = httptest.NewRequest(http.MethodGet, "/missing", nil)
+	resp = httptest.NewRecorder()
+	legacy.ServeHTTP(resp, req)
+	suite.a.Assert(resp.Code, http.StatusNotFound)
+	suite.a.Assert(strings.HasPrefix(resp.Body.String(), "{\"error\":\"404 "), true)
 }
 
 // Test suite structure.
@@ -558,6 +1729,17 @@ func TestAPISuite(t *testing.T) {
 
 	// Call the tests
 	suite.TestReadiness()
+	suite.TestProbes()
+	suite.TestGracefulShutdown()
+	suite.TestHSTS()
+	suite.TestExtendDeadlines()
+	suite.TestRateLimit()
+	suite.TestAPIKeys()
 	suite.TestMainAPIHandlers()
 	suite.TestRespondWithError()
+	suite.TestManageAPIHandlers()
+	suite.TestAnalyticsAPIHandlers()
+	suite.TestBatchAPIHandlers()
+	suite.TestMetricsAPIHandlers()
+	suite.TestAPIv1Handlers()
 }
@@ -17,18 +17,31 @@
 package http_test
 
 import (
+	"bytes"
+	"context"
+	"database/sql"
+	"embed"
 	"errors"
 	"html/template"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"os"
+	"regexp"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/google/uuid"
+	"github.com/redds-be/reddlinks/internal/accounts"
+	"github.com/redds-be/reddlinks/internal/admin"
+	"github.com/redds-be/reddlinks/internal/apikeys"
 	"github.com/redds-be/reddlinks/internal/database"
 	"github.com/redds-be/reddlinks/internal/env"
 	HTTP "github.com/redds-be/reddlinks/internal/http"
+	"github.com/redds-be/reddlinks/internal/oidc"
+	"github.com/redds-be/reddlinks/internal/ratelimit"
 	"github.com/redds-be/reddlinks/internal/utils"
 	"github.com/redds-be/reddlinks/test/helper"
 )
@@ -114,7 +127,7 @@ func (suite frontTestSuite) TestMainFrontHandlers() { //nolint:funlen
 	suite.a.AssertNoErr(err)
 
 	conf := &utils.Configuration{
-		DB:                     dataBase,
+		Store:                  database.NewSQLStore(dataBase, testEnv.DBType),
 		InstanceName:           testEnv.InstanceName,
 		InstanceURL:            testEnv.InstanceURL,
 		Version:                "noVersion",
@@ -167,7 +180,7 @@ func (suite frontTestSuite) TestMainFrontHandlers() { //nolint:funlen
 	addForm := url.Values{
 		"add":             {"Add"},
 		"length":          {"6"},
-		"expire_datetime": {"2000-01-02T12:12"},
+		"expire_datetime": {"2100-01-02T12:12"},
 		"url":             {"https://example.com"},
 		"short":           {"addpagetest"},
 		"password":        {"secret"},
@@ -230,6 +243,709 @@ func (suite frontTestSuite) TestMainFrontHandlers() { //nolint:funlen
 	httpAdapter.FrontHandlerRedirectToURL(resp, req)
 
 	suite.a.Assert(resp.Code, http.StatusOK)
+
+	// Test that an expired link isn't found, even before the garbage collection
+	addForm.Set("expire_datetime", "2000-01-02T12:12")
+	addForm.Set("short", "expiredtest")
+
+	req = httptest.NewRequest(http.MethodPost, "/add", strings.NewReader(addForm.Encode()))
+	req.Header.Set("Accept-Language", "en-US,en;q=0.5")
+	resp = httptest.NewRecorder()
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	httpAdapter.FrontHandlerAdd(resp, req)
+
+	suite.a.Assert(resp.Code, http.StatusCreated)
+
+	redirectForm.Set("short", "expiredtest")
+	redirectForm.Del("info")
+
+	req = httptest.NewRequest(http.MethodPost, "/pass", strings.NewReader(redirectForm.Encode()))
+	req.Header.Set("Accept-Language", "en-US,en;q=0.5")
+	resp = httptest.NewRecorder()
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	httpAdapter.FrontHandlerRedirectToURL(resp, req)
+
+	suite.a.Assert(resp.Code, http.StatusNotFound)
+
+	// Test the upload of a CSV file to create several links
+	body := &bytes.Buffer{}
+	form := multipart.NewWriter(body)
+	file, err := form.CreateFormFile("file", "batch.csv")
+	suite.a.AssertNoErr(err)
+	_, err = file.Write([]byte("url,customPath\nhttps://example.com,frontbatch\ninvalid,\n"))
+	suite.a.AssertNoErr(err)
+	err = form.Close()
+	suite.a.AssertNoErr(err)
+
+	req = httptest.NewRequest(http.MethodPost, "/batch", body)
+	req.Header.Set("Accept-Language", "en-US,en;q=0.5")
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	resp = httptest.NewRecorder()
+
+	httpAdapter.FrontHandlerBatch(resp, req)
+
+	suite.a.Assert(resp.Code, http.StatusOK)
+	suite.a.Assert(strings.Contains(resp.Body.String(), "frontbatch"), true)
+
+	_, err = conf.Store.GetLinkByShort("frontbatch")
+	suite.a.AssertNoErr(err)
+}
+
+func (suite frontTestSuite) TestAdminHandlers() { //nolint:funlen
+	HTTP.Templates = template.Must(template.ParseGlob("../../static/**/*.tmpl"))
+
+	testEnv := env.GetEnv("../.env.test")
+	testEnv.DBURL = "front_admin_test.db"
+
+	// If the test db already exists, delete it as it will cause errors
+	if _, err := os.Stat(testEnv.DBURL); !errors.Is(err, os.ErrNotExist) {
+		err = os.Remove(testEnv.DBURL)
+		suite.a.AssertNoErr(err)
+	}
+
+	// Prep everything
+	dataBase, err := database.DBConnect(
+		testEnv.DBType,
+		testEnv.DBURL,
+		testEnv.DBUser,
+		testEnv.DBPass,
+		testEnv.DBHost,
+		testEnv.DBPort,
+		testEnv.DBName,
+	)
+	suite.a.AssertNoErr(err)
+
+	err = database.CreateLinksTable(dataBase, testEnv.DBType, testEnv.DefaultMaxLength)
+	suite.a.AssertNoErr(err)
+
+	store := database.NewSQLStore(dataBase, testEnv.DBType)
+
+	for _, short := range []string{"adminone", "admintwo", "other"} {
+		err = store.CreateLink(database.Link{
+			ID:        uuid.New(),
+			CreatedAt: time.Now().UTC(),
+			ExpireAt:  time.Now().UTC().Add(time.Hour),
+			URL:       "https://example.com/" + short,
+			Short:     short,
+		})
+		suite.a.AssertNoErr(err)
+	}
+
+	conf := &utils.Configuration{
+		Store:                  store,
+		InstanceName:           testEnv.InstanceName,
+		InstanceURL:            testEnv.InstanceURL,
+		Version:                "noVersion",
+		AddrAndPort:            testEnv.AddrAndPort,
+		DefaultShortLength:     testEnv.DefaultLength,
+		DefaultMaxShortLength:  testEnv.DefaultMaxLength,
+		DefaultMaxCustomLength: testEnv.DefaultMaxCustomLength,
+		DefaultExpiryTime:      testEnv.DefaultExpiryTime,
+	}
+
+	// Test that the admin dashboard doesn't exist without an admin password
+	req := httptest.NewRequest(http.MethodGet, "/admin", nil)
+	resp := httptest.NewRecorder()
+
+	HTTP.NewAdapter(*conf).FrontHandlerAdmin(resp, req)
+
+	suite.a.Assert(resp.Code, http.StatusNotFound)
+
+	conf.Admin, err = admin.NewAuth("adminsecret", "")
+	suite.a.AssertNoErrf(err)
+
+	conf.RateLimiter = ratelimit.New(ratelimit.NewMemoryStore(), map[ratelimit.Route]ratelimit.Limit{
+		ratelimit.RoutePassword: {PerMinute: 1, Burst: 2},
+	})
+
+	httpAdapter := HTTP.NewAdapter(*conf)
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /admin", httpAdapter.FrontHandlerAdmin)
+	mux.HandleFunc("POST /admin", httpAdapter.FrontHandlerAdminAction)
+
+	postForm := func(form url.Values, cookie *http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/admin", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, req)
+
+		return resp
+	}
+
+	// Test that the login form is displayed when not logged in
+	req = httptest.NewRequest(http.MethodGet, "/admin", nil)
+	resp = httptest.NewRecorder()
+	mux.ServeHTTP(resp, req)
+
+	suite.a.Assert(resp.Code, http.StatusOK)
+	suite.a.Assert(strings.Contains(resp.Body.String(), `name="password"`), true)
+	suite.a.Assert(strings.Contains(resp.Body.String(), "adminone"), false)
+
+	// Test that actions require a session
+	resp = postForm(url.Values{"action": {"delete"}, "selected": {"adminone"}}, nil)
+	suite.a.Assert(resp.Code, http.StatusUnauthorized)
+
+	// Test the login with a wrong password
+	resp = postForm(url.Values{"action": {"login"}, "password": {"wrong"}}, nil)
+	suite.a.Assert(resp.Code, http.StatusUnauthorized)
+	suite.a.Assert(len(resp.Result().Cookies()), 0)
+
+	// Test the login with the right password
+	resp = postForm(url.Values{"action": {"login"}, "password": {"adminsecret"}}, nil)
+	suite.a.Assert(resp.Code, http.StatusSeeOther)
+
+	cookies := resp.Result().Cookies()
+	suite.a.Assertf(len(cookies), 1)
+	session := cookies[0]
+	suite.a.Assert(session.Name, admin.SessionCookie)
+	suite.a.Assert(session.HttpOnly, true)
+
+	// Test if the login is rate limited like the other passwords
+	resp = postForm(url.Values{"action": {"login"}, "password": {"adminsecret"}}, nil)
+	suite.a.Assert(resp.Code, http.StatusTooManyRequests)
+	suite.a.AssertNotEmpty(resp.Header().Get("Retry-After"), "")
+	suite.a.Assert(len(resp.Result().Cookies()), 0)
+
+	// Test the listing of the links
+	req = httptest.NewRequest(http.MethodGet, "/admin", nil)
+	req.AddCookie(session)
+	resp = httptest.NewRecorder()
+	mux.ServeHTTP(resp, req)
+
+	suite.a.Assert(resp.Code, http.StatusOK)
+	suite.a.Assert(strings.Contains(resp.Body.String(), "adminone"), true)
+	suite.a.Assert(strings.Contains(resp.Body.String(), "other"), true)
+
+	// Test the filtering of the links
+	req = httptest.NewRequest(http.MethodGet, "/admin?short=admin", nil)
+	req.AddCookie(session)
+	resp = httptest.NewRecorder()
+	mux.ServeHTTP(resp, req)
+
+	suite.a.Assert(resp.Code, http.StatusOK)
+	suite.a.Assert(strings.Contains(resp.Body.String(), "admintwo"), true)
+	suite.a.Assert(strings.Contains(resp.Body.String(), "example.com/other"), false)
+
+	// Test an invalid date filter
+	req = httptest.NewRequest(http.MethodGet, "/admin?created_after=yesterday", nil)
+	req.AddCookie(session)
+	resp = httptest.NewRecorder()
+	mux.ServeHTTP(resp, req)
+
+	suite.a.Assert(resp.Code, http.StatusBadRequest)
+
+	// Test the bulk force-expire, the filter is kept after the action
+	resp = postForm(url.Values{
+		"action":   {"expire"},
+		"selected": {"adminone"},
+		"query":    {"short=admin"},
+	}, session)
+	suite.a.Assert(resp.Code, http.StatusSeeOther)
+	suite.a.Assert(resp.Header().Get("Location"), "/admin?short=admin")
+
+	links, _, err := store.ListLinks(database.LinkFilter{Short: "adminone"})
+	suite.a.AssertNoErr(err)
+	suite.a.Assertf(len(links), 1)
+	suite.a.Assert(links[0].ExpireAt.After(time.Now().UTC()), false)
+
+	// Test the bulk deletion
+	resp = postForm(url.Values{"action": {"delete"}, "selected": {"adminone", "admintwo"}}, session)
+	suite.a.Assert(resp.Code, http.StatusSeeOther)
+
+	_, total, err := store.ListLinks(database.LinkFilter{})
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(total, 1)
+
+	// Test an action without selected links
+	resp = postForm(url.Values{"action": {"delete"}}, session)
+	suite.a.Assert(resp.Code, http.StatusBadRequest)
+
+	// Test an unknown action
+	resp = postForm(url.Values{"action": {"explode"}}, session)
+	suite.a.Assert(resp.Code, http.StatusBadRequest)
+
+	// Test the creation of an API key, it is displayed once
+	resp = postForm(url.Values{
+		"action":    {"create_key"},
+		"label":     {"dashboard"},
+		"scopes":    {"admin", "stats"},
+		"expire_at": {time.Now().UTC().AddDate(0, 0, 2).Format("2006-01-02")},
+	}, session)
+	suite.a.Assert(resp.Code, http.StatusOK)
+
+	plain := regexp.MustCompile(apikeys.KeyPrefix + `[A-Za-z0-9_-]+`).FindString(resp.Body.String())
+	suite.a.AssertNotEmptyf(plain, "")
+
+	keys, err := store.ListAPIKeys()
+	suite.a.AssertNoErr(err)
+	suite.a.Assertf(len(keys), 1)
+	suite.a.Assert(keys[0].Label, "dashboard")
+	suite.a.Assert(keys[0].Scopes, "admin,stats")
+	suite.a.Assert(keys[0].Hash, apikeys.Hash(plain))
+
+	// Test that the key is listed without being displayed again
+	req = httptest.NewRequest(http.MethodGet, "/admin", nil)
+	req.AddCookie(session)
+	resp = httptest.NewRecorder()
+	mux.ServeHTTP(resp, req)
+
+	suite.a.Assert(strings.Contains(resp.Body.String(), keys[0].Prefix), true)
+	suite.a.Assert(strings.Contains(resp.Body.String(), plain), false)
+
+	// Test that the key having the admin scope gives access to the dashboard without a session
+	req = httptest.NewRequest(http.MethodGet, "/admin", nil)
+	req.Header.Set("Authorization", "Bearer "+plain)
+	resp = httptest.NewRecorder()
+	httpAdapter.Authenticate(mux).ServeHTTP(resp, req)
+
+	suite.a.Assert(resp.Code, http.StatusOK)
+	suite.a.Assert(strings.Contains(resp.Body.String(), "example.com/other"), true)
+
+	// Test the creation of API keys without a label, without scopes and with an expiration date in the past
+	resp = postForm(url.Values{"action": {"create_key"}, "scopes": {"admin"}}, session)
+	suite.a.Assert(resp.Code, http.StatusBadRequest)
+
+	resp = postForm(url.Values{"action": {"create_key"}, "label": {"none"}}, session)
+	suite.a.Assert(resp.Code, http.StatusBadRequest)
+
+	resp = postForm(url.Values{"action": {"create_key"}, "label": {"old"}, "scopes": {"admin"}, "expire_at": {"2000-01-01"}}, session)
+	suite.a.Assert(resp.Code, http.StatusBadRequest)
+
+	// Test the revocation of the API key
+	resp = postForm(url.Values{"action": {"revoke_key"}, "key": {keys[0].ID.String()}}, session)
+	suite.a.Assert(resp.Code, http.StatusSeeOther)
+
+	keys, err = store.ListAPIKeys()
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(len(keys), 0)
+
+	resp = postForm(url.Values{"action": {"revoke_key"}, "key": {uuid.NewString()}}, session)
+	suite.a.Assert(resp.Code, http.StatusNotFound)
+
+	// Test the logout
+	resp = postForm(url.Values{"action": {"logout"}}, session)
+	suite.a.Assert(resp.Code, http.StatusSeeOther)
+	suite.a.Assert(resp.Result().Cookies()[0].MaxAge, -1)
+}
+
+func (suite frontTestSuite) TestAccountHandlers() { //nolint:funlen,maintidx
+	HTTP.Templates = template.Must(template.ParseGlob("../../static/**/*.tmpl"))
+
+	var emptyEmbed embed.FS
+	locales, supportedLocales, err := utils.GetLocales("./locales/", emptyEmbed)
+	suite.a.AssertNoErrf(err)
+
+	store := database.NewMemoryStore()
+	conf := utils.Configuration{
+		Store:                  store,
+		InstanceURL:            "http://127.0.0.1:8080/",
+		DefaultShortLength:     6,
+		DefaultMaxShortLength:  12,
+		DefaultMaxCustomLength: 12,
+		Locales:                locales,
+		SupportedLocales:       supportedLocales,
+		RestrictCreation:       true,
+		Accounts:               true,
+		Registration:           true,
+	}
+
+	// Test that the account page doesn't exist without the accounts
+	resp := httptest.NewRecorder()
+	HTTP.NewAdapter(utils.Configuration{Store: store}).FrontHandlerAccount(resp, httptest.NewRequest(http.MethodGet, "/account", nil))
+	suite.a.Assert(resp.Code, http.StatusNotFound)
+
+	// Serve the routes behind the session middleware
+	newHandler := func(conf utils.Configuration) http.Handler {
+		httpAdapter := HTTP.NewAdapter(conf)
+		mux := http.NewServeMux()
+		mux.HandleFunc("GET /account", httpAdapter.FrontHandlerAccount)
+		mux.HandleFunc("POST /account", httpAdapter.FrontHandlerAccountAction)
+		mux.Handle("POST /add", httpAdapter.RequireCreator(http.HandlerFunc(httpAdapter.FrontHandlerAdd)))
+		mux.Handle("POST /", httpAdapter.RequireCreator(http.HandlerFunc(httpAdapter.APICreateLink)))
+		mux.HandleFunc("GET /{short}", httpAdapter.APIRedirectToURL)
+		mux.HandleFunc("PATCH /{short}", httpAdapter.APIUpdateLink)
+		mux.HandleFunc("DELETE /{short}", httpAdapter.APIDeleteLink)
+
+		return httpAdapter.Session(mux)
+	}
+
+	handler := newHandler(conf)
+
+	serve := func(method, target, contentType, body string, cookie *http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, req)
+
+		return resp
+	}
+
+	postForm := func(form url.Values, cookie *http.Cookie) *httptest.ResponseRecorder {
+		return serve(http.MethodPost, "/account", "application/x-www-form-urlencoded", form.Encode(), cookie)
+	}
+
+	// Test that the login and registration forms are displayed when not logged in
+	resp = serve(http.MethodGet, "/account", "", "", nil)
+	suite.a.Assert(resp.Code, http.StatusOK)
+	suite.a.Assert(strings.Contains(resp.Body.String(), `name="username"`), true)
+	suite.a.Assert(strings.Contains(resp.Body.String(), `value="register"`), true)
+
+	// Test that anonymous clients can't create links when the creation is restricted
+	resp = serve(http.MethodPost, "/", "application/json", `{"url":"https://example.com"}`, nil)
+	suite.a.Assert(resp.Code, http.StatusUnauthorized)
+	suite.a.Assert(strings.Contains(resp.Body.String(), locales["en"].ErrLoginRequired), true)
+
+	// Test that actions other than logging in and registering require a session
+	resp = postForm(url.Values{"action": {"logout"}}, nil)
+	suite.a.Assert(resp.Code, http.StatusUnauthorized)
+
+	// Test the registration with invalid credentials
+	resp = postForm(url.Values{"action": {"register"}, "username": {"a"}, "password": {"password"}}, nil)
+	suite.a.Assert(resp.Code, http.StatusBadRequest)
+
+	resp = postForm(url.Values{"action": {"register"}, "username": {"redd"}, "password": {"short"}}, nil)
+	suite.a.Assert(resp.Code, http.StatusBadRequest)
+
+	// Test the registration, which logs the user in
+	register := func(username string) *http.Cookie {
+		resp := postForm(url.Values{"action": {"register"}, "username": {username}, "password": {"password"}}, nil)
+		suite.a.Assert(resp.Code, http.StatusSeeOther)
+
+		cookies := resp.Result().Cookies()
+		suite.a.Assertf(len(cookies), 1)
+		suite.a.Assert(cookies[0].Name, accounts.SessionCookie)
+		suite.a.Assert(cookies[0].Path, "/")
+		suite.a.Assert(cookies[0].HttpOnly, true)
+
+		return cookies[0]
+	}
+
+	session := register("redd")
+	otherSession := register("other")
+
+	resp = postForm(url.Values{"action": {"register"}, "username": {"REDD"}, "password": {"password"}}, nil)
+	suite.a.Assert(resp.Code, http.StatusConflict)
+
+	user, err := store.GetUserByName("redd")
+	suite.a.AssertNoErrf(err)
+
+	// Test that the links created by a logged in user belong to them
+	resp = serve(http.MethodPost, "/", "application/json", `{"url":"https://example.com/mine","customPath":"mine","password":"secret"}`, session)
+	suite.a.Assert(resp.Code, http.StatusCreated)
+
+	link, err := store.GetLinkByShort("mine")
+	suite.a.AssertNoErrf(err)
+	suite.a.Assert(link.Owner, user.ID)
+
+	resp = serve(http.MethodPost, "/add", "application/x-www-form-urlencoded",
+		url.Values{"url": {"https://example.com/theirs"}, "short": {"theirs"}, "length": {"6"}, "add": {"Add"}}.Encode(), otherSession)
+	suite.a.Assert(resp.Code, http.StatusCreated)
+
+	link, err = store.GetLinkByShort("theirs")
+	suite.a.AssertNoErrf(err)
+	suite.a.Assert(link.Owner != user.ID, true)
+
+	// Test that the account page only lists the links of the user
+	resp = serve(http.MethodGet, "/account", "", "", session)
+	suite.a.Assert(resp.Code, http.StatusOK)
+	suite.a.Assert(strings.Contains(resp.Body.String(), "example.com/mine"), true)
+	suite.a.Assert(strings.Contains(resp.Body.String(), "example.com/theirs"), false)
+
+	// Test that only the links of the user can be edited
+	resp = serve(http.MethodGet, "/account?edit=mine", "", "", session)
+	suite.a.Assert(resp.Code, http.StatusOK)
+	suite.a.Assert(strings.Contains(resp.Body.String(), `value="update"`), true)
+
+	resp = serve(http.MethodGet, "/account?edit=theirs", "", "", session)
+	suite.a.Assert(resp.Code, http.StatusNotFound)
+
+	// Test that the owner gets the information of a protected link without its password
+	resp = serve(http.MethodGet, "/mine+", "", "", session)
+	suite.a.Assert(resp.Code, http.StatusOK)
+	suite.a.Assert(strings.Contains(resp.Body.String(), "https://example.com/mine"), true)
+
+	resp = serve(http.MethodGet, "/mine+", "", "", otherSession)
+	suite.a.Assert(strings.Contains(resp.Body.String(), "https://example.com/mine"), false)
+
+	// Test the update of the links from the account page
+	resp = postForm(url.Values{"action": {"update"}, "short": {"theirs"}, "url": {"https://example.org"}}, session)
+	suite.a.Assert(resp.Code, http.StatusNotFound)
+
+	resp = postForm(url.Values{"action": {"update"}, "short": {"mine"}, "url": {"https://example.org/mine"}}, session)
+	suite.a.Assert(resp.Code, http.StatusSeeOther)
+
+	link, err = store.GetLinkByShort("mine")
+	suite.a.AssertNoErrf(err)
+	suite.a.Assert(link.URL, "https://example.org/mine")
+	suite.a.Assert(link.Password != "", true)
+
+	// Test the update of the links using the API without a management token
+	resp = serve(http.MethodPatch, "/theirs", "application/json", `{"url":"https://example.org"}`, session)
+	suite.a.Assert(resp.Code, http.StatusNotFound)
+
+	resp = serve(http.MethodPatch, "/mine", "application/json", `{"url":"https://example.net/mine"}`, session)
+	suite.a.Assert(resp.Code, http.StatusOK)
+
+	resp = serve(http.MethodPatch, "/mine", "application/json", `{"url":"https://example.net/mine"}`, nil)
+	suite.a.Assert(resp.Code, http.StatusUnauthorized)
+
+	// Test the deletion of the links
+	resp = postForm(url.Values{"action": {"delete"}, "short": {"theirs"}}, session)
+	suite.a.Assert(resp.Code, http.StatusNotFound)
+
+	resp = serve(http.MethodDelete, "/theirs", "", "", session)
+	suite.a.Assert(resp.Code, http.StatusNotFound)
+
+	resp = serve(http.MethodDelete, "/mine", "", "", session)
+	suite.a.Assert(resp.Code, http.StatusNoContent)
+
+	resp = postForm(url.Values{"action": {"delete"}, "short": {"theirs"}}, otherSession)
+	suite.a.Assert(resp.Code, http.StatusSeeOther)
+
+	_, err = store.GetLinkByShort("theirs")
+	suite.a.AssertErrIs(err, sql.ErrNoRows)
+
+	// Test the logout, which closes the session
+	resp = postForm(url.Values{"action": {"logout"}}, session)
+	suite.a.Assert(resp.Code, http.StatusSeeOther)
+	suite.a.Assertf(len(resp.Result().Cookies()), 1)
+	suite.a.Assert(resp.Result().Cookies()[0].MaxAge < 0, true)
+
+	resp = serve(http.MethodGet, "/account", "", "", session)
+	suite.a.Assert(resp.Code, http.StatusOK)
+	suite.a.Assert(strings.Contains(resp.Body.String(), `name="username"`), true)
+
+	// Test the login
+	resp = postForm(url.Values{"action": {"login"}, "username": {"redd"}, "password": {"wrongpassword"}}, nil)
+	suite.a.Assert(resp.Code, http.StatusUnauthorized)
+	suite.a.Assert(len(resp.Result().Cookies()), 0)
+
+	resp = postForm(url.Values{"action": {"login"}, "username": {"Redd"}, "password": {"password"}}, nil)
+	suite.a.Assert(resp.Code, http.StatusSeeOther)
+	suite.a.Assert(len(resp.Result().Cookies()), 1)
+
+	// Test that the registration can be closed
+	conf.Registration = false
+	handler = newHandler(conf)
+
+	resp = serve(http.MethodGet, "/account", "", "", nil)
+	suite.a.Assert(resp.Code, http.StatusOK)
+	suite.a.Assert(strings.Contains(resp.Body.String(), `value="register"`), false)
+
+	resp = postForm(url.Values{"action": {"register"}, "username": {"newcomer"}, "password": {"password"}}, nil)
+	suite.a.Assert(resp.Code, http.StatusForbidden)
+}
+
+func (suite frontTestSuite) TestOIDCHandlers() { //nolint:funlen
+	HTTP.Templates = template.Must(template.ParseGlob("../../static/**/*.tmpl"))
+
+	var emptyEmbed embed.FS
+	locales, supportedLocales, err := utils.GetLocales("./locales/", emptyEmbed)
+	suite.a.AssertNoErrf(err)
+
+	// Start the mock provider and discover it
+	mock := helper.NewMockOIDC(suite.t, "reddlinks", "secret")
+
+	provider, err := oidc.New(context.Background(), oidc.Settings{
+		Issuer:        mock.Issuer(),
+		ClientID:      "reddlinks",
+		ClientSecret:  "secret",
+		RedirectURL:   "http://127.0.0.1:8080/account/oidc/callback",
+		Scopes:        []string{"openid", "profile"},
+		UsernameClaim: "preferred_username",
+		RolesClaim:    "roles",
+	})
+	suite.a.AssertNoErrf(err)
+
+	store := database.NewMemoryStore()
+	conf := utils.Configuration{
+		Store:                  store,
+		InstanceURL:            "http://127.0.0.1:8080/",
+		DefaultShortLength:     6,
+		DefaultMaxShortLength:  12,
+		DefaultMaxCustomLength: 12,
+		Locales:                locales,
+		SupportedLocales:       supportedLocales,
+		RestrictCreation:       true,
+		Accounts:               true,
+		OIDC:                   provider,
+		AdminRole:              "reddlinks-admin",
+		CreatorRole:            "reddlinks-creator",
+	}
+
+	// Test that the routes don't exist without the single sign-on
+	resp := httptest.NewRecorder()
+	HTTP.NewAdapter(utils.Configuration{Store: store}).FrontHandlerOIDCLogin(resp, httptest.NewRequest(http.MethodGet, "/account/oidc", nil))
+	suite.a.Assert(resp.Code, http.StatusNotFound)
+
+	resp = httptest.NewRecorder()
+	HTTP.NewAdapter(utils.Configuration{Store: store}).
+		FrontHandlerOIDCCallback(resp, httptest.NewRequest(http.MethodGet, "/account/oidc/callback", nil))
+	suite.a.Assert(resp.Code, http.StatusNotFound)
+
+	// Serve the routes behind the session middleware
+	httpAdapter := HTTP.NewAdapter(conf)
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /account", httpAdapter.FrontHandlerAccount)
+	mux.HandleFunc("GET /account/oidc", httpAdapter.FrontHandlerOIDCLogin)
+	mux.HandleFunc("GET /account/oidc/callback", httpAdapter.FrontHandlerOIDCCallback)
+	mux.HandleFunc("GET /admin", httpAdapter.FrontHandlerAdmin)
+	mux.HandleFunc("POST /admin", httpAdapter.FrontHandlerAdminAction)
+	mux.Handle("POST /", httpAdapter.RequireCreator(http.HandlerFunc(httpAdapter.APICreateLink)))
+	handler := httpAdapter.Session(mux)
+
+	serve := func(method, target, body string, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if body != "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+
+		for _, cookie := range cookies {
+			req.AddCookie(cookie)
+		}
+
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, req)
+
+		return resp
+	}
+
+	findCookie := func(resp *httptest.ResponseRecorder, name string) *http.Cookie {
+		for _, cookie := range resp.Result().Cookies() {
+			if cookie.Name == name {
+				return cookie
+			}
+		}
+
+		return nil
+	}
+
+	// startLogin starts a flow and logs in at the provider, returning the flow cookie and the callback target
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	startLogin := func(target string) (*http.Cookie, *url.URL) {
+		resp := serve(http.MethodGet, target, "")
+		suite.a.Assertf(resp.Code, http.StatusFound)
+
+		flowCookie := findCookie(resp, "reddlinks_oidc")
+		suite.a.Assertf(flowCookie != nil, true)
+		suite.a.Assert(flowCookie.HttpOnly, true)
+
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, resp.Header().Get("Location"), nil)
+		suite.a.AssertNoErrf(err)
+
+		authResp, err := client.Do(req)
+		suite.a.AssertNoErrf(err)
+		authResp.Body.Close()
+		suite.a.Assertf(authResp.StatusCode, http.StatusFound)
+
+		callback, err := url.Parse(authResp.Header.Get("Location"))
+		suite.a.AssertNoErrf(err)
+
+		return flowCookie, callback
+	}
+
+	login := func(target, expectedNext string) *http.Cookie {
+		flowCookie, callback := startLogin(target)
+
+		resp := serve(http.MethodGet, callback.RequestURI(), "", flowCookie)
+		suite.a.Assertf(resp.Code, http.StatusSeeOther)
+		suite.a.Assert(resp.Header().Get("Location"), expectedNext)
+
+		session := findCookie(resp, accounts.SessionCookie)
+		suite.a.Assertf(session != nil, true)
+
+		return session
+	}
+
+	// Test that the login page links to the provider
+	resp = serve(http.MethodGet, "/account", "")
+	suite.a.Assert(resp.Code, http.StatusOK)
+	suite.a.Assert(strings.Contains(resp.Body.String(), `href="/account/oidc"`), true)
+
+	// Test that the admin dashboard only offers the single sign-on without an admin password
+	resp = serve(http.MethodGet, "/admin", "")
+	suite.a.Assert(resp.Code, http.StatusOK)
+	suite.a.Assert(strings.Contains(resp.Body.String(), `name="password"`), false)
+	suite.a.Assert(strings.Contains(resp.Body.String(), `href="/account/oidc?next=/admin"`), true)
+
+	resp = serve(http.MethodPost, "/admin", "")
+	suite.a.Assert(resp.Code, http.StatusUnauthorized)
+
+	// Test the callbacks without a flow, with another state and refused by the provider
+	flowCookie, callback := startLogin("/account/oidc")
+
+	resp = serve(http.MethodGet, callback.RequestURI(), "")
+	suite.a.Assert(resp.Code, http.StatusBadRequest)
+
+	resp = serve(http.MethodGet, "/account/oidc/callback?code=code&state=other", "", flowCookie)
+	suite.a.Assert(resp.Code, http.StatusBadRequest)
+
+	resp = serve(http.MethodGet, "/account/oidc/callback?error=access_denied&state="+callback.Query().Get("state"), "", flowCookie)
+	suite.a.Assert(resp.Code, http.StatusUnauthorized)
+
+	// Test that an invalid ID token doesn't log the user in
+	mock.SetModify(func(_, claims map[string]any) { claims["iss"] = "https://evil.example.com" })
+
+	flowCookie, callback = startLogin("/account/oidc")
+	resp = serve(http.MethodGet, callback.RequestURI(), "", flowCookie)
+	suite.a.Assert(resp.Code, http.StatusUnauthorized)
+	suite.a.Assert(findCookie(resp, accounts.SessionCookie), (*http.Cookie)(nil))
+
+	mock.SetModify(nil)
+
+	// Test that the admin role gives access to the admin dashboard, but not to the creation of links
+	mock.SetUser("admin-subject", "Boss", []string{"reddlinks-admin"})
+	adminSession := login("/account/oidc?next=/admin", "/admin")
+
+	resp = serve(http.MethodGet, "/admin", "", adminSession)
+	suite.a.Assert(resp.Code, http.StatusOK)
+	suite.a.Assert(strings.Contains(resp.Body.String(), `value="create_key"`), true)
+
+	resp = serve(http.MethodPost, "/", `{"url":"https://example.com"}`, adminSession)
+	suite.a.Assert(resp.Code, http.StatusForbidden)
+	suite.a.Assert(strings.Contains(resp.Body.String(), locales["en"].ErrCreatorRoleRequired), true)
+
+	user, err := store.GetUserBySubject("admin-subject")
+	suite.a.AssertNoErrf(err)
+	suite.a.Assert(user.Username, "boss")
+
+	// Test that the creator role allows the creation of links, owned by the user, but not the admin dashboard
+	mock.SetUser("creator-subject", "creator", []string{"staff", "reddlinks-creator"})
+	creatorSession := login("/account/oidc?next=https://evil.example.com", "/account")
+
+	resp = serve(http.MethodPost, "/", `{"url":"https://example.com/sso","customPath":"sso"}`, creatorSession)
+	suite.a.Assert(resp.Code, http.StatusCreated)
+
+	user, err = store.GetUserBySubject("creator-subject")
+	suite.a.AssertNoErrf(err)
+
+	link, err := store.GetLinkByShort("sso")
+	suite.a.AssertNoErrf(err)
+	suite.a.Assert(link.Owner, user.ID)
+
+	resp = serve(http.MethodGet, "/admin", "", creatorSession)
+	suite.a.Assert(strings.Contains(resp.Body.String(), `value="create_key"`), false)
+
+	// Test that the roles are updated on the next login
+	mock.SetUser("creator-subject", "creator", []string{"staff"})
+	creatorSession = login("/account/oidc", "/account")
+
+	resp = serve(http.MethodPost, "/", `{"url":"https://example.com"}`, creatorSession)
+	suite.a.Assert(resp.Code, http.StatusForbidden)
 }
 
 // Test suite structure.
@@ -251,4 +967,7 @@ func TestFrontSuite(t *testing.T) {
 	// Call the tests
 	suite.TestRenderTemplate()
 	suite.TestMainFrontHandlers()
+	suite.TestAdminHandlers()
+	suite.TestAccountHandlers()
+	suite.TestOIDCHandlers()
 }
@@ -17,19 +17,52 @@
 package json_test
 
 import (
+	"embed"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
 	"github.com/redds-be/reddlinks/internal/json"
+	"github.com/redds-be/reddlinks/internal/utils"
 	"github.com/redds-be/reddlinks/test/helper"
 )
 
 func (suite jsonTestSuite) TestRespondWithError() {
 	resp := httptest.NewRecorder()
-	json.RespondWithError(resp, http.StatusBadRequest, "An error.")
+	json.RespondWithError(resp, http.StatusBadRequest, json.CodeInvalidURL, "An error.")
 	suite.a.Assert(resp.Code, http.StatusBadRequest)
-	suite.a.Assert(resp.Body.String(), "{\"error\":\"400 An error.\"}\n")
+	suite.a.Assert(resp.Body.String(), "{\"error\":\"400 An error.\",\"code\":\"invalid_url\"}\n")
+}
+
+func (suite jsonTestSuite) TestRespondWithAPIError() {
+	resp := httptest.NewRecorder()
+	resp.Header().Set("X-Request-ID", "report-me")
+	json.RespondWithAPIError(resp, http.StatusNotFound, json.CodeNotFound, "Not found.")
+	suite.a.Assert(resp.Code, http.StatusNotFound)
+	suite.a.Assert(
+		resp.Body.String(),
+		"{\"error\":{\"status\":404,\"code\":\"not_found\",\"message\":\"Not found.\"},\"requestId\":\"report-me\"}\n",
+	)
+}
+
+func (suite jsonTestSuite) TestErrorCodes() {
+	var emptyEmbed embed.FS
+	locales, _, err := utils.GetLocales("../../static/locales/", emptyEmbed)
+	suite.a.AssertNoErrf(err)
+
+	// Test if every error code has its own message in every locale shipped with reddlinks
+	for lang, locale := range locales {
+		for _, code := range json.ErrorCodes() {
+			if code.Message(locale) == "" || code.Message(locale) == locale.Error {
+				suite.t.Errorf("The %s error code has no message in the %s locale", code, lang)
+			}
+		}
+	}
+
+	// Test if the generic codes use the generic message
+	suite.a.Assert(json.CodeInternal.LocaleKey(), "error")
+	suite.a.Assert(json.CodeInternal.Message(locales["en"]), locales["en"].Error)
+	suite.a.Assert(json.CodeReservedPath.Message(locales["fr"]), "Ce chemin est réservé.")
 }
 
 func (suite jsonTestSuite) TestRespondWithJSON() {
@@ -61,5 +94,7 @@ func TestJSONSuite(t *testing.T) {
 
 	// Call the tests
 	suite.TestRespondWithError()
+	suite.TestRespondWithAPIError()
+	suite.TestErrorCodes()
 	suite.TestRespondWithJSON()
 }
@@ -9,6 +9,7 @@ import (
 
 	"github.com/redds-be/reddlinks/internal/database"
 	"github.com/redds-be/reddlinks/internal/env"
+	"github.com/redds-be/reddlinks/internal/json"
 	"github.com/redds-be/reddlinks/internal/links"
 	"github.com/redds-be/reddlinks/internal/utils"
 	"github.com/redds-be/reddlinks/test/helper"
@@ -40,7 +41,7 @@ func (suite linksTestSuite) TestCreateLink() { //nolint:funlen
 	suite.a.AssertNoErr(err)
 
 	conf := &utils.Configuration{
-		DB:                     dataBase,
+		Store:                  database.NewSQLStore(dataBase, testEnv.DBType),
 		InstanceName:           testEnv.InstanceName,
 		InstanceURL:            testEnv.InstanceURL,
 		Version:                "noVersion",
@@ -52,11 +53,7 @@ func (suite linksTestSuite) TestCreateLink() { //nolint:funlen
 		ContactEmail:           testEnv.ContactEmail,
 	}
 
-	locale := utils.PageLocaleTl{
-		ErrAlphaNumeric:    "alpha",
-		ErrInvalidURL:      "invalid_url",
-		ErrRedirectionLoop: "loop",
-	}
+	locale := utils.PageLocaleTl{}
 
 	linksAdapter := links.NewAdapter(*conf)
 
@@ -69,10 +66,9 @@ func (suite linksTestSuite) TestCreateLink() { //nolint:funlen
 		Password:   "",
 	}
 
-	returnedLink, code, _, errMsg := linksAdapter.CreateLink(params, locale)
+	returnedLink, _, err := linksAdapter.CreateLink(params, locale)
 
-	suite.a.Assert(errMsg, "")
-	suite.a.Assert(code, http.StatusCreated)
+	suite.a.AssertNoErr(err)
 	suite.a.Assert(returnedLink.URL, params.URL)
 	suite.a.Assert(
 		returnedLink.ExpireAt.Format(time.RFC822),
@@ -88,10 +84,9 @@ func (suite linksTestSuite) TestCreateLink() { //nolint:funlen
 		Password:   "",
 	}
 
-	returnedLink, code, _, errMsg = linksAdapter.CreateLink(params, locale)
+	returnedLink, _, err = linksAdapter.CreateLink(params, locale)
 
-	suite.a.Assert(errMsg, "")
-	suite.a.Assert(code, http.StatusCreated)
+	suite.a.AssertNoErr(err)
 	suite.a.Assert(returnedLink.URL, params.URL)
 	suite.a.Assert(len(returnedLink.Short), params.Length)
 	suite.a.Assert(
@@ -108,12 +103,12 @@ func (suite linksTestSuite) TestCreateLink() { //nolint:funlen
 		Password:   "",
 	}
 
-	returnedLink, code, _, errMsg = linksAdapter.CreateLink(params, locale)
+	returnedLink, _, err = linksAdapter.CreateLink(params, locale)
 
-	suite.a.Assert(errMsg, "")
-	suite.a.Assert(code, http.StatusCreated)
+	suite.a.AssertNoErr(err)
 	suite.a.Assert(returnedLink.URL, params.URL)
 	suite.a.Assert(returnedLink.Short, params.Path)
+	suite.a.Assert(len(returnedLink.Token), 32)
 	suite.a.Assert(
 		returnedLink.ExpireAt.Format(time.RFC822),
 		time.Now().UTC().Add(time.Duration(conf.DefaultExpiryTime)*time.Minute).Format(time.RFC822),
@@ -128,10 +123,9 @@ func (suite linksTestSuite) TestCreateLink() { //nolint:funlen
 		Password:   "",
 	}
 
-	returnedLink, code, _, errMsg = linksAdapter.CreateLink(params, locale)
+	returnedLink, _, err = linksAdapter.CreateLink(params, locale)
 
-	suite.a.Assert(errMsg, "")
-	suite.a.Assert(code, http.StatusCreated)
+	suite.a.AssertNoErr(err)
 	suite.a.Assert(returnedLink.URL, params.URL)
 	expireAt, err := time.Parse("2006-01-02T15:04", params.ExpireDate)
 	suite.a.AssertNoErr(err)
@@ -149,10 +143,9 @@ func (suite linksTestSuite) TestCreateLink() { //nolint:funlen
 		Password:   "secret",
 	}
 
-	returnedLink, code, _, errMsg = linksAdapter.CreateLink(params, locale)
+	returnedLink, _, err = linksAdapter.CreateLink(params, locale)
 
-	suite.a.Assert(errMsg, "")
-	suite.a.Assert(code, http.StatusCreated)
+	suite.a.AssertNoErr(err)
 	suite.a.Assert(returnedLink.URL, params.URL)
 	suite.a.Assert(
 		returnedLink.ExpireAt.Format(time.RFC822),
@@ -168,10 +161,9 @@ func (suite linksTestSuite) TestCreateLink() { //nolint:funlen
 		Password:   "",
 	}
 
-	_, code, _, errMsg = linksAdapter.CreateLink(params, locale)
+	_, _, err = linksAdapter.CreateLink(params, locale)
 
-	suite.a.Assert(errMsg, "alpha")
-	suite.a.Assert(code, http.StatusBadRequest)
+	suite.assertError(err, http.StatusBadRequest, json.CodeInvalidPath)
 
 	// Test link creation with an invalid url
 	params = utils.Parameters{
@@ -182,10 +174,9 @@ func (suite linksTestSuite) TestCreateLink() { //nolint:funlen
 		Password:   "",
 	}
 
-	_, code, _, errMsg = linksAdapter.CreateLink(params, locale)
+	_, _, err = linksAdapter.CreateLink(params, locale)
 
-	suite.a.Assert(errMsg, "invalid_url")
-	suite.a.Assert(code, http.StatusBadRequest)
+	suite.assertError(err, http.StatusBadRequest, json.CodeInvalidURL)
 
 	// Test redirection loop creation
 	params = utils.Parameters{
@@ -196,10 +187,189 @@ func (suite linksTestSuite) TestCreateLink() { //nolint:funlen
 		Password:    "",
 	}
 
-	_, code, _, errMsg = linksAdapter.CreateLink(params, locale)
+	_, _, err = linksAdapter.CreateLink(params, locale)
+
+	suite.assertError(err, http.StatusBadRequest, json.CodeRedirectionLoop)
+
+	// Test link creation with a reserved path and with a path already in use
+	_, _, err = linksAdapter.CreateLink(utils.Parameters{URL: "http://example.com/", Path: "admin"}, locale)
+	suite.assertError(err, http.StatusBadRequest, json.CodeReservedPath)
+
+	_, _, err = linksAdapter.CreateLink(utils.Parameters{URL: "http://example.com/", Path: "custom"}, locale)
+	suite.assertError(err, http.StatusBadRequest, json.CodePathInUse)
+
+	// Test if the errors that aren't link errors are reported as internal errors
+	suite.a.Assert(*links.AsError(errors.ErrUnsupported), links.Error{Status: http.StatusInternalServerError, Code: json.CodeInternal})
+}
+
+// assertError checks the status and the code of an error returned by the link handling functions.
+func (suite linksTestSuite) assertError(err error, status int, code json.ErrorCode) {
+	suite.t.Helper()
 
-	suite.a.Assert(errMsg, "loop")
-	suite.a.Assert(code, http.StatusBadRequest)
+	var linkErr *links.Error
+	if !errors.As(err, &linkErr) {
+		suite.t.Errorf("Expected a link error, got %v", err)
+
+		return
+	}
+
+	suite.a.Assert(linkErr.Status, status)
+	suite.a.Assert(linkErr.Code, code)
+}
+
+func (suite linksTestSuite) TestManageLink() { //nolint:funlen
+	testEnv := env.GetEnv("../.env.test")
+	testEnv.DBURL = "links_manage_test.db"
+
+	// If the test db already exists, delete it as it will cause errors
+	if _, err := os.Stat(testEnv.DBURL); !errors.Is(err, os.ErrNotExist) {
+		err = os.Remove(testEnv.DBURL)
+		suite.a.AssertNoErr(err)
+	}
+
+	// Prep everything
+	dataBase, err := database.DBConnect(
+		testEnv.DBType,
+		testEnv.DBURL,
+		testEnv.DBUser,
+		testEnv.DBPass,
+		testEnv.DBHost,
+		testEnv.DBPort,
+		testEnv.DBName,
+	)
+	suite.a.AssertNoErr(err)
+
+	err = database.CreateLinksTable(dataBase, testEnv.DBType, testEnv.DefaultMaxLength)
+	suite.a.AssertNoErr(err)
+
+	conf := &utils.Configuration{
+		Store:                  database.NewSQLStore(dataBase, testEnv.DBType),
+		InstanceName:           testEnv.InstanceName,
+		InstanceURL:            testEnv.InstanceURL,
+		DefaultShortLength:     testEnv.DefaultLength,
+		DefaultMaxShortLength:  testEnv.DefaultMaxLength,
+		DefaultMaxCustomLength: testEnv.DefaultMaxCustomLength,
+		DefaultExpiryTime:      testEnv.DefaultExpiryTime,
+	}
+
+	linksAdapter := links.NewAdapter(*conf)
+
+	// Create the link to manage
+	link, _, err := linksAdapter.CreateLink(
+		utils.Parameters{URL: "http://example.com/", Path: "manage"},
+		utils.PageLocaleTl{},
+	)
+	suite.a.AssertNoErrf(err)
+
+	// Test link update without a token
+	_, err = linksAdapter.UpdateLink("manage", "", utils.Parameters{URL: "http://example.org/"})
+	suite.assertError(err, http.StatusUnauthorized, json.CodeTokenRequired)
+
+	// Test link update with a wrong token
+	_, err = linksAdapter.UpdateLink("manage", "wrong", utils.Parameters{URL: "http://example.org/"})
+	suite.assertError(err, http.StatusForbidden, json.CodeWrongToken)
+
+	// Test link update of a link that does not exist
+	_, err = linksAdapter.UpdateLink("idonotexist", link.Token, utils.Parameters{})
+	suite.assertError(err, http.StatusNotFound, json.CodeNotFound)
+
+	// Test link update with an invalid url
+	_, err = linksAdapter.UpdateLink("manage", link.Token, utils.Parameters{URL: "gopher://example.org/"})
+	suite.assertError(err, http.StatusBadRequest, json.CodeInvalidURL)
+
+	// Test link update creating a redirection loop
+	_, err = linksAdapter.UpdateLink("manage", link.Token, utils.Parameters{URL: "http://127.0.0.1:8080/manage"})
+	suite.assertError(err, http.StatusBadRequest, json.CodeRedirectionLoop)
+
+	// Test link update with an invalid duration
+	_, err = linksAdapter.UpdateLink("manage", link.Token, utils.Parameters{ExpireAfter: "soon"})
+	suite.assertError(err, http.StatusInternalServerError, json.CodeInvalidExpireAfter)
+
+	// Test link update of the url only, the expiration date must be kept
+	updatedLink, err := linksAdapter.UpdateLink("manage", link.Token, utils.Parameters{URL: "http://example.org/"})
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(updatedLink.URL, "http://example.org/")
+	suite.a.Assert(updatedLink.ExpireAt.Format(time.RFC822), link.ExpireAt.Format(time.RFC822))
+
+	// Test link update of the expiration date and the password
+	updatedLink, err = linksAdapter.UpdateLink(
+		"manage",
+		link.Token,
+		utils.Parameters{ExpireDate: "2006-01-02T12:12", Password: "secret"},
+	)
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(updatedLink.URL, "http://example.org/")
+	suite.a.Assert(updatedLink.ExpireAt.Format("2006-01-02T15:04"), "2006-01-02T12:12")
+
+	hash, err := conf.Store.GetHashByShort("manage")
+	suite.a.AssertNoErr(err)
+	suite.a.AssertNotEmpty(hash, "")
+
+	// Test link deletion with a wrong token
+	err = linksAdapter.DeleteLink("manage", "wrong")
+	suite.assertError(err, http.StatusForbidden, json.CodeWrongToken)
+
+	// Test link deletion
+	err = linksAdapter.DeleteLink("manage", link.Token)
+	suite.a.AssertNoErr(err)
+
+	// Test link deletion of a link that was already deleted
+	err = linksAdapter.DeleteLink("manage", link.Token)
+	suite.assertError(err, http.StatusNotFound, json.CodeNotFound)
+}
+
+func (suite linksTestSuite) TestCreateLinks() { //nolint:funlen
+	testEnv := env.GetEnv("../.env.test")
+
+	conf := &utils.Configuration{
+		Store:                  database.NewMemoryStore(),
+		InstanceName:           testEnv.InstanceName,
+		InstanceURL:            testEnv.InstanceURL,
+		DefaultShortLength:     testEnv.DefaultLength,
+		DefaultMaxShortLength:  testEnv.DefaultMaxLength,
+		DefaultMaxCustomLength: testEnv.DefaultMaxCustomLength,
+		DefaultExpiryTime:      testEnv.DefaultExpiryTime,
+	}
+
+	locale := utils.PageLocaleTl{}
+
+	linksAdapter := links.NewAdapter(*conf)
+
+	// Test the creation of a batch, the invalid rows must not prevent the others from being created
+	results := linksAdapter.CreateLinks([]utils.Parameters{
+		{URL: "http://example.com/"},
+		{URL: "invalid"},
+		{URL: "http://example.org/", Path: "batch1"},
+	}, locale)
+
+	suite.a.Assert(len(results), 3)
+	suite.a.AssertNoErr(results[0].Err)
+	suite.a.Assert(results[0].Link.URL, "http://example.com/")
+	suite.a.Assert(len(results[0].Link.Short), conf.DefaultShortLength)
+	suite.a.AssertNotEmpty(results[0].Link.Token, "")
+	suite.assertError(results[1].Err, http.StatusBadRequest, json.CodeInvalidURL)
+	suite.a.AssertNoErr(results[2].Err)
+	suite.a.Assert(results[2].Link.Short, "batch1")
+
+	link, err := conf.Store.GetLinkByShort("batch1")
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(link.URL, "http://example.org/")
+
+	// Test the creation of a batch with a path already in use, only the conflicting row must fail
+	results = linksAdapter.CreateLinks([]utils.Parameters{
+		{URL: "http://example.com/", Path: "batch1"},
+		{URL: "http://example.com/", Path: "batch2"},
+		{URL: "http://example.com/", Path: "batch2"},
+	}, locale)
+
+	suite.assertError(results[0].Err, http.StatusBadRequest, json.CodePathInUse)
+	suite.a.AssertNoErr(results[1].Err)
+	suite.a.Assert(results[1].Link.Short, "batch2")
+	suite.assertError(results[2].Err, http.StatusBadRequest, json.CodePathInUse)
+
+	link, err = conf.Store.GetLinkByShort("batch1")
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(link.URL, "http://example.org/")
 }
 
 // Test suite structure.
@@ -220,4 +390,6 @@ func TestLinksSuite(t *testing.T) {
 
 	// Call the tests
 	suite.TestCreateLink()
+	suite.TestManageLink()
+	suite.TestCreateLinks()
 }
@@ -0,0 +1,125 @@
+//    reddlinks, a simple link shortener written in Go.
+//    Copyright (C) 2025 redd
+//
+//    This program is free software: you can redistribute it and/or modify
+//    it under the terms of the GNU General Public License as published by
+//    the Free Software Foundation, either version 3 of the License, or
+//    (at your option) any later version.
+//
+//    This program is distributed in the hope that it will be useful,
+//    but WITHOUT ANY WARRANTY; without even the implied warranty of
+//    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//    GNU General Public License for more details.
+//
+//    You should have received a copy of the GNU General Public License
+//    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package logging_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/redds-be/reddlinks/internal/logging"
+	"github.com/redds-be/reddlinks/test/helper"
+)
+
+func (suite loggingTestSuite) TestParseLevel() {
+	// Test the supported levels
+	level, err := logging.ParseLevel("debug")
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(level, slog.LevelDebug)
+
+	level, err = logging.ParseLevel("WARN")
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(level, slog.LevelWarn)
+
+	level, err = logging.ParseLevel("")
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(level, slog.LevelInfo)
+
+	// Test an unsupported level
+	_, err = logging.ParseLevel("verbose")
+	suite.a.AssertErrIs(err, logging.ErrUnknownLevel)
+}
+
+func (suite loggingTestSuite) TestNewLogger() {
+	var buffer bytes.Buffer
+
+	// Test an unsupported format
+	_, err := logging.NewLogger(&buffer, "xml", "info")
+	suite.a.AssertErrIs(err, logging.ErrUnknownFormat)
+
+	// Test if the request ID of the context is added to the JSON lines
+	logger, err := logging.NewLogger(&buffer, logging.FormatJSON, "info")
+	suite.a.AssertNoErr(err)
+
+	ctx := logging.WithRequestID(context.Background(), "report-me-42")
+	suite.a.Assert(logging.RequestID(ctx), "report-me-42")
+
+	logger.InfoContext(ctx, "Request failed", slog.Int("status", 400))
+
+	var line map[string]any
+	err = json.Unmarshal(buffer.Bytes(), &line)
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(line["msg"], "Request failed")
+	suite.a.Assert(line[logging.RequestIDKey], "report-me-42")
+
+	// Test if the lines below the level are not written
+	buffer.Reset()
+	logger.DebugContext(ctx, "Request handled")
+	suite.a.Assert(buffer.Len(), 0)
+
+	// Test if the request ID is kept with added attributes in text lines
+	logger, err = logging.NewLogger(&buffer, logging.FormatText, "debug")
+	suite.a.AssertNoErr(err)
+
+	logger.With(slog.String("component", "test")).DebugContext(ctx, "Request handled")
+	suite.a.Assert(strings.Contains(buffer.String(), "request_id=report-me-42"), true)
+	suite.a.Assert(strings.Contains(buffer.String(), "component=test"), true)
+
+	// Test if there's no request ID without one in the context
+	buffer.Reset()
+	logger.Info("Listening")
+	suite.a.Assert(strings.Contains(buffer.String(), logging.RequestIDKey), false)
+}
+
+func (suite loggingTestSuite) TestRequestIDs() {
+	// Test if the generated request IDs are valid and unique
+	first := logging.NewRequestID()
+	suite.a.Assert(logging.IsValidRequestID(first), true)
+	suite.a.Assert(first != logging.NewRequestID(), true)
+
+	// Test the request IDs given by clients
+	suite.a.Assert(logging.IsValidRequestID("abc-123_x.y:z"), true)
+	suite.a.Assert(logging.IsValidRequestID(""), false)
+	suite.a.Assert(logging.IsValidRequestID("has space"), false)
+	suite.a.Assert(logging.IsValidRequestID("line\nbreak"), false)
+	suite.a.Assert(logging.IsValidRequestID(strings.Repeat("a", 129)), false)
+}
+
+// Test suite structure.
+type loggingTestSuite struct {
+	t *testing.T
+	a helper.Adapter
+}
+
+func TestLoggingSuite(t *testing.T) {
+	// Enable parallelism
+	t.Parallel()
+
+	// Initialize the helper's adapter
+	assertHelper := helper.NewAdapter(t)
+
+	// Initialize the test suite
+	suite := loggingTestSuite{t: t, a: assertHelper}
+
+	// Call the tests
+	suite.TestParseLevel()
+	suite.TestNewLogger()
+	suite.TestRequestIDs()
+}
@@ -0,0 +1,150 @@
+//    reddlinks, a simple link shortener written in Go.
+//    Copyright (C) 2025 redd
+//
+//    This program is free software: you can redistribute it and/or modify
+//    it under the terms of the GNU General Public License as published by
+//    the Free Software Foundation, either version 3 of the License, or
+//    (at your option) any later version.
+//
+//    This program is distributed in the hope that it will be useful,
+//    but WITHOUT ANY WARRANTY; without even the implied warranty of
+//    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//    GNU General Public License for more details.
+//
+//    You should have received a copy of the GNU General Public License
+//    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package metrics_test
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/redds-be/reddlinks/internal/database"
+	"github.com/redds-be/reddlinks/internal/metrics"
+	"github.com/redds-be/reddlinks/test/helper"
+)
+
+// errSweep is a mock error returned by a garbage collection sweep.
+var errSweep = errors.New("sweep failed")
+
+func (suite metricsTestSuite) TestNilMetrics() {
+	// Test if recording on disabled metrics does nothing
+	var disabled *metrics.Metrics
+
+	disabled.ObserveRequest("/", "GET", 200, time.Millisecond)
+	disabled.Redirect()
+	disabled.LinkCreated(true, false)
+	disabled.PasswordFailure()
+	disabled.ObserveGC(time.Millisecond, 3, nil)
+}
+
+func (suite metricsTestSuite) TestWrite() { //nolint:funlen
+	collected := metrics.New()
+
+	// Record some metrics
+	collected.ObserveRequest("/{short}", "GET", 303, 20*time.Millisecond)
+	collected.ObserveRequest("/{short}", "GET", 303, 3*time.Second)
+	collected.ObserveRequest("/{short}", "GET", 404, time.Millisecond)
+	collected.ObserveRequest(`/"quoted"`, "POST", 201, time.Millisecond)
+	collected.ObserveRequest("unmatched", "MADEUP", 405, time.Millisecond)
+	collected.ObserveRequest("unmatched", "INVENTED", 405, time.Millisecond)
+	collected.Redirect()
+	collected.Redirect()
+	collected.LinkCreated(true, false)
+	collected.LinkCreated(false, true)
+	collected.LinkCreated(false, true)
+	collected.PasswordFailure()
+	collected.ObserveGC(time.Millisecond, 3, nil)
+	collected.ObserveGC(time.Millisecond, 5, errSweep)
+
+	var buffer bytes.Buffer
+	err := collected.Write(&buffer)
+	suite.a.AssertNoErr(err)
+
+	output := buffer.String()
+
+	// Test if the expected samples are written
+	for _, expected := range []string{
+		"# TYPE reddlinks_http_requests_total counter\n",
+		`reddlinks_http_requests_total{route="/{short}",method="GET",code="303"} 2` + "\n",
+		`reddlinks_http_requests_total{route="/{short}",method="GET",code="404"} 1` + "\n",
+		`reddlinks_http_requests_total{route="/\"quoted\"",method="POST",code="201"} 1` + "\n",
+		`reddlinks_http_requests_total{route="unmatched",method="OTHER",code="405"} 2` + "\n",
+		"# TYPE reddlinks_http_request_duration_seconds histogram\n",
+		`reddlinks_http_request_duration_seconds_bucket{route="/{short}",method="GET",le="0.005"} 1` + "\n",
+		`reddlinks_http_request_duration_seconds_bucket{route="/{short}",method="GET",le="0.025"} 2` + "\n",
+		`reddlinks_http_request_duration_seconds_bucket{route="/{short}",method="GET",le="2.5"} 2` + "\n",
+		`reddlinks_http_request_duration_seconds_bucket{route="/{short}",method="GET",le="5"} 3` + "\n",
+		`reddlinks_http_request_duration_seconds_bucket{route="/{short}",method="GET",le="+Inf"} 3` + "\n",
+		`reddlinks_http_request_duration_seconds_count{route="/{short}",method="GET"} 3` + "\n",
+		"reddlinks_redirects_total 2\n",
+		`reddlinks_links_created_total{password="false",custom="false"} 0` + "\n",
+		`reddlinks_links_created_total{password="false",custom="true"} 2` + "\n",
+		`reddlinks_links_created_total{password="true",custom="false"} 1` + "\n",
+		"reddlinks_password_failures_total 1\n",
+		`reddlinks_gc_duration_seconds_bucket{le="+Inf"} 2` + "\n",
+		"reddlinks_gc_duration_seconds_count 2\n",
+		"reddlinks_gc_deleted_links_total 3\n",
+		"reddlinks_gc_failures_total 1\n",
+	} {
+		suite.a.Assert(strings.Contains(output, expected), true)
+	}
+
+	// Test if the methods made up by the clients don't get their own series
+	suite.a.Assert(strings.Contains(output, "MADEUP"), false)
+
+	// Test if the pool and the cache statistics are only written when available
+	suite.a.Assert(strings.Contains(output, "reddlinks_db_open_connections"), false)
+	suite.a.Assert(strings.Contains(output, "reddlinks_cache_hits_total"), false)
+
+	collected.DBStats = func() sql.DBStats {
+		return sql.DBStats{MaxOpenConnections: 10, OpenConnections: 2, InUse: 1, Idle: 1, WaitDuration: time.Second}
+	}
+	collected.CacheStats = func() database.CacheStats {
+		return database.CacheStats{Hits: 7, Misses: 3, Size: 2, Capacity: 100}
+	}
+
+	buffer.Reset()
+	err = collected.Write(&buffer)
+	suite.a.AssertNoErr(err)
+
+	output = buffer.String()
+
+	for _, expected := range []string{
+		"reddlinks_db_max_open_connections 10\n",
+		"reddlinks_db_open_connections 2\n",
+		"reddlinks_db_in_use_connections 1\n",
+		"reddlinks_db_wait_duration_seconds_total 1\n",
+		"reddlinks_cache_hits_total 7\n",
+		"reddlinks_cache_misses_total 3\n",
+		"reddlinks_cache_capacity 100\n",
+	} {
+		suite.a.Assert(strings.Contains(output, expected), true)
+	}
+}
+
+// Test suite structure.
+type metricsTestSuite struct {
+	t *testing.T
+	a helper.Adapter
+}
+
+func TestMetricsSuite(t *testing.T) {
+	// Enable parallelism
+	t.Parallel()
+
+	// Initialize the helper's adapter
+	assertHelper := helper.NewAdapter(t)
+
+	// Initialize the test suite
+	suite := metricsTestSuite{t: t, a: assertHelper}
+
+	// Call the tests
+	suite.TestNilMetrics()
+	suite.TestWrite()
+}
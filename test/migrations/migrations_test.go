@@ -0,0 +1,169 @@
+//    reddlinks, a simple link shortener written in Go.
+//    Copyright (C) 2025 redd
+//
+//    This program is free software: you can redistribute it and/or modify
+//    it under the terms of the GNU General Public License as published by
+//    the Free Software Foundation, either version 3 of the License, or
+//    (at your option) any later version.
+//
+//    This program is distributed in the hope that it will be useful,
+//    but WITHOUT ANY WARRANTY; without even the implied warranty of
+//    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//    GNU General Public License for more details.
+//
+//    You should have received a copy of the GNU General Public License
+//    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package migrations_test
+
+import (
+	"database/sql"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/redds-be/reddlinks/internal/database"
+	"github.com/redds-be/reddlinks/internal/migrations"
+	"github.com/redds-be/reddlinks/test/helper"
+)
+
+// openTestDB connects to a fresh SQLite database.
+func (suite migrationsTestSuite) openTestDB(name string) *sql.DB {
+	suite.t.Helper()
+
+	// If the test db already exists, delete it as it will cause errors
+	if _, err := os.Stat(name); !errors.Is(err, os.ErrNotExist) {
+		err = os.Remove(name)
+		suite.a.AssertNoErrf(err)
+	}
+
+	dataBase, err := database.DBConnect("sqlite", name, "", "", "", "", "")
+	suite.a.AssertNoErrf(err)
+
+	return dataBase
+}
+
+func (suite migrationsTestSuite) TestLoad() {
+	// Test that the migrations are ordered and rendered
+	loaded, err := migrations.Load("sqlite", migrations.Params{MaxShortLength: 42})
+	suite.a.AssertNoErrf(err)
+	suite.a.AssertNotEmptyf(len(loaded), 0)
+
+	for index, migration := range loaded {
+		suite.a.Assert(migration.Version, index+1)
+	}
+
+	suite.a.Assert(strings.Contains(loaded[0].SQL, "varchar(42)"), true)
+
+	// Test that both dialects have the same versions
+	latestSQLite, err := migrations.Latest("sqlite")
+	suite.a.AssertNoErr(err)
+	latestPostgres, err := migrations.Latest("postgres")
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(latestSQLite, latestPostgres)
+	latestMySQL, err := migrations.Latest("mysql")
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(latestSQLite, latestMySQL)
+
+	// Test with a dialect without migrations
+	_, err = migrations.Load("legitdriver", migrations.Params{})
+	suite.a.AssertErrIs(err, migrations.ErrUnsupportedDialect)
+}
+
+func (suite migrationsTestSuite) TestRebind() {
+	query := "UPDATE links SET url = $1 WHERE short = $2;"
+
+	// Test that the numbered placeholders are kept for the dialects supporting them
+	suite.a.Assert(migrations.Rebind("postgres", query), query)
+	suite.a.Assert(migrations.Rebind("sqlite", query), query)
+
+	// Test that they are converted for MySQL
+	suite.a.Assert(migrations.Rebind("mysql", query), "UPDATE links SET url = ? WHERE short = ?;")
+}
+
+func (suite migrationsTestSuite) TestUp() {
+	dataBase := suite.openTestDB("migrations_up_test.db")
+
+	latest, err := migrations.Latest("sqlite")
+	suite.a.AssertNoErrf(err)
+
+	// Test the migration of an empty database
+	applied, err := migrations.Up(dataBase, "sqlite", migrations.Params{MaxShortLength: 12})
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(applied, latest)
+
+	version, err := migrations.CurrentVersion(dataBase)
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(version, latest)
+
+	// Test that nothing is applied twice
+	applied, err = migrations.Up(dataBase, "sqlite", migrations.Params{MaxShortLength: 12})
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(applied, 0)
+
+	// Test that a newer schema is refused
+	_, err = dataBase.Exec(
+		"INSERT INTO schema_migrations (version, name, applied_at) VALUES ($1, 'future', CURRENT_TIMESTAMP);",
+		latest+1,
+	)
+	suite.a.AssertNoErrf(err)
+
+	_, err = migrations.Up(dataBase, "sqlite", migrations.Params{MaxShortLength: 12})
+	suite.a.AssertErrIs(err, migrations.ErrSchemaTooNew)
+}
+
+func (suite migrationsTestSuite) TestBaseline() {
+	dataBase := suite.openTestDB("migrations_baseline_test.db")
+
+	// Create a links table like the ones created before the migrations
+	_, err := dataBase.Exec("CREATE TABLE links (" +
+		"id UUID PRIMARY KEY, " +
+		"created_at TIMESTAMP NOT NULL, " +
+		"expire_at TIMESTAMP NOT NULL, " +
+		"url TEXT NOT NULL, " +
+		"short varchar(12) UNIQUE NOT NULL, " +
+		"password varchar(97));")
+	suite.a.AssertNoErrf(err)
+
+	_, err = dataBase.Exec("INSERT INTO links (id, created_at, expire_at, url, short, password) " +
+		"VALUES ('00000000-0000-0000-0000-000000000000', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP, " +
+		"'http://example.com', 'legacy', '');")
+	suite.a.AssertNoErrf(err)
+
+	latest, err := migrations.Latest("sqlite")
+	suite.a.AssertNoErrf(err)
+
+	// Test that only the migrations after the detected version are applied
+	applied, err := migrations.Up(dataBase, "sqlite", migrations.Params{MaxShortLength: 12})
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(applied, latest-1)
+
+	// Test that the data is still there and that the new column can be used
+	token, err := database.NewSQLStore(dataBase, "sqlite").GetTokenHashByShort("legacy")
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(token, "")
+}
+
+// Test suite structure.
+type migrationsTestSuite struct {
+	t *testing.T
+	a helper.Adapter
+}
+
+func TestMigrationsSuite(t *testing.T) {
+	// Enable parallelism
+	t.Parallel()
+
+	// Initialize the helper's adapter
+	assertHelper := helper.NewAdapter(t)
+
+	// Initialize the test suite
+	suite := migrationsTestSuite{t: t, a: assertHelper}
+
+	// Call the tests
+	suite.TestLoad()
+	suite.TestRebind()
+	suite.TestUp()
+	suite.TestBaseline()
+}
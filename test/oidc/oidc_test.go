@@ -0,0 +1,289 @@
+//    reddlinks, a simple link shortener written in Go.
+//    Copyright (C) 2025 redd
+//
+//    This program is free software: you can redistribute it and/or modify
+//    it under the terms of the GNU General Public License as published by
+//    the Free Software Foundation, either version 3 of the License, or
+//    (at your option) any later version.
+//
+//    This program is distributed in the hope that it will be useful,
+//    but WITHOUT ANY WARRANTY; without even the implied warranty of
+//    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//    GNU General Public License for more details.
+//
+//    You should have received a copy of the GNU General Public License
+//    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package oidc_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/redds-be/reddlinks/internal/oidc"
+	"github.com/redds-be/reddlinks/test/helper"
+)
+
+// redirectURL is the callback of the tested client, the mock provider never calls it.
+const redirectURL = "http://127.0.0.1:8080/account/oidc/callback"
+
+// newProvider discovers the mock provider.
+func (suite oidcTestSuite) newProvider(mock *helper.MockOIDC, rolesClaim string) *oidc.Provider {
+	provider, err := oidc.New(context.Background(), oidc.Settings{
+		Issuer:        mock.Issuer(),
+		ClientID:      mock.ClientID,
+		ClientSecret:  mock.Secret,
+		RedirectURL:   redirectURL,
+		Scopes:        []string{"openid", "profile"},
+		UsernameClaim: "preferred_username",
+		RolesClaim:    rolesClaim,
+	})
+	suite.a.AssertNoErrf(err)
+
+	return provider
+}
+
+// authorize sends a flow to the authorization endpoint and returns the code and the state given back.
+func (suite oidcTestSuite) authorize(provider *oidc.Provider, flow oidc.Flow) (string, string) {
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, provider.AuthCodeURL(flow), nil)
+	suite.a.AssertNoErrf(err)
+
+	resp, err := client.Do(req)
+	suite.a.AssertNoErrf(err)
+	resp.Body.Close()
+	suite.a.Assertf(resp.StatusCode, http.StatusFound)
+
+	location, err := url.Parse(resp.Header.Get("Location"))
+	suite.a.AssertNoErrf(err)
+	suite.a.Assert(strings.HasPrefix(location.String(), redirectURL), true)
+
+	return location.Query().Get("code"), location.Query().Get("state")
+}
+
+func (suite oidcTestSuite) TestDiscovery() {
+	mock := helper.NewMockOIDC(suite.t, "reddlinks", "secret")
+
+	// Test if the endpoints are discovered
+	provider := suite.newProvider(mock, "roles")
+
+	authURL, err := url.Parse(provider.AuthCodeURL(oidc.Flow{State: "state", Nonce: "nonce", Verifier: "verifier"}))
+	suite.a.AssertNoErrf(err)
+	suite.a.Assert(authURL.Path, "/authorize")
+	suite.a.Assert(authURL.Query().Get("scope"), "openid profile")
+	suite.a.Assert(authURL.Query().Get("redirect_uri"), redirectURL)
+	suite.a.Assert(authURL.Query().Get("code_challenge_method"), "S256")
+	// The challenge is the base64url encoded SHA-256 hash of the verifier
+	suite.a.Assert(authURL.Query().Get("code_challenge"), "iMnq5o6zALKXGivsnlom_0F5_WYda32GHkxlV7mq7hQ")
+
+	// Test with an issuer which doesn't match the discovery document
+	_, err = oidc.New(context.Background(), oidc.Settings{Issuer: mock.Issuer() + "/", ClientID: "reddlinks"})
+	suite.a.AssertErrIs(err, oidc.ErrDiscovery)
+
+	// Test with an issuer without discovery document
+	_, err = oidc.New(context.Background(), oidc.Settings{Issuer: mock.Issuer() + "/missing", ClientID: "reddlinks"})
+	suite.a.AssertErrIs(err, oidc.ErrDiscovery)
+}
+
+func (suite oidcTestSuite) TestFlow() {
+	flow, err := oidc.NewFlow("/admin")
+	suite.a.AssertNoErrf(err)
+	suite.a.AssertNotEmpty(flow.State, "")
+	suite.a.AssertNotEmpty(flow.Nonce, "")
+	suite.a.AssertNotEmpty(flow.Verifier, "")
+
+	// Test if a flow survives its encoding
+	decoded, err := oidc.DecodeFlow(flow.Encode())
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(decoded, flow)
+
+	// Test the state checks
+	suite.a.Assert(flow.CheckState(flow.State), true)
+	suite.a.Assert(flow.CheckState("other"), false)
+	suite.a.Assert(flow.CheckState(""), false)
+
+	// Test with values that aren't flows
+	_, err = oidc.DecodeFlow("not a flow")
+	suite.a.AssertErrIs(err, oidc.ErrInvalidFlow)
+
+	_, err = oidc.DecodeFlow(oidc.Flow{State: "state"}.Encode())
+	suite.a.AssertErrIs(err, oidc.ErrInvalidFlow)
+}
+
+func (suite oidcTestSuite) TestExchange() {
+	mock := helper.NewMockOIDC(suite.t, "reddlinks", "secret")
+	mock.SetUser("user-1", "Jane", []string{"reddlinks-admin", "staff"})
+
+	provider := suite.newProvider(mock, "roles")
+
+	// Test a whole login
+	flow, err := oidc.NewFlow("/account")
+	suite.a.AssertNoErrf(err)
+
+	code, state := suite.authorize(provider, flow)
+	suite.a.Assert(flow.CheckState(state), true)
+
+	claims, err := provider.Exchange(context.Background(), code, flow)
+	suite.a.AssertNoErrf(err)
+	suite.a.Assert(claims.Subject, "user-1")
+	suite.a.Assert(claims.Username, "Jane")
+	suite.a.Assertf(len(claims.Roles), 2)
+	suite.a.Assert(claims.Roles[0], "reddlinks-admin")
+
+	// Test if a code can only be used once
+	_, err = provider.Exchange(context.Background(), code, flow)
+	suite.a.AssertErrIs(err, oidc.ErrExchange)
+
+	// Test if the code can't be exchanged without the verifier of the flow
+	code, _ = suite.authorize(provider, flow)
+	other, err := oidc.NewFlow("/account")
+	suite.a.AssertNoErrf(err)
+
+	_, err = provider.Exchange(context.Background(), code, other)
+	suite.a.AssertErrIs(err, oidc.ErrExchange)
+
+	// Test if the ID token must carry the nonce of the flow
+	code, _ = suite.authorize(provider, other)
+
+	_, err = provider.Exchange(context.Background(), code, oidc.Flow{State: other.State, Nonce: "other", Verifier: other.Verifier})
+	suite.a.AssertErrIs(err, oidc.ErrInvalidToken)
+
+	// Test with a wrong client secret
+	wrongSecret, err := oidc.New(context.Background(), oidc.Settings{
+		Issuer: mock.Issuer(), ClientID: "reddlinks", ClientSecret: "wrong", RedirectURL: redirectURL, Scopes: []string{"openid"},
+	})
+	suite.a.AssertNoErrf(err)
+
+	code, _ = suite.authorize(wrongSecret, flow)
+
+	_, err = wrongSecret.Exchange(context.Background(), code, flow)
+	suite.a.AssertErrIs(err, oidc.ErrExchange)
+
+	// Test if an ID token changed by the provider is refused
+	mock.SetModify(func(_, claims map[string]any) { claims["aud"] = "other-client" })
+	code, _ = suite.authorize(provider, flow)
+
+	_, err = provider.Exchange(context.Background(), code, flow)
+	suite.a.AssertErrIs(err, oidc.ErrInvalidToken)
+}
+
+func (suite oidcTestSuite) TestVerify() { //nolint:funlen
+	mock := helper.NewMockOIDC(suite.t, "reddlinks", "")
+	mock.SetUser("user-2", "john", nil)
+
+	provider := suite.newProvider(mock, "realm_access.roles")
+	ctx := context.Background()
+
+	// Test a valid token signed using RS256, with nested roles
+	header, claims := mock.IDToken("nonce")
+	claims["realm_access"] = map[string]any{"roles": []string{"creator"}}
+
+	verified, err := provider.Verify(ctx, mock.Sign(header, claims), "nonce")
+	suite.a.AssertNoErrf(err)
+	suite.a.Assert(verified.Subject, "user-2")
+	suite.a.Assertf(len(verified.Roles), 1)
+	suite.a.Assert(verified.Roles[0], "creator")
+
+	// Test a valid token signed using ES256, with several audiences
+	header, claims = mock.IDToken("nonce")
+	header["alg"], header["kid"] = "ES256", helper.MockECKeyID
+	claims["aud"] = []string{"other", "reddlinks"}
+	claims["azp"] = "reddlinks"
+
+	_, err = provider.Verify(ctx, mock.Sign(header, claims), "nonce")
+	suite.a.AssertNoErr(err)
+
+	// Test if a token issued to another party is refused
+	claims["azp"] = "other"
+
+	_, err = provider.Verify(ctx, mock.Sign(header, claims), "nonce")
+	suite.a.AssertErrIs(err, oidc.ErrInvalidToken)
+
+	// Test if the claims are checked
+	for name, change := range map[string]func(map[string]any){
+		"issuer":   func(claims map[string]any) { claims["iss"] = "https://evil.example.com" },
+		"subject":  func(claims map[string]any) { delete(claims, "sub") },
+		"audience": func(claims map[string]any) { claims["aud"] = "other" },
+		"expired":  func(claims map[string]any) { claims["exp"] = time.Now().Add(-10 * time.Minute).Unix() },
+		"future":   func(claims map[string]any) { claims["iat"] = time.Now().Add(10 * time.Minute).Unix() },
+		"nonce":    func(claims map[string]any) { claims["nonce"] = "other" },
+	} {
+		header, claims = mock.IDToken("nonce")
+		change(claims)
+
+		_, err = provider.Verify(ctx, mock.Sign(header, claims), "nonce")
+		if !errors.Is(err, oidc.ErrInvalidToken) {
+			suite.t.Errorf("The %s check failed: %v", name, err)
+		}
+	}
+
+	// Test if the tokens that aren't signed are refused
+	header, claims = mock.IDToken("nonce")
+	header["alg"] = "none"
+	token := mock.Sign(header, claims)
+
+	_, err = provider.Verify(ctx, token[:strings.LastIndex(token, ".")+1], "nonce")
+	suite.a.AssertErrIs(err, oidc.ErrUnsupportedAlgorithm)
+
+	// Test if the algorithm must match the key
+	header["alg"] = "ES256"
+
+	_, err = provider.Verify(ctx, mock.Sign(header, claims), "nonce")
+	suite.a.AssertErrIs(err, oidc.ErrInvalidSignature)
+
+	// Test if a tampered token is refused
+	header, claims = mock.IDToken("nonce")
+	token = mock.Sign(header, claims)
+	claims["sub"] = "admin"
+	parts := strings.Split(mock.Sign(header, claims), ".")
+
+	_, err = provider.Verify(ctx, parts[0]+"."+parts[1]+"."+strings.Split(token, ".")[2], "nonce")
+	suite.a.AssertErrIs(err, oidc.ErrInvalidSignature)
+
+	// Test with unknown and ignored keys
+	header["kid"] = "missing"
+
+	_, err = provider.Verify(ctx, mock.Sign(header, claims), "nonce")
+	suite.a.AssertErrIs(err, oidc.ErrUnknownKey)
+
+	header["kid"] = "enc"
+
+	_, err = provider.Verify(ctx, mock.Sign(header, claims), "nonce")
+	suite.a.AssertErrIs(err, oidc.ErrUnknownKey)
+
+	// Test with tokens that aren't JWTs
+	_, err = provider.Verify(ctx, "not.a.jwt", "nonce")
+	suite.a.AssertErrIs(err, oidc.ErrInvalidToken)
+
+	_, err = provider.Verify(ctx, "opaque", "nonce")
+	suite.a.AssertErrIs(err, oidc.ErrInvalidToken)
+}
+
+// Test suite structure.
+type oidcTestSuite struct {
+	t *testing.T
+	a helper.Adapter
+}
+
+func TestOIDCSuite(t *testing.T) {
+	// Enable parallelism
+	t.Parallel()
+
+	// Initialize the helper's adapter
+	assertHelper := helper.NewAdapter(t)
+
+	// Initialize the test suite
+	suite := oidcTestSuite{t: t, a: assertHelper}
+
+	// Call the tests
+	suite.TestDiscovery()
+	suite.TestFlow()
+	suite.TestExchange()
+	suite.TestVerify()
+}
@@ -0,0 +1,162 @@
+//    reddlinks, a simple link shortener written in Go.
+//    Copyright (C) 2025 redd
+//
+//    This program is free software: you can redistribute it and/or modify
+//    it under the terms of the GNU General Public License as published by
+//    the Free Software Foundation, either version 3 of the License, or
+//    (at your option) any later version.
+//
+//    This program is distributed in the hope that it will be useful,
+//    but WITHOUT ANY WARRANTY; without even the implied warranty of
+//    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//    GNU General Public License for more details.
+//
+//    You should have received a copy of the GNU General Public License
+//    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package proxy_test
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/redds-be/reddlinks/internal/proxy"
+	"github.com/redds-be/reddlinks/test/helper"
+)
+
+// newRequest returns a request coming from an address with the given headers.
+func newRequest(remoteAddr string, headers map[string]string) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = remoteAddr
+
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	return req
+}
+
+func (suite proxyTestSuite) TestParseTrusted() {
+	// Test if the networks and the single addresses are parsed
+	trusted, err := proxy.ParseTrusted(" 10.0.0.0/8,, 192.0.2.7 ,2001:db8::/32,10.1.2.3/16")
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(len(trusted), 4)
+	suite.a.Assert(trusted[0].String(), "10.0.0.0/8")
+	suite.a.Assert(trusted[1].String(), "192.0.2.7/32")
+	suite.a.Assert(trusted[2].String(), "2001:db8::/32")
+	suite.a.Assert(trusted[3].String(), "10.1.0.0/16")
+
+	trusted, err = proxy.ParseTrusted("")
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(len(trusted), 0)
+
+	// Test with invalid networks
+	_, err = proxy.ParseTrusted("10.0.0.0/8,proxy.local")
+	suite.a.AssertErr(err)
+
+	_, err = proxy.ParseTrusted("10.0.0.0/33")
+	suite.a.AssertErr(err)
+}
+
+func (suite proxyTestSuite) TestResolve() { //nolint:funlen
+	trusted, err := proxy.ParseTrusted("10.0.0.0/8,2001:db8::1")
+	suite.a.AssertNoErrf(err)
+
+	// Test if the connection is used without trusted proxies
+	client := proxy.Resolve(newRequest("192.0.2.1:1234", map[string]string{
+		"X-Forwarded-For":   "198.51.100.7",
+		"X-Forwarded-Proto": "https",
+	}), nil)
+	suite.a.Assert(client, proxy.Client{IP: netip.MustParseAddr("192.0.2.1"), Scheme: "http"})
+
+	// Test if the headers sent by a client which isn't a trusted proxy are ignored
+	client = proxy.Resolve(newRequest("192.0.2.1:1234", map[string]string{"X-Forwarded-For": "198.51.100.7"}), trusted)
+	suite.a.Assert(client.IP, netip.MustParseAddr("192.0.2.1"))
+
+	// Test if the scheme of an encrypted connection is HTTPS
+	req := newRequest("192.0.2.1:1234", nil)
+	req.TLS = &tls.ConnectionState{}
+	suite.a.Assert(proxy.Resolve(req, trusted).Scheme, "https")
+
+	// Test if the client given by a trusted proxy is used
+	client = proxy.Resolve(newRequest("10.0.0.2:1234", map[string]string{
+		"X-Forwarded-For":   "198.51.100.7",
+		"X-Forwarded-Proto": "https",
+	}), trusted)
+	suite.a.Assert(client, proxy.Client{IP: netip.MustParseAddr("198.51.100.7"), Scheme: "https"})
+
+	// Test if the addresses forged by the client are ignored, the first untrusted address from the nearest hop being the client
+	client = proxy.Resolve(newRequest("10.0.0.2:1234", map[string]string{
+		"X-Forwarded-For":   "203.0.113.9, 198.51.100.7, 10.0.0.3",
+		"X-Forwarded-Proto": "https",
+	}), trusted)
+	suite.a.Assert(client, proxy.Client{IP: netip.MustParseAddr("198.51.100.7"), Scheme: "https"})
+
+	// Test if the schemes are matched with the addresses when there are as many
+	client = proxy.Resolve(newRequest("10.0.0.2:1234", map[string]string{
+		"X-Forwarded-For":   "198.51.100.7, 10.0.0.3",
+		"X-Forwarded-Proto": "https, http",
+	}), trusted)
+	suite.a.Assert(client, proxy.Client{IP: netip.MustParseAddr("198.51.100.7"), Scheme: "https"})
+
+	// Test if the standard header is preferred
+	client = proxy.Resolve(newRequest("[2001:db8::1]:1234", map[string]string{
+		"Forwarded":       `for=203.0.113.9;proto=http, for="[2001:db8:cafe::17]:4711";proto=HTTPS`,
+		"X-Forwarded-For": "198.51.100.7",
+	}), trusted)
+	suite.a.Assert(client, proxy.Client{IP: netip.MustParseAddr("2001:db8:cafe::17"), Scheme: "https"})
+
+	// Test if the hops stop at an obfuscated address
+	client = proxy.Resolve(newRequest("10.0.0.2:1234", map[string]string{"Forwarded": "for=198.51.100.7, for=_hidden"}), trusted)
+	suite.a.Assert(client.IP, netip.MustParseAddr("10.0.0.2"))
+
+	// Test if an unknown scheme is ignored
+	client = proxy.Resolve(newRequest("10.0.0.2:1234", map[string]string{
+		"X-Forwarded-For":   "198.51.100.7",
+		"X-Forwarded-Proto": "gopher",
+	}), trusted)
+	suite.a.Assert(client.Scheme, "http")
+
+	// Test with an address of the connection which can't be parsed
+	client = proxy.Resolve(newRequest("pipe", nil), trusted)
+	suite.a.Assert(client.IP.IsValid(), false)
+}
+
+func (suite proxyTestSuite) TestContext() {
+	req := newRequest("192.0.2.1:1234", map[string]string{"X-Forwarded-For": "198.51.100.7"})
+
+	// Test if the client is resolved without trusting any proxy when the context doesn't hold it
+	_, found := proxy.ClientFromContext(req.Context())
+	suite.a.Assert(found, false)
+	suite.a.Assert(proxy.ClientOf(req).IP, netip.MustParseAddr("192.0.2.1"))
+
+	// Test if the client held by the context is used
+	client := proxy.Client{IP: netip.MustParseAddr("198.51.100.7"), Scheme: "https"}
+	req = req.WithContext(proxy.WithClient(req.Context(), client))
+	suite.a.Assert(proxy.ClientOf(req), client)
+}
+
+// Test suite structure.
+type proxyTestSuite struct {
+	t *testing.T
+	a helper.Adapter
+}
+
+func TestProxySuite(t *testing.T) {
+	// Enable parallelism
+	t.Parallel()
+
+	// Initialize the helper's adapter
+	assertHelper := helper.NewAdapter(t)
+
+	// Initialize the test suite
+	suite := proxyTestSuite{t: t, a: assertHelper}
+
+	// Call the tests
+	suite.TestParseTrusted()
+	suite.TestResolve()
+	suite.TestContext()
+}
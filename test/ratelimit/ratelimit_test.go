@@ -0,0 +1,215 @@
+//    reddlinks, a simple link shortener written in Go.
+//    Copyright (C) 2025 redd
+//
+//    This program is free software: you can redistribute it and/or modify
+//    it under the terms of the GNU General Public License as published by
+//    the Free Software Foundation, either version 3 of the License, or
+//    (at your option) any later version.
+//
+//    This program is distributed in the hope that it will be useful,
+//    but WITHOUT ANY WARRANTY; without even the implied warranty of
+//    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//    GNU General Public License for more details.
+//
+//    You should have received a copy of the GNU General Public License
+//    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package ratelimit_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/redds-be/reddlinks/internal/database"
+	"github.com/redds-be/reddlinks/internal/proxy"
+	"github.com/redds-be/reddlinks/internal/ratelimit"
+	"github.com/redds-be/reddlinks/test/helper"
+)
+
+// errStore is a mock error returned by a failing store.
+var errStore = errors.New("store unavailable")
+
+// conflictingStore is a rate limit store whose buckets are always updated by another instance in between.
+type conflictingStore struct {
+	*database.MemoryStore
+}
+
+// SaveBucket always fails with a conflict.
+func (conflictingStore) SaveBucket(database.Bucket) error {
+	return database.ErrBucketConflict
+}
+
+// failingStore is a rate limit store which can't be reached.
+type failingStore struct{}
+
+// Take always fails.
+func (failingStore) Take(string, ratelimit.Limit, time.Time) (time.Duration, error) {
+	return 0, errStore
+}
+
+// testBucket tests the token bucket of a store, a request per second with a burst of two requests.
+func (suite ratelimitTestSuite) testBucket(store ratelimit.Store) {
+	limit := ratelimit.Limit{PerMinute: 60, Burst: 2}
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// Test if the burst is allowed at once
+	for range 2 {
+		wait, err := store.Take("create:192.0.2.1", limit, now)
+		suite.a.AssertNoErr(err)
+		suite.a.Assert(wait, time.Duration(0))
+	}
+
+	// Test if the next request has to wait for a token
+	wait, err := store.Take("create:192.0.2.1", limit, now)
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(wait, time.Second)
+
+	// Test if the other clients have their own bucket
+	wait, err = store.Take("create:192.0.2.2", limit, now)
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(wait, time.Duration(0))
+
+	// Test if the bucket is refilled over time
+	wait, err = store.Take("create:192.0.2.1", limit, now.Add(time.Second))
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(wait, time.Duration(0))
+
+	wait, err = store.Take("create:192.0.2.1", limit, now.Add(time.Second))
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(wait, time.Second)
+
+	// Test if the bucket isn't refilled beyond its size
+	for range 2 {
+		wait, err = store.Take("create:192.0.2.1", limit, now.Add(time.Hour))
+		suite.a.AssertNoErr(err)
+		suite.a.Assert(wait, time.Duration(0))
+	}
+
+	wait, err = store.Take("create:192.0.2.1", limit, now.Add(time.Hour))
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(wait > 0, true)
+}
+
+func (suite ratelimitTestSuite) TestMemoryStore() {
+	suite.testBucket(ratelimit.NewMemoryStore())
+
+	store := ratelimit.NewMemoryStore()
+	limit := ratelimit.Limit{PerMinute: 60, Burst: 2}
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for _, key := range []string{"password:192.0.2.1", "password:192.0.2.2"} {
+		_, err := store.Take(key, limit, now)
+		suite.a.AssertNoErr(err)
+	}
+
+	// Test if the buckets are kept until the next sweep
+	_, err := store.Take("password:192.0.2.1", limit, now.Add(30*time.Second))
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(store.Len(), 2)
+
+	// Test if the buckets which are full again are forgotten by the next sweep
+	_, err = store.Take("password:192.0.2.3", limit, now.Add(time.Minute))
+	suite.a.AssertNoErr(err)
+	suite.a.Assert(store.Len(), 1)
+}
+
+func (suite ratelimitTestSuite) TestDBStore() {
+	suite.testBucket(ratelimit.NewDBStore(database.NewMemoryStore()))
+
+	// Test if a bucket which keeps being updated by other instances gives up
+	store := ratelimit.NewDBStore(conflictingStore{database.NewMemoryStore()})
+	_, err := store.Take("create:192.0.2.1", ratelimit.Limit{PerMinute: 60, Burst: 2}, time.Now())
+	suite.a.AssertErrIs(err, database.ErrBucketConflict)
+}
+
+func (suite ratelimitTestSuite) TestLimiter() {
+	limits := map[ratelimit.Route]ratelimit.Limit{
+		ratelimit.RouteCreate:   {PerMinute: 1, Burst: 1},
+		ratelimit.RoutePassword: {PerMinute: 0, Burst: 5},
+	}
+
+	// Test if no limiter is returned when every limit is disabled, a nil limiter allows everything
+	disabled := ratelimit.New(ratelimit.NewMemoryStore(), map[ratelimit.Route]ratelimit.Limit{
+		ratelimit.RouteCreate: {},
+	})
+	suite.a.Assert(disabled == nil, true)
+	suite.a.Assert(disabled.Allow(httptest.NewRequest(http.MethodPost, "/", nil), ratelimit.RouteCreate), time.Duration(0))
+
+	limiter := ratelimit.New(ratelimit.NewMemoryStore(), limits)
+
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+
+	// Test if the client is limited on a limited route only
+	suite.a.Assert(limiter.Allow(req, ratelimit.RouteCreate), time.Duration(0))
+	suite.a.Assert(limiter.Allow(req, ratelimit.RouteCreate) > 0, true)
+	suite.a.Assert(limiter.Allow(req, ratelimit.RoutePassword), time.Duration(0))
+	suite.a.Assert(limiter.Allow(req, ratelimit.RouteBatch), time.Duration(0))
+
+	// Test if the limits are per client, the port isn't part of the client
+	other := httptest.NewRequest(http.MethodPost, "/", nil)
+	other.RemoteAddr = "192.0.2.2:1234"
+	suite.a.Assert(limiter.Allow(other, ratelimit.RouteCreate), time.Duration(0))
+
+	req.RemoteAddr = "192.0.2.1:5678"
+	suite.a.Assert(limiter.Allow(req, ratelimit.RouteCreate) > 0, true)
+
+	// Test if the requests are allowed when the store fails
+	failing := ratelimit.New(failingStore{}, limits)
+	suite.a.Assert(failing.Allow(req, ratelimit.RouteCreate), time.Duration(0))
+}
+
+func (suite ratelimitTestSuite) TestClientKey() {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+
+	// Test if the address of the connection is used without a resolved client
+	req.Header.Set("X-Forwarded-For", "198.51.100.7")
+	suite.a.Assert(ratelimit.ClientKey(req), "192.0.2.1")
+
+	// Test if the resolved client is used
+	req = req.WithContext(proxy.WithClient(req.Context(), proxy.Client{IP: netip.MustParseAddr("198.51.100.7")}))
+	suite.a.Assert(ratelimit.ClientKey(req), "198.51.100.7")
+
+	// Test if the IPv6 addresses are reduced to their network
+	req = req.WithContext(proxy.WithClient(req.Context(), proxy.Client{IP: netip.MustParseAddr("2001:db8:1:2:3:4:5:6")}))
+	suite.a.Assert(ratelimit.ClientKey(req), "2001:db8:1:2::/64")
+
+	// Test with an unknown client
+	req = req.WithContext(proxy.WithClient(req.Context(), proxy.Client{}))
+	suite.a.Assert(ratelimit.ClientKey(req), "unknown")
+}
+
+func (suite ratelimitTestSuite) TestRetryAfter() {
+	// Test if the wait is rounded up to the second
+	suite.a.Assert(ratelimit.RetryAfter(time.Second), "1")
+	suite.a.Assert(ratelimit.RetryAfter(1500*time.Millisecond), "2")
+}
+
+// Test suite structure.
+type ratelimitTestSuite struct {
+	t *testing.T
+	a helper.Adapter
+}
+
+func TestRatelimitSuite(t *testing.T) {
+	// Enable parallelism
+	t.Parallel()
+
+	// Initialize the helper's adapter
+	assertHelper := helper.NewAdapter(t)
+
+	// Initialize the test suite
+	suite := ratelimitTestSuite{t: t, a: assertHelper}
+
+	// Call the tests
+	suite.TestMemoryStore()
+	suite.TestDBStore()
+	suite.TestLimiter()
+	suite.TestClientKey()
+	suite.TestRetryAfter()
+}
@@ -21,6 +21,7 @@ import (
 	"context"
 	"embed"
 	"encoding/json"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -51,19 +52,20 @@ func (suite utilsTestSuite) TestCollectGarbage() {
 
 	err = database.CreateLinksTable(dataBase, testEnv.DBType, testEnv.DefaultMaxLength)
 	suite.a.AssertNoErr(err)
-	err = database.CreateLink(
-		dataBase,
-		uuid.New(),
-		time.Now().UTC(),
-		time.Now().UTC(),
-		"http://example.com",
-		"garbage",
-		"pass",
-	)
+	store := database.NewSQLStore(dataBase, testEnv.DBType)
+	err = store.CreateLink(database.Link{
+		ID:        uuid.New(),
+		CreatedAt: time.Now().UTC(),
+		ExpireAt:  time.Now().UTC(),
+		URL:       "http://example.com",
+		Short:     "garbage",
+		Password:  "pass",
+		Token:     "token",
+	})
 	suite.a.AssertNoErr(err)
 
 	// Test the execution of collectGarbage()
-	conf := &utils.Configuration{DB: dataBase}
+	conf := &utils.Configuration{Store: store}
 	err = conf.CollectGarbage()
 	suite.a.AssertNoErr(err)
 }
@@ -92,9 +94,117 @@ func (suite utilsTestSuite) TestDecodeJSON() {
 	suite.a.AssertNoErr(err)
 
 	// Test the decodeJSON() function and compare its return value to the expected values
-	decodedParams, err := utils.DecodeJSON(req)
+	decodedParams, err := utils.DecodeJSON(req, 0)
 	suite.a.AssertNoErr(err)
 	suite.a.Assert(decodedParams, paramsToEncode)
+
+	// Test the decoding of a payload larger than the limit
+	req = httptest.NewRequest(http.MethodPost, "/", bytes.NewBuffer(enc))
+
+	var tooLarge *http.MaxBytesError
+
+	_, err = utils.DecodeJSON(req, int64(len(enc)-1))
+	suite.a.AssertErrAs(err, &tooLarge)
+}
+
+func (suite utilsTestSuite) TestDecodeBatch() { //nolint:funlen
+	var tooLarge *http.MaxBytesError
+
+	expected := []utils.Parameters{
+		{URL: "http://example.com", Length: 6, ExpireAfter: "2d"},
+		{URL: "http://example.org", Path: "apath", Password: "pass"},
+	}
+
+	// Test the decoding of a JSON array
+	enc, err := json.Marshal(expected)
+	suite.a.AssertNoErr(err)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/batch", bytes.NewBuffer(enc))
+	req.Header.Set("Content-Type", "application/json")
+
+	batch, err := utils.DecodeBatch(req, 0)
+	suite.a.AssertNoErr(err)
+	suite.a.Assertf(len(batch), len(expected))
+
+	for index := range expected {
+		suite.a.Assert(batch[index], expected[index])
+	}
+
+	// Test the decoding of a CSV file, the columns can be in any order
+	csvBatch := "customPath,url,length,expireAfter,password\n" +
+		",http://example.com,6,2d,\n" +
+		"apath,http://example.org,,,pass\n"
+
+	req = httptest.NewRequest(http.MethodPost, "/api/batch", strings.NewReader(csvBatch))
+	req.Header.Set("Content-Type", "text/csv")
+
+	batch, err = utils.DecodeBatch(req, 0)
+	suite.a.AssertNoErr(err)
+	suite.a.Assertf(len(batch), len(expected))
+
+	for index := range expected {
+		suite.a.Assert(batch[index], expected[index])
+	}
+
+	// Test the decoding of a CSV file uploaded using a form
+	body := &bytes.Buffer{}
+	form := multipart.NewWriter(body)
+	file, err := form.CreateFormFile("file", "batch.csv")
+	suite.a.AssertNoErr(err)
+	_, err = file.Write([]byte(csvBatch))
+	suite.a.AssertNoErr(err)
+	err = form.Close()
+	suite.a.AssertNoErr(err)
+
+	req = httptest.NewRequest(http.MethodPost, "/batch", body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+
+	batch, err = utils.DecodeBatch(req, 0)
+	suite.a.AssertNoErr(err)
+	suite.a.Assertf(len(batch), len(expected))
+
+	for index := range expected {
+		suite.a.Assert(batch[index], expected[index])
+	}
+
+	// Test the decoding of a CSV file with an unknown column
+	req = httptest.NewRequest(http.MethodPost, "/api/batch", strings.NewReader("url,unknown\nhttp://example.com,a\n"))
+	req.Header.Set("Content-Type", "text/csv")
+
+	_, err = utils.DecodeBatch(req, 0)
+	suite.a.AssertErrIs(err, utils.ErrUnknownColumn)
+
+	// Test the decoding of a batch that is too large
+	req = httptest.NewRequest(
+		http.MethodPost,
+		"/api/batch",
+		strings.NewReader("url\n"+strings.Repeat("http://example.com\n", utils.MaxBatchSize+1)),
+	)
+	req.Header.Set("Content-Type", "text/csv")
+
+	_, err = utils.DecodeBatch(req, 0)
+	suite.a.AssertErrIs(err, utils.ErrBatchTooLarge)
+
+	// Test the decoding of a batch larger than the limit
+	req = httptest.NewRequest(http.MethodPost, "/api/batch", strings.NewReader(csvBatch))
+	req.Header.Set("Content-Type", "text/csv")
+
+	_, err = utils.DecodeBatch(req, 16)
+	suite.a.AssertErrAs(err, &tooLarge)
+
+	// Test the decoding of an empty batch
+	req = httptest.NewRequest(http.MethodPost, "/api/batch", strings.NewReader("[]"))
+	req.Header.Set("Content-Type", "application/json")
+
+	_, err = utils.DecodeBatch(req, 0)
+	suite.a.AssertErrIs(err, utils.ErrEmpty)
+
+	// Test the decoding of a batch in an unsupported format
+	req = httptest.NewRequest(http.MethodPost, "/api/batch", strings.NewReader("url"))
+	req.Header.Set("Content-Type", "text/plain")
+
+	_, err = utils.DecodeBatch(req, 0)
+	suite.a.AssertErrIs(err, utils.ErrUnsupportedBatch)
 }
 
 func (suite utilsTestSuite) TestGenStr() {
@@ -206,6 +316,7 @@ func TestUtilsSuite(t *testing.T) {
 	// Call the tests
 	suite.TestCollectGarbage()
 	suite.TestDecodeJSON()
+	suite.TestDecodeBatch()
 	suite.TestGenStr()
 	suite.TestIsURL()
 	suite.TestGetLocales()